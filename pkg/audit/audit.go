@@ -0,0 +1,144 @@
+// Package audit records a structured audit trail of /v1, /v1/subscribe and proxied Kubernetes requests.
+// Events are written as JSON lines to stdout or to a rotating local file.
+package audit
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// Config configures the audit log.
+type Config struct {
+	Enabled bool
+	// Path of the log file, "-" or empty for stdout.
+	Path string
+	// MaxSizeMB, MaxBackups and MaxAgeDays control the rotation of the log file.
+	MaxSizeMB  int
+	MaxBackups int
+	MaxAgeDays int
+	// PolicyFile is an optional YAML encoded Policy.
+	PolicyFile string
+	// Level is used when no PolicyFile is given.
+	Level string
+	// MaxBodyBytes limits the size of recorded request and response bodies.
+	MaxBodyBytes int
+}
+
+const defaultMaxBodyBytes = 64 << 10
+
+// Event is a single audit record.
+type Event struct {
+	Level      Level     `json:"level"`
+	AuditID    string    `json:"auditID"`
+	Timestamp  time.Time `json:"timestamp"`
+	RequestURI string    `json:"requestURI"`
+	SourceIP   string    `json:"sourceIP,omitempty"`
+	Verb       string    `json:"verb"`
+	User       string    `json:"user"`
+	Groups     []string  `json:"groups,omitempty"`
+	// Authenticator is the authenticator of the auth.Chain which authenticated the user.
+	Authenticator string `json:"authenticator,omitempty"`
+	APIGroup      string `json:"apiGroup,omitempty"`
+	Resource      string `json:"resource,omitempty"`
+	Namespace     string `json:"namespace,omitempty"`
+	Name          string `json:"name,omitempty"`
+	// ResponseCode is 101 for websocket connections, which are recorded when they are closed.
+	ResponseCode int     `json:"responseCode"`
+	LatencyMS    float64 `json:"latencyMs"`
+	// Aborted is set when the handler panicked, for example to break a streamed response after listing failed.
+	Aborted bool `json:"aborted,omitempty"`
+
+	RequestBody  json.RawMessage `json:"requestBody,omitempty"`
+	ResponseBody json.RawMessage `json:"responseBody,omitempty"`
+}
+
+func (e *Event) qualifiedResource() string {
+	if e.APIGroup == "" {
+		return e.Resource
+	}
+	return e.APIGroup + "/" + e.Resource
+}
+
+// Logger writes audit events.
+type Logger struct {
+	policy       *Policy
+	maxBodyBytes int
+
+	lock    sync.Mutex
+	encoder *json.Encoder
+	closer  io.Closer
+}
+
+// New creates a Logger from config. It returns nil if auditing is disabled.
+func New(config Config) (*Logger, error) {
+	if !config.Enabled {
+		return nil, nil
+	}
+
+	policy := &Policy{}
+	if config.PolicyFile != "" {
+		var err error
+		policy, err = LoadPolicyFile(config.PolicyFile)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		level, err := ParseLevel(config.Level)
+		if err != nil {
+			return nil, err
+		}
+		policy.Level = level
+	}
+
+	var out io.Writer = os.Stdout
+	var closer io.Closer
+	if config.Path != "" && config.Path != "-" {
+		file := &lumberjack.Logger{
+			Filename:   config.Path,
+			MaxSize:    config.MaxSizeMB,
+			MaxBackups: config.MaxBackups,
+			MaxAge:     config.MaxAgeDays,
+		}
+		out, closer = file, file
+	}
+
+	return NewWithWriter(out, closer, policy, config.MaxBodyBytes), nil
+}
+
+// NewWithWriter creates a Logger writing to out. closer may be nil.
+func NewWithWriter(out io.Writer, closer io.Closer, policy *Policy, maxBodyBytes int) *Logger {
+	if policy == nil {
+		policy = &Policy{}
+	}
+	if maxBodyBytes <= 0 {
+		maxBodyBytes = defaultMaxBodyBytes
+	}
+	return &Logger{
+		policy:       policy,
+		maxBodyBytes: maxBodyBytes,
+		encoder:      json.NewEncoder(out),
+		closer:       closer,
+	}
+}
+
+func (l *Logger) write(event *Event) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if err := l.encoder.Encode(event); err != nil {
+		logrus.Errorf("failed to write audit event: %v", err)
+	}
+}
+
+// Close closes the underlying log file, if any.
+func (l *Logger) Close() error {
+	if l.closer == nil {
+		return nil
+	}
+	return l.closer.Close()
+}
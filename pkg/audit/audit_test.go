@@ -0,0 +1,248 @@
+package audit
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/endpoints/request"
+)
+
+func serve(t *testing.T, handler http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req = req.WithContext(request.WithUser(req.Context(), &user.DefaultInfo{
+		Name:   "alice",
+		Groups: []string{"devs", "system:authenticated"},
+	}))
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, req)
+	return rw
+}
+
+func decodeEvents(t *testing.T, out *bytes.Buffer) []Event {
+	t.Helper()
+	var events []Event
+	decoder := json.NewDecoder(out)
+	for decoder.More() {
+		var event Event
+		require.NoError(t, decoder.Decode(&event))
+		events = append(events, event)
+	}
+	return events
+}
+
+func v1Router(logger *Logger, handler http.Handler) http.Handler {
+	m := mux.NewRouter()
+	m.Path("/v1/{type}").Handler(logger.WrapV1(handler))
+	m.Path("/v1/{type}/{nameorns}").Handler(logger.WrapV1(handler))
+	m.Path("/v1/{type}/{namespace}/{name}").Handler(logger.WrapV1(handler))
+	return m
+}
+
+func TestWrapV1(t *testing.T) {
+	out := &bytes.Buffer{}
+	logger := NewWithWriter(out, nil, &Policy{Level: LevelRequestResponse}, 0)
+	handler := v1Router(logger, http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = io.ReadAll(req.Body)
+		rw.WriteHeader(http.StatusCreated)
+		_, _ = rw.Write([]byte(`{"id":"default/cm","data":{"key":"value"}}`))
+	}))
+
+	rw := serve(t, handler, http.MethodPost, "/v1/configmaps/default/cm", `{"data":{"key":"value"}}`)
+	assert.NotEmpty(t, rw.Header().Get(HeaderAuditID))
+
+	events := decodeEvents(t, out)
+	require.Len(t, events, 1)
+	event := events[0]
+	assert.Equal(t, LevelRequestResponse, event.Level)
+	assert.Equal(t, rw.Header().Get(HeaderAuditID), event.AuditID)
+	assert.Equal(t, "alice", event.User)
+	assert.Equal(t, []string{"devs", "system:authenticated"}, event.Groups)
+	assert.Equal(t, "create", event.Verb)
+	assert.Equal(t, "configmaps", event.Resource)
+	assert.Equal(t, "default", event.Namespace)
+	assert.Equal(t, "cm", event.Name)
+	assert.Equal(t, http.StatusCreated, event.ResponseCode)
+	assert.JSONEq(t, `{"data":{"key":"value"}}`, string(event.RequestBody))
+	assert.JSONEq(t, `{"id":"default/cm","data":{"key":"value"}}`, string(event.ResponseBody))
+}
+
+func TestWrapV1Aborted(t *testing.T) {
+	out := &bytes.Buffer{}
+	logger := NewWithWriter(out, nil, &Policy{Level: LevelMetadata}, 0)
+	handler := v1Router(logger, http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+		panic(http.ErrAbortHandler)
+	}))
+
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		serve(t, handler, http.MethodGet, "/v1/pods", "")
+	})
+
+	events := decodeEvents(t, out)
+	require.Len(t, events, 1)
+	assert.Equal(t, "list", events[0].Verb)
+	assert.Equal(t, http.StatusOK, events[0].ResponseCode)
+	assert.True(t, events[0].Aborted)
+}
+
+func TestWrapV1ObjectRef(t *testing.T) {
+	out := &bytes.Buffer{}
+	logger := NewWithWriter(out, nil, nil, 0)
+	handler := v1Router(logger, http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		// /v1/pods/default is a namespaced list, which only the schema lookup can tell
+		SetObjectRef(req.Context(), "pod", "default", "")
+	}))
+
+	serve(t, handler, http.MethodGet, "/v1/pods/default", "")
+
+	events := decodeEvents(t, out)
+	require.Len(t, events, 1)
+	assert.Equal(t, LevelMetadata, events[0].Level)
+	assert.Equal(t, "list", events[0].Verb)
+	assert.Equal(t, "pod", events[0].Resource)
+	assert.Equal(t, "default", events[0].Namespace)
+	assert.Empty(t, events[0].RequestBody)
+}
+
+func TestWrapProxySecretsAreRedacted(t *testing.T) {
+	out := &bytes.Buffer{}
+	logger := NewWithWriter(out, nil, &Policy{Level: LevelRequestResponse}, 0)
+	handler := logger.WrapProxy(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = rw.Write([]byte(`{"kind":"SecretList","items":[{"metadata":{"name":"s"},"data":{"password":"aHVudGVyMg=="}}]}`))
+	}))
+
+	serve(t, handler, http.MethodGet, "/api/v1/namespaces/default/secrets", "")
+
+	events := decodeEvents(t, out)
+	require.Len(t, events, 1)
+	assert.Equal(t, "list", events[0].Verb)
+	assert.Equal(t, "secrets", events[0].Resource)
+	assert.Equal(t, "default", events[0].Namespace)
+	assert.JSONEq(t, `{"kind":"SecretList","items":[{"metadata":{"name":"s"},"data":"[redacted]"}]}`, string(events[0].ResponseBody))
+}
+
+func TestEmbeddedSecretsAreRedacted(t *testing.T) {
+	out := &bytes.Buffer{}
+	logger := NewWithWriter(out, nil, &Policy{Level: LevelRequestResponse}, 0)
+	handler := v1Router(logger, http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = io.ReadAll(req.Body)
+		_, _ = rw.Write([]byte(`{"type":"collection","data":[{"apiVersion":"v1","kind":"Secret","metadata":{"name":"s"},"stringData":{"password":"hunter2"}},{"kind":"ConfigMap","data":{"key":"value"}}]}`))
+	}))
+
+	serve(t, handler, http.MethodPost, "/v1/management.cattle.io.clusters/local?action=apply",
+		`{"yaml":"apiVersion: v1\nkind: Secret\nstringData:\n  password: hunter2\n","defaultNamespace":"default"}`)
+
+	events := decodeEvents(t, out)
+	require.Len(t, events, 1)
+	assert.JSONEq(t, `{"yaml":"[redacted]","defaultNamespace":"default"}`, string(events[0].RequestBody))
+	assert.JSONEq(t, `{"type":"collection","data":[{"apiVersion":"v1","kind":"Secret","metadata":{"name":"s"},"stringData":"[redacted]"},{"kind":"ConfigMap","data":{"key":"value"}}]}`, string(events[0].ResponseBody))
+}
+
+func TestNonJSONBodiesAreOmitted(t *testing.T) {
+	out := &bytes.Buffer{}
+	logger := NewWithWriter(out, nil, &Policy{Level: LevelRequestResponse}, 0)
+	handler := v1Router(logger, http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = rw.Write([]byte("apiVersion: v1\nkind: Secret\ndata:\n  password: aHVudGVyMg==\n"))
+	}))
+
+	serve(t, handler, http.MethodGet, "/v1/namespaces/default?link=export", "")
+
+	events := decodeEvents(t, out)
+	require.Len(t, events, 1)
+	assert.Equal(t, `"[omitted]"`, string(events[0].ResponseBody))
+}
+
+func TestGzipBodiesAreDecompressed(t *testing.T) {
+	out := &bytes.Buffer{}
+	logger := NewWithWriter(out, nil, &Policy{Level: LevelRequestResponse}, 0)
+	handler := v1Router(logger, http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(rw)
+		_, _ = gz.Write([]byte(`{"id":"default/cm","data":{"key":"value"}}`))
+		_ = gz.Close()
+	}))
+
+	serve(t, handler, http.MethodGet, "/v1/configmaps/default/cm", "")
+
+	events := decodeEvents(t, out)
+	require.Len(t, events, 1)
+	assert.JSONEq(t, `{"id":"default/cm","data":{"key":"value"}}`, string(events[0].ResponseBody))
+}
+
+func TestPolicyRules(t *testing.T) {
+	out := &bytes.Buffer{}
+	policy := &Policy{
+		Level: LevelMetadata,
+		Rules: []PolicyRule{
+			{Level: LevelNone, UserGroups: []string{"system:nodes"}},
+			{Level: LevelNone, Verbs: []string{"watch"}},
+			{Level: LevelRequest, Resources: []string{"apps/deployments"}},
+		},
+	}
+	logger := NewWithWriter(out, nil, policy, 0)
+	handler := logger.WrapProxy(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = io.ReadAll(req.Body)
+	}))
+
+	serve(t, handler, http.MethodGet, "/api/v1/pods?watch=true", "")
+	serve(t, handler, http.MethodPatch, "/apis/apps/v1/namespaces/default/deployments/web", `{"spec":{"replicas":2}}`)
+	serve(t, handler, http.MethodDelete, "/api/v1/namespaces/default/pods/web-1", "")
+
+	events := decodeEvents(t, out)
+	require.Len(t, events, 2)
+	assert.Equal(t, LevelRequest, events[0].Level)
+	assert.Equal(t, "patch", events[0].Verb)
+	assert.Equal(t, "apps", events[0].APIGroup)
+	assert.JSONEq(t, `{"spec":{"replicas":2}}`, string(events[0].RequestBody))
+	assert.Empty(t, events[0].ResponseBody)
+	assert.Equal(t, LevelMetadata, events[1].Level)
+	assert.Equal(t, "delete", events[1].Verb)
+	assert.Equal(t, "web-1", events[1].Name)
+}
+
+func TestTruncatedBody(t *testing.T) {
+	out := &bytes.Buffer{}
+	logger := NewWithWriter(out, nil, &Policy{Level: LevelRequest}, 8)
+	handler := logger.WrapProxy(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = io.ReadAll(req.Body)
+	}))
+
+	serve(t, handler, http.MethodPost, "/api/v1/namespaces/default/configmaps", `{"data":{"key":"value"}}`)
+
+	events := decodeEvents(t, out)
+	require.Len(t, events, 1)
+	assert.Equal(t, `"[truncated]"`, string(events[0].RequestBody))
+}
+
+func TestLoadPolicyFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "policy.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+level: Metadata
+rules:
+- level: RequestResponse
+  resources: ["secret", "secrets"]
+`), 0600))
+
+	policy, err := LoadPolicyFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, LevelRequestResponse, policy.LevelFor(&Event{Resource: "secret"}))
+	assert.Equal(t, LevelMetadata, policy.LevelFor(&Event{Resource: "pod"}))
+
+	require.NoError(t, os.WriteFile(path, []byte(`rules: [{level: Everything}]`), 0600))
+	_, err = LoadPolicyFile(path)
+	assert.Error(t, err)
+}
@@ -0,0 +1,57 @@
+package audit
+
+import "github.com/urfave/cli/v2"
+
+func Flags(config *Config) []cli.Flag {
+	return []cli.Flag{
+		&cli.BoolFlag{
+			Name:        "audit-log",
+			EnvVars:     []string{"AUDIT_LOG"},
+			Destination: &config.Enabled,
+		},
+		&cli.StringFlag{
+			Name:        "audit-log-path",
+			EnvVars:     []string{"AUDIT_LOG_PATH"},
+			Usage:       "File to write audit events to, - for stdout",
+			Value:       "-",
+			Destination: &config.Path,
+		},
+		&cli.IntFlag{
+			Name:        "audit-log-maxsize",
+			EnvVars:     []string{"AUDIT_LOG_MAXSIZE"},
+			Usage:       "Size in megabytes at which the audit log file is rotated",
+			Value:       100,
+			Destination: &config.MaxSizeMB,
+		},
+		&cli.IntFlag{
+			Name:        "audit-log-maxbackup",
+			EnvVars:     []string{"AUDIT_LOG_MAXBACKUP"},
+			Value:       10,
+			Destination: &config.MaxBackups,
+		},
+		&cli.IntFlag{
+			Name:        "audit-log-maxage",
+			EnvVars:     []string{"AUDIT_LOG_MAXAGE"},
+			Usage:       "Days to keep rotated audit log files",
+			Destination: &config.MaxAgeDays,
+		},
+		&cli.StringFlag{
+			Name:        "audit-policy-file",
+			EnvVars:     []string{"AUDIT_POLICY_FILE"},
+			Destination: &config.PolicyFile,
+		},
+		&cli.StringFlag{
+			Name:        "audit-level",
+			EnvVars:     []string{"AUDIT_LEVEL"},
+			Usage:       "Level used without a policy file: None, Metadata, Request or RequestResponse",
+			Value:       string(LevelMetadata),
+			Destination: &config.Level,
+		},
+		&cli.IntFlag{
+			Name:        "audit-log-max-body-bytes",
+			EnvVars:     []string{"AUDIT_LOG_MAX_BODY_BYTES"},
+			Value:       defaultMaxBodyBytes,
+			Destination: &config.MaxBodyBytes,
+		},
+	}
+}
@@ -0,0 +1,368 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/pborman/uuid"
+	"github.com/rancher/steve/pkg/auth"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apiserver/pkg/endpoints/request"
+)
+
+// HeaderAuditID is set on every audited response so that clients can correlate their requests with the audit log.
+const HeaderAuditID = "Audit-Id"
+
+var proxyRequestInfo = &request.RequestInfoFactory{
+	APIPrefixes:          sets.NewString("api", "apis"),
+	GrouplessAPIPrefixes: sets.NewString("api"),
+}
+
+type contextKey struct{}
+
+// SetObjectRef updates the resource, namespace and name recorded for the request once they are resolved.
+// It is a no-op if the request is not audited.
+func SetObjectRef(ctx context.Context, resource, namespace, name string) {
+	event, ok := ctx.Value(contextKey{}).(*Event)
+	if !ok {
+		return
+	}
+	event.Resource = resource
+	event.Namespace = namespace
+	event.Name = name
+}
+
+// WrapV1 returns a handler auditing /v1 and /v1/subscribe requests. It must run after authentication.
+func (l *Logger) WrapV1(next http.Handler) http.Handler {
+	return l.wrap(next, func(req *http.Request, event *Event) {
+		vars := mux.Vars(req)
+		event.Resource = vars["type"]
+		event.Namespace = vars["namespace"]
+		event.Name = vars["name"]
+		if event.Name == "" {
+			event.Name = vars["nameorns"]
+		}
+	}, v1Verb)
+}
+
+// WrapProxy returns a handler auditing requests proxied to the Kubernetes API. It must run after authentication.
+func (l *Logger) WrapProxy(next http.Handler) http.Handler {
+	return l.wrap(next, func(req *http.Request, event *Event) {
+		info, err := proxyRequestInfo.NewRequestInfo(req)
+		if err != nil {
+			return
+		}
+		event.Verb = info.Verb
+		event.APIGroup = info.APIGroup
+		event.Resource = info.Resource
+		if info.Subresource != "" {
+			event.Resource = info.Resource + "/" + info.Subresource
+		}
+		event.Namespace = info.Namespace
+		event.Name = info.Name
+	}, nil)
+}
+
+func (l *Logger) wrap(next http.Handler, describe func(*http.Request, *Event), verb func(*http.Request, *Event) string) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		event := &Event{
+			AuditID:    uuid.New(),
+			Timestamp:  start,
+			RequestURI: req.RequestURI,
+			SourceIP:   sourceIP(req),
+		}
+		if userInfo, ok := request.UserFrom(req.Context()); ok {
+			event.User = userInfo.GetName()
+			event.Groups = userInfo.GetGroups()
+		}
+		event.Authenticator, _ = auth.AuthenticatorFrom(req.Context())
+		describe(req, event)
+
+		maxLevel := l.policy.maxLevel()
+		if maxLevel == LevelNone {
+			next.ServeHTTP(rw, req)
+			return
+		}
+
+		var requestBody *limitedBuffer
+		if !maxLevel.Less(LevelRequest) && req.Body != nil && req.Body != http.NoBody {
+			requestBody = &limitedBuffer{limit: l.maxBodyBytes}
+			req.Body = &teeReadCloser{Reader: io.TeeReader(req.Body, requestBody), Closer: req.Body}
+		}
+
+		rw.Header().Set(HeaderAuditID, event.AuditID)
+		recorder := &responseRecorder{ResponseWriter: rw, code: http.StatusOK}
+		if !maxLevel.Less(LevelRequestResponse) && !isWebsocket(req) {
+			recorder.body = &limitedBuffer{limit: l.maxBodyBytes}
+		}
+
+		req = req.WithContext(context.WithValue(req.Context(), contextKey{}, event))
+		defer func() {
+			if r := recover(); r != nil {
+				// handlers panic with http.ErrAbortHandler to break a response they can't complete, the request is
+				// still audited before the server handles the panic
+				event.Aborted = true
+				if !recorder.wroteHeader {
+					recorder.code = http.StatusInternalServerError
+				}
+				l.finish(req, event, verb, start, recorder, requestBody)
+				panic(r)
+			}
+		}()
+		next.ServeHTTP(recorder, req)
+		l.finish(req, event, verb, start, recorder, requestBody)
+	})
+}
+
+// finish completes event once the request has been served and writes it, if the policy audits it.
+func (l *Logger) finish(req *http.Request, event *Event, verb func(*http.Request, *Event) string, start time.Time,
+	recorder *responseRecorder, requestBody *limitedBuffer) {
+	if verb != nil {
+		event.Verb = verb(req, event)
+	}
+	event.ResponseCode = recorder.code
+	event.LatencyMS = float64(time.Since(start).Microseconds()) / 1000
+
+	event.Level = l.policy.LevelFor(event)
+	if event.Level == LevelNone {
+		return
+	}
+	if !event.Level.Less(LevelRequest) && requestBody != nil {
+		event.RequestBody = l.body(event, requestBody, req.Header.Get("Content-Encoding"))
+	}
+	if !event.Level.Less(LevelRequestResponse) && recorder.body != nil {
+		event.ResponseBody = l.body(event, recorder.body, recorder.Header().Get("Content-Encoding"))
+	}
+	l.write(event)
+}
+
+func v1Verb(req *http.Request, event *Event) string {
+	if event.Resource == "subscribe" || isWebsocket(req) {
+		return "watch"
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead:
+		if event.Name == "" {
+			return "list"
+		}
+		return "get"
+	case http.MethodPost:
+		return "create"
+	case http.MethodPut:
+		return "update"
+	case http.MethodPatch:
+		return "patch"
+	case http.MethodDelete:
+		return "delete"
+	default:
+		return strings.ToLower(req.Method)
+	}
+}
+
+func (p *Policy) maxLevel() Level {
+	highest := p.Level
+	if highest == "" {
+		highest = LevelMetadata
+	}
+	for _, rule := range p.Rules {
+		if highest.Less(rule.Level) {
+			highest = rule.Level
+		}
+	}
+	return highest
+}
+
+// body returns the captured body as JSON, with the data of secrets removed. Bodies which aren't JSON, such as YAML
+// exports or logs, can't be redacted and are omitted. A gzip encoded body is decompressed first, as the recorder sees
+// what the apiserver writers compressed.
+func (l *Logger) body(event *Event, buf *limitedBuffer, contentEncoding string) json.RawMessage {
+	if strings.EqualFold(contentEncoding, "gzip") && !buf.truncated && buf.Len() > 0 {
+		decoded, err := l.gunzip(buf)
+		if err != nil {
+			return placeholder("[omitted]")
+		}
+		buf = decoded
+	}
+	if buf.truncated {
+		return placeholder("[truncated]")
+	}
+	if buf.Len() == 0 {
+		return nil
+	}
+	var obj interface{}
+	if err := json.Unmarshal(buf.Bytes(), &obj); err != nil {
+		return placeholder("[omitted]")
+	}
+	if isSecret(event) {
+		obj = redactSecretData(obj)
+	} else {
+		obj = redactSecrets(obj)
+	}
+	raw, err := json.Marshal(obj)
+	if err != nil {
+		return nil
+	}
+	return raw
+}
+
+func (l *Logger) gunzip(buf *limitedBuffer) (*limitedBuffer, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	decoded := &limitedBuffer{limit: l.maxBodyBytes}
+	if _, err := io.Copy(decoded, reader); err != nil {
+		return nil, err
+	}
+	return decoded, nil
+}
+
+func placeholder(text string) json.RawMessage {
+	raw, _ := json.Marshal(text)
+	return raw
+}
+
+func isSecret(event *Event) bool {
+	return event.APIGroup == "" && (event.Resource == "secret" || event.Resource == "secrets")
+}
+
+// embeddedSecret matches the kind of a Secret in a JSON or YAML manifest held in a string, like the YAML of the
+// cluster apply action.
+var embeddedSecret = regexp.MustCompile(`(?m)\bkind["']?\s*:\s*["']?Secret["']?\s*(?:[,}]|$)`)
+
+// redactSecrets removes the data of the objects of kind Secret anywhere in obj, for the bodies of the requests of
+// other types which include secrets, such as exports and applies. Strings holding the manifest of a Secret are
+// redacted as a whole.
+func redactSecrets(obj interface{}) interface{} {
+	switch v := obj.(type) {
+	case map[string]interface{}:
+		if v["kind"] == "Secret" {
+			return redactSecretData(v)
+		}
+		for key, value := range v {
+			v[key] = redactSecrets(value)
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = redactSecrets(v[i])
+		}
+	case string:
+		if embeddedSecret.MatchString(v) {
+			return "[redacted]"
+		}
+	}
+	return obj
+}
+
+// redactSecretData removes the data and stringData maps of secrets anywhere in obj, which covers single
+// objects as well as Kubernetes and /v1 collections.
+func redactSecretData(obj interface{}) interface{} {
+	switch v := obj.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if _, isMap := value.(map[string]interface{}); isMap && (key == "data" || key == "stringData") {
+				v[key] = "[redacted]"
+				continue
+			}
+			v[key] = redactSecretData(value)
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = redactSecretData(v[i])
+		}
+	}
+	return obj
+}
+
+func isWebsocket(req *http.Request) bool {
+	return strings.EqualFold(req.Header.Get("Upgrade"), "websocket")
+}
+
+func sourceIP(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
+
+// limitedBuffer keeps at most limit bytes and remembers if more were written.
+type limitedBuffer struct {
+	bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.truncated {
+		return len(p), nil
+	}
+	if b.Len()+len(p) > b.limit {
+		b.truncated = true
+		b.Reset()
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
+
+type teeReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+type responseRecorder struct {
+	http.ResponseWriter
+	code        int
+	wroteHeader bool
+	body        *limitedBuffer
+}
+
+func (r *responseRecorder) WriteHeader(code int) {
+	if !r.wroteHeader {
+		r.code = code
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *responseRecorder) Write(p []byte) (int, error) {
+	r.wroteHeader = true
+	if r.body != nil {
+		r.body.Write(p)
+	}
+	return r.ResponseWriter.Write(p)
+}
+
+func (r *responseRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (r *responseRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer does not support hijacking")
+	}
+	r.code = http.StatusSwitchingProtocols
+	r.wroteHeader = true
+	return hijacker.Hijack()
+}
+
+// Unwrap allows http.ResponseController to reach the underlying writer.
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -0,0 +1,137 @@
+package audit
+
+import (
+	"fmt"
+	"os"
+	"slices"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Level defines how much of a request is recorded. The levels mirror the Kubernetes audit policy levels.
+type Level string
+
+const (
+	// LevelNone disables auditing for matching requests.
+	LevelNone Level = "None"
+	// LevelMetadata records user, verb, resource, response code and latency, but no bodies.
+	LevelMetadata Level = "Metadata"
+	// LevelRequest additionally records the request body.
+	LevelRequest Level = "Request"
+	// LevelRequestResponse additionally records the response body.
+	LevelRequestResponse Level = "RequestResponse"
+)
+
+// Less returns true if l records less than other.
+func (l Level) Less(other Level) bool {
+	return l.ordinal() < other.ordinal()
+}
+
+func (l Level) ordinal() int {
+	switch l {
+	case LevelMetadata:
+		return 1
+	case LevelRequest:
+		return 2
+	case LevelRequestResponse:
+		return 3
+	default:
+		return 0
+	}
+}
+
+// ParseLevel validates a level name. An empty string is parsed as LevelMetadata.
+func ParseLevel(s string) (Level, error) {
+	switch Level(s) {
+	case "":
+		return LevelMetadata, nil
+	case LevelNone, LevelMetadata, LevelRequest, LevelRequestResponse:
+		return Level(s), nil
+	default:
+		return "", fmt.Errorf("invalid audit level %q", s)
+	}
+}
+
+// Policy decides the level at which a request is recorded. Like the Kubernetes audit policy, the first
+// matching rule wins; requests matching no rule are recorded at Level.
+type Policy struct {
+	Level Level        `json:"level,omitempty"`
+	Rules []PolicyRule `json:"rules,omitempty"`
+}
+
+// PolicyRule matches requests by their attributes. Empty lists match everything.
+type PolicyRule struct {
+	Level      Level    `json:"level"`
+	Users      []string `json:"users,omitempty"`
+	UserGroups []string `json:"userGroups,omitempty"`
+	Verbs      []string `json:"verbs,omitempty"`
+	// Resources are matched against both the /v1 type (e.g. "secret" or "apps.deployment") and the
+	// Kubernetes resource of proxied requests (e.g. "secrets" or "apps/deployments").
+	Resources  []string `json:"resources,omitempty"`
+	Namespaces []string `json:"namespaces,omitempty"`
+}
+
+// LoadPolicyFile reads a YAML encoded Policy.
+func LoadPolicyFile(path string) (*Policy, error) {
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	policy := &Policy{}
+	if err := yaml.Unmarshal(bytes, policy); err != nil {
+		return nil, fmt.Errorf("parsing audit policy %s: %w", path, err)
+	}
+	if err := policy.validate(); err != nil {
+		return nil, fmt.Errorf("invalid audit policy %s: %w", path, err)
+	}
+	return policy, nil
+}
+
+func (p *Policy) validate() error {
+	if _, err := ParseLevel(string(p.Level)); err != nil {
+		return err
+	}
+	for i, rule := range p.Rules {
+		if rule.Level == "" {
+			return fmt.Errorf("rule %d has no level", i)
+		}
+		if _, err := ParseLevel(string(rule.Level)); err != nil {
+			return fmt.Errorf("rule %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// LevelFor returns the level at which the request described by event should be recorded.
+func (p *Policy) LevelFor(event *Event) Level {
+	for _, rule := range p.Rules {
+		if rule.matches(event) {
+			return rule.Level
+		}
+	}
+	if p.Level == "" {
+		return LevelMetadata
+	}
+	return p.Level
+}
+
+func (r *PolicyRule) matches(event *Event) bool {
+	if len(r.Users) > 0 && !slices.Contains(r.Users, event.User) {
+		return false
+	}
+	if len(r.UserGroups) > 0 && !slices.ContainsFunc(event.Groups, func(g string) bool {
+		return slices.Contains(r.UserGroups, g)
+	}) {
+		return false
+	}
+	if len(r.Verbs) > 0 && !slices.Contains(r.Verbs, event.Verb) {
+		return false
+	}
+	if len(r.Namespaces) > 0 && !slices.Contains(r.Namespaces, event.Namespace) {
+		return false
+	}
+	if len(r.Resources) > 0 && !slices.Contains(r.Resources, "*") && !slices.Contains(r.Resources, event.qualifiedResource()) {
+		return false
+	}
+	return true
+}
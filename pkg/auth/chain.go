@@ -0,0 +1,126 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/rancher/steve/pkg/metrics"
+	"github.com/sirupsen/logrus"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/endpoints/request"
+)
+
+const (
+	// AnonymousAuthenticator is reported for requests authenticated as the anonymous user of a Chain.
+	AnonymousAuthenticator = "anonymous"
+	// AnonymousUser is the default anonymous user of a Chain.
+	AnonymousUser = "system:anonymous"
+)
+
+// NamedAuthenticator is an Authenticator of a Chain. Name is reported when it authenticates a request.
+type NamedAuthenticator struct {
+	Name          string
+	Authenticator Authenticator
+}
+
+// Chain tries its authenticators in order until one authenticates the request.
+//
+// If none does and none failed, the request is made by Anonymous if set, otherwise it is unauthenticated.
+// Requests for which an authenticator returned an error are never anonymous, so invalid credentials
+// cannot be used to fall back to anonymous access.
+type Chain struct {
+	Authenticators []NamedAuthenticator
+	Anonymous      user.Info
+}
+
+// NewAnonymousUser returns the user.Info used for anonymous requests, like in kube-apiserver.
+func NewAnonymousUser() user.Info {
+	return &user.DefaultInfo{
+		Name:   AnonymousUser,
+		Groups: []string{user.AllUnauthenticated},
+	}
+}
+
+type authenticatorKey struct{}
+
+// AuthenticatorFrom returns the name of the authenticator of a Chain which authenticated the request.
+func AuthenticatorFrom(ctx context.Context) (string, bool) {
+	name, ok := ctx.Value(authenticatorKey{}).(string)
+	return name, ok
+}
+
+// Authenticate implements Authenticator.
+func (c *Chain) Authenticate(req *http.Request) (user.Info, bool, error) {
+	info, _, ok, err := c.authenticate(req)
+	return info, ok, err
+}
+
+func (c *Chain) authenticate(req *http.Request) (user.Info, string, bool, error) {
+	var errs []error
+	for _, auth := range c.Authenticators {
+		info, ok, err := auth.Authenticator.Authenticate(req)
+		if err != nil {
+			logrus.Debugf("authenticator %s failed: %v", auth.Name, err)
+			errs = append(errs, err)
+			continue
+		}
+		if ok {
+			return info, auth.Name, true, nil
+		}
+	}
+	if len(errs) > 0 {
+		return nil, "", false, utilerrors.NewAggregate(errs)
+	}
+	if c.Anonymous != nil {
+		return c.Anonymous, AnonymousAuthenticator, true, nil
+	}
+	return nil, "", false, nil
+}
+
+// Middleware is like ToMiddleware, and additionally records which authenticator authenticated the request
+// in its context, see AuthenticatorFrom, and in the authentication metrics.
+func (c *Chain) Middleware() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			info, name, ok, err := c.authenticate(req)
+			ctx := req.Context()
+			switch {
+			case err != nil:
+				metrics.IncAuthenticationAttempts("", metrics.AuthenticationError)
+			case !ok:
+				metrics.IncAuthenticationAttempts("", metrics.AuthenticationUnauthenticated)
+			default:
+				metrics.IncAuthenticationAttempts(name, metrics.AuthenticationSuccess)
+				logrus.Tracef("request %s %s authenticated by %s as %s", req.Method, req.URL.Path, name, info.GetName())
+				ctx = context.WithValue(ctx, authenticatorKey{}, name)
+			}
+			next.ServeHTTP(rw, req.WithContext(withUser(ctx, info, ok, err)))
+		})
+	}
+}
+
+// withUser sets the user of the request in ctx, replacing it with an error or unauthenticated user if
+// authentication failed.
+func withUser(ctx context.Context, info user.Info, ok bool, err error) context.Context {
+	if err != nil {
+		info = &user.DefaultInfo{
+			Name: "system:cattle:error",
+			UID:  "system:cattle:error",
+			Groups: []string{
+				"system:unauthenticated",
+				"system:cattle:error",
+			},
+		}
+		ctx = request.WithValue(ctx, CattleAuthFailed, "true")
+	} else if !ok {
+		info = &user.DefaultInfo{
+			Name: "system:unauthenticated",
+			UID:  "system:unauthenticated",
+			Groups: []string{
+				"system:unauthenticated",
+			},
+		}
+	}
+	return request.WithUser(ctx, info)
+}
@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/endpoints/request"
+)
+
+func userAuthenticator(name string) Authenticator {
+	return AuthenticatorFunc(func(req *http.Request) (user.Info, bool, error) {
+		if req.Header.Get("X-User") != name {
+			return nil, false, nil
+		}
+		return &user.DefaultInfo{Name: name}, true, nil
+	})
+}
+
+func TestChainMiddleware(t *testing.T) {
+	failing := AuthenticatorFunc(func(req *http.Request) (user.Info, bool, error) {
+		if req.Header.Get("Authorization") == "" {
+			return nil, false, nil
+		}
+		return nil, false, errors.New("invalid token")
+	})
+
+	tests := []struct {
+		name              string
+		chain             *Chain
+		header            http.Header
+		wantUser          string
+		wantAuthenticator string
+		wantFailed        bool
+	}{
+		{
+			name: "authenticators are tried in order",
+			chain: &Chain{Authenticators: []NamedAuthenticator{
+				{Name: "token", Authenticator: failing},
+				{Name: "alice", Authenticator: userAuthenticator("alice")},
+			}},
+			header:            http.Header{"X-User": {"alice"}},
+			wantUser:          "alice",
+			wantAuthenticator: "alice",
+		},
+		{
+			name: "later authenticators can accept requests earlier ones failed",
+			chain: &Chain{Authenticators: []NamedAuthenticator{
+				{Name: "token", Authenticator: failing},
+				{Name: "alice", Authenticator: userAuthenticator("alice")},
+			}},
+			header:            http.Header{"X-User": {"alice"}, "Authorization": {"Bearer x"}},
+			wantUser:          "alice",
+			wantAuthenticator: "alice",
+		},
+		{
+			name: "anonymous",
+			chain: &Chain{
+				Authenticators: []NamedAuthenticator{{Name: "alice", Authenticator: userAuthenticator("alice")}},
+				Anonymous:      NewAnonymousUser(),
+			},
+			wantUser:          AnonymousUser,
+			wantAuthenticator: AnonymousAuthenticator,
+		},
+		{
+			name: "no anonymous fallback on errors",
+			chain: &Chain{
+				Authenticators: []NamedAuthenticator{{Name: "token", Authenticator: failing}},
+				Anonymous:      NewAnonymousUser(),
+			},
+			header:     http.Header{"Authorization": {"Bearer x"}},
+			wantUser:   "system:cattle:error",
+			wantFailed: true,
+		},
+		{
+			name: "unauthenticated",
+			chain: &Chain{
+				Authenticators: []NamedAuthenticator{{Name: "alice", Authenticator: userAuthenticator("alice")}},
+			},
+			header:   http.Header{"X-User": {"bob"}},
+			wantUser: "system:unauthenticated",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var gotReq *http.Request
+			handler := test.chain.Middleware()(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				gotReq = req
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/v1/pods", nil)
+			for k, v := range test.header {
+				req.Header[k] = v
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			info, ok := request.UserFrom(gotReq.Context())
+			assert.True(t, ok)
+			assert.Equal(t, test.wantUser, info.GetName())
+			name, ok := AuthenticatorFrom(gotReq.Context())
+			assert.Equal(t, test.wantAuthenticator != "", ok)
+			assert.Equal(t, test.wantAuthenticator, name)
+			failed := gotReq.Context().Value(CattleAuthFailed) != nil
+			assert.Equal(t, test.wantFailed, failed)
+		})
+	}
+}
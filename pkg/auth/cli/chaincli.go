@@ -0,0 +1,96 @@
+package cli
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/rancher/steve/pkg/auth"
+	"github.com/urfave/cli/v2"
+)
+
+// Names of the authenticators which can be listed in --auth-chain.
+const (
+	ClientCertAuthenticator    = "client-cert"
+	JWTAuthenticator           = "jwt"
+	WebhookAuthenticator       = "webhook"
+	TokenReviewAuthenticator   = "token-review"
+	ImpersonationAuthenticator = "impersonation"
+)
+
+var defaultChainOrder = []string{
+	ClientCertAuthenticator,
+	JWTAuthenticator,
+	WebhookAuthenticator,
+	TokenReviewAuthenticator,
+	ImpersonationAuthenticator,
+}
+
+type ChainConfig struct {
+	Order                   cli.StringSlice
+	ImpersonationHeaderAuth bool
+	AnonymousAuth           bool
+}
+
+// Chain returns the chain of the enabled authenticators, in the configured order. It returns nil if no
+// authenticator is enabled and anonymous access is disabled.
+func (c *ChainConfig) Chain(enabled map[string]auth.Authenticator) (*auth.Chain, error) {
+	if c.ImpersonationHeaderAuth {
+		enabled[ImpersonationAuthenticator] = auth.AuthenticatorFunc(auth.Impersonation)
+	}
+
+	order := c.Order.Value()
+	if len(order) == 0 {
+		order = defaultChainOrder
+	}
+	for _, name := range order {
+		if !slices.Contains(defaultChainOrder, name) {
+			return nil, fmt.Errorf("unknown authenticator %q in auth chain, must be one of %v", name, defaultChainOrder)
+		}
+	}
+
+	chain := &auth.Chain{}
+	for name, authenticator := range enabled {
+		if authenticator != nil && !slices.Contains(order, name) {
+			return nil, fmt.Errorf("authenticator %q is enabled but missing from the auth chain", name)
+		}
+	}
+	for _, name := range order {
+		if authenticator := enabled[name]; authenticator != nil {
+			chain.Authenticators = append(chain.Authenticators, auth.NamedAuthenticator{
+				Name:          name,
+				Authenticator: authenticator,
+			})
+		}
+	}
+	if c.AnonymousAuth {
+		chain.Anonymous = auth.NewAnonymousUser()
+	}
+
+	if len(chain.Authenticators) == 0 && chain.Anonymous == nil {
+		return nil, nil
+	}
+	return chain, nil
+}
+
+func ChainFlags(config *ChainConfig) []cli.Flag {
+	return []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:        "auth-chain",
+			EnvVars:     []string{"AUTH_CHAIN"},
+			Usage:       fmt.Sprintf("Order in which the enabled authenticators are tried, defaults to %v", defaultChainOrder),
+			Destination: &config.Order,
+		},
+		&cli.BoolFlag{
+			Name:        "impersonation-header-auth",
+			EnvVars:     []string{"IMPERSONATION_HEADER_AUTH"},
+			Usage:       "Trust the Impersonate-User, Impersonate-Group and Impersonate-Extra headers, only enable behind a proxy that sets them",
+			Destination: &config.ImpersonationHeaderAuth,
+		},
+		&cli.BoolFlag{
+			Name:        "anonymous-auth",
+			EnvVars:     []string{"ANONYMOUS_AUTH"},
+			Usage:       "Treat requests without credentials as made by system:anonymous",
+			Destination: &config.AnonymousAuth,
+		},
+	}
+}
@@ -0,0 +1,33 @@
+package cli
+
+import (
+	"github.com/rancher/steve/pkg/auth"
+	"github.com/urfave/cli/v2"
+)
+
+type ClientCertConfig struct {
+	ClientCAFile string
+}
+
+// Enabled returns whether client certificates should be requested and authenticated.
+func (c *ClientCertConfig) Enabled() bool {
+	return c.ClientCAFile != ""
+}
+
+func (c *ClientCertConfig) ClientCertAuthenticator() (auth.Authenticator, error) {
+	if !c.Enabled() {
+		return nil, nil
+	}
+	return auth.NewClientCertAuthenticatorFromFile(c.ClientCAFile)
+}
+
+func ClientCertFlags(config *ClientCertConfig) []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:        "client-ca-file",
+			EnvVars:     []string{"CLIENT_CA_FILE"},
+			Usage:       "Authenticate TLS client certificates signed by one of the CAs in this PEM bundle, using the CN as username and the O fields as groups",
+			Destination: &config.ClientCAFile,
+		},
+	}
+}
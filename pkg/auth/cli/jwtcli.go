@@ -0,0 +1,139 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rancher/steve/pkg/auth"
+	"github.com/urfave/cli/v2"
+)
+
+type JWTConfig struct {
+	JWTAuthentication   bool
+	Issuer              string
+	Audiences           cli.StringSlice
+	JWKSFile            string
+	JWKSURL             string
+	JWKSRefreshInterval time.Duration
+	UsernameClaim       string
+	UsernamePrefix      string
+	GroupsClaim         string
+	GroupsPrefix        string
+	ExtraClaims         cli.StringSlice
+}
+
+func (j *JWTConfig) JWTMiddleware() (auth.Middleware, error) {
+	authenticator, err := j.JWTAuthenticator()
+	if err != nil || authenticator == nil {
+		return nil, err
+	}
+	return auth.ToMiddleware(authenticator), nil
+}
+
+func (j *JWTConfig) JWTAuthenticator() (auth.Authenticator, error) {
+	if !j.JWTAuthentication {
+		return nil, nil
+	}
+
+	opts, err := j.options()
+	if err != nil {
+		return nil, err
+	}
+	return auth.NewJWTAuthenticator(opts)
+}
+
+func (j *JWTConfig) options() (auth.JWTOptions, error) {
+	opts := auth.JWTOptions{
+		Issuer:              j.Issuer,
+		Audiences:           j.Audiences.Value(),
+		JWKSFile:            j.JWKSFile,
+		JWKSURL:             j.JWKSURL,
+		JWKSRefreshInterval: j.JWKSRefreshInterval,
+		UsernameClaim:       j.UsernameClaim,
+		UsernamePrefix:      j.UsernamePrefix,
+		GroupsClaim:         j.GroupsClaim,
+		GroupsPrefix:        j.GroupsPrefix,
+	}
+	for _, mapping := range j.ExtraClaims.Value() {
+		key, claim, ok := strings.Cut(mapping, "=")
+		if !ok || key == "" || claim == "" {
+			return opts, fmt.Errorf("invalid JWT extra claim mapping %q, expected key=claim", mapping)
+		}
+		if opts.ExtraClaims == nil {
+			opts.ExtraClaims = map[string]string{}
+		}
+		opts.ExtraClaims[key] = claim
+	}
+	return opts, nil
+}
+
+func JWTFlags(config *JWTConfig) []cli.Flag {
+	return []cli.Flag{
+		&cli.BoolFlag{
+			Name:        "jwt-auth",
+			EnvVars:     []string{"JWT_AUTH"},
+			Usage:       "Authenticate bearer JWTs against the JWKS of the configured issuer",
+			Destination: &config.JWTAuthentication,
+		},
+		&cli.StringFlag{
+			Name:        "jwt-issuer",
+			EnvVars:     []string{"JWT_ISSUER"},
+			Usage:       "Expected iss claim of the tokens",
+			Destination: &config.Issuer,
+		},
+		&cli.StringSliceFlag{
+			Name:        "jwt-audience",
+			EnvVars:     []string{"JWT_AUDIENCE"},
+			Usage:       "Accepted aud claims, any audience is accepted if unset",
+			Destination: &config.Audiences,
+		},
+		&cli.StringFlag{
+			Name:        "jwt-jwks-file",
+			EnvVars:     []string{"JWT_JWKS_FILE"},
+			Usage:       "Path of the JSON Web Key Set of the issuer",
+			Destination: &config.JWKSFile,
+		},
+		&cli.StringFlag{
+			Name:        "jwt-jwks-url",
+			EnvVars:     []string{"JWT_JWKS_URL"},
+			Usage:       "URL of the JSON Web Key Set of the issuer",
+			Destination: &config.JWKSURL,
+		},
+		&cli.DurationFlag{
+			Name:        "jwt-jwks-refresh",
+			EnvVars:     []string{"JWT_JWKS_REFRESH"},
+			Usage:       "Interval at which the JSON Web Key Set is reloaded, keys are also reloaded on unknown key IDs",
+			Value:       time.Hour,
+			Destination: &config.JWKSRefreshInterval,
+		},
+		&cli.StringFlag{
+			Name:        "jwt-username-claim",
+			EnvVars:     []string{"JWT_USERNAME_CLAIM"},
+			Value:       "sub",
+			Destination: &config.UsernameClaim,
+		},
+		&cli.StringFlag{
+			Name:        "jwt-username-prefix",
+			EnvVars:     []string{"JWT_USERNAME_PREFIX"},
+			Destination: &config.UsernamePrefix,
+		},
+		&cli.StringFlag{
+			Name:        "jwt-groups-claim",
+			EnvVars:     []string{"JWT_GROUPS_CLAIM"},
+			Value:       "groups",
+			Destination: &config.GroupsClaim,
+		},
+		&cli.StringFlag{
+			Name:        "jwt-groups-prefix",
+			EnvVars:     []string{"JWT_GROUPS_PREFIX"},
+			Destination: &config.GroupsPrefix,
+		},
+		&cli.StringSliceFlag{
+			Name:        "jwt-extra-claim",
+			EnvVars:     []string{"JWT_EXTRA_CLAIMS"},
+			Usage:       "Map a claim to a user extra key, as key=claim",
+			Destination: &config.ExtraClaims,
+		},
+	}
+}
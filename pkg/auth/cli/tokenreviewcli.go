@@ -0,0 +1,45 @@
+package cli
+
+import (
+	"time"
+
+	"github.com/rancher/steve/pkg/auth"
+	"github.com/rancher/steve/pkg/client"
+	"github.com/urfave/cli/v2"
+)
+
+type TokenReviewConfig struct {
+	TokenReviewAuthentication bool
+	CacheTTLSeconds           int
+	Audiences                 cli.StringSlice
+}
+
+func (t *TokenReviewConfig) TokenReviewAuthenticator(clientFactory *client.Factory) (auth.Authenticator, error) {
+	if !t.TokenReviewAuthentication {
+		return nil, nil
+	}
+	return auth.NewTokenReviewAuthenticator(clientFactory, time.Duration(t.CacheTTLSeconds)*time.Second, t.Audiences.Value())
+}
+
+func TokenReviewFlags(config *TokenReviewConfig) []cli.Flag {
+	return []cli.Flag{
+		&cli.BoolFlag{
+			Name:        "token-review-auth",
+			EnvVars:     []string{"TOKEN_REVIEW_AUTH"},
+			Usage:       "Authenticate bearer tokens issued by the cluster, such as service account tokens, with the TokenReview API",
+			Destination: &config.TokenReviewAuthentication,
+		},
+		&cli.IntFlag{
+			Name:        "token-review-cache-ttl",
+			EnvVars:     []string{"TOKEN_REVIEW_CACHE_TTL"},
+			Value:       10,
+			Destination: &config.CacheTTLSeconds,
+		},
+		&cli.StringSliceFlag{
+			Name:        "token-review-audience",
+			EnvVars:     []string{"TOKEN_REVIEW_AUDIENCE"},
+			Usage:       "Audiences the tokens must be issued for, the audiences of the cluster's API server are accepted if unset",
+			Destination: &config.Audiences,
+		},
+	}
+}
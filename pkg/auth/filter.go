@@ -45,6 +45,16 @@ func (a AuthenticatorFunc) Authenticate(req *http.Request) (user.Info, bool, err
 	return a(req)
 }
 
+// Union returns an Authenticator trying each of authenticators in order until one succeeds. If none succeeds,
+// the errors of the authenticators which failed are returned. See Chain to also report which one succeeded.
+func Union(authenticators ...Authenticator) Authenticator {
+	chain := &Chain{}
+	for _, auth := range authenticators {
+		chain.Authenticators = append(chain.Authenticators, NamedAuthenticator{Authenticator: auth})
+	}
+	return chain
+}
+
 type Middleware func(next http.Handler) http.Handler
 
 func (m Middleware) Chain(middleware Middleware) Middleware {
@@ -146,30 +156,7 @@ func ToMiddleware(auth Authenticator) Middleware {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
 			info, ok, err := auth.Authenticate(req)
-			ctx := req.Context()
-			if err != nil {
-				info = &user.DefaultInfo{
-					Name: "system:cattle:error",
-					UID:  "system:cattle:error",
-					Groups: []string{
-						"system:unauthenticated",
-						"system:cattle:error",
-					},
-				}
-				ctx = request.WithValue(ctx, CattleAuthFailed, "true")
-			} else if !ok {
-				info = &user.DefaultInfo{
-					Name: "system:unauthenticated",
-					UID:  "system:unauthenticated",
-					Groups: []string{
-						"system:unauthenticated",
-					},
-				}
-			}
-			ctx = request.WithUser(ctx, info)
-
-			req = req.WithContext(ctx)
-			next.ServeHTTP(rw, req)
+			next.ServeHTTP(rw, req.WithContext(withUser(req.Context(), info, ok, err)))
 		})
 	}
 }
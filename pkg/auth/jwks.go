@@ -0,0 +1,177 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	jose "github.com/go-jose/go-jose/v4"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// jwksMinRefresh limits how often an unknown key ID can trigger a reload of the key set.
+	jwksMinRefresh = time.Minute
+	jwksMaxBytes   = 1 << 20
+)
+
+// keySet holds the verification keys of an issuer, loaded from a local file or a URL.
+type keySet struct {
+	file, url       string
+	client          *http.Client
+	refreshInterval time.Duration
+
+	// reloads collapses the reloads triggered by concurrent requests into one
+	reloads singleflight.Group
+
+	lock     sync.RWMutex
+	keys     []jose.JSONWebKey
+	loadedAt time.Time
+}
+
+func newKeySet(file, url string, refreshInterval time.Duration) (*keySet, error) {
+	if (file == "") == (url == "") {
+		return nil, fmt.Errorf("exactly one of the JWKS file or URL must be set")
+	}
+	ks := &keySet{
+		file:            file,
+		url:             url,
+		client:          &http.Client{Timeout: 30 * time.Second},
+		refreshInterval: refreshInterval,
+	}
+	if err := ks.load(context.Background()); err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
+
+// lookup returns the keys which may have signed a token with the given key ID and algorithm. The key set is
+// reloaded when it is older than the refresh interval, or when the key ID is unknown.
+func (k *keySet) lookup(ctx context.Context, kid, alg string) []jose.JSONWebKey {
+	k.lock.RLock()
+	keys, loadedAt := k.keys, k.loadedAt
+	k.lock.RUnlock()
+
+	result := matchingKeys(keys, kid, alg)
+	age := time.Since(loadedAt)
+	if (len(result) == 0 && age > jwksMinRefresh) || (k.refreshInterval > 0 && age > k.refreshInterval) {
+		if err := k.reload(ctx, loadedAt); err != nil {
+			logrus.Warnf("failed to reload JWKS: %v", err)
+			return result
+		}
+		k.lock.RLock()
+		result = matchingKeys(k.keys, kid, alg)
+		k.lock.RUnlock()
+	}
+	return result
+}
+
+// reload loads the key set again, unless it was reloaded since seen, once for all the concurrent callers.
+func (k *keySet) reload(ctx context.Context, seen time.Time) error {
+	_, err, _ := k.reloads.Do("", func() (interface{}, error) {
+		k.lock.RLock()
+		reloaded := k.loadedAt.After(seen)
+		k.lock.RUnlock()
+		if reloaded {
+			return nil, nil
+		}
+		// the reload is shared, so it mustn't be canceled with the request which started it
+		return nil, k.load(context.WithoutCancel(ctx))
+	})
+	return err
+}
+
+func matchingKeys(keys []jose.JSONWebKey, kid, alg string) []jose.JSONWebKey {
+	var result []jose.JSONWebKey
+	for _, key := range keys {
+		if kid != "" && key.KeyID != "" && key.KeyID != kid {
+			continue
+		}
+		if key.Algorithm != "" && key.Algorithm != alg {
+			continue
+		}
+		result = append(result, key)
+	}
+	return result
+}
+
+func (k *keySet) load(ctx context.Context) error {
+	data, err := k.read(ctx)
+	if err != nil {
+		return fmt.Errorf("loading JWKS: %w", err)
+	}
+	keys, err := parseJWKS(data)
+	if err != nil {
+		return err
+	}
+
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	k.keys = keys
+	k.loadedAt = time.Now()
+	return nil
+}
+
+func (k *keySet) read(ctx context.Context) ([]byte, error) {
+	if k.file != "" {
+		return os.ReadFile(k.file)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, k.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d from %s", resp.StatusCode, k.url)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, jwksMaxBytes))
+}
+
+// parseJWKS returns the public signing keys of a JSON Web Key Set. Keys for other uses, and keys of types go-jose
+// doesn't support, are skipped rather than failing the whole set.
+func parseJWKS(data []byte) ([]jose.JSONWebKey, error) {
+	var set struct {
+		Keys []json.RawMessage `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("parsing JWKS: %w", err)
+	}
+	var keys []jose.JSONWebKey
+	for _, raw := range set.Keys {
+		var use struct {
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+		}
+		if err := json.Unmarshal(raw, &use); err != nil {
+			return nil, fmt.Errorf("parsing JWKS: %w", err)
+		}
+		if use.Use != "" && use.Use != "sig" {
+			continue
+		}
+		var key jose.JSONWebKey
+		if err := key.UnmarshalJSON(raw); err != nil {
+			logrus.Warnf("skipping JWKS key %q: %v", use.Kid, err)
+			continue
+		}
+		// symmetric keys have no public part, they would let anyone reading the key set sign tokens
+		public := key.Public()
+		if !public.Valid() {
+			continue
+		}
+		keys = append(keys, public)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("JWKS contains no usable signing keys")
+	}
+	return keys, nil
+}
@@ -0,0 +1,226 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	jose "github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"k8s.io/apiserver/pkg/authentication/user"
+)
+
+const (
+	defaultUsernameClaim = "sub"
+	defaultGroupsClaim   = "groups"
+	jwtClockSkew         = time.Minute
+	// systemPrefix is reserved for the users and groups of Kubernetes, like system:masters, which an issuer mustn't
+	// be able to impersonate
+	systemPrefix = "system:"
+)
+
+var errInvalidToken = errors.New("invalid bearer token")
+
+// JWTOptions configure an authenticator for JWTs issued by an OIDC provider or any other issuer publishing a JWKS.
+type JWTOptions struct {
+	// Issuer must match the iss claim of the token.
+	Issuer string
+	// Audiences, if set, must contain one of the values of the aud claim.
+	Audiences []string
+	// JWKSFile or JWKSURL locate the JSON Web Key Set used to verify token signatures.
+	JWKSFile string
+	JWKSURL  string
+	// JWKSRefreshInterval reloads the key set periodically, in addition to reloading on unknown key IDs.
+	JWKSRefreshInterval time.Duration
+
+	// UsernameClaim defaults to "sub". If it is "email", the email_verified claim must be true.
+	UsernameClaim string
+	// UsernamePrefix and GroupsPrefix are prepended to the claims. Tokens whose username or groups, prefix included,
+	// start with "system:" are rejected, as are prefixes starting with it.
+	UsernamePrefix string
+	// GroupsClaim defaults to "groups". The claim may be a string or a list of strings.
+	GroupsClaim  string
+	GroupsPrefix string
+	// ExtraClaims maps user extra keys to the claims they are read from.
+	ExtraClaims map[string]string
+}
+
+// NewJWTAuthenticator returns an Authenticator validating bearer JWTs against the issuer's JWKS and mapping
+// their claims to a user. Tokens which are not JWTs or are from another issuer are not authenticated, so
+// other authenticators can handle them.
+func NewJWTAuthenticator(opts JWTOptions) (Authenticator, error) {
+	if opts.Issuer == "" {
+		return nil, fmt.Errorf("JWT issuer is required")
+	}
+	if strings.HasPrefix(opts.UsernamePrefix, systemPrefix) || strings.HasPrefix(opts.GroupsPrefix, systemPrefix) {
+		return nil, fmt.Errorf("JWT username and groups prefixes must not start with %q", systemPrefix)
+	}
+	keys, err := newKeySet(opts.JWKSFile, opts.JWKSURL, opts.JWKSRefreshInterval)
+	if err != nil {
+		return nil, err
+	}
+	if opts.UsernameClaim == "" {
+		opts.UsernameClaim = defaultUsernameClaim
+	}
+	if opts.GroupsClaim == "" {
+		opts.GroupsClaim = defaultGroupsClaim
+	}
+	return &jwtAuth{
+		opts: opts,
+		keys: keys,
+		now:  time.Now,
+	}, nil
+}
+
+// NewJWTMiddleware returns a Middleware authenticating requests with NewJWTAuthenticator.
+func NewJWTMiddleware(opts JWTOptions) (Middleware, error) {
+	auth, err := NewJWTAuthenticator(opts)
+	if err != nil {
+		return nil, err
+	}
+	return ToMiddleware(auth), nil
+}
+
+type jwtAuth struct {
+	opts JWTOptions
+	keys *keySet
+	now  func() time.Time
+}
+
+// signatureAlgorithms are the asymmetric algorithms accepted for tokens. HMAC is left out, as the JWKS is public.
+var signatureAlgorithms = []jose.SignatureAlgorithm{
+	jose.RS256, jose.RS384, jose.RS512,
+	jose.PS256, jose.PS384, jose.PS512,
+	jose.ES256, jose.ES384, jose.ES512,
+}
+
+// Authenticate implements Authenticator.
+func (j *jwtAuth) Authenticate(req *http.Request) (user.Info, bool, error) {
+	token, ok := bearerToken(req)
+	if !ok {
+		return nil, false, nil
+	}
+	tok, err := jwt.ParseSigned(token, signatureAlgorithms)
+	if err != nil {
+		// not a JWT, probably meant for another authenticator
+		return nil, false, nil
+	}
+	var unverified jwt.Claims
+	if err := tok.UnsafeClaimsWithoutVerification(&unverified); err != nil || unverified.Issuer != j.opts.Issuer {
+		return nil, false, nil
+	}
+
+	var (
+		registered jwt.Claims
+		claims     map[string]interface{}
+	)
+	if err := j.verifySignature(req.Context(), tok, &registered, &claims); err != nil {
+		return nil, false, err
+	}
+	if err := j.validateClaims(registered); err != nil {
+		return nil, false, err
+	}
+	info, err := j.userInfo(claims)
+	if err != nil {
+		return nil, false, err
+	}
+	return info, true, nil
+}
+
+func bearerToken(req *http.Request) (string, bool) {
+	token, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+	token = strings.TrimSpace(token)
+	return token, ok && token != ""
+}
+
+// verifySignature checks the signature of tok against the keys of the issuer and decodes its claims.
+func (j *jwtAuth) verifySignature(ctx context.Context, tok *jwt.JSONWebToken, registered *jwt.Claims, claims *map[string]interface{}) error {
+	header := tok.Headers[0]
+	for _, key := range j.keys.lookup(ctx, header.KeyID, header.Algorithm) {
+		if err := tok.Claims(key.Key, registered, claims); err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: signature verification failed", errInvalidToken)
+}
+
+func (j *jwtAuth) validateClaims(registered jwt.Claims) error {
+	if registered.Expiry == nil {
+		return fmt.Errorf("%w: missing exp claim", errInvalidToken)
+	}
+	expected := jwt.Expected{
+		Issuer:      j.opts.Issuer,
+		AnyAudience: j.opts.Audiences,
+		Time:        j.now(),
+	}
+	if err := registered.ValidateWithLeeway(expected, jwtClockSkew); err != nil {
+		return fmt.Errorf("%w: %w", errInvalidToken, err)
+	}
+	return nil
+}
+
+func (j *jwtAuth) userInfo(claims map[string]interface{}) (user.Info, error) {
+	username, _ := claims[j.opts.UsernameClaim].(string)
+	if username == "" {
+		return nil, fmt.Errorf("%w: missing %s claim", errInvalidToken, j.opts.UsernameClaim)
+	}
+	if j.opts.UsernameClaim == "email" {
+		// an unverified email may belong to someone else
+		if verified, _ := claims["email_verified"].(bool); !verified {
+			return nil, fmt.Errorf("%w: email not verified", errInvalidToken)
+		}
+	}
+
+	info := &user.DefaultInfo{
+		Name: j.opts.UsernamePrefix + username,
+	}
+	if strings.HasPrefix(info.Name, systemPrefix) {
+		return nil, fmt.Errorf("%w: username %s is reserved", errInvalidToken, info.Name)
+	}
+	if uid, _ := claims["sub"].(string); uid != "" {
+		info.UID = uid
+	}
+	groups, _ := stringOrSlice(claims[j.opts.GroupsClaim])
+	for _, group := range groups {
+		group = j.opts.GroupsPrefix + group
+		if strings.HasPrefix(group, systemPrefix) {
+			return nil, fmt.Errorf("%w: group %s is reserved", errInvalidToken, group)
+		}
+		info.Groups = append(info.Groups, group)
+	}
+	info.Groups = append(info.Groups, user.AllAuthenticated)
+
+	for key, claim := range j.opts.ExtraClaims {
+		values, ok := stringOrSlice(claims[claim])
+		if !ok || len(values) == 0 {
+			continue
+		}
+		if info.Extra == nil {
+			info.Extra = map[string][]string{}
+		}
+		info.Extra[key] = values
+	}
+	return info, nil
+}
+
+func stringOrSlice(v interface{}) ([]string, bool) {
+	switch value := v.(type) {
+	case string:
+		return []string{value}, true
+	case []interface{}:
+		result := make([]string, 0, len(value))
+		for _, item := range value {
+			s, ok := item.(string)
+			if !ok {
+				return nil, false
+			}
+			result = append(result, s)
+		}
+		return result, true
+	default:
+		return nil, false
+	}
+}
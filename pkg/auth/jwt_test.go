@@ -0,0 +1,349 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	jose "github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apiserver/pkg/authentication/user"
+)
+
+const testIssuer = "https://issuer.example.com"
+
+func sign(t *testing.T, alg jose.SignatureAlgorithm, key interface{}, kid string, claims map[string]interface{}) string {
+	t.Helper()
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: alg, Key: jose.JSONWebKey{Key: key, KeyID: kid}}, (&jose.SignerOptions{}).WithType("JWT"))
+	require.NoError(t, err)
+	token, err := jwt.Signed(signer).Claims(claims).Serialize()
+	require.NoError(t, err)
+	return token
+}
+
+func signRS256(t *testing.T, key *rsa.PrivateKey, kid string, claims map[string]interface{}) string {
+	return sign(t, jose.RS256, key, kid, claims)
+}
+
+func signES256(t *testing.T, key *ecdsa.PrivateKey, kid string, claims map[string]interface{}) string {
+	return sign(t, jose.ES256, key, kid, claims)
+}
+
+func jwks(rsaKid string, rsaKey *rsa.PrivateKey, ecKey *ecdsa.PrivateKey) []byte {
+	var keys []json.RawMessage
+	for _, key := range []jose.JSONWebKey{
+		{Key: &rsaKey.PublicKey, KeyID: rsaKid, Use: "sig"},
+		{Key: &ecKey.PublicKey, KeyID: "ec"},
+		// symmetric keys would let anyone reading the key set sign tokens
+		{Key: []byte("shared-secret-shared-secret-shared"), KeyID: "hmac"},
+	} {
+		data, _ := key.MarshalJSON()
+		keys = append(keys, data)
+	}
+	// keys for other uses are skipped, even when they can't be parsed
+	keys = append(keys, json.RawMessage(`{"kty":"RSA","kid":"enc","use":"enc"}`))
+	data, _ := json.Marshal(map[string]interface{}{"keys": keys})
+	return data
+}
+
+func bearerRequest(token string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/v1/pods", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	return req
+}
+
+func TestJWTAuthenticator(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	jwksFile := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(jwksFile, jwks("rsa", rsaKey, ecKey), 0600))
+
+	a, err := NewJWTAuthenticator(JWTOptions{
+		Issuer:         testIssuer,
+		Audiences:      []string{"steve"},
+		JWKSFile:       jwksFile,
+		UsernameClaim:  "email",
+		UsernamePrefix: "oidc:",
+		GroupsPrefix:   "oidc:",
+		ExtraClaims:    map[string]string{"tenant": "tid"},
+	})
+	require.NoError(t, err)
+
+	now := time.Now()
+	claims := func(overrides map[string]interface{}) map[string]interface{} {
+		c := map[string]interface{}{
+			"iss":   testIssuer,
+			"aud":   []string{"other", "steve"},
+			"sub":   "1234",
+			"email": "alice@example.com",
+			// an unverified email isn't trusted
+			"email_verified": true,
+			"groups":         []string{"devs", "ops"},
+			"tid":            "acme",
+			"exp":            now.Add(time.Hour).Unix(),
+			"iat":            now.Unix(),
+		}
+		for k, v := range overrides {
+			if v == nil {
+				delete(c, k)
+				continue
+			}
+			c[k] = v
+		}
+		return c
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		want    user.Info
+		wantErr bool
+	}{
+		{
+			name:  "RS256",
+			token: signRS256(t, rsaKey, "rsa", claims(nil)),
+			want: &user.DefaultInfo{
+				Name:   "oidc:alice@example.com",
+				UID:    "1234",
+				Groups: []string{"oidc:devs", "oidc:ops", user.AllAuthenticated},
+				Extra:  map[string][]string{"tenant": {"acme"}},
+			},
+		},
+		{
+			name:  "ES256 with a single group and audience",
+			token: signES256(t, ecKey, "ec", claims(map[string]interface{}{"aud": "steve", "groups": "devs", "tid": nil})),
+			want: &user.DefaultInfo{
+				Name:   "oidc:alice@example.com",
+				UID:    "1234",
+				Groups: []string{"oidc:devs", user.AllAuthenticated},
+			},
+		},
+		{
+			name:  "other issuer is left to other authenticators",
+			token: signRS256(t, rsaKey, "rsa", claims(map[string]interface{}{"iss": "https://other.example.com"})),
+		},
+		{
+			name:  "opaque token is left to other authenticators",
+			token: "kubeconfig-u-abc:secret",
+		},
+		{
+			name:    "unknown signing key",
+			token:   signRS256(t, otherKey, "rsa", claims(nil)),
+			wantErr: true,
+		},
+		{
+			name:    "expired",
+			token:   signRS256(t, rsaKey, "rsa", claims(map[string]interface{}{"exp": now.Add(-time.Hour).Unix()})),
+			wantErr: true,
+		},
+		{
+			name:    "missing exp",
+			token:   signRS256(t, rsaKey, "rsa", claims(map[string]interface{}{"exp": nil})),
+			wantErr: true,
+		},
+		{
+			name:    "not yet valid",
+			token:   signRS256(t, rsaKey, "rsa", claims(map[string]interface{}{"nbf": now.Add(time.Hour).Unix()})),
+			wantErr: true,
+		},
+		{
+			name:    "wrong audience",
+			token:   signRS256(t, rsaKey, "rsa", claims(map[string]interface{}{"aud": "other"})),
+			wantErr: true,
+		},
+		{
+			name:    "unverified email",
+			token:   signRS256(t, rsaKey, "rsa", claims(map[string]interface{}{"email_verified": false})),
+			wantErr: true,
+		},
+		{
+			name:    "email without email_verified",
+			token:   signRS256(t, rsaKey, "rsa", claims(map[string]interface{}{"email_verified": nil})),
+			wantErr: true,
+		},
+		{
+			name:  "signed with HMAC using the published key",
+			token: sign(t, jose.HS256, []byte("shared-secret-shared-secret-shared"), "hmac", claims(nil)),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			info, ok, err := a.Authenticate(bearerRequest(test.token))
+			if test.wantErr {
+				assert.Error(t, err)
+				assert.False(t, ok)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.want != nil, ok)
+			assert.Equal(t, test.want, info)
+		})
+	}
+}
+
+func TestJWTAuthenticatorJWKSURL(t *testing.T) {
+	oldKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	newKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	var current atomic.Value
+	current.Store(jwks("rsa-1", oldKey, ecKey))
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = rw.Write(current.Load().([]byte))
+	}))
+	defer server.Close()
+
+	a, err := NewJWTAuthenticator(JWTOptions{
+		Issuer:  testIssuer,
+		JWKSURL: server.URL,
+	})
+	require.NoError(t, err)
+
+	claims := map[string]interface{}{
+		"iss": testIssuer,
+		"sub": "alice",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+	info, ok, err := a.Authenticate(bearerRequest(signRS256(t, oldKey, "rsa-1", claims)))
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "alice", info.GetName())
+
+	// rotated keys are picked up on an unknown key ID once the key set is old enough
+	current.Store(jwks("rsa-2", newKey, ecKey))
+	a.(*jwtAuth).keys.loadedAt = time.Now().Add(-2 * jwksMinRefresh)
+	_, ok, err = a.Authenticate(bearerRequest(signRS256(t, newKey, "rsa-2", claims)))
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func TestJWTAuthenticatorConcurrentReloads(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	var loads atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		loads.Add(1)
+		_, _ = rw.Write(jwks("rsa", rsaKey, ecKey))
+	}))
+	defer server.Close()
+
+	a, err := NewJWTAuthenticator(JWTOptions{
+		Issuer:  testIssuer,
+		JWKSURL: server.URL,
+	})
+	require.NoError(t, err)
+	a.(*jwtAuth).keys.loadedAt = time.Now().Add(-2 * jwksMinRefresh)
+
+	// tokens signed with an unknown key ID all trigger a reload of the stale key set
+	token := signRS256(t, rsaKey, "unknown", map[string]interface{}{
+		"iss": testIssuer,
+		"sub": "alice",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	})
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, _ = a.Authenticate(bearerRequest(token))
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(2), loads.Load())
+}
+
+func TestJWTAuthenticatorSystemNames(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	jwksFile := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(jwksFile, jwks("rsa", rsaKey, ecKey), 0600))
+
+	a, err := NewJWTAuthenticator(JWTOptions{
+		Issuer:   testIssuer,
+		JWKSFile: jwksFile,
+	})
+	require.NoError(t, err)
+
+	claims := func(sub string, groups ...string) map[string]interface{} {
+		return map[string]interface{}{
+			"iss":    testIssuer,
+			"sub":    sub,
+			"groups": groups,
+			"exp":    time.Now().Add(time.Hour).Unix(),
+		}
+	}
+	tests := []struct {
+		name    string
+		claims  map[string]interface{}
+		wantErr bool
+	}{
+		{
+			name:   "unprefixed names",
+			claims: claims("alice", "devs", "systems"),
+		},
+		{
+			name:    "system username",
+			claims:  claims("system:admin", "devs"),
+			wantErr: true,
+		},
+		{
+			name:    "system group",
+			claims:  claims("alice", "devs", "system:masters"),
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			info, ok, err := a.Authenticate(bearerRequest(signRS256(t, rsaKey, "rsa", test.claims)))
+			if test.wantErr {
+				assert.ErrorIs(t, err, errInvalidToken)
+				assert.False(t, ok)
+				assert.Nil(t, info)
+				return
+			}
+			require.NoError(t, err)
+			assert.True(t, ok)
+			assert.Equal(t, "alice", info.GetName())
+		})
+	}
+}
+
+func TestNewJWTAuthenticatorErrors(t *testing.T) {
+	_, err := NewJWTAuthenticator(JWTOptions{JWKSFile: "jwks.json"})
+	assert.Error(t, err)
+	_, err = NewJWTAuthenticator(JWTOptions{Issuer: testIssuer})
+	assert.Error(t, err)
+	_, err = NewJWTAuthenticator(JWTOptions{Issuer: testIssuer, JWKSFile: filepath.Join(t.TempDir(), "missing.json")})
+	assert.Error(t, err)
+
+	// prefixes are checked, as they would make every token rejected
+	_, err = NewJWTAuthenticator(JWTOptions{Issuer: testIssuer, JWKSFile: "jwks.json", UsernamePrefix: "system:oidc:"})
+	assert.ErrorContains(t, err, "prefixes")
+	_, err = NewJWTAuthenticator(JWTOptions{Issuer: testIssuer, JWKSFile: "jwks.json", GroupsPrefix: "system:"})
+	assert.ErrorContains(t, err, "prefixes")
+}
@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/rancher/steve/pkg/client"
+	"k8s.io/apiserver/pkg/authentication/authenticator"
+	"k8s.io/apiserver/pkg/authentication/token/cache"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/plugin/pkg/authenticator/token/webhook"
+)
+
+const tokenReviewTimeout = 10 * time.Second
+
+// NewTokenReviewAuthenticator returns an Authenticator for bearer tokens issued by the cluster, such as
+// service account tokens, validated with the TokenReview API using the admin credentials of clientFactory.
+// Like for the webhook authenticator, results are cached for cacheTTL if it is positive.
+//
+// audiences, if set, are the audiences the tokens must be issued for. Otherwise the audiences of the
+// cluster's API server are accepted.
+func NewTokenReviewAuthenticator(clientFactory *client.Factory, cacheTTL time.Duration, audiences []string) (Authenticator, error) {
+	k8s, err := clientFactory.AdminK8sInterface()
+	if err != nil {
+		return nil, err
+	}
+	tr, err := webhook.NewFromInterface(k8s.AuthenticationV1(), nil, WebhookBackoff, tokenReviewTimeout, webhook.AuthenticatorMetrics{
+		RecordRequestTotal:   func(context.Context, string) {},
+		RecordRequestLatency: func(context.Context, string, float64) {},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var token authenticator.Token = tr
+	if cacheTTL > 0 {
+		token = cache.New(tr, false, cacheTTL, cacheTTL)
+	}
+	return &tokenReviewAuth{
+		auth:      token,
+		audiences: audiences,
+	}, nil
+}
+
+type tokenReviewAuth struct {
+	auth      authenticator.Token
+	audiences authenticator.Audiences
+}
+
+func (t *tokenReviewAuth) Authenticate(req *http.Request) (user.Info, bool, error) {
+	token, ok := bearerToken(req)
+	if !ok {
+		return nil, false, nil
+	}
+	ctx := req.Context()
+	if len(t.audiences) > 0 {
+		// the TokenReview then only succeeds if the token was issued for one of the audiences
+		ctx = authenticator.WithAudiences(ctx, t.audiences)
+	}
+	resp, ok, err := t.auth.AuthenticateToken(ctx, token)
+	if err != nil || !ok || resp == nil {
+		return nil, false, err
+	}
+	return resp.User, true, nil
+}
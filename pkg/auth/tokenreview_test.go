@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rancher/steve/pkg/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/client-go/rest"
+)
+
+func tokenReviewServer(t *testing.T, reviews *atomic.Int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost || req.URL.Path != "/apis/authentication.k8s.io/v1/tokenreviews" {
+			http.NotFound(rw, req)
+			return
+		}
+		reviews.Add(1)
+		review := &authenticationv1.TokenReview{}
+		if !assert.NoError(t, json.NewDecoder(req.Body).Decode(review)) {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		audiences := []string{"https://kubernetes.default.svc"}
+		if review.Spec.Token == "sa-token" && (len(review.Spec.Audiences) == 0 || slices.Contains(review.Spec.Audiences, audiences[0])) {
+			review.Status = authenticationv1.TokenReviewStatus{
+				Authenticated: true,
+				User: authenticationv1.UserInfo{
+					Username: "system:serviceaccount:default:automation",
+					UID:      "1234",
+					Groups:   []string{"system:serviceaccounts", "system:authenticated"},
+				},
+				Audiences: audiences,
+			}
+		}
+		rw.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(rw).Encode(review)
+	}))
+}
+
+func TestTokenReviewAuthenticator(t *testing.T) {
+	var reviews atomic.Int32
+	server := tokenReviewServer(t, &reviews)
+	defer server.Close()
+
+	clientFactory, err := client.NewFactory(&rest.Config{Host: server.URL}, true)
+	require.NoError(t, err)
+
+	a, err := NewTokenReviewAuthenticator(clientFactory, time.Minute, nil)
+	require.NoError(t, err)
+
+	info, ok, err := a.Authenticate(bearerRequest("sa-token"))
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "system:serviceaccount:default:automation", info.GetName())
+	assert.Equal(t, []string{"system:serviceaccounts", "system:authenticated"}, info.GetGroups())
+
+	// cached
+	_, ok, err = a.Authenticate(bearerRequest("sa-token"))
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, int32(1), reviews.Load())
+
+	_, ok, err = a.Authenticate(bearerRequest("other-token"))
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	_, ok, err = a.Authenticate(bearerRequest(""))
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, int32(2), reviews.Load())
+}
+
+func TestTokenReviewAuthenticatorAudiences(t *testing.T) {
+	var reviews atomic.Int32
+	server := tokenReviewServer(t, &reviews)
+	defer server.Close()
+
+	clientFactory, err := client.NewFactory(&rest.Config{Host: server.URL}, true)
+	require.NoError(t, err)
+
+	a, err := NewTokenReviewAuthenticator(clientFactory, 0, []string{"steve"})
+	require.NoError(t, err)
+
+	_, ok, err := a.Authenticate(bearerRequest("sa-token"))
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+
+	"k8s.io/apiserver/pkg/authentication/authenticator"
+	x509request "k8s.io/apiserver/pkg/authentication/request/x509"
+	"k8s.io/apiserver/pkg/authentication/user"
+)
+
+// NewClientCertAuthenticator returns an Authenticator for TLS client certificates signed by one of the CAs in
+// caBundle, a list of PEM encoded certificates. Like in kube-apiserver, the CN of the certificate is the
+// username and its O fields are the groups. Requests without a client certificate are not authenticated,
+// so other authenticators can handle them, while certificates which fail verification are an error.
+//
+// The HTTPS listener must request client certificates, see server.Options.RequestClientCerts.
+func NewClientCertAuthenticator(caBundle []byte) (Authenticator, error) {
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caBundle) {
+		return nil, fmt.Errorf("no valid certificates found in the client CA bundle")
+	}
+	opts := x509request.DefaultVerifyOptions()
+	opts.Roots = pool
+	return &clientCertAuth{
+		auth: x509request.New(opts, x509request.CommonNameUserConversion),
+	}, nil
+}
+
+// NewClientCertAuthenticatorFromFile is like NewClientCertAuthenticator with the CA bundle read from a file.
+func NewClientCertAuthenticatorFromFile(caFile string) (Authenticator, error) {
+	caBundle, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading client CA file: %w", err)
+	}
+	return NewClientCertAuthenticator(caBundle)
+}
+
+type clientCertAuth struct {
+	auth authenticator.Request
+}
+
+func (c *clientCertAuth) Authenticate(req *http.Request) (user.Info, bool, error) {
+	resp, ok, err := c.auth.AuthenticateRequest(req)
+	if err != nil || !ok || resp == nil {
+		return nil, false, err
+	}
+	info := resp.User
+	if slices.Contains(info.GetGroups(), user.AllAuthenticated) {
+		return info, true, nil
+	}
+	return &user.DefaultInfo{
+		Name:   info.GetName(),
+		UID:    info.GetUID(),
+		Groups: append(slices.Clone(info.GetGroups()), user.AllAuthenticated),
+		Extra:  info.GetExtra(),
+	}, true, nil
+}
@@ -0,0 +1,161 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apiserver/pkg/authentication/user"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &testCA{cert: cert, key: key}
+}
+
+func (ca *testCA) pem() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw})
+}
+
+func (ca *testCA) clientCert(t *testing.T, subject pkix.Name, usage x509.ExtKeyUsage) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      subject,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert
+}
+
+func certRequest(certs ...*x509.Certificate) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/v1/pods", nil)
+	req.TLS = &tls.ConnectionState{PeerCertificates: certs}
+	return req
+}
+
+func TestClientCertAuthenticator(t *testing.T) {
+	ca := newTestCA(t)
+	otherCA := newTestCA(t)
+
+	a, err := NewClientCertAuthenticator(ca.pem())
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		req     *http.Request
+		want    user.Info
+		wantErr bool
+	}{
+		{
+			name: "CN and O",
+			req:  certRequest(ca.clientCert(t, pkix.Name{CommonName: "deployer", Organization: []string{"ci", "ops"}}, x509.ExtKeyUsageClientAuth)),
+			want: &user.DefaultInfo{
+				Name:   "deployer",
+				Groups: []string{"ci", "ops", user.AllAuthenticated},
+			},
+		},
+		{
+			name: "no client certificate",
+			req:  httptest.NewRequest(http.MethodGet, "/v1/pods", nil),
+		},
+		{
+			name:    "unknown CA",
+			req:     certRequest(otherCA.clientCert(t, pkix.Name{CommonName: "deployer"}, x509.ExtKeyUsageClientAuth)),
+			wantErr: true,
+		},
+		{
+			name:    "server certificate",
+			req:     certRequest(ca.clientCert(t, pkix.Name{CommonName: "deployer"}, x509.ExtKeyUsageServerAuth)),
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			info, ok, err := a.Authenticate(test.req)
+			if test.wantErr {
+				assert.Error(t, err)
+				assert.False(t, ok)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.want != nil, ok)
+			if test.want == nil {
+				assert.Nil(t, info)
+				return
+			}
+			assert.Equal(t, test.want.GetName(), info.GetName())
+			assert.Equal(t, test.want.GetGroups(), info.GetGroups())
+		})
+	}
+
+	_, err = NewClientCertAuthenticator([]byte("not a certificate"))
+	assert.Error(t, err)
+}
+
+func TestUnion(t *testing.T) {
+	alice := &user.DefaultInfo{Name: "alice"}
+	unauthenticated := AuthenticatorFunc(func(req *http.Request) (user.Info, bool, error) {
+		return nil, false, nil
+	})
+	failing := AuthenticatorFunc(func(req *http.Request) (user.Info, bool, error) {
+		return nil, false, errors.New("invalid token")
+	})
+	authenticated := AuthenticatorFunc(func(req *http.Request) (user.Info, bool, error) {
+		return alice, true, nil
+	})
+	req := httptest.NewRequest(http.MethodGet, "/v1/pods", nil)
+
+	info, ok, err := Union(unauthenticated, failing, authenticated).Authenticate(req)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, alice, info)
+
+	_, ok, err = Union(unauthenticated, failing).Authenticate(req)
+	assert.EqualError(t, err, "invalid token")
+	assert.False(t, ok)
+
+	_, ok, err = Union(unauthenticated).Authenticate(req)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
@@ -0,0 +1,154 @@
+// Package etag computes the entity tags of /v1 responses and evaluates If-None-Match against them, so that clients
+// re-fetching unchanged objects and lists get a 304 instead of the whole response.
+package etag
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/rancher/wrangler/v3/pkg/schemas/validation"
+	"k8s.io/apiserver/pkg/endpoints/request"
+)
+
+// NotModified is the error returned by stores which answer a conditional request without listing.
+var NotModified = validation.ErrorCode{Code: "NotModified", Status: http.StatusNotModified}
+
+// epoch changes on every start, as the formatters and redactions rendering responses may have changed
+var epoch = strconv.FormatInt(time.Now().UnixNano(), 36)
+
+type (
+	generationsKey   struct{}
+	taggedRequestKey struct{}
+)
+
+// Generations are the parts of the tags which change without the version of the objects changing. Each server has
+// its own, as its caches and policies are its own, and adds them to the context of its requests with
+// WithGenerations. The zero value is ready to use.
+type Generations struct {
+	// invalidated is increased by Invalidate
+	invalidated atomic.Uint64
+	// types holds the func() uint64 set with SetType, by type
+	types sync.Map
+	// schema holds the func(*types.APISchema) uint64 set with SetSchema
+	schema atomic.Value
+}
+
+// Invalidate changes the tags of all responses. It must be called when responses change without the version of the
+// objects changing, for example when the policy rules deciding which objects are visible are reloaded.
+func (g *Generations) Invalidate() {
+	g.invalidated.Add(1)
+}
+
+// SetType mixes generation into the tags of the responses of the type with the given ID. It is for the types whose
+// responses change without the version of their objects changing, without invalidating the tags of all the other
+// types.
+func (g *Generations) SetType(typeID string, generation func() uint64) {
+	g.types.Store(typeID, generation)
+}
+
+// SetSchema mixes the result of generation for the schema of a request into the tags of its responses. It is for the
+// parts of the responses of every type which change without the version of the objects changing, like the
+// relationships and states added from the summary cache.
+func (g *Generations) SetSchema(generation func(apiSchema *types.APISchema) uint64) {
+	g.schema.Store(generation)
+}
+
+func (g *Generations) write(apiOp *types.APIRequest, write func(string)) {
+	write(strconv.FormatUint(g.invalidated.Load(), 10))
+	if typeGeneration, ok := g.types.Load(apiOp.Type); ok {
+		write(strconv.FormatUint(typeGeneration.(func() uint64)(), 10))
+	}
+	if generation, ok := g.schema.Load().(func(*types.APISchema) uint64); ok && apiOp.Schema != nil {
+		write(strconv.FormatUint(generation(apiOp.Schema), 10))
+	}
+}
+
+// WithGenerations returns a context in which the tags are computed with generations.
+func WithGenerations(ctx context.Context, generations *Generations) context.Context {
+	return context.WithValue(ctx, generationsKey{}, generations)
+}
+
+// WithTaggedRequest returns a context in which the tags are computed for req, rather than for the request being
+// served. It is for the stores listing with a modified copy of the request of the user, whose response is still
+// tagged by the writer for the original request.
+func WithTaggedRequest(ctx context.Context, req *http.Request) context.Context {
+	return context.WithValue(ctx, taggedRequestKey{}, req)
+}
+
+// For returns the entity tag of the response to apiOp for the given resourceVersion of an object, or revision of a
+// list, or an empty string if there is none. The tag also depends on the URL, the response format, who is asking and
+// the Generations of the context, as all of them change the response for the same version.
+func For(apiOp *types.APIRequest, version string) string {
+	if version == "" || apiOp.Request == nil {
+		return ""
+	}
+
+	hash := sha256.New()
+	write := func(s string) {
+		hash.Write([]byte(s))
+		hash.Write([]byte{0})
+	}
+	req := apiOp.Request
+	if tagged, ok := req.Context().Value(taggedRequestKey{}).(*http.Request); ok {
+		req = tagged
+	}
+
+	write(epoch)
+	if generations, ok := apiOp.Context().Value(generationsKey{}).(*Generations); ok {
+		generations.write(apiOp, write)
+	}
+	write(req.URL.Path)
+	// Encode sorts the parameters, so that their order doesn't matter
+	write(req.URL.Query().Encode())
+	write(apiOp.ResponseFormat)
+	if accessSet := accesscontrol.AccessSetFromAPIRequest(apiOp); accessSet != nil {
+		write(accessSet.ID)
+	}
+	if user, ok := request.UserFrom(apiOp.Context()); ok {
+		write(user.GetName())
+	}
+
+	return `W/"` + version + "-" + hex.EncodeToString(hash.Sum(nil)[:8]) + `"`
+}
+
+// Matches returns whether the If-None-Match header of req matches tag. Tags are compared weakly, as responses may
+// be compressed.
+func Matches(req *http.Request, tag string) bool {
+	header := req.Header.Get("If-None-Match")
+	if tag == "" || header == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || weak(candidate) == weak(tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// Check sets tag as the ETag of the response to apiOp, and returns a NotModified error if the request matches it.
+func Check(apiOp *types.APIRequest, tag string) error {
+	if tag == "" || apiOp.Method != http.MethodGet {
+		return nil
+	}
+	apiOp.Response.Header().Set("ETag", tag)
+	if Matches(apiOp.Request, tag) {
+		return apierror.NewAPIError(NotModified, "not modified")
+	}
+	return nil
+}
+
+func weak(tag string) string {
+	return strings.TrimPrefix(tag, "W/")
+}
@@ -0,0 +1,142 @@
+package etag
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/wrangler/v3/pkg/schemas"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newRequest(target string) *types.APIRequest {
+	return &types.APIRequest{
+		Method:         http.MethodGet,
+		Request:        httptest.NewRequest(http.MethodGet, target, nil),
+		Response:       httptest.NewRecorder(),
+		ResponseFormat: "json",
+	}
+}
+
+func TestFor(t *testing.T) {
+	tag := For(newRequest("/v1/pods?filter=a&sort=b"), "10")
+	assert.Regexp(t, `^W/"10-[0-9a-f]{16}"$`, tag)
+
+	assert.Equal(t, tag, For(newRequest("/v1/pods?sort=b&filter=a"), "10"), "parameter order should not matter")
+	assert.NotEqual(t, tag, For(newRequest("/v1/pods?filter=a&sort=b"), "11"))
+	assert.NotEqual(t, tag, For(newRequest("/v1/pods?filter=a"), "10"))
+	assert.NotEqual(t, tag, For(newRequest("/v1/secrets?filter=a&sort=b"), "10"))
+
+	yaml := newRequest("/v1/pods?filter=a&sort=b")
+	yaml.ResponseFormat = "yaml"
+	assert.NotEqual(t, tag, For(yaml, "10"))
+
+	assert.Empty(t, For(newRequest("/v1/pods"), ""))
+}
+
+func withGenerations(apiOp *types.APIRequest, generations *Generations) *types.APIRequest {
+	apiOp.Request = apiOp.Request.WithContext(WithGenerations(apiOp.Context(), generations))
+	return apiOp
+}
+
+func TestGenerationsInvalidate(t *testing.T) {
+	generations, other := &Generations{}, &Generations{}
+	tag := For(withGenerations(newRequest("/v1/pods"), generations), "10")
+	otherTag := For(withGenerations(newRequest("/v1/pods"), other), "10")
+
+	generations.Invalidate()
+	assert.NotEqual(t, tag, For(withGenerations(newRequest("/v1/pods"), generations), "10"), "invalidated tags should change")
+	assert.Equal(t, otherTag, For(withGenerations(newRequest("/v1/pods"), other), "10"), "the tags of other servers should not change")
+}
+
+func TestGenerationsSetType(t *testing.T) {
+	var generation uint64
+	generations := &Generations{}
+	generations.SetType("node", func() uint64 { return generation })
+
+	nodes := withGenerations(newRequest("/v1/nodes"), generations)
+	secrets := withGenerations(newRequest("/v1/secrets"), generations)
+	other := withGenerations(newRequest("/v1/nodes"), &Generations{})
+	nodes.Type, secrets.Type, other.Type = "node", "secret", "node"
+	nodeTag, secretTag, otherTag := For(nodes, "10"), For(secrets, "10"), For(other, "10")
+
+	generation++
+	assert.NotEqual(t, nodeTag, For(nodes, "10"))
+	assert.Equal(t, secretTag, For(secrets, "10"), "other types should keep their tags")
+	assert.Equal(t, otherTag, For(other, "10"), "the tags of other servers should not change")
+}
+
+func TestGenerationsSetSchema(t *testing.T) {
+	var generation uint64
+	generations := &Generations{}
+	generations.SetSchema(func(apiSchema *types.APISchema) uint64 {
+		if apiSchema.ID == "pod" {
+			return generation
+		}
+		return 0
+	})
+
+	pods := withGenerations(newRequest("/v1/pods"), generations)
+	secrets := withGenerations(newRequest("/v1/secrets"), generations)
+	pods.Schema = &types.APISchema{Schema: &schemas.Schema{ID: "pod"}}
+	secrets.Schema = &types.APISchema{Schema: &schemas.Schema{ID: "secret"}}
+	podTag, secretTag := For(pods, "10"), For(secrets, "10")
+
+	generation++
+	assert.NotEqual(t, podTag, For(pods, "10"))
+	assert.Equal(t, secretTag, For(secrets, "10"))
+}
+
+func TestWithTaggedRequest(t *testing.T) {
+	paged := newRequest("/v1/pods?pagesize=10")
+	tag := For(paged, "10")
+
+	unpaged := newRequest("/v1/pods")
+	unpaged.Request = unpaged.Request.WithContext(WithTaggedRequest(unpaged.Context(), paged.Request))
+	assert.Equal(t, tag, For(unpaged, "10"))
+}
+
+func TestMatches(t *testing.T) {
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		tag         string
+		want        bool
+	}{
+		{name: "equal", ifNoneMatch: `W/"1-a"`, tag: `W/"1-a"`, want: true},
+		{name: "weak comparison", ifNoneMatch: `"1-a"`, tag: `W/"1-a"`, want: true},
+		{name: "one of many", ifNoneMatch: `"0-a", W/"1-a"`, tag: `W/"1-a"`, want: true},
+		{name: "any", ifNoneMatch: "*", tag: `W/"1-a"`, want: true},
+		{name: "different", ifNoneMatch: `W/"0-a"`, tag: `W/"1-a"`},
+		{name: "no header", tag: `W/"1-a"`},
+		{name: "no tag", ifNoneMatch: "*"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/v1/pods", nil)
+			req.Header.Set("If-None-Match", test.ifNoneMatch)
+			assert.Equal(t, test.want, Matches(req, test.tag))
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	apiOp := newRequest("/v1/pods")
+	tag := For(apiOp, "10")
+	assert.NoError(t, Check(apiOp, tag))
+	assert.Equal(t, tag, apiOp.Response.Header().Get("ETag"))
+
+	apiOp.Request.Header.Set("If-None-Match", tag)
+	var apiError *apierror.APIError
+	require.ErrorAs(t, Check(apiOp, tag), &apiError)
+	assert.Equal(t, NotModified, apiError.Code)
+
+	apiOp = newRequest("/v1/pods")
+	apiOp.Method = http.MethodPut
+	apiOp.Request.Header.Set("If-None-Match", "*")
+	assert.NoError(t, Check(apiOp, tag))
+	assert.Empty(t, apiOp.Response.Header().Get("ETag"))
+}
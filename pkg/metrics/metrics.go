@@ -19,6 +19,18 @@ const (
 	resourceLabel = "resource"
 	methodLabel   = "method"
 	codeLabel     = "code"
+
+	authenticatorLabel = "authenticator"
+	resultLabel        = "result"
+	classLabel         = "class"
+	reasonLabel        = "reason"
+)
+
+// Results of authentication attempts.
+const (
+	AuthenticationSuccess         = "success"
+	AuthenticationUnauthenticated = "unauthenticated"
+	AuthenticationError           = "error"
 )
 
 var (
@@ -47,6 +59,20 @@ var (
 			Help:      "Request times in ms for k8s proxy store",
 		},
 		[]string{resourceLabel, methodLabel, codeLabel})
+	AuthenticationAttempts = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Subsystem: "auth",
+			Name:      "authentication_attempts",
+			Help:      "Total count of authentication attempts by result and successful authenticator",
+		},
+		[]string{authenticatorLabel, resultLabel})
+	RateLimitRejections = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Subsystem: "ratelimit",
+			Name:      "rejected_requests",
+			Help:      "Total count of requests rejected by the per-user rate and concurrency limits",
+		},
+		[]string{classLabel, reasonLabel})
 )
 
 func (m MetricLogger) IncTotalResponses(err error) {
@@ -85,6 +111,30 @@ func (m MetricLogger) RecordProxyStoreResponseTime(err error, val float64) {
 	}
 }
 
+// IncAuthenticationAttempts counts a request authenticated by authenticator, or not authenticated at all.
+func IncAuthenticationAttempts(authenticator, result string) {
+	if prometheusMetrics {
+		AuthenticationAttempts.With(
+			prometheus.Labels{
+				authenticatorLabel: authenticator,
+				resultLabel:        result,
+			},
+		).Inc()
+	}
+}
+
+// IncRateLimitRejections counts a request of class rejected because of reason, "rate" or "concurrency".
+func IncRateLimitRejections(class, reason string) {
+	if prometheusMetrics {
+		RateLimitRejections.With(
+			prometheus.Labels{
+				classLabel:  class,
+				reasonLabel: reason,
+			},
+		).Inc()
+	}
+}
+
 func (m MetricLogger) getAPIErrorCode(err error) string {
 	successCode := "200"
 	if m.Method == http.MethodPost {
@@ -94,6 +144,10 @@ func (m MetricLogger) getAPIErrorCode(err error) string {
 		return successCode
 	}
 	if apiError, ok := err.(*apierror.APIError); ok {
+		if apiError.Code.Status < http.StatusBadRequest {
+			// stores answer conditional requests with a 304 error, which isn't a failure
+			return successCode
+		}
 		return strconv.Itoa(apiError.Code.Status)
 	}
 	return "500"
@@ -14,5 +14,7 @@ func init() {
 		prometheus.MustRegister(ProxyTotalResponses)
 		prometheus.MustRegister(K8sClientResponseTime)
 		prometheus.MustRegister(ProxyStoreResponseTime)
+		prometheus.MustRegister(AuthenticationAttempts)
+		prometheus.MustRegister(RateLimitRejections)
 	}
 }
@@ -0,0 +1,200 @@
+package policy
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/google/cel-go/cel"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+)
+
+// RulesKey is the key of the ConfigMap entry holding the YAML encoded list of rules.
+const RulesKey = "rules"
+
+// Rule is a single CEL deny rule. The expression must evaluate to a bool; true means the access is denied.
+//
+// The following variables are available to the expression:
+//   - user: map with the keys name, uid, groups and extra
+//   - request: map with the keys verb (get, list, watch, create, update, delete), group, version, resource,
+//     namespace and name
+//   - object: the object content, an empty map when it is not known
+type Rule struct {
+	Name string `json:"name"`
+	// Verbs restricts the rule to the given verbs. An empty list matches all verbs.
+	Verbs []string `json:"verbs,omitempty"`
+	// Resources restricts the rule to the given resources, either as "resource" for the core group
+	// or as "group/resource". "*" and an empty list match all resources.
+	Resources  []string `json:"resources,omitempty"`
+	Expression string   `json:"expression"`
+	// Message is returned to the user when the rule denies a request.
+	Message string `json:"message,omitempty"`
+}
+
+type compiledRule struct {
+	Rule
+	program cel.Program
+}
+
+// CELPolicy is a Policy whose rules are CEL expressions. Rules can be replaced at runtime with Load.
+type CELPolicy struct {
+	env *cel.Env
+	// onLoad is called once new rules are loaded, it may be nil
+	onLoad func()
+
+	lock  sync.RWMutex
+	rules []compiledRule
+}
+
+// NewCELPolicy creates a CELPolicy with the given rules. onLoad, if not nil, is called every time rules are loaded,
+// for example to invalidate the responses cached by clients as the same objects may now be hidden or visible.
+func NewCELPolicy(rules []Rule, onLoad func()) (*CELPolicy, error) {
+	env, err := cel.NewEnv(
+		cel.Variable("user", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("request", cel.MapType(cel.StringType, cel.StringType)),
+		cel.Variable("object", cel.MapType(cel.StringType, cel.DynType)),
+	)
+	if err != nil {
+		return nil, err
+	}
+	p := &CELPolicy{env: env, onLoad: onLoad}
+	if err := p.Load(rules); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Load compiles rules and replaces the current rule set. The current rules are kept if any rule fails to compile.
+func (p *CELPolicy) Load(rules []Rule) error {
+	compiled := make([]compiledRule, 0, len(rules))
+	for _, rule := range rules {
+		ast, issues := p.env.Compile(rule.Expression)
+		if issues != nil && issues.Err() != nil {
+			return fmt.Errorf("compiling policy rule %q: %w", rule.Name, issues.Err())
+		}
+		if ast.OutputType() != cel.BoolType && ast.OutputType() != cel.DynType {
+			return fmt.Errorf("policy rule %q must evaluate to a bool, not %s", rule.Name, ast.OutputType())
+		}
+		program, err := p.env.Program(ast)
+		if err != nil {
+			return fmt.Errorf("building policy rule %q: %w", rule.Name, err)
+		}
+		compiled = append(compiled, compiledRule{Rule: rule, program: program})
+	}
+
+	p.lock.Lock()
+	p.rules = compiled
+	p.lock.Unlock()
+	if p.onLoad != nil {
+		p.onLoad()
+	}
+	return nil
+}
+
+// LoadYAML parses a YAML list of rules and loads them.
+func (p *CELPolicy) LoadYAML(data []byte) error {
+	var rules []Rule
+	if err := yaml.Unmarshal(data, &rules); err != nil {
+		return fmt.Errorf("parsing policy rules: %w", err)
+	}
+	return p.Load(rules)
+}
+
+// Evaluate implements Policy. A rule which fails to evaluate denies the access.
+func (p *CELPolicy) Evaluate(attrs *Attributes) Decision {
+	p.lock.RLock()
+	rules := p.rules
+	p.lock.RUnlock()
+
+	var vars map[string]interface{}
+	for _, rule := range rules {
+		if !rule.matches(attrs.GVR, attrs.Verb) {
+			continue
+		}
+		if vars == nil {
+			vars = activation(attrs)
+		}
+		out, _, err := rule.program.Eval(vars)
+		if err != nil {
+			logrus.Errorf("failed to evaluate policy rule %q: %v", rule.Name, err)
+			return Deny(fmt.Sprintf("rule %q could not be evaluated", rule.Name))
+		}
+		if denied, ok := out.Value().(bool); !ok || denied {
+			return Deny(rule.reason())
+		}
+	}
+	return Allow
+}
+
+// AppliesTo implements Scoped.
+func (p *CELPolicy) AppliesTo(gvr schema.GroupVersionResource, verb string) bool {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	for _, rule := range p.rules {
+		if rule.matches(gvr, verb) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *compiledRule) matches(gvr schema.GroupVersionResource, verb string) bool {
+	if len(r.Verbs) > 0 && !slices.Contains(r.Verbs, verb) {
+		return false
+	}
+	if len(r.Resources) == 0 {
+		return true
+	}
+	resource := gvr.Resource
+	if gvr.Group != "" {
+		resource = gvr.Group + "/" + resource
+	}
+	return slices.Contains(r.Resources, "*") || slices.Contains(r.Resources, resource)
+}
+
+func (r *compiledRule) reason() string {
+	if r.Message != "" {
+		return r.Message
+	}
+	return fmt.Sprintf("rule %q", r.Name)
+}
+
+func activation(attrs *Attributes) map[string]interface{} {
+	userVars := map[string]interface{}{
+		"name":   "",
+		"uid":    "",
+		"groups": []string{},
+		"extra":  map[string][]string{},
+	}
+	if attrs.User != nil {
+		userVars["name"] = attrs.User.GetName()
+		userVars["uid"] = attrs.User.GetUID()
+		if groups := attrs.User.GetGroups(); groups != nil {
+			userVars["groups"] = groups
+		}
+		if extra := attrs.User.GetExtra(); extra != nil {
+			userVars["extra"] = extra
+		}
+	}
+
+	obj := attrs.Object
+	if obj == nil {
+		obj = map[string]interface{}{}
+	}
+
+	return map[string]interface{}{
+		"user": userVars,
+		"request": map[string]string{
+			"verb":      attrs.Verb,
+			"group":     attrs.GVR.Group,
+			"version":   attrs.GVR.Version,
+			"resource":  attrs.GVR.Resource,
+			"namespace": attrs.Namespace,
+			"name":      attrs.Name,
+		},
+		"object": obj,
+	}
+}
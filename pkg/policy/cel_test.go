@@ -0,0 +1,145 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/authentication/user"
+)
+
+const helmReleaseRules = `
+- name: helm-releases
+  verbs: ["get", "list", "watch"]
+  resources: ["secrets"]
+  expression: >-
+    request.namespace == "cattle-system" &&
+    has(object.type) && object.type == "helm.sh/release.v1" &&
+    !("platform-admins" in user.groups)
+  message: helm releases are restricted to platform admins
+`
+
+func secretAttributes(verb string, groups []string, secretType string) *Attributes {
+	return &Attributes{
+		User:      &user.DefaultInfo{Name: "alice", Groups: groups},
+		Verb:      verb,
+		GVR:       schema.GroupVersionResource{Version: "v1", Resource: "secrets"},
+		Namespace: "cattle-system",
+		Name:      "sh.helm.release.v1.rancher.v1",
+		Object: map[string]interface{}{
+			"type": secretType,
+		},
+	}
+}
+
+func TestCELPolicy(t *testing.T) {
+	p, err := NewCELPolicy(nil, nil)
+	require.NoError(t, err)
+	require.NoError(t, p.LoadYAML([]byte(helmReleaseRules)))
+
+	tests := []struct {
+		name   string
+		attrs  *Attributes
+		denied bool
+	}{
+		{
+			name:   "helm release denied for regular user",
+			attrs:  secretAttributes("list", []string{"system:authenticated"}, "helm.sh/release.v1"),
+			denied: true,
+		},
+		{
+			name:  "helm release allowed for platform admins",
+			attrs: secretAttributes("get", []string{"platform-admins"}, "helm.sh/release.v1"),
+		},
+		{
+			name:  "other secret types are allowed",
+			attrs: secretAttributes("get", []string{"system:authenticated"}, "Opaque"),
+		},
+		{
+			name:  "verb not covered by the rule",
+			attrs: secretAttributes("delete", []string{"system:authenticated"}, "helm.sh/release.v1"),
+		},
+		{
+			name: "unknown object is not denied",
+			attrs: &Attributes{
+				Verb:      "get",
+				GVR:       schema.GroupVersionResource{Version: "v1", Resource: "secrets"},
+				Namespace: "cattle-system",
+			},
+		},
+		{
+			name: "resource not covered by the rule",
+			attrs: &Attributes{
+				Verb:      "get",
+				GVR:       schema.GroupVersionResource{Version: "v1", Resource: "configmaps"},
+				Namespace: "cattle-system",
+				Object:    map[string]interface{}{"type": "helm.sh/release.v1"},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			d := p.Evaluate(test.attrs)
+			assert.Equal(t, test.denied, d.Denied)
+			if test.denied {
+				assert.Equal(t, "helm releases are restricted to platform admins", d.Reason)
+			}
+		})
+	}
+}
+
+func TestCELPolicyLoadKeepsRulesOnError(t *testing.T) {
+	p, err := NewCELPolicy([]Rule{{Name: "deny-all", Expression: "true"}}, nil)
+	require.NoError(t, err)
+
+	err = p.Load([]Rule{{Name: "broken", Expression: "object.("}})
+	assert.Error(t, err)
+	assert.True(t, p.Evaluate(&Attributes{Verb: "get"}).Denied)
+
+	err = p.Load([]Rule{{Name: "not-bool", Expression: `"text"`}})
+	assert.Error(t, err)
+
+	require.NoError(t, p.Load(nil))
+	assert.False(t, p.Evaluate(&Attributes{Verb: "get"}).Denied)
+}
+
+func TestCELPolicyResources(t *testing.T) {
+	p, err := NewCELPolicy([]Rule{{Name: "deployments", Resources: []string{"apps/deployments"}, Expression: "true"}}, nil)
+	require.NoError(t, err)
+
+	assert.True(t, p.Evaluate(&Attributes{GVR: schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}}).Denied)
+	assert.False(t, p.Evaluate(&Attributes{GVR: schema.GroupVersionResource{Group: "extensions", Version: "v1", Resource: "deployments"}}).Denied)
+}
+
+func TestCELPolicyAppliesTo(t *testing.T) {
+	loads := 0
+	p, err := NewCELPolicy(nil, func() { loads++ })
+	require.NoError(t, err)
+	require.NoError(t, p.LoadYAML([]byte(helmReleaseRules)))
+	assert.Equal(t, 2, loads)
+
+	secrets := schema.GroupVersionResource{Version: "v1", Resource: "secrets"}
+	configMaps := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+	assert.True(t, AppliesTo(p, secrets, "list"))
+	assert.False(t, AppliesTo(p, secrets, "delete"))
+	assert.False(t, AppliesTo(p, configMaps, "list"))
+
+	allow := Func(func(*Attributes) Decision { return Allow })
+	assert.False(t, AppliesTo(nil, secrets, "list"))
+	assert.True(t, AppliesTo(allow, configMaps, "list"))
+	assert.False(t, AppliesTo(Union(nil, p), configMaps, "list"))
+	assert.True(t, AppliesTo(Union(p, allow), configMaps, "list"))
+}
+
+func TestUnion(t *testing.T) {
+	allow := Func(func(*Attributes) Decision { return Allow })
+	deny := Func(func(*Attributes) Decision { return Deny("no") })
+
+	assert.False(t, Union().Evaluate(&Attributes{}).Denied)
+	assert.False(t, Union(allow, nil).Evaluate(&Attributes{}).Denied)
+	assert.Equal(t, Deny("no"), Union(allow, deny).Evaluate(&Attributes{}))
+	assert.True(t, Allowed(nil, &Attributes{}))
+	assert.NoError(t, Check(nil, &Attributes{}))
+	assert.Error(t, Check(deny, &Attributes{Verb: "get"}))
+}
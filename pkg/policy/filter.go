@@ -0,0 +1,75 @@
+package policy
+
+import (
+	"github.com/rancher/apiserver/pkg/types"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// ObjectAttributes builds the Attributes of a request for verb on obj.
+func ObjectAttributes(apiOp *types.APIRequest, schema *types.APISchema, verb string, obj *unstructured.Unstructured) *Attributes {
+	return AttributesFor(apiOp, schema, verb, obj.GetNamespace(), obj.GetName(), obj.Object)
+}
+
+// AllowedObject returns whether pol allows verb on the object of schema with the given namespace and name. obj is the
+// object as stored by a cache, it may be nil if it isn't known.
+func AllowedObject(apiOp *types.APIRequest, schema *types.APISchema, pol Policy, verb, namespace, name string, obj runtime.Object) bool {
+	if pol == nil {
+		return true
+	}
+	var data map[string]interface{}
+	if unstr, ok := obj.(*unstructured.Unstructured); ok {
+		data = unstr.Object
+	} else if obj != nil {
+		var err error
+		if data, err = runtime.DefaultUnstructuredConverter.ToUnstructured(obj); err != nil {
+			return false
+		}
+	}
+	return Allowed(pol, AttributesFor(apiOp, schema, verb, namespace, name, data))
+}
+
+// FilterList returns the objects of items which pol allows for verb.
+func FilterList(apiOp *types.APIRequest, schema *types.APISchema, pol Policy, verb string, items []unstructured.Unstructured) []unstructured.Unstructured {
+	if pol == nil {
+		return items
+	}
+	result := items[:0]
+	for i := range items {
+		if Allowed(pol, ObjectAttributes(apiOp, schema, verb, &items[i])) {
+			result = append(result, items[i])
+		}
+	}
+	return result
+}
+
+// FilterWatch forwards the events of c for objects which pol allows watching.
+func FilterWatch(apiOp *types.APIRequest, schema *types.APISchema, pol Policy, c chan watch.Event) chan watch.Event {
+	if pol == nil {
+		return c
+	}
+	result := make(chan watch.Event)
+	go func() {
+		defer close(result)
+		for event := range c {
+			if event.Type != watch.Error {
+				m, err := meta.Accessor(event.Object)
+				if err != nil {
+					continue
+				}
+				var obj map[string]interface{}
+				if unstr, ok := event.Object.(*unstructured.Unstructured); ok {
+					obj = unstr.Object
+				}
+				attrs := AttributesFor(apiOp, schema, "watch", m.GetNamespace(), m.GetName(), obj)
+				if !Allowed(pol, attrs) {
+					continue
+				}
+			}
+			result <- event
+		}
+	}()
+	return result
+}
@@ -0,0 +1,149 @@
+// Package policy provides deny policies which are evaluated on top of RBAC. RBAC decides what a user may access;
+// a Policy can only take access away again, for example to hide a particular kind of object from everyone but a
+// set of administrators even when they hold a wildcard grant.
+package policy
+
+import (
+	"fmt"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/attributes"
+	"github.com/rancher/wrangler/v3/pkg/schemas/validation"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/authentication/user"
+)
+
+// Attributes describe a single access to an object which has already been allowed by RBAC.
+type Attributes struct {
+	User      user.Info
+	Verb      string
+	GVR       schema.GroupVersionResource
+	Namespace string
+	Name      string
+	// Object is the content of the object being accessed. It is nil when the object is not known,
+	// e.g. for a delete request before the object has been looked up.
+	Object map[string]interface{}
+}
+
+// Decision is the result of evaluating a Policy.
+type Decision struct {
+	Denied bool
+	Reason string
+}
+
+// Allow is the Decision returned when a policy has no objection.
+var Allow = Decision{}
+
+// Deny returns a Decision refusing access for the given reason.
+func Deny(reason string) Decision {
+	return Decision{Denied: true, Reason: reason}
+}
+
+// Policy vetoes individual objects or verbs that RBAC would otherwise allow.
+type Policy interface {
+	Evaluate(attrs *Attributes) Decision
+}
+
+// Scoped is implemented by policies which only deny some verbs on some resources, for callers to skip evaluating
+// them, and what that costs, for the others.
+type Scoped interface {
+	AppliesTo(gvr schema.GroupVersionResource, verb string) bool
+}
+
+// AppliesTo returns whether p may deny verb on gvr. Policies which don't implement Scoped may deny anything.
+func AppliesTo(p Policy, gvr schema.GroupVersionResource, verb string) bool {
+	if p == nil {
+		return false
+	}
+	if scoped, ok := p.(Scoped); ok {
+		return scoped.AppliesTo(gvr, verb)
+	}
+	return true
+}
+
+// Func adapts a plain function to the Policy interface.
+type Func func(attrs *Attributes) Decision
+
+// Evaluate implements Policy.
+func (f Func) Evaluate(attrs *Attributes) Decision {
+	return f(attrs)
+}
+
+// Union returns a Policy which denies access as soon as one of the given policies does.
+// Nil policies are skipped.
+func Union(policies ...Policy) Policy {
+	var result union
+	for _, p := range policies {
+		if p != nil {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+type union []Policy
+
+func (u union) Evaluate(attrs *Attributes) Decision {
+	for _, p := range u {
+		if d := p.Evaluate(attrs); d.Denied {
+			return d
+		}
+	}
+	return Allow
+}
+
+func (u union) AppliesTo(gvr schema.GroupVersionResource, verb string) bool {
+	for _, p := range u {
+		if AppliesTo(p, gvr, verb) {
+			return true
+		}
+	}
+	return false
+}
+
+// AttributesFor builds the Attributes of a request against schema. obj may be nil if the object is not known.
+func AttributesFor(apiOp *types.APIRequest, apiSchema *types.APISchema, verb, namespace, name string, obj map[string]interface{}) *Attributes {
+	attrs := &Attributes{
+		Verb:      verb,
+		GVR:       attributes.GVR(apiSchema),
+		Namespace: namespace,
+		Name:      name,
+		Object:    obj,
+	}
+	if apiOp != nil && apiOp.Request != nil {
+		if info, ok := apiOp.GetUserInfo(); ok {
+			attrs.User = info
+		}
+	}
+	return attrs
+}
+
+// Allowed is a convenience wrapper returning false when p is non-nil and denies attrs.
+func Allowed(p Policy, attrs *Attributes) bool {
+	return p == nil || !p.Evaluate(attrs).Denied
+}
+
+// Check evaluates p and converts a denial into a permission denied API error. A nil policy allows everything.
+func Check(p Policy, attrs *Attributes) error {
+	if p == nil {
+		return nil
+	}
+	d := p.Evaluate(attrs)
+	if !d.Denied {
+		return nil
+	}
+	return NewDeniedError(attrs, d.Reason)
+}
+
+// NewDeniedError returns the API error used when a policy refuses access.
+func NewDeniedError(attrs *Attributes, reason string) error {
+	msg := fmt.Sprintf("%s %s is denied by policy", attrs.Verb, attrs.GVR.Resource)
+	if attrs.Name != "" {
+		msg = fmt.Sprintf("%s %s %q is denied by policy", attrs.Verb, attrs.GVR.Resource, attrs.Name)
+	}
+	if reason != "" {
+		msg = msg + ": " + reason
+	}
+	return apierror.NewAPIError(validation.PermissionDenied, msg)
+}
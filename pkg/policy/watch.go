@@ -0,0 +1,45 @@
+package policy
+
+import (
+	"context"
+
+	v1 "github.com/rancher/wrangler/v3/pkg/generated/controllers/core/v1"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// WatchConfigMap keeps the rules of p in sync with the "rules" entry of the given ConfigMap.
+// Removing the ConfigMap removes all rules.
+func WatchConfigMap(ctx context.Context, controller v1.ConfigMapController, namespace, name string, p *CELPolicy) {
+	if namespace == "" || name == "" {
+		return
+	}
+	h := &configMapHandler{
+		namespace: namespace,
+		name:      name,
+		policy:    p,
+	}
+	controller.OnChange(ctx, "policy-configmap", h.OnConfigMap)
+}
+
+type configMapHandler struct {
+	namespace, name string
+	policy          *CELPolicy
+}
+
+func (h *configMapHandler) OnConfigMap(key string, cm *corev1.ConfigMap) (*corev1.ConfigMap, error) {
+	if key != h.namespace+"/"+h.name {
+		return cm, nil
+	}
+	if cm == nil || cm.DeletionTimestamp != nil {
+		logrus.Infof("policy configmap %s removed, clearing policy rules", key)
+		return cm, h.policy.Load(nil)
+	}
+	if err := h.policy.LoadYAML([]byte(cm.Data[RulesKey])); err != nil {
+		// keep the previous rules, a broken ConfigMap must not drop all restrictions
+		logrus.Errorf("failed to load policy rules from configmap %s/%s: %v", h.namespace, h.name, err)
+		return cm, nil
+	}
+	logrus.Infof("loaded policy rules from configmap %s/%s", h.namespace, h.name)
+	return cm, nil
+}
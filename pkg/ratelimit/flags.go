@@ -0,0 +1,48 @@
+package ratelimit
+
+import (
+	"strings"
+
+	"github.com/urfave/cli/v2"
+)
+
+func Flags(config *Config) []cli.Flag {
+	flags := []cli.Flag{
+		&cli.BoolFlag{
+			Name:        "rate-limit",
+			EnvVars:     []string{"RATE_LIMIT"},
+			Usage:       "Limit the rate and concurrency of list, watch and mutating requests of each user",
+			Destination: &config.Enabled,
+		},
+	}
+	flags = append(flags, budgetFlags(ClassList, &config.List, Budget{QPS: 10, Burst: 20, MaxInFlight: 10})...)
+	flags = append(flags, budgetFlags(ClassWatch, &config.Watch, Budget{QPS: 5, Burst: 20, MaxInFlight: 100})...)
+	return append(flags, budgetFlags(ClassMutate, &config.Mutate, Budget{QPS: 20, Burst: 40, MaxInFlight: 20})...)
+}
+
+func budgetFlags(class Class, budget *Budget, defaults Budget) []cli.Flag {
+	name := "rate-limit-" + string(class)
+	env := strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+	return []cli.Flag{
+		&cli.Float64Flag{
+			Name:        name + "-qps",
+			EnvVars:     []string{env + "_QPS"},
+			Usage:       "Sustained " + string(class) + " requests per second of each user, 0 for unlimited",
+			Value:       defaults.QPS,
+			Destination: &budget.QPS,
+		},
+		&cli.IntFlag{
+			Name:        name + "-burst",
+			EnvVars:     []string{env + "_BURST"},
+			Value:       defaults.Burst,
+			Destination: &budget.Burst,
+		},
+		&cli.IntFlag{
+			Name:        name + "-max-inflight",
+			EnvVars:     []string{env + "_MAX_INFLIGHT"},
+			Usage:       "Concurrent " + string(class) + " requests of each user, 0 for unlimited",
+			Value:       defaults.MaxInFlight,
+			Destination: &budget.MaxInFlight,
+		},
+	}
+}
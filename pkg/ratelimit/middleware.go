@@ -0,0 +1,110 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/rancher/steve/pkg/metrics"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apiserver/pkg/endpoints/request"
+)
+
+var longRunningSubresources = sets.NewString("exec", "attach", "portforward", "proxy")
+
+var proxyRequestInfo = &request.RequestInfoFactory{
+	APIPrefixes:          sets.NewString("api", "apis"),
+	GrouplessAPIPrefixes: sets.NewString("api"),
+}
+
+// WrapProxy returns a handler limiting requests proxied to the Kubernetes API. It must run after authentication.
+// It returns next if l is nil.
+func (l *Limiter) WrapProxy(next http.Handler) http.Handler {
+	if l == nil {
+		return next
+	}
+	return l.wrap(next, proxyClass)
+}
+
+// Admit accounts a request against the budget of class. If the request is over budget, a 429 response is
+// written and false is returned. Otherwise release must be called once the request is done.
+// A nil Limiter admits all requests.
+func (l *Limiter) Admit(rw http.ResponseWriter, req *http.Request, class Class) (release func(), ok bool) {
+	if l == nil {
+		return func() {}, true
+	}
+	release, retryAfter, reason := l.acquire(userName(req.Context()), class, true)
+	if release == nil {
+		metrics.IncRateLimitRejections(string(class), reason)
+		tooManyRequests(rw, class, reason, retryAfter)
+		return nil, false
+	}
+	return release, true
+}
+
+// Charge accounts an operation run as part of a request already admitted, such as one of a bulk request, against
+// the rate of class. The operation doesn't count against the in-flight limit. If it is over budget, a
+// TooManyRequests error is returned. A nil Limiter allows all operations.
+func (l *Limiter) Charge(ctx context.Context, class Class) error {
+	if l == nil {
+		return nil
+	}
+	release, retryAfter, reason := l.acquire(userName(ctx), class, false)
+	if release == nil {
+		metrics.IncRateLimitRejections(string(class), reason)
+		return k8serrors.NewTooManyRequests(tooManyRequestsMessage(class, reason), int(retryAfterSeconds(retryAfter)))
+	}
+	return nil
+}
+
+func userName(ctx context.Context) string {
+	if info, ok := request.UserFrom(ctx); ok {
+		return info.GetName()
+	}
+	return "system:unauthenticated"
+}
+
+// V1Class returns the budget of a /v1 request for the given type and name, or false if it is not limited.
+// The name must be empty for collections, including namespaced ones.
+func V1Class(req *http.Request, resourceType, name string) (Class, bool) {
+	if resourceType == "subscribe" || isWebsocket(req) {
+		return ClassWatch, true
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead:
+		if name == "" && req.URL.Query().Get("link") == "" {
+			return ClassList, true
+		}
+		return "", false
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return ClassMutate, true
+	default:
+		return "", false
+	}
+}
+
+func proxyClass(req *http.Request) (Class, bool) {
+	info, err := proxyRequestInfo.NewRequestInfo(req)
+	if err != nil || !info.IsResourceRequest {
+		return "", false
+	}
+	if longRunningSubresources.Has(info.Subresource) {
+		// exec, attach, port-forward and proxy streams are not API calls
+		return "", false
+	}
+	switch info.Verb {
+	case "list":
+		return ClassList, true
+	case "watch":
+		return ClassWatch, true
+	case "create", "update", "patch", "delete", "deletecollection":
+		return ClassMutate, true
+	default:
+		return "", false
+	}
+}
+
+func isWebsocket(req *http.Request) bool {
+	return strings.EqualFold(req.Header.Get("Upgrade"), "websocket")
+}
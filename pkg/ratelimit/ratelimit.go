@@ -0,0 +1,208 @@
+// Package ratelimit limits the rate and concurrency of /v1, /v1/subscribe and proxied Kubernetes requests
+// per authenticated user, with separate budgets for lists, watches and mutations.
+package ratelimit
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Class is the budget a request is accounted against.
+type Class string
+
+const (
+	ClassList   Class = "list"
+	ClassWatch  Class = "watch"
+	ClassMutate Class = "mutate"
+)
+
+const (
+	// idleTimeout is how long the limits of a user without requests are kept.
+	idleTimeout = 10 * time.Minute
+	// concurrencyRetryAfter is the Retry-After of requests rejected because of the in-flight limit.
+	concurrencyRetryAfter = time.Second
+)
+
+// Budget limits one class of requests of a single user. Zero values are unlimited.
+type Budget struct {
+	// QPS and Burst configure a token bucket for the start of requests.
+	QPS   float64
+	Burst int
+	// MaxInFlight limits the concurrent requests, for watches the number of open watches and websockets.
+	MaxInFlight int
+}
+
+func (b Budget) unlimited() bool {
+	return b.QPS <= 0 && b.MaxInFlight <= 0
+}
+
+// Config configures the limits applied to every user.
+type Config struct {
+	Enabled bool
+	List    Budget
+	Watch   Budget
+	Mutate  Budget
+}
+
+// Limiter enforces the budgets of a Config.
+type Limiter struct {
+	budgets map[Class]Budget
+	now     func() time.Time
+
+	lock      sync.Mutex
+	users     map[userClass]*userLimit
+	lastSweep time.Time
+}
+
+type userClass struct {
+	user  string
+	class Class
+}
+
+type userLimit struct {
+	rate     *rate.Limiter
+	inFlight int
+	lastSeen time.Time
+}
+
+// New creates a Limiter from config. It returns nil if rate limiting is disabled.
+func New(config Config) *Limiter {
+	if !config.Enabled {
+		return nil
+	}
+	return &Limiter{
+		budgets: map[Class]Budget{
+			ClassList:   config.List,
+			ClassWatch:  config.Watch,
+			ClassMutate: config.Mutate,
+		},
+		now:   time.Now,
+		users: map[userClass]*userLimit{},
+	}
+}
+
+// acquire accounts a request of user against the budget of class. If the request is allowed, release must be
+// called once it is done, otherwise the returned duration is how long the client should wait. Requests which aren't
+// concurrent only take a token from the rate limit, they run within a request already in flight.
+func (l *Limiter) acquire(user string, class Class, concurrent bool) (release func(), retryAfter time.Duration, reason string) {
+	budget := l.budgets[class]
+	if budget.unlimited() {
+		return func() {}, 0, ""
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	key := userClass{user: user, class: class}
+	limit, ok := l.users[key]
+	if !ok {
+		limit = &userLimit{}
+		if budget.QPS > 0 {
+			burst := budget.Burst
+			if burst <= 0 {
+				burst = int(math.Ceil(budget.QPS))
+			}
+			limit.rate = rate.NewLimiter(rate.Limit(budget.QPS), burst)
+		}
+		l.users[key] = limit
+	}
+	limit.lastSeen = now
+
+	if concurrent && budget.MaxInFlight > 0 && limit.inFlight >= budget.MaxInFlight {
+		return nil, concurrencyRetryAfter, "concurrency"
+	}
+	if limit.rate != nil {
+		reservation := limit.rate.ReserveN(now, 1)
+		if !reservation.OK() {
+			return nil, time.Second, "rate"
+		}
+		if delay := reservation.DelayFrom(now); delay > 0 {
+			reservation.CancelAt(now)
+			return nil, delay, "rate"
+		}
+	}
+
+	if !concurrent {
+		return func() {}, 0, ""
+	}
+	limit.inFlight++
+	return func() {
+		l.lock.Lock()
+		defer l.lock.Unlock()
+		limit.inFlight--
+		limit.lastSeen = l.now()
+	}, 0, ""
+}
+
+// sweep forgets users without requests in flight which have been idle long enough for their token bucket to be full.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < idleTimeout {
+		return
+	}
+	l.lastSweep = now
+	for key, limit := range l.users {
+		if limit.inFlight == 0 && now.Sub(limit.lastSeen) > idleTimeout {
+			delete(l.users, key)
+		}
+	}
+}
+
+func (l *Limiter) wrap(next http.Handler, classify func(*http.Request) (Class, bool)) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		class, limited := classify(req)
+		if !limited {
+			next.ServeHTTP(rw, req)
+			return
+		}
+
+		release, ok := l.Admit(rw, req, class)
+		if !ok {
+			return
+		}
+		defer release()
+		next.ServeHTTP(rw, req)
+	})
+}
+
+func retryAfterSeconds(retryAfter time.Duration) int32 {
+	return max(int32(math.Ceil(retryAfter.Seconds())), 1)
+}
+
+func tooManyRequestsMessage(class Class, reason string) string {
+	if reason == "concurrency" {
+		return "too many concurrent " + string(class) + " requests, please try again later"
+	}
+	return "too many " + string(class) + " requests, please try again later"
+}
+
+func tooManyRequests(rw http.ResponseWriter, class Class, reason string, retryAfter time.Duration) {
+	seconds := retryAfterSeconds(retryAfter)
+	message := tooManyRequestsMessage(class, reason)
+
+	rw.Header().Set("Retry-After", strconv.Itoa(int(seconds)))
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(http.StatusTooManyRequests)
+	_ = json.NewEncoder(rw).Encode(&metav1.Status{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Status",
+			APIVersion: "v1",
+		},
+		Status:  metav1.StatusFailure,
+		Message: message,
+		Reason:  metav1.StatusReasonTooManyRequests,
+		Details: &metav1.StatusDetails{
+			RetryAfterSeconds: seconds,
+		},
+		Code: http.StatusTooManyRequests,
+	})
+}
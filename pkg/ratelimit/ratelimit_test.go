@@ -0,0 +1,141 @@
+package ratelimit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/endpoints/request"
+)
+
+func serve(handler http.Handler, userName, method, target string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	req = req.WithContext(request.WithUser(req.Context(), &user.DefaultInfo{Name: userName}))
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, req)
+	return rw
+}
+
+func TestRateLimit(t *testing.T) {
+	now := time.Now()
+	l := New(Config{
+		Enabled: true,
+		List:    Budget{QPS: 1, Burst: 2},
+	})
+	l.now = func() time.Time { return now }
+	handler := l.WrapProxy(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+
+	assert.Equal(t, http.StatusOK, serve(handler, "alice", http.MethodGet, "/api/v1/pods").Code)
+	assert.Equal(t, http.StatusOK, serve(handler, "alice", http.MethodGet, "/api/v1/pods").Code)
+
+	rw := serve(handler, "alice", http.MethodGet, "/api/v1/pods")
+	assert.Equal(t, http.StatusTooManyRequests, rw.Code)
+	assert.Equal(t, "1", rw.Header().Get("Retry-After"))
+	status := &metav1.Status{}
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), status))
+	assert.Equal(t, metav1.StatusReasonTooManyRequests, status.Reason)
+	assert.Equal(t, int32(1), status.Details.RetryAfterSeconds)
+
+	// budgets are per user and per class
+	assert.Equal(t, http.StatusOK, serve(handler, "bob", http.MethodGet, "/api/v1/pods").Code)
+	assert.Equal(t, http.StatusOK, serve(handler, "alice", http.MethodGet, "/api/v1/namespaces/default/pods/web").Code)
+	assert.Equal(t, http.StatusOK, serve(handler, "alice", http.MethodDelete, "/api/v1/namespaces/default/pods/web").Code)
+
+	now = now.Add(time.Second)
+	assert.Equal(t, http.StatusOK, serve(handler, "alice", http.MethodGet, "/api/v1/pods").Code)
+}
+
+func TestMaxInFlight(t *testing.T) {
+	l := New(Config{
+		Enabled: true,
+		Watch:   Budget{MaxInFlight: 1},
+	})
+	started, done := make(chan struct{}), make(chan struct{})
+	handler := l.WrapProxy(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Query().Get("block") != "" {
+			close(started)
+			<-done
+		}
+	}))
+
+	go serve(handler, "alice", http.MethodGet, "/api/v1/pods?watch=true&block=true")
+	<-started
+
+	rw := serve(handler, "alice", http.MethodGet, "/api/v1/pods?watch=true")
+	assert.Equal(t, http.StatusTooManyRequests, rw.Code)
+	assert.Equal(t, "1", rw.Header().Get("Retry-After"))
+	assert.Equal(t, http.StatusOK, serve(handler, "bob", http.MethodGet, "/api/v1/pods?watch=true").Code)
+
+	close(done)
+	assert.Eventually(t, func() bool {
+		return serve(handler, "alice", http.MethodGet, "/api/v1/pods?watch=true").Code == http.StatusOK
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestV1Class(t *testing.T) {
+	tests := []struct {
+		method, target string
+		resourceType   string
+		name           string
+		websocket      bool
+		want           Class
+		wantNotLimited bool
+	}{
+		{method: http.MethodGet, target: "/v1/pods", resourceType: "pod", want: ClassList},
+		{method: http.MethodGet, target: "/v1/pods/default", resourceType: "pod", want: ClassList},
+		{method: http.MethodGet, target: "/v1/pods/default/web", resourceType: "pod", name: "web", wantNotLimited: true},
+		{method: http.MethodGet, target: "/v1/pods/default/web?link=log", resourceType: "pod", wantNotLimited: true},
+		{method: http.MethodGet, target: "/v1/subscribe", resourceType: "subscribe", websocket: true, want: ClassWatch},
+		{method: http.MethodPost, target: "/v1/pods", resourceType: "pod", want: ClassMutate},
+		{method: http.MethodDelete, target: "/v1/pods/default/web", resourceType: "pod", name: "web", want: ClassMutate},
+	}
+	for _, test := range tests {
+		t.Run(test.method+" "+test.target, func(t *testing.T) {
+			req := httptest.NewRequest(test.method, test.target, nil)
+			if test.websocket {
+				req.Header.Set("Upgrade", "websocket")
+			}
+			class, limited := V1Class(req, test.resourceType, test.name)
+			assert.Equal(t, !test.wantNotLimited, limited)
+			assert.Equal(t, test.want, class)
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	assert.Nil(t, New(Config{}))
+
+	var l *Limiter
+	release, ok := l.Admit(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/pods", nil), ClassList)
+	assert.True(t, ok)
+	release()
+}
+
+func TestCharge(t *testing.T) {
+	l := New(Config{
+		Enabled: true,
+		Mutate:  Budget{QPS: 0.001, Burst: 2, MaxInFlight: 1},
+	})
+	ctx := request.WithUser(context.Background(), &user.DefaultInfo{Name: "alice"})
+
+	// charged operations run within an admitted request, so they don't count against the in-flight limit
+	req := httptest.NewRequest(http.MethodPost, "/v1/configmaps?action=bulk", nil).WithContext(ctx)
+	release, ok := l.Admit(httptest.NewRecorder(), req, ClassMutate)
+	require.True(t, ok)
+	defer release()
+
+	assert.NoError(t, l.Charge(ctx, ClassMutate))
+	// but they take a token from the rate limit
+	err := l.Charge(ctx, ClassMutate)
+	assert.True(t, k8serrors.IsTooManyRequests(err))
+	assert.NoError(t, l.Charge(request.WithUser(context.Background(), &user.DefaultInfo{Name: "bob"}), ClassMutate))
+	assert.NoError(t, (*Limiter)(nil).Charge(ctx, ClassMutate))
+}
@@ -0,0 +1,337 @@
+// Package bulk adds the bulk collection action, which runs a list of create, update, patch and delete operations
+// against a single type in one request.
+package bulk
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/handlers"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/attributes"
+	"github.com/rancher/steve/pkg/ratelimit"
+	"github.com/rancher/steve/pkg/schema"
+	"github.com/rancher/wrangler/v3/pkg/kv"
+	"github.com/rancher/wrangler/v3/pkg/schemas"
+	"github.com/rancher/wrangler/v3/pkg/schemas/validation"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	apitypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	// MaxOperations is the maximum number of operations of a single bulk request.
+	MaxOperations = 500
+	// MaxBodyBytes is the maximum size of the body of a bulk request.
+	MaxBodyBytes = 16 << 20
+	// MaxParallelism is the maximum number of operations of a bulk request run concurrently.
+	MaxParallelism     = 16
+	defaultParallelism = 4
+)
+
+var mutatingVerbs = sets.NewString("create", "update", "patch", "delete")
+
+func Register(apiSchemas *types.APISchemas) {
+	apiSchemas.MustImportAndCustomize(&BulkInput{}, nil)
+	apiSchemas.MustImportAndCustomize(&BulkOutput{}, nil)
+}
+
+// Template adds the bulk action to every Kubernetes type which supports any mutating verb. Each operation is charged
+// against the mutate budget of rateLimiter, which may be nil.
+func Template(rateLimiter *ratelimit.Limiter) schema.Template {
+	return schema.Template{
+		Customize: func(apiSchema *types.APISchema) {
+			AddBulk(apiSchema, rateLimiter)
+		},
+	}
+}
+
+func AddBulk(apiSchema *types.APISchema, rateLimiter *ratelimit.Limiter) {
+	if attributes.GVK(apiSchema).Kind == "" || !mutatingVerbs.HasAny(attributes.Verbs(apiSchema)...) {
+		return
+	}
+	if apiSchema.ActionHandlers == nil {
+		apiSchema.ActionHandlers = map[string]http.Handler{}
+	}
+	apiSchema.ActionHandlers["bulk"] = &Bulk{RateLimiter: rateLimiter}
+
+	if apiSchema.CollectionActions == nil {
+		apiSchema.CollectionActions = map[string]schemas.Action{}
+	}
+	apiSchema.CollectionActions["bulk"] = schemas.Action{
+		Input:  "bulkInput",
+		Output: "bulkOutput",
+	}
+}
+
+// Bulk runs each operation of a bulk request as if it was a single request to the schema's store, using the
+// credentials of the caller.
+type Bulk struct {
+	// RateLimiter, if set, limits the rate of operations as if they were single requests.
+	RateLimiter *ratelimit.Limiter
+}
+
+func (b *Bulk) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	var (
+		apiContext = types.GetAPIContext(req.Context())
+		input      BulkInput
+	)
+
+	if err := json.NewDecoder(http.MaxBytesReader(rw, req.Body, MaxBodyBytes)).Decode(&input); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			apiContext.WriteError(apierror.NewAPIError(validation.MaxLimitExceeded,
+				fmt.Sprintf("the request body must be at most %d bytes", MaxBodyBytes)))
+			return
+		}
+		apiContext.WriteError(apierror.NewAPIError(validation.InvalidBodyContent, err.Error()))
+		return
+	}
+	if len(input.Operations) > MaxOperations {
+		apiContext.WriteError(apierror.NewAPIError(validation.MaxLimitExceeded,
+			fmt.Sprintf("at most %d operations are allowed", MaxOperations)))
+		return
+	}
+
+	output := b.Run(apiContext, input)
+	apiContext.WriteResponse(http.StatusOK, types.APIObject{
+		Type:   "bulkOutput",
+		Object: output,
+	})
+}
+
+// Run runs the operations of input with bounded parallelism and returns a result for each of them, in order.
+func (b *Bulk) Run(apiOp *types.APIRequest, input BulkInput) *BulkOutput {
+	parallelism := input.Parallelism
+	if parallelism <= 0 {
+		parallelism = defaultParallelism
+	}
+	parallelism = min(parallelism, MaxParallelism, max(len(input.Operations), 1))
+
+	var (
+		results = make([]BulkResult, len(input.Operations))
+		failed  atomic.Bool
+		indexes = make(chan int)
+		wg      sync.WaitGroup
+	)
+
+	for range parallelism {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				op := input.Operations[i]
+				if (input.StopOnError && failed.Load()) || apiOp.Context().Err() != nil {
+					results[i] = BulkResult{Index: i, Op: op.Op, ID: op.ID, Skipped: true}
+					continue
+				}
+				if err := b.RateLimiter.Charge(apiOp.Context(), ratelimit.ClassMutate); err != nil {
+					results[i] = withError(BulkResult{Index: i, Op: op.Op, ID: op.ID}, err)
+				} else {
+					results[i] = run(apiOp, i, op)
+				}
+				if results[i].Error != nil {
+					failed.Store(true)
+				}
+			}
+		}()
+	}
+	for i := range input.Operations {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	output := &BulkOutput{
+		Results: results,
+		DryRun:  len(apiOp.Request.URL.Query()["dryRun"]) > 0,
+	}
+	for _, result := range results {
+		switch {
+		case result.Skipped:
+			output.Skipped++
+		case result.Error != nil:
+			output.Failed++
+		default:
+			output.Succeeded++
+		}
+	}
+	return output
+}
+
+func run(apiOp *types.APIRequest, index int, op BulkOperation) BulkResult {
+	result := BulkResult{
+		Index: index,
+		Op:    op.Op,
+		ID:    op.ID,
+	}
+
+	opAPIOp, err := newOpRequest(apiOp, op)
+	if err != nil {
+		return withError(result, err)
+	}
+
+	var (
+		obj    types.APIObject
+		status = http.StatusOK
+	)
+	switch op.Op {
+	case OpCreate:
+		obj, err = handlers.CreateHandler(opAPIOp)
+		status = http.StatusCreated
+	case OpUpdate, OpPatch:
+		obj, err = handlers.UpdateHandler(opAPIOp)
+	case OpDelete:
+		obj, err = handlers.DeleteHandler(opAPIOp)
+	}
+
+	var code validation.ErrorCode
+	if errors.As(err, &code) && code.Status < http.StatusBadRequest {
+		// the object is gone, so it can't be returned
+		result.Status = code.Status
+		return result
+	}
+	if err != nil {
+		return withError(result, err)
+	}
+
+	result.Status = status
+	if obj.ID != "" {
+		result.ID = obj.ID
+	}
+	if obj.Object != nil {
+		result.Object = format(opAPIOp, obj)
+	}
+	return result
+}
+
+// newOpRequest returns a copy of apiOp as it would have been parsed for a single request performing op.
+func newOpRequest(apiOp *types.APIRequest, op BulkOperation) (*types.APIRequest, error) {
+	var (
+		method      string
+		body        interface{}
+		contentType = "application/json"
+		id          = op.ID
+	)
+
+	switch op.Op {
+	case OpCreate:
+		method, body = http.MethodPost, op.Object
+	case OpUpdate:
+		method, body = http.MethodPut, op.Object
+		if id == "" && op.Object != nil {
+			obj := types.APIObject{Object: op.Object}
+			id = obj.Name()
+			if ns := obj.Namespace(); ns != "" && attributes.Namespaced(apiOp.Schema) {
+				id = ns + "/" + id
+			}
+		}
+	case OpPatch:
+		method, body = http.MethodPatch, op.Patch
+		contentType = string(apitypes.StrategicMergePatchType)
+		if op.PatchType != "" {
+			contentType = op.PatchType
+		}
+	case OpDelete:
+		method = http.MethodDelete
+	default:
+		return nil, apierror.NewAPIError(validation.InvalidOption, fmt.Sprintf("invalid op %q, must be one of create, update, patch or delete", op.Op))
+	}
+
+	if op.Op == OpCreate {
+		if id != "" {
+			return nil, apierror.NewAPIError(validation.InvalidOption, "id is not allowed for create, set the name of the object instead")
+		}
+	} else if id == "" {
+		return nil, apierror.NewAPIError(validation.MissingRequired, "id is required for "+op.Op)
+	}
+	if body == nil && method != http.MethodDelete {
+		return nil, apierror.NewAPIError(validation.MissingRequired, "no object or patch given for "+op.Op)
+	}
+
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return nil, apierror.NewAPIError(validation.InvalidBodyContent, err.Error())
+		}
+	}
+
+	// keep query parameters such as dryRun, but drop the action of the bulk request
+	query := url.Values{}
+	for k, v := range apiOp.Request.URL.Query() {
+		if k != "action" {
+			query[k] = v
+		}
+	}
+	u := *apiOp.Request.URL
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(apiOp.Context(), method, u.String(), bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	result := apiOp.Clone()
+	result.Request = req
+	result.Method = method
+	result.Action = ""
+	result.Query = query
+	result.Name = ""
+	if id != "" {
+		if attributes.Namespaced(apiOp.Schema) {
+			ns, name := kv.RSplit(id, "/")
+			if ns != "" {
+				result.Namespace = ns
+			}
+			result.Name = name
+		} else {
+			result.Name = id
+		}
+	}
+	return result, nil
+}
+
+// format returns obj as it would have been written in response to a single request.
+func format(apiOp *types.APIRequest, obj types.APIObject) *types.RawResource {
+	resource := &types.RawResource{
+		ID:        obj.ID,
+		Type:      apiOp.Schema.ID,
+		Schema:    apiOp.Schema,
+		Links:     map[string]string{},
+		Actions:   map[string]string{},
+		APIObject: obj,
+	}
+	if apiOp.Schema.Formatter != nil {
+		apiOp.Schema.Formatter(apiOp, resource)
+	}
+	return resource
+}
+
+// withError sets the error of result the way the API would have reported it for a single request.
+func withError(result BulkResult, err error) BulkResult {
+	var (
+		apiError *apierror.APIError
+		status   k8serrors.APIStatus
+	)
+	switch {
+	case errors.As(err, &apiError):
+		result.Status = apiError.Code.Status
+		result.Error = &BulkError{Code: apiError.Code.Code, Message: apiError.Message}
+	case errors.As(err, &status):
+		result.Status = int(status.Status().Code)
+		result.Error = &BulkError{Code: string(status.Status().Reason), Message: status.Status().Message}
+	default:
+		result.Status = validation.ServerError.Status
+		result.Error = &BulkError{Code: validation.ServerError.Code, Message: err.Error()}
+	}
+	return result
+}
@@ -0,0 +1,251 @@
+package bulk
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/server"
+	"github.com/rancher/apiserver/pkg/store/empty"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/attributes"
+	"github.com/rancher/steve/pkg/ratelimit"
+	"github.com/rancher/wrangler/v3/pkg/schemas"
+	"github.com/rancher/wrangler/v3/pkg/schemas/validation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+type call struct {
+	method, namespace, name, contentType, body string
+}
+
+type fakeStore struct {
+	empty.Store
+
+	lock  sync.Mutex
+	calls []call
+}
+
+func (f *fakeStore) record(apiOp *types.APIRequest, id string) error {
+	body, _ := io.ReadAll(apiOp.Request.Body)
+	f.lock.Lock()
+	f.calls = append(f.calls, call{
+		method:      apiOp.Method,
+		namespace:   apiOp.Namespace,
+		name:        id,
+		contentType: apiOp.Request.Header.Get("Content-Type"),
+		body:        string(body),
+	})
+	f.lock.Unlock()
+	if id == "missing" {
+		return k8serrors.NewNotFound(schema.GroupResource{Resource: "configmaps"}, id)
+	}
+	return nil
+}
+
+func (f *fakeStore) Create(apiOp *types.APIRequest, schema *types.APISchema, data types.APIObject) (types.APIObject, error) {
+	if err := f.record(apiOp, data.Name()); err != nil {
+		return types.APIObject{}, err
+	}
+	return types.APIObject{Type: schema.ID, ID: data.Namespace() + "/" + data.Name(), Object: data.Object}, nil
+}
+
+func (f *fakeStore) Update(apiOp *types.APIRequest, schema *types.APISchema, data types.APIObject, id string) (types.APIObject, error) {
+	if err := f.record(apiOp, id); err != nil {
+		return types.APIObject{}, err
+	}
+	return types.APIObject{Type: schema.ID, ID: apiOp.Namespace + "/" + id, Object: map[string]interface{}{"updated": true}}, nil
+}
+
+func (f *fakeStore) Delete(apiOp *types.APIRequest, schema *types.APISchema, id string) (types.APIObject, error) {
+	if err := f.record(apiOp, id); err != nil {
+		return types.APIObject{}, err
+	}
+	return types.APIObject{}, validation.ErrorCode{Status: http.StatusNoContent}
+}
+
+func newAPIOp(store types.Store) *types.APIRequest {
+	apiSchema := &types.APISchema{
+		Schema: &schemas.Schema{
+			ID:                "configmap",
+			CollectionMethods: []string{http.MethodGet, http.MethodPost},
+			ResourceMethods:   []string{http.MethodGet, http.MethodPut, http.MethodPatch, http.MethodDelete},
+			Attributes:        map[string]interface{}{},
+		},
+		Store: store,
+	}
+	attributes.SetNamespaced(apiSchema, true)
+	attributes.SetGVK(apiSchema, schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"})
+	attributes.SetVerbs(apiSchema, []string{"get", "list", "create", "update", "patch", "delete"})
+	AddBulk(apiSchema, nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/configmaps/default?action=bulk&dryRun=All", nil)
+	return &types.APIRequest{
+		Request:       req,
+		Method:        http.MethodPost,
+		Type:          apiSchema.ID,
+		Schema:        apiSchema,
+		Namespace:     "default",
+		Action:        "bulk",
+		AccessControl: &server.SchemaBasedAccess{},
+	}
+}
+
+func TestRun(t *testing.T) {
+	store := &fakeStore{}
+	apiOp := newAPIOp(store)
+	require.Contains(t, apiOp.Schema.CollectionActions, "bulk")
+
+	output := (&Bulk{}).Run(apiOp, BulkInput{
+		Operations: []BulkOperation{
+			{Op: OpCreate, Object: map[string]interface{}{"metadata": map[string]interface{}{"name": "a"}}},
+			{Op: OpUpdate, Object: map[string]interface{}{"metadata": map[string]interface{}{"name": "b", "namespace": "other"}}},
+			{Op: OpPatch, ID: "c", Patch: []interface{}{map[string]interface{}{"op": "remove", "path": "/data/x"}}, PatchType: "application/json-patch+json"},
+			{Op: OpDelete, ID: "other/d"},
+			{Op: OpDelete, ID: "missing"},
+			{Op: "replace", ID: "e"},
+			{Op: OpPatch, ID: "f"},
+		},
+	})
+
+	assert.Equal(t, 4, output.Succeeded)
+	assert.Equal(t, 3, output.Failed)
+	assert.Equal(t, 0, output.Skipped)
+	assert.True(t, output.DryRun)
+	require.Len(t, output.Results, 7)
+
+	assert.Equal(t, http.StatusCreated, output.Results[0].Status)
+	assert.Equal(t, "/a", output.Results[0].ID)
+	assert.NotNil(t, output.Results[0].Object)
+	assert.Equal(t, http.StatusOK, output.Results[1].Status)
+	assert.Equal(t, "other/b", output.Results[1].ID)
+	assert.Equal(t, http.StatusOK, output.Results[2].Status)
+	assert.Equal(t, http.StatusNoContent, output.Results[3].Status)
+	assert.Nil(t, output.Results[3].Object)
+
+	assert.Equal(t, http.StatusNotFound, output.Results[4].Status)
+	assert.Equal(t, &BulkError{Code: "NotFound", Message: `configmaps "missing" not found`}, output.Results[4].Error)
+	assert.Equal(t, http.StatusUnprocessableEntity, output.Results[5].Status)
+	assert.Equal(t, "InvalidOption", output.Results[5].Error.Code)
+	assert.Equal(t, "MissingRequired", output.Results[6].Error.Code)
+
+	calls := map[string]call{}
+	for _, c := range store.calls {
+		calls[c.name] = c
+	}
+	assert.Len(t, calls, 5)
+	assert.Equal(t, call{method: http.MethodPost, namespace: "default", name: "a", contentType: "application/json"}, calls["a"])
+	assert.Equal(t, http.MethodPut, calls["b"].method)
+	assert.Equal(t, "other", calls["b"].namespace)
+	assert.Equal(t, call{method: http.MethodPatch, namespace: "default", name: "c", contentType: "application/json-patch+json",
+		body: `[{"op":"remove","path":"/data/x"}]`}, calls["c"])
+	assert.Equal(t, http.MethodDelete, calls["d"].method)
+	assert.Equal(t, "other", calls["d"].namespace)
+}
+
+func TestRunStopOnError(t *testing.T) {
+	store := &fakeStore{}
+	apiOp := newAPIOp(store)
+
+	var operations []BulkOperation
+	for i := range 10 {
+		id := fmt.Sprintf("cm-%d", i)
+		if i == 3 {
+			id = "missing"
+		}
+		operations = append(operations, BulkOperation{Op: OpDelete, ID: id})
+	}
+	output := (&Bulk{}).Run(apiOp, BulkInput{
+		Operations:  operations,
+		StopOnError: true,
+		Parallelism: 1,
+	})
+
+	assert.Equal(t, 3, output.Succeeded)
+	assert.Equal(t, 1, output.Failed)
+	assert.Equal(t, 6, output.Skipped)
+	assert.True(t, output.Results[9].Skipped)
+	assert.Equal(t, "cm-9", output.Results[9].ID)
+	assert.Len(t, store.calls, 4)
+}
+
+func TestRegister(t *testing.T) {
+	apiSchemas := types.EmptyAPISchemas()
+	Register(apiSchemas)
+	assert.NotNil(t, apiSchemas.LookupSchema("bulkInput"))
+	assert.NotNil(t, apiSchemas.LookupSchema("bulkOutput"))
+
+	apiSchema := &types.APISchema{Schema: &schemas.Schema{ID: "apigroup", Attributes: map[string]interface{}{}}}
+	AddBulk(apiSchema, nil)
+	assert.Empty(t, apiSchema.ActionHandlers)
+}
+
+func TestRunRateLimit(t *testing.T) {
+	store := &fakeStore{}
+	apiOp := newAPIOp(store)
+	rateLimiter := ratelimit.New(ratelimit.Config{
+		Enabled: true,
+		Mutate:  ratelimit.Budget{QPS: 0.001, Burst: 2},
+	})
+
+	var operations []BulkOperation
+	for i := range 4 {
+		operations = append(operations, BulkOperation{Op: OpDelete, ID: fmt.Sprintf("cm-%d", i)})
+	}
+	output := (&Bulk{RateLimiter: rateLimiter}).Run(apiOp, BulkInput{
+		Operations:  operations,
+		Parallelism: 1,
+	})
+
+	assert.Equal(t, 2, output.Succeeded)
+	assert.Equal(t, 2, output.Failed)
+	assert.Equal(t, http.StatusTooManyRequests, output.Results[3].Status)
+	require.NotNil(t, output.Results[3].Error)
+	assert.Equal(t, "TooManyRequests", output.Results[3].Error.Code)
+	assert.Len(t, store.calls, 2)
+}
+
+func TestServeHTTPLimits(t *testing.T) {
+	var operations []BulkOperation
+	for i := range MaxOperations + 1 {
+		operations = append(operations, BulkOperation{Op: OpDelete, ID: fmt.Sprintf("cm-%d", i)})
+	}
+	tooMany, err := json.Marshal(BulkInput{Operations: operations})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{name: "too many operations", body: string(tooMany)},
+		{name: "body too large", body: `{"operations": [` + strings.Repeat(" ", MaxBodyBytes) + `]}`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := &fakeStore{}
+			apiOp := newAPIOp(store)
+			apiOp.Request = httptest.NewRequest(http.MethodPost, "/v1/configmaps/default?action=bulk", strings.NewReader(test.body))
+			var writeErr error
+			apiOp.ErrorHandler = func(_ *types.APIRequest, err error) {
+				writeErr = err
+			}
+			types.StoreAPIContext(apiOp)
+
+			(&Bulk{}).ServeHTTP(httptest.NewRecorder(), apiOp.Request)
+
+			var apiError *apierror.APIError
+			require.ErrorAs(t, writeErr, &apiError)
+			assert.Equal(t, validation.MaxLimitExceeded, apiError.Code)
+			assert.Empty(t, store.calls)
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package bulk
+
+const (
+	OpCreate = "create"
+	OpUpdate = "update"
+	OpPatch  = "patch"
+	OpDelete = "delete"
+)
+
+type BulkInput struct {
+	Operations []BulkOperation `json:"operations,omitempty"`
+	// StopOnError skips the operations which have not been started yet once an operation fails.
+	StopOnError bool `json:"stopOnError,omitempty"`
+	// Parallelism is the number of operations run concurrently, bounded by the server.
+	Parallelism int `json:"parallelism,omitempty"`
+}
+
+type BulkOperation struct {
+	// Op is one of create, update, patch or delete.
+	Op string `json:"op,omitempty"`
+	// ID is the name, or namespace/name of namespaced objects. It is required for patch and delete and
+	// defaults to the name of the object for update.
+	ID string `json:"id,omitempty"`
+	// Object is the object to create or update.
+	Object map[string]interface{} `json:"object,omitempty"`
+	// Patch is the patch body, a JSON patch if PatchType is application/json-patch+json.
+	Patch interface{} `json:"patch,omitempty"`
+	// PatchType is the content type of the patch, a strategic merge patch by default.
+	PatchType string `json:"patchType,omitempty"`
+}
+
+type BulkOutput struct {
+	Results   []BulkResult `json:"results,omitempty"`
+	Succeeded int          `json:"succeeded"`
+	Failed    int          `json:"failed"`
+	Skipped   int          `json:"skipped"`
+	// DryRun is set if the operations were only validated and nothing was persisted.
+	DryRun bool `json:"dryRun,omitempty"`
+}
+
+type BulkResult struct {
+	Index int    `json:"index"`
+	Op    string `json:"op,omitempty"`
+	ID    string `json:"id,omitempty"`
+	// Status is the HTTP status code the operation would have had as a single request, 0 if it was skipped.
+	Status  int         `json:"status"`
+	Skipped bool        `json:"skipped,omitempty"`
+	Object  interface{} `json:"object,omitempty"`
+	Error   *BulkError  `json:"error,omitempty"`
+}
+
+type BulkError struct {
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
@@ -23,6 +23,14 @@ import (
 type Apply struct {
 	cg            proxy.ClientGetter
 	schemaFactory steveschema.Factory
+	// inSQLMode is set when the objects are listed from the SQL cache, for prune to filter them by label
+	inSQLMode bool
+}
+
+func (a *Apply) withSQLMode(inSQLMode bool) *Apply {
+	result := *a
+	result.inSQLMode = inSQLMode
+	return &result
 }
 
 func (a *Apply) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
@@ -42,6 +50,19 @@ func (a *Apply) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	if input.Preview || input.DryRun || input.Prune || input.SetID != "" {
+		output, err := a.applyEach(apiContext, input, objs)
+		if err != nil {
+			apiContext.WriteError(err)
+			return
+		}
+		apiContext.WriteResponse(http.StatusOK, types.APIObject{
+			Type:   "applyOutput",
+			Object: output,
+		})
+		return
+	}
+
 	apply, err := a.createApply(apiContext)
 	if err != nil {
 		apiContext.WriteError(err)
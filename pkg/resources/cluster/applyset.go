@@ -0,0 +1,431 @@
+package cluster
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/handlers"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/attributes"
+	"github.com/rancher/steve/pkg/resources/diff"
+	"github.com/rancher/wrangler/v3/pkg/schemas/validation"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	k8svalidation "k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	// FieldManager is the field manager of the objects applied one by one.
+	FieldManager = "steve-apply"
+	// SetIDLabel is the label of the objects applied with a set ID, which prune uses to find the objects applied
+	// before.
+	SetIDLabel = "steve.cattle.io/apply-set-id"
+	// SetParentLabel is the label of the ConfigMap recording the types of a set, set to the set ID.
+	SetParentLabel = "steve.cattle.io/apply-set-parent"
+	// SetGroupKindsAnnotation lists, on the ConfigMap of a set, the group kinds the set has objects of, like the
+	// applyset.kubernetes.io/contains-group-kinds annotation of kubectl apply sets.
+	SetGroupKindsAnnotation = "steve.cattle.io/apply-set-group-kinds"
+
+	setParentPrefix = "steve-apply-set-"
+)
+
+// applyEach applies objs one by one with server-side apply as the caller, and returns the result of each of them
+// rather than failing the whole request. With Prune, the objects of the set which are not part of objs are deleted.
+func (a *Apply) applyEach(apiOp *types.APIRequest, input ApplyInput, objs []runtime.Object) (*ApplyOutput, error) {
+	if input.Prune && input.SetID == "" {
+		return nil, apierror.NewAPIError(validation.MissingRequired, "setID is required to prune")
+	}
+	if input.SetID != "" {
+		if errs := k8svalidation.IsValidLabelValue(input.SetID); len(errs) > 0 {
+			return nil, apierror.NewAPIError(validation.InvalidFormat, "invalid setID: "+strings.Join(errs, ", "))
+		}
+	}
+
+	var (
+		dryRun      = input.Preview || input.DryRun
+		output      = &ApplyOutput{DryRun: dryRun}
+		applied     = map[string]bool{}
+		seenSchemas []*types.APISchema
+		parent      *setParent
+	)
+	if input.SetID != "" {
+		var err error
+		if parent, err = loadSetParent(apiOp, input); err != nil {
+			return nil, err
+		}
+	}
+
+	failed := false
+	for _, obj := range objs {
+		result, apiSchema := a.applyObject(apiOp, input, obj, dryRun)
+		output.Results = append(output.Results, result)
+		failed = failed || result.Error != nil
+		if apiSchema == nil {
+			continue
+		}
+		// objects which failed to apply are still part of the set, and mustn't be pruned
+		applied[result.ResourceType+"/"+result.ID] = true
+		if !containsSchema(seenSchemas, apiSchema) {
+			seenSchemas = append(seenSchemas, apiSchema)
+		}
+	}
+
+	if parent != nil && !dryRun {
+		// the types are recorded before pruning, so that the objects of a type dropped from the set are still pruned
+		// by a later apply if this one fails
+		if result, ok := parent.save(apiOp, parent.groupKinds.Union(groupKinds(seenSchemas))); !ok {
+			output.Results = append(output.Results, result)
+		}
+	}
+
+	// like kubectl apply --prune, nothing is pruned if an object failed, as the objects of a type which couldn't be
+	// resolved, or an object whose ID couldn't be read, would be pruned as though they had been removed from the set
+	output.PruneSkipped = input.Prune && failed
+	if input.Prune && !failed {
+		pruneSchemas := seenSchemas
+		for _, groupKind := range sets.List(parent.groupKinds) {
+			if apiSchema := schemaForGroupKind(apiOp, groupKind); apiSchema != nil && !containsSchema(pruneSchemas, apiSchema) {
+				pruneSchemas = append(pruneSchemas, apiSchema)
+			}
+		}
+
+		pruned := true
+		for _, apiSchema := range pruneSchemas {
+			results := a.prune(apiOp, input, apiSchema, applied, dryRun)
+			for _, result := range results {
+				pruned = pruned && result.Error == nil
+			}
+			output.Results = append(output.Results, results...)
+		}
+		if pruned && !dryRun {
+			if result, ok := parent.save(apiOp, groupKinds(seenSchemas)); !ok {
+				output.Results = append(output.Results, result)
+			}
+		}
+	}
+
+	for _, result := range output.Results {
+		switch {
+		case result.Error != nil:
+			output.Failed++
+		case result.Action == ActionCreate:
+			output.Created++
+		case result.Action == ActionUpdate:
+			output.Updated++
+		case result.Action == ActionUnchanged:
+			output.Unchanged++
+		case result.Action == ActionDelete:
+			output.Deleted++
+		}
+	}
+	return output, nil
+}
+
+// applyObject applies obj, and returns its result and schema, if it has one.
+func (a *Apply) applyObject(apiOp *types.APIRequest, input ApplyInput, obj runtime.Object, dryRun bool) (ApplyResult, *types.APISchema) {
+	data, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return withError(ApplyResult{}, err), nil
+	}
+	u := &unstructured.Unstructured{Object: data}
+	result := ApplyResult{ID: u.GetName()}
+
+	gvk := u.GroupVersionKind()
+	apiSchema := apiOp.Schemas.LookupSchema(a.schemaFactory.ByGVK(gvk))
+	if apiSchema == nil || apiSchema.Store == nil {
+		return withError(result, apierror.NewAPIError(validation.NotFound, fmt.Sprintf("no type found for %s", gvk))), nil
+	}
+	result.ResourceType = apiSchema.ID
+
+	namespace := ""
+	if attributes.Namespaced(apiSchema) {
+		namespace = u.GetNamespace()
+		if namespace == "" {
+			namespace = input.DefaultNamespace
+		}
+		if namespace == "" {
+			namespace = "default"
+		}
+		result.ID = namespace + "/" + u.GetName()
+	}
+	if input.SetID != "" {
+		labels := u.GetLabels()
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		labels[SetIDLabel] = input.SetID
+		u.SetLabels(labels)
+	}
+
+	objOp := objectRequest(apiOp, apiSchema, namespace, u.GetName())
+	live, err := handlers.ByIDHandler(objOp)
+	exists := err == nil
+	if err != nil && !isNotFound(err) {
+		return withError(result, err), apiSchema
+	}
+
+	applyOp, err := diff.ApplyRequest(objOp, diff.Proposed(objOp, u.Object), diff.ApplyOptions{
+		FieldManager: FieldManager,
+		Force:        true,
+		DryRun:       dryRun,
+	})
+	if err != nil {
+		return withError(result, err), apiSchema
+	}
+	resp, err := handlers.UpdateHandler(applyOp)
+	if err != nil {
+		return withError(result, err), apiSchema
+	}
+
+	liveData := map[string]interface{}{}
+	if exists {
+		liveData = diff.Formatted(objOp, live)
+	}
+	changes, err := diff.Compare(liveData, diff.Formatted(objOp, resp))
+	if err != nil {
+		return withError(result, err), apiSchema
+	}
+
+	switch {
+	case !exists:
+		result.Action = ActionCreate
+	case len(changes.Changes) == 0:
+		result.Action = ActionUnchanged
+	default:
+		result.Action = ActionUpdate
+	}
+	if input.Preview {
+		result.Changes = changes.Changes
+		result.Diff = changes.Diff
+	} else {
+		result.Object = resp.Object
+	}
+	return result, apiSchema
+}
+
+// prune deletes the objects of apiSchema labeled with the set ID of input which were not applied.
+func (a *Apply) prune(apiOp *types.APIRequest, input ApplyInput, apiSchema *types.APISchema, applied map[string]bool, dryRun bool) []ApplyResult {
+	list, err := a.listSet(apiOp, input, apiSchema)
+	if err != nil {
+		return []ApplyResult{withError(ApplyResult{ResourceType: apiSchema.ID}, err)}
+	}
+
+	var results []ApplyResult
+	for _, obj := range list {
+		// stores which don't support label selectors list every object
+		if obj.Data().String("metadata", "labels", SetIDLabel) != input.SetID || applied[apiSchema.ID+"/"+obj.ID] {
+			continue
+		}
+		result := ApplyResult{ResourceType: apiSchema.ID, ID: obj.ID, Action: ActionDelete}
+		if !input.Preview {
+			deleteOp := objectRequest(apiOp, apiSchema, obj.Namespace(), obj.Name())
+			deleteOp.Method = http.MethodDelete
+			if dryRun {
+				deleteOp.Query = url.Values{"dryRun": []string{"All"}}
+				deleteOp.Request.URL.RawQuery = deleteOp.Query.Encode()
+			}
+			var code validation.ErrorCode
+			if _, err := handlers.DeleteHandler(deleteOp); err != nil && !(errors.As(err, &code) && code.Status < http.StatusBadRequest) {
+				result = withError(ApplyResult{ResourceType: apiSchema.ID, ID: obj.ID}, err)
+			}
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// listSet lists the objects of apiSchema labeled with the set ID of input, following the continue tokens of the
+// store until the last page. The SQL cache selects the label with a filter, the proxy store passes the label selector
+// to Kubernetes.
+func (a *Apply) listSet(apiOp *types.APIRequest, input ApplyInput, apiSchema *types.APISchema) ([]types.APIObject, error) {
+	query := url.Values{}
+	if a.inSQLMode {
+		query.Set("filter", "metadata.labels["+SetIDLabel+"]="+input.SetID)
+	} else {
+		query.Set("labelSelector", SetIDLabel+"="+input.SetID)
+	}
+
+	var result []types.APIObject
+	for {
+		listOp := objectRequest(apiOp, apiSchema, "", "")
+		listOp.Method = http.MethodGet
+		listOp.Query = query
+		listOp.Request.URL.RawQuery = query.Encode()
+
+		list, err := handlers.ListHandler(listOp)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, list.Objects...)
+		if list.Continue == "" || list.Continue == query.Get("continue") {
+			return result, nil
+		}
+		query = maps.Clone(query)
+		query.Set("continue", list.Continue)
+	}
+}
+
+// setParent is the ConfigMap recording the types of the objects of a set, so that prune still visits the types which
+// are no longer part of the input.
+type setParent struct {
+	setID, namespace, name string
+	exists                 bool
+	groupKinds             sets.Set[string]
+}
+
+// loadSetParent reads the types of the set of input. The ConfigMap is named after the set ID, in the default
+// namespace of input.
+func loadSetParent(apiOp *types.APIRequest, input ApplyInput) (*setParent, error) {
+	parent := &setParent{
+		setID:      input.SetID,
+		namespace:  input.DefaultNamespace,
+		name:       setParentName(input.SetID),
+		groupKinds: sets.New[string](),
+	}
+	if parent.namespace == "" {
+		parent.namespace = "default"
+	}
+
+	apiSchema := apiOp.Schemas.LookupSchema("configmap")
+	if apiSchema == nil || apiSchema.Store == nil {
+		return nil, apierror.NewAPIError(validation.PermissionDenied, "apply sets are recorded in a ConfigMap, which the user can't access")
+	}
+	live, err := handlers.ByIDHandler(objectRequest(apiOp, apiSchema, parent.namespace, parent.name))
+	if isNotFound(err) {
+		return parent, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	parent.exists = true
+	for _, groupKind := range strings.Split(live.Data().String("metadata", "annotations", SetGroupKindsAnnotation), ",") {
+		if groupKind != "" {
+			parent.groupKinds.Insert(groupKind)
+		}
+	}
+	return parent, nil
+}
+
+// save records groupKinds as the types of the set, unless they already are. It returns a failed result if recording
+// failed.
+func (p *setParent) save(apiOp *types.APIRequest, groupKinds sets.Set[string]) (ApplyResult, bool) {
+	result := ApplyResult{ResourceType: "configmap", ID: p.namespace + "/" + p.name}
+	if p.exists && p.groupKinds.Equal(groupKinds) {
+		return result, true
+	}
+
+	obj := map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata": map[string]interface{}{
+			"name":        p.name,
+			"namespace":   p.namespace,
+			"labels":      map[string]interface{}{SetParentLabel: p.setID},
+			"annotations": map[string]interface{}{SetGroupKindsAnnotation: strings.Join(sets.List(groupKinds), ",")},
+		},
+	}
+	objOp := objectRequest(apiOp, apiOp.Schemas.LookupSchema("configmap"), p.namespace, p.name)
+	applyOp, err := diff.ApplyRequest(objOp, obj, diff.ApplyOptions{
+		FieldManager: FieldManager,
+		Force:        true,
+	})
+	if err == nil {
+		_, err = handlers.UpdateHandler(applyOp)
+	}
+	if err != nil {
+		return withError(result, err), false
+	}
+	p.exists, p.groupKinds = true, groupKinds
+	return result, true
+}
+
+// setParentName returns the name of the ConfigMap of a set. Set IDs are label values, which aren't all valid names.
+func setParentName(setID string) string {
+	sum := sha256.Sum256([]byte(setID))
+	return setParentPrefix + hex.EncodeToString(sum[:8])
+}
+
+func groupKinds(apiSchemas []*types.APISchema) sets.Set[string] {
+	result := sets.New[string]()
+	for _, apiSchema := range apiSchemas {
+		result.Insert(attributes.GVK(apiSchema).GroupKind().String())
+	}
+	return result
+}
+
+// schemaForGroupKind returns the schema of the given group kind, as recorded by a set, or nil if the user has none.
+func schemaForGroupKind(apiOp *types.APIRequest, groupKind string) *types.APISchema {
+	gk := schema.ParseGroupKind(groupKind)
+	var result *types.APISchema
+	for _, apiSchema := range apiOp.Schemas.Schemas {
+		if apiSchema.Store == nil || attributes.GVK(apiSchema).GroupKind() != gk {
+			continue
+		}
+		if result == nil || apiSchema.ID < result.ID {
+			result = apiSchema
+		}
+	}
+	return result
+}
+
+// objectRequest returns a copy of apiOp for the object of apiSchema with the given namespace and name, without the
+// query parameters of apiOp.
+func objectRequest(apiOp *types.APIRequest, apiSchema *types.APISchema, namespace, name string) *types.APIRequest {
+	u := *apiOp.Request.URL
+	u.RawQuery = ""
+
+	result := apiOp.Clone()
+	result.Request = apiOp.Request.Clone(apiOp.Context())
+	result.Request.URL = &u
+	result.Schema = apiSchema
+	result.Type = apiSchema.ID
+	result.Namespace = namespace
+	result.Name = name
+	result.Action = ""
+	result.Query = url.Values{}
+	return result
+}
+
+func containsSchema(apiSchemas []*types.APISchema, apiSchema *types.APISchema) bool {
+	for _, s := range apiSchemas {
+		if s.ID == apiSchema.ID {
+			return true
+		}
+	}
+	return false
+}
+
+func isNotFound(err error) bool {
+	var apiError *apierror.APIError
+	if errors.As(err, &apiError) {
+		return apiError.Code.Status == http.StatusNotFound
+	}
+	return k8serrors.IsNotFound(err)
+}
+
+// withError sets the error of result the way the API would have reported it for a single request.
+func withError(result ApplyResult, err error) ApplyResult {
+	var (
+		apiError *apierror.APIError
+		status   k8serrors.APIStatus
+	)
+	switch {
+	case errors.As(err, &apiError):
+		result.Error = &ApplyError{Code: apiError.Code.Code, Message: apiError.Message}
+	case errors.As(err, &status):
+		result.Error = &ApplyError{Code: string(status.Status().Reason), Message: status.Status().Message}
+	default:
+		result.Error = &ApplyError{Code: validation.ServerError.Code, Message: err.Error()}
+	}
+	return result
+}
@@ -0,0 +1,394 @@
+package cluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/rancher/apiserver/pkg/server"
+	"github.com/rancher/apiserver/pkg/store/empty"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/attributes"
+	steveschema "github.com/rancher/steve/pkg/schema"
+	"github.com/rancher/wrangler/v3/pkg/data"
+	"github.com/rancher/wrangler/v3/pkg/schemas"
+	"github.com/rancher/wrangler/v3/pkg/schemas/validation"
+	"github.com/rancher/wrangler/v3/pkg/yaml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+type fakeFactory struct {
+	steveschema.Factory
+}
+
+func (f *fakeFactory) ByGVK(gvk schema.GroupVersionKind) string {
+	switch gvk {
+	case schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}:
+		return "configmap"
+	case schema.GroupVersionKind{Version: "v1", Kind: "Secret"}:
+		return "secret"
+	}
+	return ""
+}
+
+type fakeStore struct {
+	empty.Store
+
+	objects map[string]map[string]interface{}
+	// pageSize splits the lists in pages with continue tokens, if set
+	pageSize int
+	// fail is the error of the applies of the objects with the given IDs
+	fail    map[string]error
+	applied []string
+	deleted []string
+	dryRun  []string
+	queries []url.Values
+}
+
+func (f *fakeStore) ByID(apiOp *types.APIRequest, apiSchema *types.APISchema, id string) (types.APIObject, error) {
+	obj, ok := f.objects[apiOp.Namespace+"/"+id]
+	if !ok {
+		return types.APIObject{}, k8serrors.NewNotFound(schema.GroupResource{Resource: "configmaps"}, id)
+	}
+	return types.APIObject{Type: apiSchema.ID, ID: apiOp.Namespace + "/" + id, Object: &unstructured.Unstructured{Object: obj}}, nil
+}
+
+func (f *fakeStore) List(apiOp *types.APIRequest, apiSchema *types.APISchema) (types.APIObjectList, error) {
+	f.queries = append(f.queries, apiOp.Request.URL.Query())
+	ids := slices.Sorted(maps.Keys(f.objects))
+	var result types.APIObjectList
+	if f.pageSize > 0 {
+		start, _ := strconv.Atoi(apiOp.Request.URL.Query().Get("continue"))
+		end := min(start+f.pageSize, len(ids))
+		if end < len(ids) {
+			result.Continue = strconv.Itoa(end)
+		}
+		ids = ids[start:end]
+	}
+	for _, id := range ids {
+		result.Objects = append(result.Objects, types.APIObject{Type: apiSchema.ID, ID: id, Object: &unstructured.Unstructured{Object: f.objects[id]}})
+	}
+	return result, nil
+}
+
+// Update returns the applied object, as a server-side apply of a whole object would.
+func (f *fakeStore) Update(apiOp *types.APIRequest, apiSchema *types.APISchema, _ types.APIObject, id string) (types.APIObject, error) {
+	body, err := io.ReadAll(apiOp.Request.Body)
+	if err != nil {
+		return types.APIObject{}, err
+	}
+	obj := map[string]interface{}{}
+	if err := json.Unmarshal(body, &obj); err != nil {
+		return types.APIObject{}, err
+	}
+	if err := f.fail[apiOp.Namespace+"/"+id]; err != nil {
+		return types.APIObject{}, err
+	}
+	unstructured.SetNestedField(obj, "2", "metadata", "resourceVersion")
+	f.applied = append(f.applied, apiOp.Namespace+"/"+id)
+	f.dryRun = append(f.dryRun, apiOp.Request.URL.Query().Get("dryRun"))
+	if f.dryRun[len(f.dryRun)-1] == "" {
+		if f.objects == nil {
+			f.objects = map[string]map[string]interface{}{}
+		}
+		f.objects[apiOp.Namespace+"/"+id] = obj
+	}
+	return types.APIObject{Type: apiSchema.ID, ID: apiOp.Namespace + "/" + id, Object: &unstructured.Unstructured{Object: obj}}, nil
+}
+
+func (f *fakeStore) Delete(apiOp *types.APIRequest, _ *types.APISchema, id string) (types.APIObject, error) {
+	f.deleted = append(f.deleted, apiOp.Namespace+"/"+id)
+	f.dryRun = append(f.dryRun, apiOp.Request.URL.Query().Get("dryRun"))
+	return types.APIObject{}, validation.ErrorCode{Status: http.StatusNoContent}
+}
+
+func configMap(name, set string, data map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata": map[string]interface{}{
+			"name":            name,
+			"namespace":       "default",
+			"resourceVersion": "1",
+			"labels":          map[string]interface{}{SetIDLabel: set},
+		},
+		"data": data,
+	}
+}
+
+func addApplySchema(apiSchemas *types.APISchemas, id string, gvk schema.GroupVersionKind, store types.Store) {
+	apiSchema := &types.APISchema{
+		Schema: &schemas.Schema{
+			ID:                id,
+			CollectionMethods: []string{http.MethodGet},
+			ResourceMethods:   []string{http.MethodGet, http.MethodPut, http.MethodPatch, http.MethodDelete},
+			Attributes:        map[string]interface{}{},
+		},
+		Store: store,
+	}
+	attributes.SetNamespaced(apiSchema, true)
+	attributes.SetGVK(apiSchema, gvk)
+	apiSchemas.AddSchema(*apiSchema)
+}
+
+func newApplyRequest(t *testing.T, store types.Store) *types.APIRequest {
+	apiSchemas := types.EmptyAPISchemas()
+	addApplySchema(apiSchemas, "configmap", schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, store)
+
+	return &types.APIRequest{
+		Request:       httptest.NewRequest(http.MethodPost, "/v1/management.cattle.io.clusters/local?action=apply", nil),
+		Method:        http.MethodPost,
+		Type:          "management.cattle.io.cluster",
+		Name:          "local",
+		Action:        "apply",
+		Schemas:       apiSchemas,
+		AccessControl: &server.SchemaBasedAccess{},
+	}
+}
+
+const applyYAML = `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: same
+data:
+  k: v
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: new
+data:
+  k: v
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: unknown
+`
+
+func TestApplyEach(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       ApplyInput
+		wantDryRun  string
+		wantDeleted []string
+		wantPreview bool
+	}{
+		{
+			name:        "preview",
+			input:       ApplyInput{Preview: true, SetID: "s1", Prune: true},
+			wantDryRun:  "All",
+			wantPreview: true,
+		},
+		{
+			name:        "dry run",
+			input:       ApplyInput{DryRun: true, SetID: "s1", Prune: true},
+			wantDryRun:  "All",
+			wantDeleted: []string{"default/gone"},
+		},
+		{
+			name:        "apply",
+			input:       ApplyInput{SetID: "s1", Prune: true},
+			wantDeleted: []string{"default/gone"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := &fakeStore{objects: map[string]map[string]interface{}{
+				"default/same":  configMap("same", "s1", map[string]interface{}{"k": "v"}),
+				"default/gone":  configMap("gone", "s1", nil),
+				"default/other": configMap("other", "s10", nil),
+			}}
+			objs, err := yaml.ToObjects(strings.NewReader(applyYAML))
+			require.NoError(t, err)
+
+			a := &Apply{schemaFactory: &fakeFactory{}}
+			output, err := a.applyEach(newApplyRequest(t, store), test.input, objs[:2])
+			require.NoError(t, err)
+
+			assert.Equal(t, test.input.Preview || test.input.DryRun, output.DryRun)
+			assert.Equal(t, 1, output.Created)
+			assert.Equal(t, 1, output.Unchanged)
+			assert.Equal(t, 1, output.Deleted)
+			assert.Equal(t, 0, output.Failed)
+			assert.False(t, output.PruneSkipped)
+			require.Len(t, output.Results, 3)
+
+			same, created, deleted := output.Results[0], output.Results[1], output.Results[2]
+			assert.Equal(t, ApplyResult{ResourceType: "configmap", ID: "default/same", Action: ActionUnchanged,
+				Changes: same.Changes, Diff: same.Diff, Object: same.Object}, same)
+			assert.Empty(t, same.Changes)
+			assert.Equal(t, "default/new", created.ID)
+			assert.Equal(t, ActionCreate, created.Action)
+			assert.Equal(t, ApplyResult{ResourceType: "configmap", ID: "default/gone", Action: ActionDelete}, deleted)
+
+			if test.wantPreview {
+				assert.NotEmpty(t, created.Changes)
+				assert.Contains(t, created.Diff, "+  name: new")
+				assert.Nil(t, created.Object)
+			} else {
+				assert.Empty(t, created.Changes)
+				assert.NotNil(t, created.Object)
+			}
+			parentID := "default/" + setParentName("s1")
+			if test.wantDryRun == "" {
+				// the types of the set are recorded once, as they don't change after the prune
+				assert.Equal(t, []string{"default/same", "default/new", parentID}, store.applied)
+				assert.Equal(t, "ConfigMap", data.Object(store.objects[parentID]).String("metadata", "annotations", SetGroupKindsAnnotation))
+			} else {
+				assert.Equal(t, []string{"default/same", "default/new"}, store.applied)
+			}
+			assert.Equal(t, test.wantDeleted, store.deleted)
+			for _, dryRun := range store.dryRun {
+				assert.Equal(t, test.wantDryRun, dryRun)
+			}
+			// the label key has dots, which the filter of the non-SQL store splits on
+			require.Len(t, store.queries, 1)
+			assert.Equal(t, url.Values{"labelSelector": {SetIDLabel + "=s1"}}, store.queries[0])
+		})
+	}
+}
+
+func TestApplyEachSkipsPruneOnFailure(t *testing.T) {
+	tests := []struct {
+		name   string
+		objs   func(objs []runtime.Object) []runtime.Object
+		fail   map[string]error
+		failed string
+	}{
+		{
+			name:   "apply failed",
+			objs:   func(objs []runtime.Object) []runtime.Object { return objs[:2] },
+			fail:   map[string]error{"default/same": k8serrors.NewConflict(schema.GroupResource{Resource: "configmaps"}, "same", nil)},
+			failed: "Conflict",
+		},
+		{
+			name:   "unknown type",
+			objs:   func(objs []runtime.Object) []runtime.Object { return objs },
+			failed: "NotFound",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := &fakeStore{
+				objects: map[string]map[string]interface{}{
+					"default/same": configMap("same", "s1", map[string]interface{}{"k": "old"}),
+					"default/gone": configMap("gone", "s1", nil),
+				},
+				fail: test.fail,
+			}
+			objs, err := yaml.ToObjects(strings.NewReader(applyYAML))
+			require.NoError(t, err)
+
+			a := &Apply{schemaFactory: &fakeFactory{}}
+			output, err := a.applyEach(newApplyRequest(t, store), ApplyInput{SetID: "s1", Prune: true}, test.objs(objs))
+			require.NoError(t, err)
+
+			assert.True(t, output.PruneSkipped)
+			assert.Equal(t, 1, output.Failed)
+			assert.Equal(t, 0, output.Deleted)
+			var codes []string
+			for _, result := range output.Results {
+				if result.Error != nil {
+					codes = append(codes, result.Error.Code)
+				}
+			}
+			assert.Equal(t, []string{test.failed}, codes)
+			assert.Empty(t, store.deleted)
+			assert.Contains(t, store.objects, "default/same")
+			assert.Contains(t, store.objects, "default/gone")
+			// the types of the set are still recorded, so that a later apply prunes them
+			assert.Equal(t, "ConfigMap", data.Object(store.objects["default/"+setParentName("s1")]).String("metadata", "annotations", SetGroupKindsAnnotation))
+		})
+	}
+}
+
+func TestApplyEachPruneRequiresSetID(t *testing.T) {
+	a := &Apply{schemaFactory: &fakeFactory{}}
+	_, err := a.applyEach(newApplyRequest(t, &fakeStore{}), ApplyInput{Prune: true}, nil)
+	assert.ErrorContains(t, err, "setID is required")
+
+	_, err = a.applyEach(newApplyRequest(t, &fakeStore{}), ApplyInput{SetID: "not a label"}, nil)
+	assert.ErrorContains(t, err, "invalid setID")
+}
+
+func TestApplyEachPrunesRecordedTypes(t *testing.T) {
+	secret := configMap("old-secret", "s1", nil)
+	secret["kind"] = "Secret"
+	secrets := &fakeStore{objects: map[string]map[string]interface{}{
+		"default/old-secret": secret,
+	}}
+	configMaps := &fakeStore{objects: map[string]map[string]interface{}{
+		"default/" + setParentName("s1"): {
+			"metadata": map[string]interface{}{
+				"name":        setParentName("s1"),
+				"namespace":   "default",
+				"labels":      map[string]interface{}{SetParentLabel: "s1"},
+				"annotations": map[string]interface{}{SetGroupKindsAnnotation: "ConfigMap,Secret"},
+			},
+		},
+	}}
+	apiOp := newApplyRequest(t, configMaps)
+	addApplySchema(apiOp.Schemas, "secret", schema.GroupVersionKind{Version: "v1", Kind: "Secret"}, secrets)
+
+	objs, err := yaml.ToObjects(strings.NewReader(applyYAML))
+	require.NoError(t, err)
+	a := &Apply{schemaFactory: &fakeFactory{}}
+	output, err := a.applyEach(apiOp, ApplyInput{SetID: "s1", Prune: true}, objs[:2])
+	require.NoError(t, err)
+
+	// the input has no secrets anymore, but the set had some
+	assert.Equal(t, 0, output.Failed)
+	assert.Equal(t, 1, output.Deleted)
+	assert.Equal(t, []string{"default/old-secret"}, secrets.deleted)
+	assert.Equal(t, "ConfigMap", data.Object(configMaps.objects["default/"+setParentName("s1")]).String("metadata", "annotations", SetGroupKindsAnnotation))
+}
+
+func TestApplyEachPrunesEveryPage(t *testing.T) {
+	for _, inSQLMode := range []bool{false, true} {
+		t.Run(fmt.Sprintf("inSQLMode=%v", inSQLMode), func(t *testing.T) {
+			store := &fakeStore{objects: map[string]map[string]interface{}{}, pageSize: 2}
+			for _, name := range []string{"a", "b", "c", "d", "e"} {
+				store.objects["default/"+name] = configMap(name, "s1", nil)
+			}
+			store.objects["default/other"] = configMap("other", "s2", nil)
+			store.objects["default/"+setParentName("s1")] = map[string]interface{}{
+				"metadata": map[string]interface{}{
+					"name":        setParentName("s1"),
+					"namespace":   "default",
+					"labels":      map[string]interface{}{SetParentLabel: "s1"},
+					"annotations": map[string]interface{}{SetGroupKindsAnnotation: "ConfigMap"},
+				},
+			}
+
+			a := &Apply{schemaFactory: &fakeFactory{}, inSQLMode: inSQLMode}
+			output, err := a.applyEach(newApplyRequest(t, store), ApplyInput{SetID: "s1", Prune: true, Preview: true}, nil)
+			require.NoError(t, err)
+
+			assert.Equal(t, 5, output.Deleted)
+			query := url.Values{"labelSelector": {SetIDLabel + "=s1"}}
+			if inSQLMode {
+				query = url.Values{"filter": {"metadata.labels[" + SetIDLabel + "]=s1"}}
+			}
+			// the set parent is looked up by ID, the configmaps are listed in 4 pages
+			require.Len(t, store.queries, 4)
+			assert.Equal(t, query, store.queries[0])
+			query.Set("continue", "6")
+			assert.Equal(t, query, store.queries[3])
+		})
+	}
+}
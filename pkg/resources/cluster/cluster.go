@@ -82,8 +82,14 @@ func provider(ctx context.Context, cg proxy.ClientGetter) string {
 	return provider
 }
 
-func AddApply(apiSchemas *types.APISchemas, schema *types.APISchema) {
-	if _, ok := schema.ActionHandlers["apply"]; ok {
+// AddApply adds the apply action of the cluster schema to schema. inSQLMode is whether the objects are listed from
+// the SQL cache.
+func AddApply(apiSchemas *types.APISchemas, schema *types.APISchema, inSQLMode bool) {
+	if existing, ok := schema.ActionHandlers["apply"]; ok {
+		// the cluster schema itself only needs the mode
+		if apply, ok := existing.(*Apply); ok && apply.inSQLMode != inSQLMode {
+			schema.ActionHandlers["apply"] = apply.withSQLMode(inSQLMode)
+		}
 		return
 	}
 	cluster := apiSchemas.LookupSchema("management.cattle.io.cluster")
@@ -95,6 +101,9 @@ func AddApply(apiSchemas *types.APISchemas, schema *types.APISchema) {
 	if !ok {
 		return
 	}
+	if apply, ok := actionHandler.(*Apply); ok {
+		actionHandler = apply.withSQLMode(inSQLMode)
+	}
 
 	if schema.ActionHandlers == nil {
 		schema.ActionHandlers = map[string]http.Handler{}
@@ -1,14 +1,59 @@
 package cluster
 
 import (
+	"github.com/rancher/steve/pkg/resources/diff"
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
+const (
+	ActionCreate    = "create"
+	ActionUpdate    = "update"
+	ActionUnchanged = "unchanged"
+	ActionDelete    = "delete"
+)
+
 type ApplyInput struct {
 	DefaultNamespace string `json:"defaultNamespace,omitempty"`
 	YAML             string `json:"yaml,omitempty"`
+	// Preview reports what applying would change, with a diff of each object, without changing anything.
+	Preview bool `json:"preview,omitempty"`
+	// DryRun applies with a server-side dry run, so that nothing is persisted.
+	DryRun bool `json:"dryRun,omitempty"`
+	// SetID labels the applied objects, so that the objects of a set can be pruned by a later apply.
+	SetID string `json:"setID,omitempty"`
+	// Prune deletes the objects labeled with SetID, of the types of the input, which are not part of the input.
+	Prune bool `json:"prune,omitempty"`
 }
 
 type ApplyOutput struct {
 	Resources []runtime.Object `json:"resources,omitempty"`
+	// Results has a result for each object of the input, in order, followed by the objects pruned.
+	Results   []ApplyResult `json:"results,omitempty"`
+	Created   int           `json:"created"`
+	Updated   int           `json:"updated"`
+	Unchanged int           `json:"unchanged"`
+	Deleted   int           `json:"deleted"`
+	Failed    int           `json:"failed"`
+	// DryRun is set if nothing was persisted, for previews too.
+	DryRun bool `json:"dryRun,omitempty"`
+	// PruneSkipped is set if Prune was requested but nothing was pruned, because an object of the input failed.
+	PruneSkipped bool `json:"pruneSkipped,omitempty"`
+}
+
+type ApplyResult struct {
+	ResourceType string `json:"resourceType,omitempty"`
+	ID           string `json:"id,omitempty"`
+	// Action is one of create, update, unchanged or delete, empty if the object failed.
+	Action string `json:"action,omitempty"`
+	// Changes and Diff are only set for previews.
+	Changes []diff.Change `json:"changes,omitempty"`
+	Diff    string        `json:"diff,omitempty"`
+	// Object is the applied object, as it would be persisted for a dry run. It is not set for previews.
+	Object interface{} `json:"object,omitempty"`
+	Error  *ApplyError `json:"error,omitempty"`
+}
+
+type ApplyError struct {
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
 }
@@ -10,6 +10,8 @@ import (
 	"github.com/rancher/apiserver/pkg/types"
 	"github.com/rancher/steve/pkg/accesscontrol"
 	"github.com/rancher/steve/pkg/attributes"
+	"github.com/rancher/steve/pkg/policy"
+	"github.com/rancher/steve/pkg/ratelimit"
 	"github.com/rancher/steve/pkg/resources/virtual/common"
 	"github.com/sirupsen/logrus"
 
@@ -29,6 +31,12 @@ import (
 
 type TemplateOptions struct {
 	InSQLMode bool
+	// Policy, if set, is evaluated after RBAC to deny access to individual objects.
+	Policy policy.Policy
+	// Redactions hide fields from read responses.
+	Redactions []RedactionRule
+	// RateLimiter, if set, limits the rate of the operations of bulk requests.
+	RateLimiter *ratelimit.Limiter
 }
 
 func DefaultTemplate(clientGetter proxy.ClientGetter,
@@ -37,7 +45,7 @@ func DefaultTemplate(clientGetter proxy.ClientGetter,
 	namespaceCache corecontrollers.NamespaceCache,
 	options TemplateOptions) schema.Template {
 	return schema.Template{
-		Store:     metricsStore.NewMetricsStore(proxy.NewProxyStore(clientGetter, summaryCache, asl, namespaceCache)),
+		Store:     metricsStore.NewMetricsStore(proxy.NewProxyStore(clientGetter, summaryCache, asl, namespaceCache, options.Policy)),
 		Formatter: formatter(summaryCache, asl, options),
 	}
 }
@@ -106,6 +114,15 @@ func formatter(summarycache common.SummaryCache, asl accesscontrol.AccessSetLook
 			return
 		}
 
+		var accessSet *accesscontrol.AccessSet
+		// redaction runs at the end of this formatter whichever way it returns, so that include cannot bring back a
+		// redacted field and objects which aren't otherwise formatted are still redacted. It only covers what the
+		// object and this formatter hold: the formatters of type templates, like the pod log link or the usage, run
+		// after it and their fields aren't redacted.
+		defer func() {
+			redactResource(options.Redactions, accessSet, gvr, resource)
+		}()
+
 		meta, err := meta.Accessor(resource.APIObject.Object)
 		if err != nil {
 			return
@@ -114,7 +131,7 @@ func formatter(summarycache common.SummaryCache, asl accesscontrol.AccessSetLook
 		if !ok {
 			return
 		}
-		accessSet := accesscontrol.AccessSetFromAPIRequest(request)
+		accessSet = accesscontrol.AccessSetFromAPIRequest(request)
 		if accessSet == nil {
 			accessSet = asl.AccessFor(userInfo)
 			if accessSet == nil {
@@ -133,6 +150,16 @@ func formatter(summarycache common.SummaryCache, asl accesscontrol.AccessSetLook
 		if hasGet {
 			if attributes.DisallowMethods(resource.Schema)[http.MethodGet] {
 				resource.Links["view"] = "blocked"
+			} else if self, ok := resource.Links["self"]; ok && attributes.GVK(resource.Schema).Kind != "" {
+				// the object as Kubernetes YAML, without the fields added by steve
+				resource.Links["download"] = self + "?format=yaml&download=true"
+				// the object as a manifest ready to be committed, or for types with their own export, like
+				// namespaces, everything it holds
+				if _, ok := resource.Schema.LinkHandlers["export"]; ok {
+					resource.Links["export"] = self + "?link=export"
+				} else {
+					resource.Links["export"] = self + "?format=yaml&export=true"
+				}
 			}
 		} else {
 			delete(resource.Links, "view")
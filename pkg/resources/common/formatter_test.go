@@ -681,6 +681,80 @@ func Test_formatterLinks(t *testing.T) {
 				"view":    "/api/v1/namespaces/example-ns/pods/example-pod",
 			},
 		},
+		{
+			name:    "get permission granted, kubernetes type",
+			hasUser: true,
+			permissions: &permissions{
+				hasGet: true,
+			},
+			schema: &types.APISchema{
+				Schema: &schemas.Schema{
+					ID: "example",
+					Attributes: map[string]interface{}{
+						"group":    "",
+						"version":  "v1",
+						"kind":     "Pod",
+						"resource": "pods",
+					},
+				},
+			},
+			apiObject: types.APIObject{
+				ID: "example",
+				Object: &v1.Pod{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "example-pod",
+						Namespace: "example-ns",
+					},
+				},
+			},
+			currentLinks: map[string]string{
+				"self": "/v1/pods/example-ns/example-pod",
+			},
+			wantLinks: map[string]string{
+				"self":     "/v1/pods/example-ns/example-pod",
+				"view":     "/api/v1/namespaces/example-ns/pods/example-pod",
+				"download": "/v1/pods/example-ns/example-pod?format=yaml&download=true",
+				"export":   "/v1/pods/example-ns/example-pod?format=yaml&export=true",
+			},
+		},
+		{
+			name:    "get permission granted, kubernetes type with export link handler",
+			hasUser: true,
+			permissions: &permissions{
+				hasGet: true,
+			},
+			schema: &types.APISchema{
+				Schema: &schemas.Schema{
+					ID: "namespace",
+					Attributes: map[string]interface{}{
+						"group":    "",
+						"version":  "v1",
+						"kind":     "Namespace",
+						"resource": "namespaces",
+					},
+				},
+				LinkHandlers: map[string]http.Handler{
+					"export": http.NotFoundHandler(),
+				},
+			},
+			apiObject: types.APIObject{
+				ID: "example-ns",
+				Object: &v1.Namespace{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "example-ns",
+					},
+				},
+			},
+			currentLinks: map[string]string{
+				"self": "/v1/namespaces/example-ns",
+			},
+			wantLinks: map[string]string{
+				"self":     "/v1/namespaces/example-ns",
+				"view":     "/api/v1/namespaces/example-ns",
+				"download": "/v1/namespaces/example-ns?format=yaml&download=true",
+				"export":   "/v1/namespaces/example-ns?link=export",
+			},
+		},
 		{
 			name:    "get permission granted, but disallowed in schema",
 			hasUser: true,
@@ -0,0 +1,148 @@
+package common
+
+import (
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/rancher/wrangler/v3/pkg/data"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	schema2 "k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	// RedactedValue replaces values which are masked by a redaction rule.
+	RedactedValue = "[redacted]"
+	// redactedFieldsKey lists, under metadata, the fields that were removed or masked,
+	// so that clients can tell a hidden field apart from an empty one.
+	redactedFieldsKey = "redactedFields"
+)
+
+// RedactionRule hides fields from the read responses (get, list and watch) of matching resources.
+type RedactionRule struct {
+	// Resources restricts the rule to the given resources, either as "resource" for the core group or as
+	// "group/resource". "*" and an empty list match all resources.
+	Resources []string
+	// Fields are dot separated paths, e.g. "data" or "spec.password", which are removed from the object.
+	Fields []string
+	// EnvNamePattern masks the value of every container env var whose name matches this glob, e.g. "*_TOKEN".
+	EnvNamePattern string
+	// UnlessVerb skips the rule if the user is granted this verb on the object, e.g. "update".
+	UnlessVerb string
+}
+
+func (r *RedactionRule) matches(gvr schema2.GroupVersionResource) bool {
+	if len(r.Resources) == 0 || slices.Contains(r.Resources, "*") {
+		return true
+	}
+	resource := gvr.Resource
+	if gvr.Group != "" {
+		resource = gvr.Group + "/" + resource
+	}
+	return slices.Contains(r.Resources, resource)
+}
+
+// redactResource applies the rules matching gvr to the object of resource, whatever its type. Objects which can't be
+// converted to unstructured are dropped rather than returned unredacted.
+func redactResource(rules []RedactionRule, accessSet *accesscontrol.AccessSet, gvr schema2.GroupVersionResource, resource *types.RawResource) {
+	if resource.APIObject.Object == nil || !slices.ContainsFunc(rules, func(rule RedactionRule) bool {
+		return rule.matches(gvr)
+	}) {
+		return
+	}
+
+	var unstr *unstructured.Unstructured
+	switch obj := resource.APIObject.Object.(type) {
+	case *unstructured.Unstructured:
+		unstr = obj
+	case map[string]interface{}:
+		unstr = &unstructured.Unstructured{Object: obj}
+	case data.Object:
+		unstr = &unstructured.Unstructured{Object: obj}
+	default:
+		content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+		if err != nil {
+			logrus.Errorf("failed to redact %s %s: %v", gvr.Resource, resource.ID, err)
+			resource.APIObject.Object = map[string]interface{}{}
+			return
+		}
+		unstr = &unstructured.Unstructured{Object: content}
+		resource.APIObject.Object = unstr
+	}
+	redact(rules, accessSet, gvr, unstr)
+}
+
+// redact applies rules to unstr and records the hidden fields in metadata.redactedFields.
+func redact(rules []RedactionRule, accessSet *accesscontrol.AccessSet, gvr schema2.GroupVersionResource, unstr *unstructured.Unstructured) {
+	var redacted []string
+	for i := range rules {
+		rule := &rules[i]
+		if !rule.matches(gvr) {
+			continue
+		}
+		if rule.UnlessVerb != "" && accessSet != nil &&
+			accessSet.Grants(rule.UnlessVerb, gvr.GroupResource(), unstr.GetNamespace(), unstr.GetName()) {
+			continue
+		}
+		for _, field := range rule.Fields {
+			fieldParts := strings.Split(field, ".")
+			if _, ok := data.GetValue(unstr.Object, fieldParts...); ok {
+				data.RemoveValue(unstr.Object, fieldParts...)
+				redacted = append(redacted, field)
+			}
+		}
+		if rule.EnvNamePattern != "" {
+			redacted = append(redacted, maskEnv(unstr.Object, rule.EnvNamePattern, "")...)
+		}
+	}
+	if len(redacted) == 0 {
+		return
+	}
+	slices.Sort(redacted)
+	fields := make([]interface{}, 0, len(redacted))
+	for _, f := range slices.Compact(redacted) {
+		fields = append(fields, f)
+	}
+	data.PutValue(unstr.Object, fields, "metadata", redactedFieldsKey)
+}
+
+// maskEnv walks obj looking for container env lists and masks the values of the entries whose name matches
+// pattern. It returns the paths of the masked values.
+func maskEnv(obj map[string]interface{}, pattern, prefix string) []string {
+	var masked []string
+	for key, value := range obj {
+		fieldPath := key
+		if prefix != "" {
+			fieldPath = prefix + "." + key
+		}
+		switch v := value.(type) {
+		case map[string]interface{}:
+			masked = append(masked, maskEnv(v, pattern, fieldPath)...)
+		case []interface{}:
+			for i, item := range v {
+				m, ok := item.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				itemPath := fieldPath + "." + strconv.Itoa(i)
+				if key == "env" {
+					name, _ := m["name"].(string)
+					if _, hasValue := m["value"]; hasValue {
+						if ok, _ := path.Match(pattern, name); ok {
+							m["value"] = RedactedValue
+							masked = append(masked, itemPath+".value")
+						}
+					}
+					continue
+				}
+				masked = append(masked, maskEnv(m, pattern, itemPath)...)
+			}
+		}
+	}
+	return masked
+}
@@ -0,0 +1,220 @@
+package common
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/apiserver/pkg/urlbuilder"
+	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/rancher/steve/pkg/accesscontrol/fake"
+	"github.com/rancher/steve/pkg/attributes"
+	"github.com/rancher/wrangler/v3/pkg/schemas"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	schema2 "k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/endpoints/request"
+)
+
+func Test_redact(t *testing.T) {
+	secrets := schema2.GroupVersionResource{Version: "v1", Resource: "secrets"}
+	deployments := schema2.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+
+	secretRule := RedactionRule{
+		Resources:  []string{"secrets"},
+		Fields:     []string{"data", "stringData"},
+		UnlessVerb: "update",
+	}
+	tokenRule := RedactionRule{
+		EnvNamePattern: "*_TOKEN",
+	}
+
+	newSecret := func() *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"name":      "s",
+				"namespace": "ns",
+			},
+			"data": map[string]interface{}{
+				"password": "aHVudGVyMg==",
+			},
+		}}
+	}
+
+	tests := []struct {
+		name      string
+		rules     []RedactionRule
+		accessSet *accesscontrol.AccessSet
+		gvr       schema2.GroupVersionResource
+		obj       *unstructured.Unstructured
+		want      map[string]interface{}
+	}{
+		{
+			name:      "secret data removed without update",
+			rules:     []RedactionRule{secretRule},
+			accessSet: &accesscontrol.AccessSet{},
+			gvr:       secrets,
+			obj:       newSecret(),
+			want: map[string]interface{}{
+				"metadata": map[string]interface{}{
+					"name":           "s",
+					"namespace":      "ns",
+					"redactedFields": []interface{}{"data"},
+				},
+			},
+		},
+		{
+			name:  "secret data kept with update",
+			rules: []RedactionRule{secretRule},
+			accessSet: func() *accesscontrol.AccessSet {
+				as := &accesscontrol.AccessSet{}
+				as.Add("update", secrets.GroupResource(), accesscontrol.Access{Namespace: "ns", ResourceName: "s"})
+				return as
+			}(),
+			gvr:  secrets,
+			obj:  newSecret(),
+			want: newSecret().Object,
+		},
+		{
+			name:      "rule for other resources is ignored",
+			rules:     []RedactionRule{secretRule},
+			accessSet: &accesscontrol.AccessSet{},
+			gvr:       deployments,
+			obj:       newSecret(),
+			want:      newSecret().Object,
+		},
+		{
+			name:  "env vars matching the pattern are masked",
+			rules: []RedactionRule{tokenRule},
+			gvr:   deployments,
+			obj: &unstructured.Unstructured{Object: map[string]interface{}{
+				"metadata": map[string]interface{}{"name": "d"},
+				"spec": map[string]interface{}{
+					"template": map[string]interface{}{
+						"spec": map[string]interface{}{
+							"containers": []interface{}{
+								map[string]interface{}{
+									"name": "app",
+									"env": []interface{}{
+										map[string]interface{}{"name": "GITHUB_TOKEN", "value": "ghp_secret"},
+										map[string]interface{}{"name": "LOG_LEVEL", "value": "debug"},
+										map[string]interface{}{"name": "API_TOKEN", "valueFrom": map[string]interface{}{}},
+									},
+								},
+							},
+						},
+					},
+				},
+			}},
+			want: map[string]interface{}{
+				"metadata": map[string]interface{}{
+					"name":           "d",
+					"redactedFields": []interface{}{"spec.template.spec.containers.0.env.0.value"},
+				},
+				"spec": map[string]interface{}{
+					"template": map[string]interface{}{
+						"spec": map[string]interface{}{
+							"containers": []interface{}{
+								map[string]interface{}{
+									"name": "app",
+									"env": []interface{}{
+										map[string]interface{}{"name": "GITHUB_TOKEN", "value": RedactedValue},
+										map[string]interface{}{"name": "LOG_LEVEL", "value": "debug"},
+										map[string]interface{}{"name": "API_TOKEN", "valueFrom": map[string]interface{}{}},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			redact(test.rules, test.accessSet, test.gvr, test.obj)
+			assert.Equal(t, test.want, test.obj.Object)
+		})
+	}
+}
+
+func TestFormatterRedactsOnEarlyReturn(t *testing.T) {
+	rules := []RedactionRule{{Resources: []string{"secrets"}, Fields: []string{"data"}}}
+	userInfo := &user.DefaultInfo{Name: "test-user"}
+	secretMap := func() map[string]interface{} {
+		return map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Secret",
+			"metadata":   map[string]interface{}{"name": "s", "namespace": "ns"},
+			"data":       map[string]interface{}{"password": "aHVudGVyMg=="},
+		}
+	}
+
+	tests := []struct {
+		name      string
+		object    interface{}
+		hasUser   bool
+		accessSet *accesscontrol.AccessSet
+	}{
+		{
+			name:   "no user",
+			object: &unstructured.Unstructured{Object: secretMap()},
+		},
+		{
+			name:    "no access set",
+			object:  &unstructured.Unstructured{Object: secretMap()},
+			hasUser: true,
+		},
+		{
+			name: "typed object",
+			object: &v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "s", Namespace: "ns"},
+				Data:       map[string][]byte{"password": []byte("hunter2")},
+			},
+			hasUser:   true,
+			accessSet: &accesscontrol.AccessSet{},
+		},
+		{
+			name:      "object without metadata accessor",
+			object:    secretMap(),
+			hasUser:   true,
+			accessSet: &accesscontrol.AccessSet{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			asl := fake.NewMockAccessSetLookup(gomock.NewController(t))
+			asl.EXPECT().AccessFor(userInfo).Return(test.accessSet).AnyTimes()
+			ctx := context.Background()
+			if test.hasUser {
+				ctx = request.WithUser(ctx, userInfo)
+			}
+			httpRequest, err := http.NewRequestWithContext(ctx, http.MethodGet, "/v1/secrets/ns/s", nil)
+			require.NoError(t, err)
+			apiOp := &types.APIRequest{
+				Request:    httpRequest,
+				URLBuilder: &urlbuilder.DefaultURLBuilder{},
+			}
+			apiSchema := &types.APISchema{Schema: &schemas.Schema{ID: "secret", Attributes: map[string]interface{}{}}}
+			attributes.SetGVR(apiSchema, schema2.GroupVersionResource{Version: "v1", Resource: "secrets"})
+			resource := &types.RawResource{
+				ID:        "ns/s",
+				Schema:    apiSchema,
+				APIObject: types.APIObject{Type: "secret", ID: "ns/s", Object: test.object},
+				Links:     map[string]string{},
+			}
+
+			formatter(nil, asl, TemplateOptions{Redactions: rules})(apiOp, resource)
+
+			obj := resource.APIObject.Data()
+			assert.NotContains(t, obj, "data")
+			assert.Equal(t, []interface{}{"data"}, obj.Map("metadata")["redactedFields"])
+		})
+	}
+}
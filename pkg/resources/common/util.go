@@ -6,6 +6,8 @@ import (
 
 	"github.com/rancher/apiserver/pkg/types"
 	"github.com/rancher/steve/pkg/attributes"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
 // GetIndexValueFromString looks for values between [ ].
@@ -39,3 +41,25 @@ func GetColumnDefinitions(schema *types.APISchema) []ColumnDefinition {
 	}
 	return colDefs
 }
+
+// ToAPIObject returns obj, as returned by a typed client, as an object of apiSchema.
+func ToAPIObject(apiSchema *types.APISchema, obj runtime.Object) (types.APIObject, error) {
+	data, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return types.APIObject{}, err
+	}
+	u := &unstructured.Unstructured{Object: data}
+	// typed clients don't return the type of the objects
+	u.SetAPIVersion(attributes.GVK(apiSchema).GroupVersion().String())
+	u.SetKind(attributes.Kind(apiSchema))
+
+	id := u.GetName()
+	if u.GetNamespace() != "" {
+		id = u.GetNamespace() + "/" + id
+	}
+	return types.APIObject{
+		Type:   apiSchema.ID,
+		ID:     id,
+		Object: u,
+	}, nil
+}
@@ -0,0 +1,277 @@
+// Package diff adds the diff action, which shows what applying an object would change, without changing anything.
+package diff
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"slices"
+	"strconv"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/handlers"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/attributes"
+	"github.com/rancher/steve/pkg/schema"
+	"github.com/rancher/steve/pkg/server/writer"
+	"github.com/rancher/wrangler/v3/pkg/data"
+	"github.com/rancher/wrangler/v3/pkg/schemas"
+	"github.com/rancher/wrangler/v3/pkg/schemas/validation"
+	apitypes "k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/yaml"
+)
+
+// DefaultFieldManager is the field manager of the dry-run apply if the request doesn't set one.
+const DefaultFieldManager = "steve-diff"
+
+func Register(apiSchemas *types.APISchemas) {
+	apiSchemas.MustImportAndCustomize(&DiffOutput{}, nil)
+}
+
+// Template adds the diff action to every Kubernetes type which supports patch.
+func Template() schema.Template {
+	return schema.Template{
+		Customize: AddDiff,
+	}
+}
+
+func AddDiff(apiSchema *types.APISchema) {
+	if attributes.GVK(apiSchema).Kind == "" || !slices.Contains(attributes.Verbs(apiSchema), "patch") {
+		return
+	}
+	if apiSchema.ActionHandlers == nil {
+		apiSchema.ActionHandlers = map[string]http.Handler{}
+	}
+	apiSchema.ActionHandlers["diff"] = &Diff{}
+
+	if apiSchema.ResourceActions == nil {
+		apiSchema.ResourceActions = map[string]schemas.Action{}
+	}
+	apiSchema.ResourceActions["diff"] = schemas.Action{
+		Output: "diffOutput",
+	}
+}
+
+// Diff applies the object of the request with a server-side dry run, as the caller, and compares the result with
+// the live object. The object can be partial, as for any apply.
+type Diff struct{}
+
+func (d *Diff) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	var (
+		apiContext = types.GetAPIContext(req.Context())
+		input      map[string]interface{}
+	)
+
+	if err := json.NewDecoder(io.LimitReader(req.Body, 2<<20)).Decode(&input); err != nil {
+		apiContext.WriteError(apierror.NewAPIError(validation.InvalidBodyContent, err.Error()))
+		return
+	}
+
+	output, err := d.Run(apiContext, input)
+	if err != nil {
+		apiContext.WriteError(err)
+		return
+	}
+	apiContext.WriteResponse(http.StatusOK, types.APIObject{
+		Type:   "diffOutput",
+		Object: output,
+	})
+}
+
+// Run returns the changes applying input to the object of apiOp would make. Both objects are formatted as they
+// would be for a read, so that fields hidden from the user aren't revealed by the diff.
+func (d *Diff) Run(apiOp *types.APIRequest, input map[string]interface{}) (*DiffOutput, error) {
+	live, err := handlers.ByIDHandler(apiOp)
+	if err != nil {
+		return nil, err
+	}
+
+	// the dry run must succeed even if the fields are managed by others, to show what taking them over would change
+	applyOp, err := ApplyRequest(apiOp, Proposed(apiOp, input), ApplyOptions{
+		FieldManager: DefaultFieldManager,
+		Force:        true,
+		DryRun:       true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	result, err := handlers.UpdateHandler(applyOp)
+	if err != nil {
+		return nil, err
+	}
+
+	return Compare(Formatted(apiOp, live), Formatted(apiOp, result))
+}
+
+// Formatted returns the data of obj as a read of it would return it, after the formatter of the schema of apiOp has
+// run. The formatter applies the redaction rules.
+func Formatted(apiOp *types.APIRequest, obj types.APIObject) data.Object {
+	if apiOp.Schema.Formatter == nil || obj.Object == nil {
+		return obj.Data()
+	}
+	resource := &types.RawResource{
+		ID:        obj.ID,
+		Type:      apiOp.Schema.ID,
+		Schema:    apiOp.Schema,
+		Links:     map[string]string{},
+		Actions:   map[string]string{},
+		APIObject: obj,
+	}
+	apiOp.Schema.Formatter(apiOp, resource)
+	return resource.APIObject.Data()
+}
+
+// Compare returns the changes between the live object and the result of applying to it.
+func Compare(live, result map[string]interface{}) (*DiffOutput, error) {
+	from, to := normalize(live), normalize(result)
+	text, err := unified(from, to)
+	if err != nil {
+		return nil, err
+	}
+	return &DiffOutput{
+		Changes: compare(nil, from, to, []Change{}),
+		Diff:    text,
+	}, nil
+}
+
+// Proposed returns input as an apply configuration of the object of apiOp. The apiVersion and kind of the schema
+// are used if input has none.
+func Proposed(apiOp *types.APIRequest, input map[string]interface{}) map[string]interface{} {
+	obj := normalize(input)
+	if obj["apiVersion"] == nil || obj["kind"] == nil {
+		obj["apiVersion"], obj["kind"] = attributes.GVK(apiOp.Schema).ToAPIVersionAndKind()
+	}
+	data.PutValue(obj, apiOp.Name, "metadata", "name")
+	if attributes.Namespaced(apiOp.Schema) {
+		data.PutValue(obj, apiOp.Namespace, "metadata", "namespace")
+	}
+	return obj
+}
+
+// normalize returns obj without the fields added by steve and the fields changed by the API server on every write.
+func normalize(obj map[string]interface{}) map[string]interface{} {
+	obj = writer.Clean(obj, writer.CleanOptions{ManagedFields: true})
+	data.RemoveValue(obj, "metadata", "resourceVersion")
+	data.RemoveValue(obj, "metadata", "generation")
+	return obj
+}
+
+// ApplyOptions are the options of an apply request, in addition to the query parameters of the original request.
+type ApplyOptions struct {
+	// FieldManager is the field manager if the request doesn't set one.
+	FieldManager string
+	// Force takes ownership of the fields managed by others even if the request doesn't set force.
+	Force  bool
+	DryRun bool
+}
+
+// ApplyRequest returns a copy of apiOp applying obj server-side to the object of apiOp. Query parameters such as
+// fieldManager and force are kept.
+func ApplyRequest(apiOp *types.APIRequest, obj map[string]interface{}, opts ApplyOptions) (*types.APIRequest, error) {
+	body, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	query := url.Values{}
+	for k, v := range apiOp.Request.URL.Query() {
+		if k != "action" {
+			query[k] = v
+		}
+	}
+	if opts.DryRun {
+		query.Set("dryRun", "All")
+	}
+	if query.Get("fieldManager") == "" {
+		query.Set("fieldManager", opts.FieldManager)
+	}
+	if opts.Force {
+		query.Set("force", "true")
+	}
+	u := *apiOp.Request.URL
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(apiOp.Context(), http.MethodPatch, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", string(apitypes.ApplyYAMLPatchType))
+
+	result := apiOp.Clone()
+	result.Request = req
+	result.Method = http.MethodPatch
+	result.Action = ""
+	result.Query = query
+	return result, nil
+}
+
+// compare appends the changes from a to b under path to changes. Lists are compared item by item.
+func compare(path []string, a, b interface{}, changes []Change) []Change {
+	switch {
+	case reflect.DeepEqual(a, b):
+		return changes
+	case a == nil:
+		return append(changes, Change{Path: path, Op: OpAdd, To: b})
+	case b == nil:
+		return append(changes, Change{Path: path, Op: OpRemove, From: a})
+	}
+
+	aMap, aIsMap := a.(map[string]interface{})
+	bMap, bIsMap := b.(map[string]interface{})
+	if aIsMap && bIsMap {
+		keys := make([]string, 0, len(aMap)+len(bMap))
+		for k := range aMap {
+			keys = append(keys, k)
+		}
+		for k := range bMap {
+			if _, ok := aMap[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		slices.Sort(keys)
+		for _, k := range keys {
+			changes = compare(append(slices.Clip(path), k), aMap[k], bMap[k], changes)
+		}
+		return changes
+	}
+
+	aSlice, aIsSlice := a.([]interface{})
+	bSlice, bIsSlice := b.([]interface{})
+	if aIsSlice && bIsSlice {
+		for i := range max(len(aSlice), len(bSlice)) {
+			var aItem, bItem interface{}
+			if i < len(aSlice) {
+				aItem = aSlice[i]
+			}
+			if i < len(bSlice) {
+				bItem = bSlice[i]
+			}
+			changes = compare(append(slices.Clip(path), strconv.Itoa(i)), aItem, bItem, changes)
+		}
+		return changes
+	}
+
+	return append(changes, Change{Path: path, Op: OpReplace, From: a, To: b})
+}
+
+func unified(a, b map[string]interface{}) (string, error) {
+	aYAML, err := yaml.Marshal(a)
+	if err != nil {
+		return "", err
+	}
+	bYAML, err := yaml.Marshal(b)
+	if err != nil {
+		return "", err
+	}
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(aYAML)),
+		B:        difflib.SplitLines(string(bYAML)),
+		FromFile: "live",
+		ToFile:   "proposed",
+		Context:  3,
+	})
+}
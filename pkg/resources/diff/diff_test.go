@@ -0,0 +1,232 @@
+package diff
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/server"
+	"github.com/rancher/apiserver/pkg/store/empty"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/attributes"
+	"github.com/rancher/steve/pkg/resources/common"
+	"github.com/rancher/wrangler/v3/pkg/schemas"
+	"github.com/rancher/wrangler/v3/pkg/schemas/validation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+type fakeStore struct {
+	empty.Store
+
+	live    map[string]interface{}
+	applied map[string]interface{}
+	request *http.Request
+}
+
+func (f *fakeStore) ByID(_ *types.APIRequest, apiSchema *types.APISchema, id string) (types.APIObject, error) {
+	return types.APIObject{Type: apiSchema.ID, ID: "default/" + id, Object: &unstructured.Unstructured{Object: f.live}}, nil
+}
+
+// Update merges the applied configuration into the live object, as a dry-run apply would for these tests.
+func (f *fakeStore) Update(apiOp *types.APIRequest, apiSchema *types.APISchema, _ types.APIObject, id string) (types.APIObject, error) {
+	f.request = apiOp.Request
+	body, err := io.ReadAll(apiOp.Request.Body)
+	if err != nil {
+		return types.APIObject{}, err
+	}
+	if err := json.Unmarshal(body, &f.applied); err != nil {
+		return types.APIObject{}, err
+	}
+
+	result := map[string]interface{}{}
+	data, _ := json.Marshal(f.live)
+	_ = json.Unmarshal(data, &result)
+	result["metadata"].(map[string]interface{})["resourceVersion"] = "2"
+	result["data"] = f.applied["data"]
+	return types.APIObject{Type: apiSchema.ID, ID: "default/" + id, Object: &unstructured.Unstructured{Object: result}}, nil
+}
+
+func newAPIOp(store types.Store) *types.APIRequest {
+	apiSchema := &types.APISchema{
+		Schema: &schemas.Schema{
+			ID:              "configmap",
+			ResourceMethods: []string{http.MethodGet, http.MethodPut, http.MethodPatch},
+			Attributes:      map[string]interface{}{},
+		},
+		Store: store,
+	}
+	attributes.SetNamespaced(apiSchema, true)
+	attributes.SetGVK(apiSchema, schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"})
+	attributes.SetVerbs(apiSchema, []string{"get", "list", "update", "patch"})
+	AddDiff(apiSchema)
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/configmaps/default/settings?action=diff", nil)
+	return &types.APIRequest{
+		Request:       req,
+		Method:        http.MethodPost,
+		Type:          apiSchema.ID,
+		Schema:        apiSchema,
+		Namespace:     "default",
+		Name:          "settings",
+		Action:        "diff",
+		AccessControl: &server.SchemaBasedAccess{},
+	}
+}
+
+func TestRun(t *testing.T) {
+	store := &fakeStore{
+		live: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"id":         "default/settings",
+			"metadata": map[string]interface{}{
+				"name":            "settings",
+				"namespace":       "default",
+				"resourceVersion": "1",
+				"managedFields":   []interface{}{map[string]interface{}{"manager": "kubectl"}},
+				"state":           map[string]interface{}{"name": "active"},
+			},
+			"data": map[string]interface{}{
+				"color": "blue",
+				"size":  "small",
+			},
+		},
+	}
+	apiOp := newAPIOp(store)
+	require.Contains(t, apiOp.Schema.ResourceActions, "diff")
+
+	output, err := (&Diff{}).Run(apiOp, map[string]interface{}{
+		"id": "default/settings",
+		"metadata": map[string]interface{}{
+			"resourceVersion": "1",
+			"managedFields":   []interface{}{map[string]interface{}{"manager": "kubectl"}},
+		},
+		"data": map[string]interface{}{
+			"color": "green",
+			"shape": "round",
+		},
+	})
+	require.NoError(t, err)
+
+	// the apply is a dry run, of an object Kubernetes accepts
+	assert.Equal(t, http.MethodPatch, store.request.Method)
+	assert.Equal(t, "application/apply-patch+yaml", store.request.Header.Get("Content-Type"))
+	query := store.request.URL.Query()
+	assert.Equal(t, "All", query.Get("dryRun"))
+	assert.Equal(t, DefaultFieldManager, query.Get("fieldManager"))
+	assert.Equal(t, "true", query.Get("force"))
+	assert.False(t, query.Has("action"))
+	assert.Equal(t, map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata": map[string]interface{}{
+			"name":      "settings",
+			"namespace": "default",
+		},
+		"data": map[string]interface{}{
+			"color": "green",
+			"shape": "round",
+		},
+	}, store.applied)
+
+	assert.Equal(t, []Change{
+		{Path: []string{"data", "color"}, Op: OpReplace, From: "blue", To: "green"},
+		{Path: []string{"data", "shape"}, Op: OpAdd, To: "round"},
+		{Path: []string{"data", "size"}, Op: OpRemove, From: "small"},
+	}, output.Changes)
+	assert.Equal(t, `--- live
++++ proposed
+@@ -1,7 +1,7 @@
+ apiVersion: v1
+ data:
+-  color: blue
+-  size: small
++  color: green
++  shape: round
+ kind: ConfigMap
+ metadata:
+   name: settings
+`, output.Diff)
+}
+
+func TestRunRequiresGet(t *testing.T) {
+	store := &fakeStore{live: map[string]interface{}{"metadata": map[string]interface{}{"name": "settings"}}}
+	apiOp := newAPIOp(store)
+	apiOp.Schema.ResourceMethods = []string{http.MethodPatch}
+
+	_, err := (&Diff{}).Run(apiOp, map[string]interface{}{"data": map[string]interface{}{"color": "green"}})
+
+	var apiError *apierror.APIError
+	require.ErrorAs(t, err, &apiError)
+	assert.Equal(t, validation.PermissionDenied, apiError.Code)
+	assert.Nil(t, store.request, "nothing should be applied")
+}
+
+func TestRunRedacts(t *testing.T) {
+	store := &fakeStore{
+		live: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata": map[string]interface{}{
+				"name":      "settings",
+				"namespace": "default",
+			},
+			"data": map[string]interface{}{"password": "old"},
+		},
+	}
+	apiOp := newAPIOp(store)
+	apiOp.Schema.Formatter = common.DefaultTemplateForStore(store, nil, nil, common.TemplateOptions{
+		Redactions: []common.RedactionRule{{Resources: []string{"configmaps"}, Fields: []string{"data"}}},
+	}).Formatter
+	attributes.SetGVR(apiOp.Schema, schema.GroupVersionResource{Version: "v1", Resource: "configmaps"})
+
+	output, err := (&Diff{}).Run(apiOp, map[string]interface{}{
+		"data": map[string]interface{}{"password": "new"},
+	})
+	require.NoError(t, err)
+
+	assert.Empty(t, output.Changes)
+	assert.NotContains(t, output.Diff, "old")
+	assert.NotContains(t, output.Diff, "new")
+}
+
+func TestCompare(t *testing.T) {
+	a := map[string]interface{}{
+		"spec": map[string]interface{}{
+			"containers": []interface{}{
+				map[string]interface{}{"name": "web", "image": "nginx:1"},
+				map[string]interface{}{"name": "sidecar"},
+			},
+		},
+	}
+	b := map[string]interface{}{
+		"spec": map[string]interface{}{
+			"containers": []interface{}{
+				map[string]interface{}{"name": "web", "image": "nginx:2"},
+			},
+			"replicas": float64(2),
+		},
+	}
+	assert.Equal(t, []Change{
+		{Path: []string{"spec", "containers", "0", "image"}, Op: OpReplace, From: "nginx:1", To: "nginx:2"},
+		{Path: []string{"spec", "containers", "1"}, Op: OpRemove, From: map[string]interface{}{"name": "sidecar"}},
+		{Path: []string{"spec", "replicas"}, Op: OpAdd, To: float64(2)},
+	}, compare(nil, a, b, []Change{}))
+	assert.Empty(t, compare(nil, a, a, []Change{}))
+}
+
+func TestRegister(t *testing.T) {
+	apiSchemas := types.EmptyAPISchemas()
+	Register(apiSchemas)
+	assert.NotNil(t, apiSchemas.LookupSchema("diffOutput"))
+
+	apiSchema := &types.APISchema{Schema: &schemas.Schema{ID: "apigroup", Attributes: map[string]interface{}{}}}
+	AddDiff(apiSchema)
+	assert.Empty(t, apiSchema.ActionHandlers)
+}
@@ -0,0 +1,23 @@
+package diff
+
+const (
+	OpAdd     = "add"
+	OpRemove  = "remove"
+	OpReplace = "replace"
+)
+
+type DiffOutput struct {
+	// Changes lists the fields which differ between the live and the proposed object.
+	Changes []Change `json:"changes"`
+	// Diff is a unified diff between the live and the proposed object as YAML.
+	Diff string `json:"diff"`
+}
+
+type Change struct {
+	// Path is the path of the field, with the indexes of list items as strings.
+	Path []string `json:"path"`
+	// Op is one of add, remove or replace.
+	Op   string      `json:"op"`
+	From interface{} `json:"from,omitempty"`
+	To   interface{} `json:"to,omitempty"`
+}
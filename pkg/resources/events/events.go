@@ -0,0 +1,218 @@
+// Package events adds the events link to resources, and the involvedObject parameter of event lists, which returns
+// the latest event of each reason about an object.
+package events
+
+import (
+	"cmp"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/attributes"
+	virtualevents "github.com/rancher/steve/pkg/resources/virtual/events"
+	"github.com/rancher/steve/pkg/schema"
+	"github.com/rancher/wrangler/v3/pkg/schemas/validation"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	eventSchemaID = "event"
+	// InvolvedObjectParam selects the events of an object, by UID or as <type>/<namespace>/<name>, or <type>/<name>
+	// for cluster-scoped objects
+	InvolvedObjectParam = "involvedObject"
+)
+
+// paginationParams don't apply to the events of an object, which are all returned once deduplicated
+var paginationParams = []string{"limit", "continue", "page", "pagesize", "sort"}
+
+// Template adds the involvedObject parameter to event lists. sqlCache tells whether the events are listed from the
+// SQL cache, whose filters are written differently.
+func Template(sqlCache bool) schema.Template {
+	return schema.Template{
+		ID: eventSchemaID,
+		StoreFactory: func(store types.Store) types.Store {
+			return &Store{Store: store, SQLCache: sqlCache}
+		},
+	}
+}
+
+// LinkTemplate adds the events link to every Kubernetes type.
+func LinkTemplate() schema.Template {
+	return schema.Template{
+		Formatter: Link,
+	}
+}
+
+// Link sets the events link of a resource to the events about it, if the user can list events.
+func Link(request *types.APIRequest, resource *types.RawResource) {
+	if resource.Schema == nil || attributes.Kind(resource.Schema) == "" || request.URLBuilder == nil || request.Schemas == nil {
+		return
+	}
+	eventSchema := request.Schemas.LookupSchema(eventSchemaID)
+	if eventSchema == nil || !slices.Contains(eventSchema.CollectionMethods, http.MethodGet) {
+		return
+	}
+	m, err := meta.Accessor(resource.APIObject.Object)
+	if err != nil || m.GetUID() == "" {
+		return
+	}
+
+	link := request.URLBuilder.Collection(eventSchema)
+	if m.GetNamespace() != "" {
+		link += "/" + url.PathEscape(m.GetNamespace())
+	}
+	resource.Links["events"] = link + "?" + InvolvedObjectParam + "=" + url.QueryEscape(string(m.GetUID()))
+}
+
+// Store lists the events of an object when the involvedObject parameter is set. The events are filtered on their
+// involvedObject fields, which are indexed with the SQL cache, sorted from the most recent and deduplicated by
+// reason, keeping the most recent event of each.
+type Store struct {
+	types.Store
+	// SQLCache is set when the events are listed from the SQL cache
+	SQLCache bool
+}
+
+func (s *Store) List(apiOp *types.APIRequest, apiSchema *types.APISchema) (types.APIObjectList, error) {
+	query := apiOp.Request.URL.Query()
+	involvedObject := query.Get(InvolvedObjectParam)
+	if involvedObject == "" {
+		return s.Store.List(apiOp, apiSchema)
+	}
+	fields, err := involvedObjectFields(apiOp, involvedObject)
+	if err != nil {
+		return types.APIObjectList{}, err
+	}
+	var filters []string
+	for _, field := range fields {
+		if s.SQLCache {
+			// quoted values are matched exactly by the SQL cache, rather than as substrings
+			filters = append(filters, "involvedObject."+field.name+"='"+field.value+"'")
+		} else {
+			// without SQL cache values can't be quoted, and match as substrings, so the events are checked below
+			filters = append(filters, "involvedObject."+field.name+"="+field.value)
+		}
+	}
+
+	query.Del(InvolvedObjectParam)
+	for _, param := range paginationParams {
+		query.Del(param)
+	}
+	query["filter"] = append(query["filter"], filters...)
+	filtered := apiOp.Clone()
+	filtered.Request = apiOp.Request.Clone(apiOp.Context())
+	filtered.Request.URL.RawQuery = query.Encode()
+	filtered.Query = query
+
+	list, err := s.Store.List(filtered, apiSchema)
+	if err != nil {
+		return list, err
+	}
+	list.Objects = latestByReason(involving(list.Objects, fields))
+	list.Count = len(list.Objects)
+	list.Continue = ""
+	list.Pages = 0
+	return list, nil
+}
+
+// field is a field of the involvedObject of events, and its value.
+type field struct {
+	name, value string
+}
+
+// involvedObjectFields returns the involvedObject fields of the events of the object designated by value.
+func involvedObjectFields(apiOp *types.APIRequest, value string) ([]field, error) {
+	// names and UIDs can't contain these, which would change the filter
+	if strings.ContainsAny(value, `'",=!`) {
+		return nil, apierror.NewAPIError(validation.InvalidOption, "invalid involvedObject "+value)
+	}
+	parts := strings.Split(value, "/")
+	if len(parts) == 1 {
+		return []field{{name: "uid", value: value}}, nil
+	}
+
+	var namespace, name string
+	switch len(parts) {
+	case 2:
+		name = parts[1]
+	case 3:
+		namespace, name = parts[1], parts[2]
+	default:
+		return nil, apierror.NewAPIError(validation.InvalidOption, "invalid involvedObject "+value+", must be a UID, <type>/<namespace>/<name> or <type>/<name>")
+	}
+	objSchema := apiOp.Schemas.LookupSchema(parts[0])
+	if objSchema == nil || attributes.Kind(objSchema) == "" {
+		return nil, apierror.NewAPIError(validation.InvalidOption, "unknown involvedObject type "+parts[0])
+	}
+	if (namespace != "") != attributes.Namespaced(objSchema) || name == "" {
+		return nil, apierror.NewAPIError(validation.InvalidOption, "invalid involvedObject "+value+", must be a UID, <type>/<namespace>/<name> or <type>/<name>")
+	}
+
+	fields := []field{
+		{name: "kind", value: attributes.Kind(objSchema)},
+		{name: "name", value: name},
+	}
+	if namespace != "" {
+		fields = append(fields, field{name: "namespace", value: namespace})
+	}
+	return fields, nil
+}
+
+// involving returns the events of objs whose involvedObject has exactly the given fields.
+func involving(objs []types.APIObject, fields []field) []types.APIObject {
+	result := make([]types.APIObject, 0, len(objs))
+	for _, obj := range objs {
+		u, ok := obj.Object.(*unstructured.Unstructured)
+		if !ok {
+			continue
+		}
+		matches := true
+		for _, field := range fields {
+			value, _, _ := unstructured.NestedString(u.Object, "involvedObject", field.name)
+			matches = matches && value == field.value
+		}
+		if matches {
+			result = append(result, obj)
+		}
+	}
+	return result
+}
+
+// latestByReason sorts events from the most recent and keeps the first event of each reason.
+func latestByReason(objs []types.APIObject) []types.APIObject {
+	type event struct {
+		obj    types.APIObject
+		u      *unstructured.Unstructured
+		reason string
+	}
+	events := make([]event, 0, len(objs))
+	for _, obj := range objs {
+		u, ok := obj.Object.(*unstructured.Unstructured)
+		if !ok {
+			continue
+		}
+		reason, _, _ := unstructured.NestedString(u.Object, "reason")
+		events = append(events, event{obj: obj, u: u, reason: reason})
+	}
+	slices.SortStableFunc(events, func(a, b event) int {
+		return cmp.Or(
+			virtualevents.LastTimestamp(b.u).Compare(virtualevents.LastTimestamp(a.u)),
+			strings.Compare(a.u.GetName(), b.u.GetName()),
+		)
+	})
+
+	result := make([]types.APIObject, 0, len(events))
+	seen := map[string]bool{}
+	for _, e := range events {
+		if seen[e.reason] {
+			continue
+		}
+		seen[e.reason] = true
+		result = append(result, e.obj)
+	}
+	return result
+}
@@ -0,0 +1,229 @@
+package events
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/store/empty"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/apiserver/pkg/urlbuilder"
+	"github.com/rancher/steve/pkg/attributes"
+	"github.com/rancher/steve/pkg/stores/partition/listprocessor"
+	"github.com/rancher/wrangler/v3/pkg/schemas"
+	"github.com/rancher/wrangler/v3/pkg/schemas/validation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+type fakeStore struct {
+	empty.Store
+
+	objects []*unstructured.Unstructured
+	query   url.Values
+	// listProcessor filters the objects as the store without SQL cache does
+	listProcessor bool
+}
+
+func (f *fakeStore) List(apiOp *types.APIRequest, apiSchema *types.APISchema) (types.APIObjectList, error) {
+	f.query = apiOp.Request.URL.Query()
+	result := types.APIObjectList{Continue: "next", Pages: 3}
+	var filters []listprocessor.OrFilter
+	if f.listProcessor {
+		filters = listprocessor.ParseQuery(apiOp).Filters
+	}
+	for _, obj := range f.objects {
+		if f.listProcessor && !listprocessor.Matches(obj.Object, filters) {
+			continue
+		}
+		result.Objects = append(result.Objects, types.APIObject{Type: apiSchema.ID, ID: obj.GetName(), Object: obj})
+	}
+	result.Count = len(result.Objects)
+	return result, nil
+}
+
+func event(name, reason, lastTimestamp string, involvedObject map[string]interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion":     "v1",
+		"kind":           "Event",
+		"metadata":       map[string]interface{}{"name": name, "namespace": "default"},
+		"involvedObject": involvedObject,
+		"reason":         reason,
+		"lastTimestamp":  lastTimestamp,
+		"_type":          "Warning",
+	}}
+}
+
+func newSchemas() *types.APISchemas {
+	apiSchemas := types.EmptyAPISchemas()
+	for id, gvk := range map[string]schema.GroupVersionKind{
+		"event":           {Version: "v1", Kind: "Event"},
+		"apps.deployment": {Group: "apps", Version: "v1", Kind: "Deployment"},
+		"node":            {Version: "v1", Kind: "Node"},
+	} {
+		apiSchema := &types.APISchema{Schema: &schemas.Schema{
+			ID:                id,
+			PluralName:        id + "s",
+			CollectionMethods: []string{http.MethodGet},
+			Attributes:        map[string]interface{}{},
+		}}
+		attributes.SetGVK(apiSchema, gvk)
+		attributes.SetNamespaced(apiSchema, id != "node")
+		apiSchemas.AddSchema(*apiSchema)
+	}
+	return apiSchemas
+}
+
+func newRequest(query string) *types.APIRequest {
+	req := httptest.NewRequest(http.MethodGet, "/v1/events?"+query, nil)
+	return &types.APIRequest{
+		Request: req,
+		Schemas: newSchemas(),
+		Query:   req.URL.Query(),
+	}
+}
+
+var (
+	webDeployment = map[string]interface{}{"uid": "1234-abcd", "kind": "Deployment", "name": "web", "namespace": "default"}
+	node1         = map[string]interface{}{"uid": "5678-efgh", "kind": "Node", "name": "node1"}
+)
+
+func TestList(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		involvedObject map[string]interface{}
+		wantFilters    []string
+		wantSQLFilters []string
+	}{
+		{
+			name:           "by UID",
+			query:          "involvedObject=1234-abcd&pagesize=10&page=2&sort=metadata.name&filter=_type=Warning",
+			involvedObject: webDeployment,
+			wantFilters:    []string{"_type=Warning", "involvedObject.uid=1234-abcd"},
+			wantSQLFilters: []string{"_type=Warning", "involvedObject.uid='1234-abcd'"},
+		},
+		{
+			name:           "namespaced object",
+			query:          "involvedObject=apps.deployment/default/web",
+			involvedObject: webDeployment,
+			wantFilters:    []string{"involvedObject.kind=Deployment", "involvedObject.name=web", "involvedObject.namespace=default"},
+			wantSQLFilters: []string{"involvedObject.kind='Deployment'", "involvedObject.name='web'", "involvedObject.namespace='default'"},
+		},
+		{
+			name:           "cluster-scoped object",
+			query:          "involvedObject=node/node1",
+			involvedObject: node1,
+			wantFilters:    []string{"involvedObject.kind=Node", "involvedObject.name=node1"},
+			wantSQLFilters: []string{"involvedObject.kind='Node'", "involvedObject.name='node1'"},
+		},
+	}
+	for _, test := range tests {
+		for _, sqlCache := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s sqlCache=%v", test.name, sqlCache), func(t *testing.T) {
+				// the filters without SQL cache match the names and UIDs containing the value too
+				other := map[string]interface{}{}
+				for key, value := range test.involvedObject {
+					other[key] = value.(string) + "-2"
+				}
+				other["kind"] = test.involvedObject["kind"]
+				store := &fakeStore{listProcessor: !sqlCache, objects: []*unstructured.Unstructured{
+					event("pulled-1", "Pulled", "2025-01-10T10:00:00Z", test.involvedObject),
+					event("backoff-1", "BackOff", "2025-01-10T10:05:00Z", test.involvedObject),
+					event("pulled-2", "Pulled", "2025-01-10T10:10:00Z", test.involvedObject),
+					event("scheduled", "Scheduled", "2025-01-10T09:00:00Z", test.involvedObject),
+					event("other", "Killing", "2025-01-10T11:00:00Z", other),
+				}}
+				apiOp := newRequest(test.query)
+
+				list, err := (&Store{Store: store, SQLCache: sqlCache}).List(apiOp, apiOp.Schemas.LookupSchema("event"))
+				require.NoError(t, err)
+
+				if sqlCache {
+					assert.Equal(t, url.Values{"filter": test.wantSQLFilters}, store.query)
+				} else {
+					assert.Equal(t, url.Values{"filter": test.wantFilters}, store.query)
+				}
+				var names []string
+				for _, obj := range list.Objects {
+					names = append(names, obj.ID)
+				}
+				assert.Equal(t, []string{"pulled-2", "backoff-1", "scheduled"}, names)
+				assert.Equal(t, 3, list.Count)
+				assert.Empty(t, list.Continue)
+				assert.Zero(t, list.Pages)
+				// the request of the caller is left as is
+				assert.Equal(t, test.query, apiOp.Request.URL.RawQuery)
+			})
+		}
+	}
+}
+
+func TestListWithoutInvolvedObject(t *testing.T) {
+	store := &fakeStore{objects: []*unstructured.Unstructured{event("a", "Pulled", "", nil), event("b", "Pulled", "", nil)}}
+	apiOp := newRequest("pagesize=10")
+
+	list, err := (&Store{Store: store}).List(apiOp, apiOp.Schemas.LookupSchema("event"))
+	require.NoError(t, err)
+	assert.Len(t, list.Objects, 2)
+	assert.Equal(t, "next", list.Continue)
+	assert.Equal(t, url.Values{"pagesize": {"10"}}, store.query)
+}
+
+func TestListInvalidInvolvedObject(t *testing.T) {
+	for _, value := range []string{"pod/a/b/c", "unknown/default/web", "node/default/node1", "apps.deployment/web", "x',metadata.name='y", "x,metadata.name=y"} {
+		t.Run(value, func(t *testing.T) {
+			apiOp := newRequest("involvedObject=" + url.QueryEscape(value))
+			_, err := (&Store{Store: &fakeStore{}}).List(apiOp, apiOp.Schemas.LookupSchema("event"))
+			var apiError *apierror.APIError
+			require.ErrorAs(t, err, &apiError)
+			assert.Equal(t, validation.InvalidOption, apiError.Code)
+		})
+	}
+}
+
+func TestLink(t *testing.T) {
+	apiSchemas := newSchemas()
+	urlBuilder, err := urlbuilder.NewPrefixed(httptest.NewRequest(http.MethodGet, "https://example.com/v1/nodes", nil), apiSchemas, "v1")
+	require.NoError(t, err)
+	request := &types.APIRequest{Schemas: apiSchemas, URLBuilder: urlBuilder}
+
+	tests := []struct {
+		name      string
+		schemaID  string
+		namespace string
+		want      string
+	}{
+		{name: "namespaced", schemaID: "apps.deployment", namespace: "default", want: "https://example.com/v1/events/default?involvedObject=uid-1"},
+		{name: "cluster-scoped", schemaID: "node", want: "https://example.com/v1/events?involvedObject=uid-1"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			obj := &unstructured.Unstructured{Object: map[string]interface{}{}}
+			obj.SetNamespace(test.namespace)
+			obj.SetName("web")
+			obj.SetUID("uid-1")
+			resource := &types.RawResource{
+				Schema:    apiSchemas.LookupSchema(test.schemaID),
+				Links:     map[string]string{},
+				APIObject: types.APIObject{Object: obj},
+			}
+			Link(request, resource)
+			assert.Equal(t, test.want, resource.Links["events"])
+		})
+	}
+
+	t.Run("without access to events", func(t *testing.T) {
+		request := &types.APIRequest{Schemas: types.EmptyAPISchemas(), URLBuilder: urlBuilder}
+		obj := &unstructured.Unstructured{Object: map[string]interface{}{}}
+		obj.SetUID("uid-1")
+		resource := &types.RawResource{Schema: apiSchemas.LookupSchema("node"), Links: map[string]string{}, APIObject: types.APIObject{Object: obj}}
+		Link(request, resource)
+		assert.NotContains(t, resource.Links, "events")
+	})
+}
@@ -0,0 +1,184 @@
+// Package export adds the export link of namespaces, which returns every object of a namespace as manifests which
+// can be committed and applied to another cluster.
+package export
+
+import (
+	"fmt"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/attributes"
+	"github.com/rancher/steve/pkg/schema"
+	"github.com/rancher/steve/pkg/server/writer"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// parallelism is the number of types listed at once.
+const parallelism = 4
+
+var (
+	// skippedTypes are recreated or recorded by the cluster, rather than declared
+	skippedTypes = sets.NewString(
+		"event",
+		"events.k8s.io.event",
+		"endpoints",
+		"discovery.k8s.io.endpointslice",
+		"coordination.k8s.io.lease",
+	)
+	skippedGroups = sets.NewString("metrics.k8s.io")
+)
+
+// Template adds the export link to namespaces.
+func Template() schema.Template {
+	return schema.Template{
+		ID:        "namespace",
+		Customize: AddExport,
+	}
+}
+
+func AddExport(apiSchema *types.APISchema) {
+	if apiSchema.LinkHandlers == nil {
+		apiSchema.LinkHandlers = map[string]http.Handler{}
+	}
+	apiSchema.LinkHandlers["export"] = &Namespace{}
+}
+
+// Namespace writes a namespace and the objects it holds which the user can list, as a multi-document YAML stream.
+// Objects controlled by another object, or created by Kubernetes in every namespace, are left out as applying the
+// rest recreates them.
+type Namespace struct{}
+
+func (n *Namespace) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	apiOp := types.GetAPIContext(req.Context())
+
+	objs, failed, err := n.Objects(apiOp)
+	if err != nil {
+		apiOp.WriteError(err)
+		return
+	}
+	manifests := writer.Manifests(apiOp, writer.CleanOptions{Export: true}, objs)
+
+	rw.Header().Set("Content-Type", "application/yaml")
+	if apiOp.Query.Get(writer.DownloadQuery) != "" {
+		rw.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.yaml"`, apiOp.Name))
+	}
+	for _, id := range slices.Sorted(maps.Keys(failed)) {
+		logrus.Warnf("export of namespace %s: failed to list %s: %v", apiOp.Name, id, failed[id])
+		rw.Header().Add("Warning", "299 - "+strconv.Quote(fmt.Sprintf("failed to export %s: %v", id, failed[id])))
+	}
+	rw.WriteHeader(http.StatusOK)
+	_ = writer.WriteManifests(rw, manifests)
+}
+
+// Objects returns the namespace of apiOp followed by the objects to export, grouped by type. A type failing to list
+// doesn't fail the export, it is left out and its error is returned in failed, by schema ID.
+func (n *Namespace) Objects(apiOp *types.APIRequest) (objs []types.APIObject, failed map[string]error, err error) {
+	namespace, err := apiOp.Schema.Store.ByID(apiOp, apiOp.Schema, apiOp.Name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ids := make([]string, 0, len(apiOp.Schemas.Schemas))
+	for id := range apiOp.Schemas.Schemas {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+
+	var listOps []*types.APIRequest
+	for _, id := range ids {
+		apiSchema := apiOp.Schemas.Schemas[id]
+		if !exportable(apiSchema) {
+			continue
+		}
+		listOp := listRequest(apiOp, apiSchema)
+		if err := apiOp.AccessControl.CanList(listOp, apiSchema); err != nil {
+			continue
+		}
+		listOps = append(listOps, listOp)
+	}
+
+	var (
+		lists = make([]types.APIObjectList, len(listOps))
+		errs  = make([]error, len(listOps))
+		eg    errgroup.Group
+	)
+	eg.SetLimit(parallelism)
+	for i, listOp := range listOps {
+		eg.Go(func() error {
+			lists[i], errs[i] = listOp.Schema.Store.List(listOp, listOp.Schema)
+			return nil
+		})
+	}
+	_ = eg.Wait()
+
+	objs = []types.APIObject{namespace}
+	for i, list := range lists {
+		if errs[i] != nil {
+			if failed == nil {
+				failed = map[string]error{}
+			}
+			failed[listOps[i].Schema.ID] = errs[i]
+			continue
+		}
+		for _, obj := range list.Objects {
+			if !generated(obj) {
+				objs = append(objs, obj)
+			}
+		}
+	}
+	return objs, failed, nil
+}
+
+func exportable(apiSchema *types.APISchema) bool {
+	gvk := attributes.GVK(apiSchema)
+	return apiSchema.Store != nil &&
+		gvk.Kind != "" &&
+		attributes.Namespaced(apiSchema) &&
+		slices.Contains(attributes.Verbs(apiSchema), "list") &&
+		!skippedTypes.Has(apiSchema.ID) &&
+		!skippedGroups.Has(gvk.Group)
+}
+
+// listRequest returns a copy of apiOp listing every object of apiSchema in the namespace of apiOp.
+func listRequest(apiOp *types.APIRequest, apiSchema *types.APISchema) *types.APIRequest {
+	u := *apiOp.Request.URL
+	u.RawQuery = ""
+
+	result := apiOp.Clone()
+	result.Request = apiOp.Request.Clone(apiOp.Context())
+	result.Request.URL = &u
+	result.Schema = apiSchema
+	result.Type = apiSchema.ID
+	result.Namespace = apiOp.Name
+	result.Name = ""
+	result.Link = ""
+	result.Query = url.Values{}
+	return result
+}
+
+// generated returns whether obj is created from another object, or by Kubernetes, rather than declared.
+func generated(obj types.APIObject) bool {
+	object := obj.Data()
+	for _, ref := range object.Slice("metadata", "ownerReferences") {
+		if ref.Bool("controller") {
+			return true
+		}
+	}
+
+	name := object.String("metadata", "name")
+	switch obj.Type {
+	case "configmap":
+		return name == "kube-root-ca.crt"
+	case "serviceaccount":
+		return name == "default"
+	case "secret":
+		return object.String("type") == "kubernetes.io/service-account-token"
+	}
+	return false
+}
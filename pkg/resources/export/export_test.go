@@ -0,0 +1,192 @@
+package export
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/rancher/apiserver/pkg/server"
+	"github.com/rancher/apiserver/pkg/store/empty"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/apiserver/pkg/urlbuilder"
+	"github.com/rancher/steve/pkg/attributes"
+	"github.com/rancher/wrangler/v3/pkg/schemas"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+type fakeStore struct {
+	empty.Store
+
+	objects    []map[string]interface{}
+	namespaces []string
+	err        error
+}
+
+func (f *fakeStore) ByID(_ *types.APIRequest, apiSchema *types.APISchema, id string) (types.APIObject, error) {
+	return toAPI(apiSchema, f.objects[0]), nil
+}
+
+func (f *fakeStore) List(apiOp *types.APIRequest, apiSchema *types.APISchema) (types.APIObjectList, error) {
+	f.namespaces = append(f.namespaces, apiOp.Namespace)
+	if f.err != nil {
+		return types.APIObjectList{}, f.err
+	}
+	var result types.APIObjectList
+	for _, obj := range f.objects {
+		result.Objects = append(result.Objects, toAPI(apiSchema, obj))
+	}
+	return result, nil
+}
+
+func toAPI(apiSchema *types.APISchema, obj map[string]interface{}) types.APIObject {
+	u := &unstructured.Unstructured{Object: obj}
+	id := u.GetName()
+	if u.GetNamespace() != "" {
+		id = u.GetNamespace() + "/" + id
+	}
+	return types.APIObject{Type: apiSchema.ID, ID: id, Object: u}
+}
+
+func object(kind, name string, metadata map[string]interface{}) map[string]interface{} {
+	if metadata == nil {
+		metadata = map[string]interface{}{}
+	}
+	metadata["name"] = name
+	metadata["namespace"] = "team"
+	metadata["uid"] = "uid-" + name
+	metadata["resourceVersion"] = "1"
+	return map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       kind,
+		"metadata":   metadata,
+	}
+}
+
+func addSchema(apiSchemas *types.APISchemas, id, group, kind string, namespaced bool, store types.Store) {
+	apiSchema := &types.APISchema{
+		Schema: &schemas.Schema{
+			ID:                id,
+			CollectionMethods: []string{http.MethodGet},
+			ResourceMethods:   []string{http.MethodGet},
+			Attributes:        map[string]interface{}{},
+		},
+		Store: store,
+	}
+	attributes.SetGVK(apiSchema, schema.GroupVersionKind{Group: group, Version: "v1", Kind: kind})
+	attributes.SetNamespaced(apiSchema, namespaced)
+	attributes.SetVerbs(apiSchema, []string{"get", "list"})
+	apiSchemas.AddSchema(*apiSchema)
+}
+
+func serveExport(t *testing.T, apiSchemas *types.APISchemas) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/v1/namespaces/team?link=export", nil)
+	rw := httptest.NewRecorder()
+	urlBuilder, err := urlbuilder.NewPrefixed(req, apiSchemas, "v1")
+	require.NoError(t, err)
+	apiOp := &types.APIRequest{
+		Request:       req,
+		Response:      rw,
+		Method:        http.MethodGet,
+		Type:          "namespace",
+		Name:          "team",
+		Link:          "export",
+		Query:         url.Values{"link": []string{"export"}},
+		Schema:        apiSchemas.LookupSchema("namespace"),
+		Schemas:       apiSchemas,
+		URLBuilder:    urlBuilder,
+		AccessControl: &server.SchemaBasedAccess{},
+	}
+
+	types.StoreAPIContext(apiOp)
+	(&Namespace{}).ServeHTTP(rw, apiOp.Request)
+	return rw
+
+}
+
+func TestNamespaceExport(t *testing.T) {
+	namespaces := &fakeStore{objects: []map[string]interface{}{{
+		"apiVersion": "v1",
+		"kind":       "Namespace",
+		"metadata":   map[string]interface{}{"name": "team", "uid": "uid-team"},
+		"status":     map[string]interface{}{"phase": "Active"},
+	}}}
+	configMaps := &fakeStore{objects: []map[string]interface{}{
+		object("ConfigMap", "settings", map[string]interface{}{
+			"annotations": map[string]interface{}{"kubectl.kubernetes.io/last-applied-configuration": "{}"},
+		}),
+		object("ConfigMap", "kube-root-ca.crt", nil),
+	}}
+	pods := &fakeStore{objects: []map[string]interface{}{
+		object("Pod", "web-1", map[string]interface{}{
+			"ownerReferences": []interface{}{map[string]interface{}{"kind": "ReplicaSet", "name": "web", "controller": true}},
+		}),
+		object("Pod", "debug", nil),
+	}}
+	events := &fakeStore{objects: []map[string]interface{}{object("Event", "e", nil)}}
+
+	apiSchemas := types.EmptyAPISchemas()
+	addSchema(apiSchemas, "namespace", "", "Namespace", false, namespaces)
+	addSchema(apiSchemas, "configmap", "", "ConfigMap", true, configMaps)
+	addSchema(apiSchemas, "pod", "", "Pod", true, pods)
+	addSchema(apiSchemas, "event", "", "Event", true, events)
+
+	rw := serveExport(t, apiSchemas)
+	require.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, "application/yaml", rw.Header().Get("Content-Type"))
+	assert.Equal(t, `apiVersion: v1
+kind: Namespace
+metadata:
+  name: team
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+  namespace: team
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: debug
+  namespace: team
+`, rw.Body.String())
+	assert.Equal(t, []string{"team"}, configMaps.namespaces)
+	assert.Empty(t, events.namespaces)
+}
+
+func TestNamespaceExportSkipsFailedTypes(t *testing.T) {
+	namespaces := &fakeStore{objects: []map[string]interface{}{{
+		"apiVersion": "v1",
+		"kind":       "Namespace",
+		"metadata":   map[string]interface{}{"name": "team"},
+	}}}
+	configMaps := &fakeStore{objects: []map[string]interface{}{object("ConfigMap", "settings", nil)}}
+	secrets := &fakeStore{err: errors.New(`secrets "team" is forbidden`)}
+
+	apiSchemas := types.EmptyAPISchemas()
+	addSchema(apiSchemas, "namespace", "", "Namespace", false, namespaces)
+	addSchema(apiSchemas, "configmap", "", "ConfigMap", true, configMaps)
+	addSchema(apiSchemas, "secret", "", "Secret", true, secrets)
+
+	rw := serveExport(t, apiSchemas)
+
+	require.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, []string{`299 - "failed to export secret: secrets \"team\" is forbidden"`}, rw.Header().Values("Warning"))
+	assert.Equal(t, `apiVersion: v1
+kind: Namespace
+metadata:
+  name: team
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+  namespace: team
+`, rw.Body.String())
+}
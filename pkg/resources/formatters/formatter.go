@@ -60,12 +60,13 @@ func HandleHelmData(request *types.APIRequest, resource *types.RawResource) {
 	}
 }
 
-func Pod(_ *types.APIRequest, resource *types.RawResource) {
+func Pod(request *types.APIRequest, resource *types.RawResource) {
 	data := resource.APIObject.Data()
 	fields := data.StringSlice("metadata", "fields")
 	if len(fields) > 2 {
 		data.SetNested(convert.LowerTitle(fields[2]), "metadata", "state", "name")
 	}
+	podLogLink(request, resource)
 }
 
 // decodeHelm3 receives a helm3 release data string, decodes the string data using the standard base64 library
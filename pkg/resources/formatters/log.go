@@ -0,0 +1,257 @@
+package formatters
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/rancher/steve/pkg/schema"
+	"github.com/rancher/steve/pkg/stores/proxy"
+	"github.com/rancher/wrangler/v3/pkg/schemas/validation"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sschema "k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+)
+
+var logUpgrader = websocket.Upgrader{
+	HandshakeTimeout:  60 * time.Second,
+	EnableCompression: true,
+}
+
+// PodLogTemplate adds the log link to pods.
+func PodLogTemplate(cg proxy.ClientGetter) schema.Template {
+	return schema.Template{
+		ID: "pod",
+		Customize: func(apiSchema *types.APISchema) {
+			AddPodLog(apiSchema, cg)
+		},
+	}
+}
+
+func AddPodLog(apiSchema *types.APISchema, cg proxy.ClientGetter) {
+	if apiSchema.LinkHandlers == nil {
+		apiSchema.LinkHandlers = map[string]http.Handler{}
+	}
+	apiSchema.LinkHandlers["log"] = &PodLog{cg: cg}
+}
+
+// podLogLink sets the log link of a pod if the user can get its logs, which the pods/log subresource grants
+// separately from the pod.
+func podLogLink(request *types.APIRequest, resource *types.RawResource) {
+	if resource.Schema == nil || resource.Schema.LinkHandlers["log"] == nil {
+		return
+	}
+	namespace, name := resource.APIObject.Namespace(), resource.APIObject.Name()
+	accessSet := accesscontrol.AccessSetFromAPIRequest(request)
+	if accessSet != nil && !accessSet.Grants("get", k8sschema.GroupResource{Resource: "pods/log"}, namespace, name) {
+		delete(resource.Links, "log")
+		return
+	}
+	if request.URLBuilder != nil {
+		resource.Links["log"] = request.URLBuilder.Link(resource.Schema, resource.ID, "log")
+	}
+}
+
+// PodLog streams the logs of a pod as lines, over a websocket if the request asks for an upgrade, or as chunked
+// plain text otherwise. With allContainers, the lines of every container are interleaved as they come and prefixed
+// with the name of the container.
+type PodLog struct {
+	cg proxy.ClientGetter
+}
+
+type logOptions struct {
+	containers []string
+	prefix     bool
+	podOptions corev1.PodLogOptions
+}
+
+func (p *PodLog) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	apiOp := types.GetAPIContext(req.Context())
+
+	client, err := p.cg.K8sInterface(apiOp)
+	if err != nil {
+		apiOp.WriteError(err)
+		return
+	}
+	opts, err := parseLogOptions(apiOp, client)
+	if err != nil {
+		apiOp.WriteError(err)
+		return
+	}
+
+	// open the streams before writing anything, so that errors, like a forbidden pod, are returned as such
+	ctx, cancel := context.WithCancel(req.Context())
+	defer cancel()
+	var (
+		streams  = map[string]io.ReadCloser{}
+		failed   []string
+		firstErr error
+	)
+	defer func() {
+		for _, stream := range streams {
+			stream.Close()
+		}
+	}()
+	for _, container := range opts.containers {
+		podOptions := opts.podOptions
+		podOptions.Container = container
+		stream, err := client.CoreV1().Pods(apiOp.Namespace).GetLogs(apiOp.Name, &podOptions).Stream(ctx)
+		if err != nil {
+			// containers which haven't started yet have no logs, which shouldn't hide the logs of the others
+			firstErr = cmp.Or(firstErr, err)
+			failed = append(failed, "["+container+"] "+err.Error())
+			continue
+		}
+		streams[container] = stream
+	}
+	if len(streams) == 0 {
+		apiOp.WriteError(firstErr)
+		return
+	}
+
+	var write func(line string) error
+	if websocket.IsWebSocketUpgrade(req) {
+		conn, err := logUpgrader.Upgrade(rw, req, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		// the client sends nothing, reading only notices when it goes away
+		go func() {
+			defer cancel()
+			for {
+				if _, _, err := conn.NextReader(); err != nil {
+					return
+				}
+			}
+		}()
+		write = func(line string) error {
+			return conn.WriteMessage(websocket.TextMessage, []byte(line))
+		}
+	} else {
+		rc := http.NewResponseController(rw)
+		rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		rw.Header().Set("X-Content-Type-Options", "nosniff")
+		rw.WriteHeader(http.StatusOK)
+		write = func(line string) error {
+			if _, err := io.WriteString(rw, line+"\n"); err != nil {
+				return err
+			}
+			return rc.Flush()
+		}
+	}
+
+	lines := make(chan string, 100)
+	for _, line := range failed {
+		if err := write(line); err != nil {
+			return
+		}
+	}
+	var wg sync.WaitGroup
+	for container, stream := range streams {
+		prefix := ""
+		if opts.prefix {
+			prefix = "[" + container + "] "
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			readLines(ctx, stream, prefix, lines)
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(lines)
+	}()
+
+	for line := range lines {
+		if err := write(line); err != nil {
+			cancel()
+			break
+		}
+	}
+}
+
+// readLines sends the lines of r to lines, without their newline, until r ends or ctx is done.
+func readLines(ctx context.Context, r io.Reader, prefix string, lines chan<- string) {
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadString('\n')
+		if line != "" {
+			select {
+			case lines <- prefix + strings.TrimSuffix(line, "\n"):
+			case <-ctx.Done():
+				return
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func parseLogOptions(apiOp *types.APIRequest, client kubernetes.Interface) (logOptions, error) {
+	var (
+		opts = logOptions{
+			podOptions: corev1.PodLogOptions{Follow: true},
+		}
+		err error
+	)
+
+	if follow := apiOp.Query.Get("follow"); follow != "" {
+		if opts.podOptions.Follow, err = strconv.ParseBool(follow); err != nil {
+			return opts, apierror.NewAPIError(validation.InvalidOption, "invalid follow: "+follow)
+		}
+	}
+	opts.podOptions.Timestamps = apiOp.Query.Get("timestamps") == "true"
+	opts.podOptions.Previous = apiOp.Query.Get("previous") == "true"
+	for param, value := range map[string]**int64{
+		"tailLines":    &opts.podOptions.TailLines,
+		"sinceSeconds": &opts.podOptions.SinceSeconds,
+	} {
+		if v := apiOp.Query.Get(param); v != "" {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || n < 0 || (param == "sinceSeconds" && n == 0) {
+				return opts, apierror.NewAPIError(validation.InvalidOption, "invalid "+param+": "+v)
+			}
+			*value = &n
+		}
+	}
+
+	container := apiOp.Query.Get("container")
+	if apiOp.Query.Get("allContainers") != "true" {
+		// with no container, Kubernetes picks the default container of the pod, or fails if it has several
+		opts.containers = []string{container}
+		return opts, nil
+	}
+	if container != "" {
+		return opts, apierror.NewAPIError(validation.InvalidOption, "container and allContainers are exclusive")
+	}
+
+	pod, err := client.CoreV1().Pods(apiOp.Namespace).Get(apiOp.Context(), apiOp.Name, metav1.GetOptions{})
+	if err != nil {
+		return opts, err
+	}
+	for _, c := range pod.Spec.InitContainers {
+		opts.containers = append(opts.containers, c.Name)
+	}
+	for _, c := range pod.Spec.Containers {
+		opts.containers = append(opts.containers, c.Name)
+	}
+	for _, c := range pod.Spec.EphemeralContainers {
+		opts.containers = append(opts.containers, c.Name)
+	}
+	opts.prefix = true
+	return opts, nil
+}
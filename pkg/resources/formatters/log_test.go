@@ -0,0 +1,153 @@
+package formatters
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/apiserver/pkg/urlbuilder"
+	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/rancher/steve/pkg/resources/resourcetest"
+	"github.com/rancher/wrangler/v3/pkg/schemas"
+	"github.com/rancher/wrangler/v3/pkg/schemas/validation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	k8sschema "k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/utils/ptr"
+)
+
+func newLogRequest(query string) *types.APIRequest {
+	req := httptest.NewRequest(http.MethodGet, "/v1/pods/default/web?link=log&"+query, nil)
+	return types.StoreAPIContext(&types.APIRequest{
+		Request:   req,
+		Method:    http.MethodGet,
+		Type:      "pod",
+		Namespace: "default",
+		Name:      "web",
+		Link:      "log",
+		Query:     req.URL.Query(),
+	})
+}
+
+func logOptionsOf(client *fake.Clientset) map[string]corev1.PodLogOptions {
+	result := map[string]corev1.PodLogOptions{}
+	for _, action := range client.Actions() {
+		if action.GetSubresource() == "log" {
+			opts := action.(k8stesting.GenericActionImpl).Value.(*corev1.PodLogOptions)
+			result[opts.Container] = *opts
+		}
+	}
+	return result
+}
+
+func TestPodLog(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Spec: corev1.PodSpec{
+			InitContainers: []corev1.Container{{Name: "init"}},
+			Containers:     []corev1.Container{{Name: "app"}, {Name: "sidecar"}},
+		},
+	}
+	tests := []struct {
+		name      string
+		query     string
+		wantLines []string
+		wantOpts  map[string]corev1.PodLogOptions
+	}{
+		{
+			name:      "default container",
+			wantLines: []string{"fake logs"},
+			wantOpts:  map[string]corev1.PodLogOptions{"": {Follow: true}},
+		},
+		{
+			name:      "options",
+			query:     "container=app&tailLines=10&sinceSeconds=60&timestamps=true&previous=true&follow=false",
+			wantLines: []string{"fake logs"},
+			wantOpts: map[string]corev1.PodLogOptions{
+				"app": {Container: "app", TailLines: ptr.To[int64](10), SinceSeconds: ptr.To[int64](60), Timestamps: true, Previous: true},
+			},
+		},
+		{
+			name:      "all containers",
+			query:     "allContainers=true&tailLines=5",
+			wantLines: []string{"[app] fake logs", "[init] fake logs", "[sidecar] fake logs"},
+			wantOpts: map[string]corev1.PodLogOptions{
+				"init":    {Container: "init", Follow: true, TailLines: ptr.To[int64](5)},
+				"app":     {Container: "app", Follow: true, TailLines: ptr.To[int64](5)},
+				"sidecar": {Container: "sidecar", Follow: true, TailLines: ptr.To[int64](5)},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := fake.NewSimpleClientset(pod)
+			apiOp := newLogRequest(test.query)
+			rw := httptest.NewRecorder()
+
+			(&PodLog{cg: &resourcetest.ClientGetter{K8s: client}}).ServeHTTP(rw, apiOp.Request)
+
+			assert.Equal(t, http.StatusOK, rw.Code)
+			assert.Equal(t, "text/plain; charset=utf-8", rw.Header().Get("Content-Type"))
+			lines := strings.Split(strings.TrimSuffix(rw.Body.String(), "\n"), "\n")
+			slices.Sort(lines)
+			assert.Equal(t, test.wantLines, lines)
+			assert.Equal(t, test.wantOpts, logOptionsOf(client))
+		})
+	}
+}
+
+func TestParseLogOptionsInvalid(t *testing.T) {
+	for _, query := range []string{"tailLines=-1", "tailLines=x", "sinceSeconds=0", "follow=maybe", "allContainers=true&container=app"} {
+		t.Run(query, func(t *testing.T) {
+			_, err := parseLogOptions(newLogRequest(query), fake.NewSimpleClientset())
+			var apiError *apierror.APIError
+			require.ErrorAs(t, err, &apiError)
+			assert.Equal(t, validation.InvalidOption, apiError.Code)
+		})
+	}
+}
+
+func TestPodLogLink(t *testing.T) {
+	apiSchema := &types.APISchema{Schema: &schemas.Schema{ID: "pod", PluralName: "pods"}}
+	AddPodLog(apiSchema, &resourcetest.ClientGetter{})
+
+	accessSet := &accesscontrol.AccessSet{}
+	accessSet.Add("get", k8sschema.GroupResource{Resource: "pods/log"}, accesscontrol.Access{Namespace: "allowed", ResourceName: accesscontrol.All})
+	apiSchemas := types.EmptyAPISchemas()
+	accesscontrol.SetAccessSetAttribute(apiSchemas, accessSet)
+
+	urlBuilder, err := urlbuilder.NewPrefixed(httptest.NewRequest(http.MethodGet, "https://example.com/v1/pods", nil), apiSchemas, "v1")
+	require.NoError(t, err)
+	request := &types.APIRequest{Schemas: apiSchemas, URLBuilder: urlBuilder}
+
+	for namespace, want := range map[string]bool{"allowed": true, "denied": false} {
+		t.Run(namespace, func(t *testing.T) {
+			obj := &unstructured.Unstructured{}
+			obj.SetNamespace(namespace)
+			obj.SetName("web")
+			resource := &types.RawResource{
+				ID:        namespace + "/web",
+				Schema:    apiSchema,
+				Links:     map[string]string{"log": "default"},
+				APIObject: types.APIObject{Object: obj},
+			}
+
+			Pod(request, resource)
+
+			if want {
+				assert.Equal(t, "https://example.com/v1/pods/"+namespace+"/web/log", resource.Links["log"])
+			} else {
+				assert.NotContains(t, resource.Links, "log")
+			}
+		})
+	}
+}
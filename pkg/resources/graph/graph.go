@@ -0,0 +1,299 @@
+// Package graph adds the graph link, which returns the objects related to an object, and the objects related to
+// them in turn, as nodes and edges.
+package graph
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/rancher/steve/pkg/attributes"
+	"github.com/rancher/steve/pkg/policy"
+	"github.com/rancher/steve/pkg/schema"
+	"github.com/rancher/steve/pkg/summarycache"
+	"github.com/rancher/wrangler/v3/pkg/schemas/validation"
+	"github.com/rancher/wrangler/v3/pkg/summary"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	runtimeschema "k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	defaultDepth = 2
+	maxDepth     = 10
+	// maxNodes bounds the response for objects related to a large part of the cluster, like a node or a namespace
+	maxNodes = 1000
+)
+
+// Cache returns the relationships of objects and the objects they refer to. Implemented by the summaryCache
+// in pkg/summarycache.
+type Cache interface {
+	SummaryAndRelationship(obj runtime.Object) (*summary.SummarizedObject, []summarycache.Relationship)
+	Get(gvk runtimeschema.GroupVersionKind, namespace, name string) (runtime.Object, bool)
+	Select(gvk runtimeschema.GroupVersionKind, namespace string, selector labels.Selector) []runtime.Object
+}
+
+func Register(apiSchemas *types.APISchemas) {
+	apiSchemas.MustImportAndCustomize(&GraphOutput{}, nil)
+}
+
+// Template adds the graph link to every Kubernetes type which supports get. Nothing is added without a cache. pol may
+// be nil if no policy should be applied on top of RBAC.
+func Template(summaryCache *summarycache.SummaryCache, pol policy.Policy) schema.Template {
+	if summaryCache == nil {
+		return schema.Template{}
+	}
+	return schema.Template{
+		Customize: func(apiSchema *types.APISchema) {
+			AddGraph(apiSchema, summaryCache, pol)
+		},
+	}
+}
+
+func AddGraph(apiSchema *types.APISchema, cache Cache, pol policy.Policy) {
+	if attributes.GVK(apiSchema).Kind == "" || !slices.Contains(attributes.Verbs(apiSchema), "get") {
+		return
+	}
+	if apiSchema.LinkHandlers == nil {
+		apiSchema.LinkHandlers = map[string]http.Handler{}
+	}
+	apiSchema.LinkHandlers["graph"] = &Graph{cache: cache, policy: pol}
+}
+
+// Graph walks the relationships of the summary cache from the object of the request, breadth first. Objects of types
+// the user can't see, or which the user can't get or the policy denies, are left out along with everything only
+// reachable through them.
+type Graph struct {
+	cache  Cache
+	policy policy.Policy
+}
+
+func (g *Graph) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	apiOp := types.GetAPIContext(req.Context())
+
+	output, err := g.Run(apiOp)
+	if err != nil {
+		apiOp.WriteError(err)
+		return
+	}
+	apiOp.WriteResponse(http.StatusOK, types.APIObject{
+		Type:   "graphOutput",
+		Object: output,
+	})
+}
+
+type options struct {
+	depth         int
+	direction     string
+	relationships []string
+}
+
+func parseOptions(apiOp *types.APIRequest) (options, error) {
+	opts := options{
+		depth:         defaultDepth,
+		direction:     DirectionBoth,
+		relationships: []string{RelationshipOwners, RelationshipChildren, RelationshipSelectors, RelationshipReferences},
+	}
+
+	if depth := apiOp.Query.Get("depth"); depth != "" {
+		n, err := strconv.Atoi(depth)
+		if err != nil || n < 1 || n > maxDepth {
+			return opts, apierror.NewAPIError(validation.InvalidOption, "depth must be between 1 and "+strconv.Itoa(maxDepth))
+		}
+		opts.depth = n
+	}
+
+	if direction := apiOp.Query.Get("direction"); direction != "" {
+		if direction != DirectionInbound && direction != DirectionOutbound && direction != DirectionBoth {
+			return opts, apierror.NewAPIError(validation.InvalidOption, "direction must be one of inbound, outbound or both")
+		}
+		opts.direction = direction
+	}
+
+	if rels := apiOp.Query["relationships"]; len(rels) > 0 {
+		var result []string
+		for _, rel := range rels {
+			for _, r := range strings.Split(rel, ",") {
+				if !slices.Contains(opts.relationships, r) {
+					return opts, apierror.NewAPIError(validation.InvalidOption, "unknown relationship "+r+", must be one of "+strings.Join(opts.relationships, ", "))
+				}
+				result = append(result, r)
+			}
+		}
+		opts.relationships = result
+	}
+
+	return opts, nil
+}
+
+// follows returns whether rel is followed with opts, and whether it points to the object it belongs to.
+func (o options) follows(rel summarycache.Relationship) (follow, inbound bool) {
+	inbound = rel.FromID != ""
+	if (inbound && o.direction == DirectionOutbound) || (!inbound && o.direction == DirectionInbound) {
+		return false, inbound
+	}
+
+	category := RelationshipReferences
+	switch {
+	case rel.Rel == "owner" && inbound:
+		category = RelationshipOwners
+	case rel.Rel == "owner":
+		category = RelationshipChildren
+	case rel.Selector != "":
+		category = RelationshipSelectors
+	}
+	return slices.Contains(o.relationships, category), inbound
+}
+
+type target struct {
+	node GraphNode
+	obj  runtime.Object
+	rels []summarycache.Relationship
+}
+
+// Run returns the graph of the object of apiOp.
+func (g *Graph) Run(apiOp *types.APIRequest) (*GraphOutput, error) {
+	opts, err := parseOptions(apiOp)
+	if err != nil {
+		return nil, err
+	}
+
+	obj, ok := g.cache.Get(attributes.GVK(apiOp.Schema), apiOp.Namespace, apiOp.Name)
+	if !ok {
+		return nil, apierror.NewAPIError(validation.NotFound, "not found in the summary cache")
+	}
+
+	root := newTarget(apiOp.Schema, apiOp.Namespace, apiOp.Name, obj)
+	g.summarize(&root)
+
+	var (
+		output = &GraphOutput{Root: root.node.ID, Nodes: []GraphNode{root.node}, Edges: []GraphEdge{}}
+		seen   = map[string]bool{root.node.ID: true}
+		edges  = map[GraphEdge]bool{}
+		queue  = []target{root}
+	)
+
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if current.node.Depth >= opts.depth {
+			continue
+		}
+
+		for _, rel := range current.rels {
+			follow, inbound := opts.follows(rel)
+			if !follow {
+				continue
+			}
+			for _, next := range g.targets(apiOp, rel) {
+				if !seen[next.node.ID] {
+					if len(output.Nodes) >= maxNodes {
+						output.Truncated = true
+						continue
+					}
+					seen[next.node.ID] = true
+					next.node.Depth = current.node.Depth + 1
+					g.summarize(&next)
+					output.Nodes = append(output.Nodes, next.node)
+					if next.obj != nil {
+						queue = append(queue, next)
+					}
+				}
+
+				edge := GraphEdge{From: current.node.ID, To: next.node.ID, Rel: rel.Rel, Selector: rel.Selector}
+				if inbound {
+					edge.From, edge.To = edge.To, edge.From
+				}
+				if !edges[edge] {
+					edges[edge] = true
+					output.Edges = append(output.Edges, edge)
+				}
+			}
+		}
+	}
+
+	return output, nil
+}
+
+// targets returns the objects rel refers to which the user can get and the policy allows, sorted by ID.
+func (g *Graph) targets(apiOp *types.APIRequest, rel summarycache.Relationship) []target {
+	schemaID, id := rel.ToType, rel.ToID
+	if rel.FromID != "" {
+		schemaID, id = rel.FromType, rel.FromID
+	}
+	apiSchema := apiOp.Schemas.LookupSchema(schemaID)
+	if apiSchema == nil {
+		return nil
+	}
+	access := accesscontrol.GetAccessListMap(apiSchema)
+	gvk := attributes.GVK(apiSchema)
+
+	if id != "" {
+		namespace, name := "", id
+		if i := strings.Index(id, "/"); i >= 0 {
+			namespace, name = id[:i], id[i+1:]
+		}
+		if !access.Grants("get", namespace, name) {
+			return nil
+		}
+		obj, _ := g.cache.Get(gvk, namespace, name)
+		if !policy.AllowedObject(apiOp, apiSchema, g.policy, "get", namespace, name, obj) {
+			return nil
+		}
+		return []target{newTarget(apiSchema, namespace, name, obj)}
+	}
+
+	selector, err := labels.Parse(rel.Selector)
+	if rel.Selector == "" || err != nil {
+		return nil
+	}
+	var result []target
+	for _, obj := range g.cache.Select(gvk, rel.ToNamespace, selector) {
+		m, err := meta.Accessor(obj)
+		if err != nil || !access.Grants("get", m.GetNamespace(), m.GetName()) ||
+			!policy.AllowedObject(apiOp, apiSchema, g.policy, "get", m.GetNamespace(), m.GetName(), obj) {
+			continue
+		}
+		result = append(result, newTarget(apiSchema, m.GetNamespace(), m.GetName(), obj))
+	}
+	slices.SortFunc(result, func(a, b target) int {
+		return strings.Compare(a.node.ID, b.node.ID)
+	})
+	return result
+}
+
+func newTarget(apiSchema *types.APISchema, namespace, name string, obj runtime.Object) target {
+	id := apiSchema.ID + "/" + name
+	if namespace != "" {
+		id = apiSchema.ID + "/" + namespace + "/" + name
+	}
+	return target{
+		node: GraphNode{
+			ID:        id,
+			Type:      apiSchema.ID,
+			Namespace: namespace,
+			Name:      name,
+			Missing:   obj == nil,
+		},
+		obj: obj,
+	}
+}
+
+// summarize sets the state of the node of t and the relationships to follow from it. It is only called once per
+// node, as it looks up every object t refers to.
+func (g *Graph) summarize(t *target) {
+	if t.obj == nil {
+		return
+	}
+	summarized, rels := g.cache.SummaryAndRelationship(t.obj)
+	t.rels = rels
+	t.node.State = summarized.State
+	t.node.Message = strings.Join(summarized.Message, "; ")
+	t.node.Error = summarized.Error
+	t.node.Transitioning = summarized.Transitioning
+}
@@ -0,0 +1,280 @@
+package graph
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/rancher/steve/pkg/attributes"
+	"github.com/rancher/steve/pkg/policy"
+	"github.com/rancher/steve/pkg/summarycache"
+	"github.com/rancher/wrangler/v3/pkg/schemas"
+	"github.com/rancher/wrangler/v3/pkg/schemas/validation"
+	"github.com/rancher/wrangler/v3/pkg/summary"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+type fakeCache struct {
+	objects []*unstructured.Unstructured
+	rels    map[string][]summarycache.Relationship
+}
+
+func (f *fakeCache) SummaryAndRelationship(obj runtime.Object) (*summary.SummarizedObject, []summarycache.Relationship) {
+	u := obj.(*unstructured.Unstructured)
+	return &summary.SummarizedObject{Summary: summary.Summary{State: "active"}}, f.rels[u.GetKind()+"/"+u.GetName()]
+}
+
+func (f *fakeCache) Get(gvk schema.GroupVersionKind, namespace, name string) (runtime.Object, bool) {
+	for _, obj := range f.objects {
+		if obj.GroupVersionKind() == gvk && obj.GetNamespace() == namespace && obj.GetName() == name {
+			return obj, true
+		}
+	}
+	return nil, false
+}
+
+func (f *fakeCache) Select(gvk schema.GroupVersionKind, namespace string, selector labels.Selector) []runtime.Object {
+	var result []runtime.Object
+	for _, obj := range f.objects {
+		if obj.GroupVersionKind() == gvk && obj.GetNamespace() == namespace && selector.Matches(labels.Set(obj.GetLabels())) {
+			result = append(result, obj)
+		}
+	}
+	return result
+}
+
+func object(apiVersion, kind, name string, labels map[string]string) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{Object: map[string]interface{}{}}
+	u.SetAPIVersion(apiVersion)
+	u.SetKind(kind)
+	u.SetNamespace("default")
+	u.SetName(name)
+	u.SetLabels(labels)
+	return u
+}
+
+func newCache() *fakeCache {
+	return &fakeCache{
+		objects: []*unstructured.Unstructured{
+			object("apps/v1", "Deployment", "web", nil),
+			object("apps/v1", "ReplicaSet", "web-1", nil),
+			object("v1", "Pod", "web-1-a", map[string]string{"app": "web"}),
+			object("v1", "Pod", "web-1-b", map[string]string{"app": "web"}),
+			object("v1", "Pod", "db", map[string]string{"app": "db"}),
+			object("v1", "ConfigMap", "cfg", nil),
+			object("v1", "Secret", "hidden", nil),
+		},
+		rels: map[string][]summarycache.Relationship{
+			"Deployment/web": {
+				{ToType: "pod", ToNamespace: "default", Rel: "creates", Selector: "app=web"},
+			},
+			"ReplicaSet/web-1": {
+				{FromType: "apps.deployment", FromID: "default/web", Rel: "owner"},
+				{ToType: "pod", ToID: "default/web-1-a", Rel: "owner"},
+				{ToType: "pod", ToID: "default/web-1-b", Rel: "owner"},
+			},
+			"Pod/web-1-a": {
+				{FromType: "apps.replicaset", FromID: "default/web-1", Rel: "owner"},
+				{ToType: "configmap", ToID: "default/cfg", Rel: "uses"},
+				{ToType: "configmap", ToID: "default/gone", Rel: "uses"},
+				{ToType: "secret", ToID: "default/hidden", Rel: "uses"},
+			},
+			"Pod/web-1-b": {
+				{FromType: "apps.replicaset", FromID: "default/web-1", Rel: "owner"},
+			},
+		},
+	}
+}
+
+func newRequest(schemaID, name, query string) *types.APIRequest {
+	apiSchemas := types.EmptyAPISchemas()
+	for id, gvk := range map[string]schema.GroupVersionKind{
+		"apps.deployment": {Group: "apps", Version: "v1", Kind: "Deployment"},
+		"apps.replicaset": {Group: "apps", Version: "v1", Kind: "ReplicaSet"},
+		"pod":             {Version: "v1", Kind: "Pod"},
+		"configmap":       {Version: "v1", Kind: "ConfigMap"},
+		"secret":          {Version: "v1", Kind: "Secret"},
+	} {
+		apiSchema := &types.APISchema{Schema: &schemas.Schema{ID: id, Attributes: map[string]interface{}{}}}
+		attributes.SetGVK(apiSchema, gvk)
+		attributes.SetNamespaced(apiSchema, true)
+		access := accesscontrol.AccessListByVerb{
+			"get": accesscontrol.AccessList{{Namespace: accesscontrol.All, ResourceName: accesscontrol.All}},
+		}
+		if id == "secret" {
+			access["get"] = accesscontrol.AccessList{{Namespace: "default", ResourceName: "other"}}
+		}
+		attributes.SetAccess(apiSchema, access)
+		apiSchemas.AddSchema(*apiSchema)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/"+schemaID+"/default/"+name+"?link=graph&"+query, nil)
+	return &types.APIRequest{
+		Request:   req,
+		Schemas:   apiSchemas,
+		Schema:    apiSchemas.LookupSchema(schemaID),
+		Type:      schemaID,
+		Namespace: "default",
+		Name:      name,
+		Link:      "graph",
+		Query:     req.URL.Query(),
+	}
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name      string
+		schemaID  string
+		objName   string
+		query     string
+		wantNodes map[string]int
+		wantEdges []GraphEdge
+	}{
+		{
+			name:     "one hop",
+			schemaID: "apps.replicaset",
+			objName:  "web-1",
+			query:    "depth=1",
+			wantNodes: map[string]int{
+				"apps.replicaset/default/web-1": 0,
+				"apps.deployment/default/web":   1,
+				"pod/default/web-1-a":           1,
+				"pod/default/web-1-b":           1,
+			},
+			wantEdges: []GraphEdge{
+				{From: "apps.deployment/default/web", To: "apps.replicaset/default/web-1", Rel: "owner"},
+				{From: "apps.replicaset/default/web-1", To: "pod/default/web-1-a", Rel: "owner"},
+				{From: "apps.replicaset/default/web-1", To: "pod/default/web-1-b", Rel: "owner"},
+			},
+		},
+		{
+			name:     "selectors and references, without objects the user can't get",
+			schemaID: "apps.deployment",
+			objName:  "web",
+			wantNodes: map[string]int{
+				"apps.deployment/default/web":   0,
+				"pod/default/web-1-a":           1,
+				"pod/default/web-1-b":           1,
+				"apps.replicaset/default/web-1": 2,
+				"configmap/default/cfg":         2,
+				"configmap/default/gone":        2,
+			},
+			wantEdges: []GraphEdge{
+				{From: "apps.deployment/default/web", To: "pod/default/web-1-a", Rel: "creates", Selector: "app=web"},
+				{From: "apps.deployment/default/web", To: "pod/default/web-1-b", Rel: "creates", Selector: "app=web"},
+				{From: "apps.replicaset/default/web-1", To: "pod/default/web-1-a", Rel: "owner"},
+				{From: "pod/default/web-1-a", To: "configmap/default/cfg", Rel: "uses"},
+				{From: "pod/default/web-1-a", To: "configmap/default/gone", Rel: "uses"},
+				{From: "apps.replicaset/default/web-1", To: "pod/default/web-1-b", Rel: "owner"},
+			},
+		},
+		{
+			name:     "children only",
+			schemaID: "apps.replicaset",
+			objName:  "web-1",
+			query:    "direction=outbound&relationships=children",
+			wantNodes: map[string]int{
+				"apps.replicaset/default/web-1": 0,
+				"pod/default/web-1-a":           1,
+				"pod/default/web-1-b":           1,
+			},
+			wantEdges: []GraphEdge{
+				{From: "apps.replicaset/default/web-1", To: "pod/default/web-1-a", Rel: "owner"},
+				{From: "apps.replicaset/default/web-1", To: "pod/default/web-1-b", Rel: "owner"},
+			},
+		},
+		{
+			name:     "owners only",
+			schemaID: "pod",
+			objName:  "web-1-a",
+			query:    "depth=10&relationships=owners",
+			wantNodes: map[string]int{
+				"pod/default/web-1-a":           0,
+				"apps.replicaset/default/web-1": 1,
+				"apps.deployment/default/web":   2,
+			},
+			wantEdges: []GraphEdge{
+				{From: "apps.replicaset/default/web-1", To: "pod/default/web-1-a", Rel: "owner"},
+				{From: "apps.deployment/default/web", To: "apps.replicaset/default/web-1", Rel: "owner"},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g := &Graph{cache: newCache()}
+			output, err := g.Run(newRequest(test.schemaID, test.objName, test.query))
+			require.NoError(t, err)
+
+			nodes := map[string]int{}
+			for _, node := range output.Nodes {
+				nodes[node.ID] = node.Depth
+				assert.Equal(t, node.ID == "configmap/default/gone", node.Missing, node.ID)
+				if !node.Missing {
+					assert.Equal(t, "active", node.State)
+				}
+			}
+			assert.Equal(t, output.Nodes[0].ID, output.Root)
+			assert.Equal(t, test.wantNodes, nodes)
+			assert.Equal(t, test.wantEdges, output.Edges)
+			assert.False(t, output.Truncated)
+		})
+	}
+}
+
+func TestRunPolicy(t *testing.T) {
+	pol := policy.Func(func(attrs *policy.Attributes) policy.Decision {
+		if attrs.Name == "cfg" || attrs.Name == "web-1-b" {
+			return policy.Deny("hidden")
+		}
+		return policy.Allow
+	})
+	g := &Graph{cache: newCache(), policy: pol}
+
+	output, err := g.Run(newRequest("pod", "web-1-a", "relationships=references"))
+	require.NoError(t, err)
+	var ids []string
+	for _, node := range output.Nodes {
+		ids = append(ids, node.ID)
+	}
+	assert.Equal(t, []string{"pod/default/web-1-a", "configmap/default/gone"}, ids)
+	assert.Equal(t, []GraphEdge{{From: "pod/default/web-1-a", To: "configmap/default/gone", Rel: "uses"}}, output.Edges)
+
+	output, err = g.Run(newRequest("apps.deployment", "web", "depth=1&relationships=selectors"))
+	require.NoError(t, err)
+	ids = nil
+	for _, node := range output.Nodes {
+		ids = append(ids, node.ID)
+	}
+	assert.Equal(t, []string{"apps.deployment/default/web", "pod/default/web-1-a"}, ids)
+}
+
+func TestRunInvalidOptions(t *testing.T) {
+	for _, query := range []string{"depth=0", "depth=11", "depth=x", "direction=up", "relationships=owners,parents"} {
+		t.Run(query, func(t *testing.T) {
+			g := &Graph{cache: newCache()}
+			_, err := g.Run(newRequest("pod", "web-1-a", query))
+			var apiError *apierror.APIError
+			require.ErrorAs(t, err, &apiError)
+			assert.Equal(t, validation.InvalidOption, apiError.Code)
+		})
+	}
+}
+
+func TestAddGraph(t *testing.T) {
+	apiSchema := &types.APISchema{Schema: &schemas.Schema{ID: "pod", Attributes: map[string]interface{}{}}}
+	AddGraph(apiSchema, newCache(), nil)
+	assert.Nil(t, apiSchema.LinkHandlers)
+
+	attributes.SetGVK(apiSchema, schema.GroupVersionKind{Version: "v1", Kind: "Pod"})
+	attributes.SetVerbs(apiSchema, []string{"get", "list"})
+	AddGraph(apiSchema, newCache(), nil)
+	assert.Contains(t, apiSchema.LinkHandlers, "graph")
+}
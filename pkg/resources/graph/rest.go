@@ -0,0 +1,47 @@
+package graph
+
+const (
+	DirectionInbound  = "inbound"
+	DirectionOutbound = "outbound"
+	DirectionBoth     = "both"
+
+	RelationshipOwners     = "owners"
+	RelationshipChildren   = "children"
+	RelationshipSelectors  = "selectors"
+	RelationshipReferences = "references"
+)
+
+type GraphOutput struct {
+	// Root is the ID of the node of the object of the request.
+	Root  string      `json:"root"`
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+	// Truncated is set if nodes were left out because the graph reached the maximum number of nodes.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+type GraphNode struct {
+	// ID is the type of the object followed by its ID, like apps.deployment/default/web.
+	ID        string `json:"id"`
+	Type      string `json:"type"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	// Depth is the number of edges from the root.
+	Depth int `json:"depth"`
+	// Missing is set if the object is referred to but doesn't exist.
+	Missing bool `json:"missing,omitempty"`
+
+	State         string `json:"state,omitempty"`
+	Message       string `json:"message,omitempty"`
+	Error         bool   `json:"error,omitempty"`
+	Transitioning bool   `json:"transitioning,omitempty"`
+}
+
+type GraphEdge struct {
+	// From and To are node IDs, with From the owner, the selecting or the referring object.
+	From string `json:"from"`
+	To   string `json:"to"`
+	// Rel is the type of the relationship, like owner, selects or uses.
+	Rel      string `json:"rel"`
+	Selector string `json:"selector,omitempty"`
+}
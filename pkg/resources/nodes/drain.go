@@ -0,0 +1,167 @@
+package nodes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/wrangler/v3/pkg/schemas/validation"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+)
+
+var (
+	// evictionRetryInterval is how long to wait before retrying an eviction refused by a PodDisruptionBudget
+	evictionRetryInterval = 5 * time.Second
+	// deletionPollInterval is how often to check whether an evicted pod is gone
+	deletionPollInterval = 2 * time.Second
+)
+
+// podsToEvict returns the pods of the node to evict. Mirror pods, which the kubelet recreates, and the pods of
+// daemonsets, which tolerate unschedulable nodes, are left. Pods which wouldn't be recreated or would lose their
+// emptyDir data fail the drain, unless force or deleteEmptyDirData allow it, as for kubectl drain.
+func podsToEvict(ctx context.Context, client kubernetes.Interface, node string, input DrainInput) ([]corev1.Pod, error) {
+	pods, err := client.CoreV1().Pods("").List(ctx, metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("spec.nodeName", node).String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		result   []corev1.Pod
+		blocking []string
+	)
+	for _, pod := range pods.Items {
+		if _, ok := pod.Annotations[corev1.MirrorPodAnnotationKey]; ok {
+			continue
+		}
+		controller := metav1.GetControllerOf(&pod)
+		if controller != nil && controller.Kind == "DaemonSet" {
+			continue
+		}
+		// finished pods have nothing left to lose
+		if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			result = append(result, pod)
+			continue
+		}
+		if controller == nil && !input.Force {
+			blocking = append(blocking, pod.Namespace+"/"+pod.Name+" isn't managed by a controller (use force)")
+			continue
+		}
+		if !input.DeleteEmptyDirData && slices.ContainsFunc(pod.Spec.Volumes, func(volume corev1.Volume) bool {
+			return volume.EmptyDir != nil
+		}) {
+			blocking = append(blocking, pod.Namespace+"/"+pod.Name+" has emptyDir data (use deleteEmptyDirData)")
+			continue
+		}
+		result = append(result, pod)
+	}
+	if len(blocking) > 0 {
+		return nil, apierror.NewAPIError(validation.InvalidState, "can't drain node "+node+": "+strings.Join(blocking, ", "))
+	}
+	return result, nil
+}
+
+// evict evicts the pods concurrently and waits for them to be gone, then sets the final state of the drain.
+func (s *Store) evict(client kubernetes.Interface, node string, pods []corev1.Pod, gracePeriod *int64, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(s.ctx, timeout)
+	defer cancel()
+
+	var (
+		wg     sync.WaitGroup
+		lock   sync.Mutex
+		errs   []error
+		failed []string
+	)
+	for _, pod := range pods {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			key := pod.Namespace + "/" + pod.Name
+			if err := s.evictPod(ctx, client, node, pod, gracePeriod); err != nil {
+				lock.Lock()
+				errs = append(errs, fmt.Errorf("evicting pod %s: %w", key, err))
+				failed = append(failed, key)
+				lock.Unlock()
+				return
+			}
+			s.update(node, func(drain *NodeDrain) {
+				drain.Pending = slices.DeleteFunc(drain.Pending, func(pending string) bool {
+					return pending == key
+				})
+				drain.Evicted = append(drain.Evicted, key)
+			})
+		}()
+	}
+	wg.Wait()
+
+	err := errors.Join(errs...)
+	s.update(node, func(drain *NodeDrain) {
+		drain.FinishedAt = time.Now().UTC().Format(time.RFC3339)
+		if err != nil {
+			drain.State = DrainStateFailed
+			drain.Message, drain.messagePods = err.Error(), failed
+			return
+		}
+		drain.State = DrainStateDrained
+		drain.Message, drain.messagePods = "", nil
+	})
+}
+
+// evictPod evicts a pod through the eviction API, which respects PodDisruptionBudgets, and waits for it to be gone.
+// Evictions refused by a budget are retried until the context is done.
+func (s *Store) evictPod(ctx context.Context, client kubernetes.Interface, node string, pod corev1.Pod, gracePeriod *int64) error {
+	eviction := &policyv1.Eviction{
+		ObjectMeta:    metav1.ObjectMeta{Name: pod.Name, Namespace: pod.Namespace},
+		DeleteOptions: &metav1.DeleteOptions{},
+	}
+	if gracePeriod != nil && *gracePeriod >= 0 {
+		eviction.DeleteOptions.GracePeriodSeconds = gracePeriod
+	}
+
+	for {
+		err := client.PolicyV1().Evictions(pod.Namespace).Evict(ctx, eviction)
+		if err == nil || k8serrors.IsNotFound(err) {
+			break
+		}
+		if !k8serrors.IsTooManyRequests(err) {
+			return err
+		}
+		s.update(node, func(drain *NodeDrain) {
+			drain.Message = "waiting for the disruption budget of pod " + pod.Namespace + "/" + pod.Name + ": " + err.Error()
+			drain.messagePods = []string{pod.Namespace + "/" + pod.Name}
+		})
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timed out: %w", err)
+		case <-time.After(evictionRetryInterval):
+		}
+	}
+
+	err := wait.PollUntilContextCancel(ctx, deletionPollInterval, true, func(ctx context.Context) (bool, error) {
+		current, err := client.CoreV1().Pods(pod.Namespace).Get(ctx, pod.Name, metav1.GetOptions{})
+		if k8serrors.IsNotFound(err) {
+			return true, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		// a pod with the same name may have been created since
+		return current.UID != pod.UID, nil
+	})
+	if wait.Interrupted(err) {
+		return errors.New("timed out waiting for the pod to be deleted")
+	}
+	return err
+}
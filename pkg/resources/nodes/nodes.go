@@ -0,0 +1,329 @@
+// Package nodes adds the cordon, uncordon and drain actions to nodes. Drains run in the background, as the user who
+// started them, and their status is the nodeDrain type, which can be polled or watched.
+package nodes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/store/empty"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/rancher/steve/pkg/attributes"
+	"github.com/rancher/steve/pkg/resources/common"
+	"github.com/rancher/steve/pkg/stores/proxy"
+	"github.com/rancher/wrangler/v3/pkg/schemas"
+	"github.com/rancher/wrangler/v3/pkg/schemas/validation"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sschema "k8s.io/apimachinery/pkg/runtime/schema"
+	apitypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+const drainSchemaID = "nodeDrain"
+
+// DefaultDrainTimeout is how long the pods of a node can take to be evicted if the drain doesn't set a timeout.
+const DefaultDrainTimeout = 10 * time.Minute
+
+func Register(ctx context.Context, apiSchemas *types.APISchemas, cg proxy.ClientGetter) {
+	apiSchemas.MustImportAndCustomize(&DrainInput{}, nil)
+	apiSchemas.MustImportAndCustomize(NodeDrain{}, func(schema *types.APISchema) {
+		schema.CollectionMethods = []string{http.MethodGet}
+		schema.ResourceMethods = []string{http.MethodGet}
+		schema.Store = NewStore(ctx, cg)
+	})
+}
+
+// AddActions adds the cordon, uncordon and drain actions to the node schema, if the user can patch nodes.
+func AddActions(apiSchemas *types.APISchemas, apiSchema *types.APISchema) {
+	if !slices.Contains(attributes.Verbs(apiSchema), "patch") {
+		return
+	}
+	drainSchema := apiSchemas.LookupSchema(drainSchemaID)
+	if drainSchema == nil {
+		return
+	}
+	store, ok := drainSchema.Store.(*Store)
+	if !ok {
+		return
+	}
+
+	if apiSchema.ActionHandlers == nil {
+		apiSchema.ActionHandlers = map[string]http.Handler{}
+	}
+	if apiSchema.ResourceActions == nil {
+		apiSchema.ResourceActions = map[string]schemas.Action{}
+	}
+	for _, name := range []string{"cordon", "uncordon"} {
+		apiSchema.ActionHandlers[name] = &Action{store: store, name: name}
+		apiSchema.ResourceActions[name] = schemas.Action{
+			Output: apiSchema.ID,
+		}
+	}
+	apiSchema.ActionHandlers["drain"] = &Action{store: store, name: "drain"}
+	apiSchema.ResourceActions["drain"] = schemas.Action{
+		Input:  "drainInput",
+		Output: drainSchemaID,
+	}
+}
+
+// Action cordons or uncordons a node and returns it, or starts draining it and returns the status of the drain.
+type Action struct {
+	store *Store
+	name  string
+}
+
+func (a *Action) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	apiOp := types.GetAPIContext(req.Context())
+
+	client, err := a.store.cg.K8sInterface(apiOp)
+	if err != nil {
+		apiOp.WriteError(err)
+		return
+	}
+
+	switch a.name {
+	case "cordon", "uncordon":
+		node, err := setUnschedulable(apiOp.Context(), client, apiOp.Name, a.name == "cordon")
+		if err != nil {
+			apiOp.WriteError(proxy.TranslateError(err))
+			return
+		}
+		obj, err := common.ToAPIObject(apiOp.Schema, node)
+		if err != nil {
+			apiOp.WriteError(err)
+			return
+		}
+		apiOp.WriteResponse(http.StatusOK, obj)
+	case "drain":
+		var input DrainInput
+		if err := json.NewDecoder(io.LimitReader(req.Body, 1<<20)).Decode(&input); err != nil && !errors.Is(err, io.EOF) {
+			apiOp.WriteError(apierror.NewAPIError(validation.InvalidBodyContent, err.Error()))
+			return
+		}
+		drain, err := a.store.Drain(apiOp.Context(), client, apiOp.Name, input)
+		if err != nil {
+			apiOp.WriteError(proxy.TranslateError(err))
+			return
+		}
+		apiOp.WriteResponse(http.StatusAccepted, view(apiOp, drain))
+	}
+}
+
+func setUnschedulable(ctx context.Context, client kubernetes.Interface, name string, unschedulable bool) (*corev1.Node, error) {
+	patch, err := json.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{
+			"unschedulable": unschedulable,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return client.CoreV1().Nodes().Patch(ctx, name, apitypes.MergePatchType, patch, metav1.PatchOptions{})
+}
+
+func toDrainAPIObject(drain *NodeDrain) types.APIObject {
+	return types.APIObject{
+		Type:   drainSchemaID,
+		ID:     drain.ID,
+		Object: drain,
+	}
+}
+
+// Store keeps the status of the last drain of each node since steve started. Users see the drains of the nodes they
+// can get, with the pods they can get.
+type Store struct {
+	empty.Store
+
+	ctx context.Context
+	cg  proxy.ClientGetter
+
+	lock     sync.Mutex
+	drains   map[string]*NodeDrain
+	watchers map[chan types.APIEvent]watcher
+}
+
+type watcher struct {
+	apiOp *types.APIRequest
+	id    string
+}
+
+func NewStore(ctx context.Context, cg proxy.ClientGetter) *Store {
+	return &Store{
+		ctx:      ctx,
+		cg:       cg,
+		drains:   map[string]*NodeDrain{},
+		watchers: map[chan types.APIEvent]watcher{},
+	}
+}
+
+func canGetNode(apiOp *types.APIRequest, name string) bool {
+	accessSet := accesscontrol.AccessSetFromAPIRequest(apiOp)
+	return accessSet == nil || accessSet.Grants("get", k8sschema.GroupResource{Resource: "nodes"}, "", name)
+}
+
+// view returns the status of drain as seen by the user of apiOp, who only sees the pods they can get. The message is
+// replaced when it is about pods the user can't see.
+func view(apiOp *types.APIRequest, drain *NodeDrain) types.APIObject {
+	result := drain.DeepCopy()
+	accessSet := accesscontrol.AccessSetFromAPIRequest(apiOp)
+	if accessSet == nil {
+		return toDrainAPIObject(result)
+	}
+	hidden := func(key string) bool {
+		namespace, name, _ := strings.Cut(key, "/")
+		gr := k8sschema.GroupResource{Resource: "pods"}
+		return !accessSet.Grants("get", gr, namespace, name) && !accessSet.Grants("list", gr, namespace, name)
+	}
+	result.Pending = slices.DeleteFunc(result.Pending, hidden)
+	result.Evicted = slices.DeleteFunc(result.Evicted, hidden)
+	if slices.ContainsFunc(result.messagePods, hidden) {
+		switch result.State {
+		case DrainStateDraining:
+			result.Message = "waiting for the disruption budget of a pod"
+		case DrainStateFailed:
+			result.Message = "failed to evict some of the pods"
+		}
+	}
+	return toDrainAPIObject(result)
+}
+
+func (s *Store) ByID(apiOp *types.APIRequest, schema *types.APISchema, id string) (types.APIObject, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	drain := s.drains[id]
+	if drain == nil || !canGetNode(apiOp, id) {
+		return types.APIObject{}, apierror.NewAPIError(validation.NotFound, "no drain of node "+id)
+	}
+	return view(apiOp, drain), nil
+}
+
+func (s *Store) List(apiOp *types.APIRequest, schema *types.APISchema) (types.APIObjectList, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var result types.APIObjectList
+	for id, drain := range s.drains {
+		if canGetNode(apiOp, id) {
+			result.Objects = append(result.Objects, view(apiOp, drain))
+		}
+	}
+	slices.SortFunc(result.Objects, func(a, b types.APIObject) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+	result.Count = len(result.Objects)
+	return result, nil
+}
+
+// Watch sends the status of the drains every time it changes, until the request is done.
+func (s *Store) Watch(apiOp *types.APIRequest, schema *types.APISchema, w types.WatchRequest) (chan types.APIEvent, error) {
+	result := make(chan types.APIEvent, 100)
+
+	s.lock.Lock()
+	s.watchers[result] = watcher{apiOp: apiOp, id: w.ID}
+	s.lock.Unlock()
+
+	go func() {
+		<-apiOp.Context().Done()
+		s.lock.Lock()
+		delete(s.watchers, result)
+		close(result)
+		s.lock.Unlock()
+	}()
+
+	return result, nil
+}
+
+// Drain cordons the node, checks that its pods can be evicted and starts evicting them in the background. The pods
+// which can't be evicted without losing data or for good fail the drain before it starts, unless the input allows
+// it, and leave the node cordoned.
+func (s *Store) Drain(ctx context.Context, client kubernetes.Interface, node string, input DrainInput) (*NodeDrain, error) {
+	timeout := DefaultDrainTimeout
+	if input.Timeout != "" {
+		var err error
+		timeout, err = time.ParseDuration(input.Timeout)
+		if err != nil || timeout <= 0 {
+			return nil, apierror.NewAPIError(validation.InvalidFormat, "invalid timeout "+input.Timeout)
+		}
+	}
+
+	s.lock.Lock()
+	draining := s.drains[node] != nil && s.drains[node].State == DrainStateDraining
+	s.lock.Unlock()
+	if draining {
+		return nil, apierror.NewAPIError(validation.Conflict, "node "+node+" is already being drained")
+	}
+
+	if _, err := setUnschedulable(ctx, client, node, true); err != nil {
+		return nil, err
+	}
+	pods, err := podsToEvict(ctx, client, node, input)
+	if err != nil {
+		return nil, err
+	}
+
+	drain := &NodeDrain{
+		ID:        node,
+		State:     DrainStateDraining,
+		Pending:   []string{},
+		StartedAt: time.Now().UTC().Format(time.RFC3339),
+	}
+	for _, pod := range pods {
+		drain.Pending = append(drain.Pending, pod.Namespace+"/"+pod.Name)
+	}
+
+	s.lock.Lock()
+	if s.drains[node] != nil && s.drains[node].State == DrainStateDraining {
+		s.lock.Unlock()
+		return nil, apierror.NewAPIError(validation.Conflict, "node "+node+" is already being drained")
+	}
+	s.drains[node] = drain
+	s.notify(types.CreateAPIEvent, drain)
+	result := drain.DeepCopy()
+	s.lock.Unlock()
+
+	go s.evict(client, node, pods, input.GracePeriod, timeout)
+	return result, nil
+}
+
+// update changes the status of the drain of node and notifies the watchers.
+func (s *Store) update(node string, f func(drain *NodeDrain)) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	drain := s.drains[node]
+	if drain == nil {
+		return
+	}
+	f(drain)
+	s.notify(types.ChangeAPIEvent, drain)
+}
+
+// notify sends the status of drain to the watchers who can see it. It must be called with the lock held.
+func (s *Store) notify(name string, drain *NodeDrain) {
+	for result, w := range s.watchers {
+		if (w.id != "" && w.id != drain.ID) || !canGetNode(w.apiOp, drain.ID) {
+			continue
+		}
+		// every event has the whole status, so a slow watcher missing one only misses an intermediate state
+		select {
+		case result <- types.APIEvent{
+			Name:         name,
+			ResourceType: drainSchemaID,
+			ID:           drain.ID,
+			Object:       view(w.apiOp, drain),
+		}:
+		default:
+		}
+	}
+}
@@ -0,0 +1,317 @@
+package nodes
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/rancher/steve/pkg/attributes"
+	"github.com/rancher/steve/pkg/resources/resourcetest"
+	"github.com/rancher/wrangler/v3/pkg/schemas"
+	"github.com/rancher/wrangler/v3/pkg/schemas/validation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sschema "k8s.io/apimachinery/pkg/runtime/schema"
+	apitypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/utils/ptr"
+)
+
+func init() {
+	evictionRetryInterval = 10 * time.Millisecond
+	deletionPollInterval = 10 * time.Millisecond
+}
+
+var podsResource = corev1.SchemeGroupVersion.WithResource("pods")
+
+func newNode() *corev1.Node {
+	return &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1"}}
+}
+
+func newPod(name string, mutate func(pod *corev1.Pod)) *corev1.Pod {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Namespace:       "default",
+			UID:             apitypes.UID("uid-" + name),
+			OwnerReferences: []metav1.OwnerReference{{Kind: "ReplicaSet", Name: "web", Controller: ptr.To(true)}},
+		},
+		Spec:   corev1.PodSpec{NodeName: "node1"},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+	if mutate != nil {
+		mutate(pod)
+	}
+	return pod
+}
+
+// evictions deletes the evicted pods, after refusing to evict each pod refusals times as a disruption budget would.
+func evictions(client *fake.Clientset, refusals int) {
+	var (
+		lock    sync.Mutex
+		refused = map[string]int{}
+	)
+	client.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "eviction" {
+			return false, nil, nil
+		}
+		eviction := action.(k8stesting.CreateAction).GetObject().(*policyv1.Eviction)
+		lock.Lock()
+		defer lock.Unlock()
+		if refused[eviction.Name] < refusals {
+			refused[eviction.Name]++
+			return true, nil, k8serrors.NewTooManyRequests("Cannot evict pod as it would violate the pod's disruption budget.", 0)
+		}
+		return true, nil, client.Tracker().Delete(podsResource, eviction.Namespace, eviction.Name)
+	})
+}
+
+func waitForDrain(t *testing.T, store *Store, state string) *NodeDrain {
+	t.Helper()
+	var drain *NodeDrain
+	require.Eventually(t, func() bool {
+		obj, err := store.ByID(&types.APIRequest{}, nil, "node1")
+		require.NoError(t, err)
+		drain = obj.Object.(*NodeDrain)
+		return drain.State != DrainStateDraining
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, state, drain.State)
+	return drain
+}
+
+func TestAddActions(t *testing.T) {
+	apiSchemas := types.EmptyAPISchemas()
+	Register(context.Background(), apiSchemas, &resourcetest.ClientGetter{})
+
+	newNodeSchema := func(verbs ...string) *types.APISchema {
+		apiSchema := &types.APISchema{Schema: &schemas.Schema{ID: "node", Attributes: map[string]interface{}{}}}
+		attributes.SetVerbs(apiSchema, verbs)
+		return apiSchema
+	}
+
+	apiSchema := newNodeSchema("get", "patch")
+	AddActions(apiSchemas, apiSchema)
+	assert.Equal(t, map[string]schemas.Action{
+		"cordon":   {Output: "node"},
+		"uncordon": {Output: "node"},
+		"drain":    {Input: "drainInput", Output: "nodeDrain"},
+	}, apiSchema.ResourceActions)
+	assert.Len(t, apiSchema.ActionHandlers, 3)
+
+	apiSchema = newNodeSchema("get")
+	AddActions(apiSchemas, apiSchema)
+	assert.Empty(t, apiSchema.ResourceActions)
+}
+
+func TestCordon(t *testing.T) {
+	client := fake.NewClientset(newNode())
+	store := NewStore(context.Background(), &resourcetest.ClientGetter{K8s: client})
+
+	for _, action := range []string{"cordon", "uncordon"} {
+		t.Run(action, func(t *testing.T) {
+			nodeSchema := &types.APISchema{Schema: &schemas.Schema{ID: "node", Attributes: map[string]interface{}{}}}
+			attributes.SetGVK(nodeSchema, corev1.SchemeGroupVersion.WithKind("Node"))
+			response := resourcetest.RunAction(&Action{store: store, name: action}, nodeSchema, "", "node1", action, "")
+
+			require.NoError(t, response.Err)
+			assert.Equal(t, http.StatusOK, response.Code)
+			assert.Equal(t, "node1", response.Object.ID)
+			u := response.Object.Object.(*unstructured.Unstructured)
+			assert.Equal(t, "Node", u.GetKind())
+			unschedulable, _, _ := unstructured.NestedBool(u.Object, "spec", "unschedulable")
+			assert.Equal(t, action == "cordon", unschedulable)
+		})
+	}
+}
+
+func TestDrain(t *testing.T) {
+	client := fake.NewClientset(
+		newNode(),
+		newPod("web", nil),
+		newPod("done", func(pod *corev1.Pod) {
+			pod.OwnerReferences = nil
+			pod.Status.Phase = corev1.PodSucceeded
+		}),
+		newPod("agent", func(pod *corev1.Pod) {
+			pod.OwnerReferences[0].Kind = "DaemonSet"
+		}),
+		newPod("static", func(pod *corev1.Pod) {
+			pod.OwnerReferences = nil
+			pod.Annotations = map[string]string{corev1.MirrorPodAnnotationKey: "mirror"}
+		}),
+	)
+	evictions(client, 2)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	store := NewStore(ctx, &resourcetest.ClientGetter{K8s: client})
+	events, err := store.Watch(&types.APIRequest{Request: httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)}, nil, types.WatchRequest{ID: "node1"})
+	require.NoError(t, err)
+
+	started, err := store.Drain(context.Background(), client, "node1", DrainInput{GracePeriod: ptr.To[int64](30)})
+	require.NoError(t, err)
+	assert.Equal(t, DrainStateDraining, started.State)
+	assert.ElementsMatch(t, []string{"default/web", "default/done"}, started.Pending)
+
+	node, err := client.CoreV1().Nodes().Get(context.Background(), "node1", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.True(t, node.Spec.Unschedulable)
+
+	drain := waitForDrain(t, store, DrainStateDrained)
+	assert.ElementsMatch(t, []string{"default/web", "default/done"}, drain.Evicted)
+	assert.Empty(t, drain.Pending)
+	assert.Empty(t, drain.Message)
+	assert.NotEmpty(t, drain.FinishedAt)
+
+	pods, err := client.CoreV1().Pods("").List(context.Background(), metav1.ListOptions{})
+	require.NoError(t, err)
+	var remaining []string
+	for _, pod := range pods.Items {
+		remaining = append(remaining, pod.Name)
+	}
+	assert.ElementsMatch(t, []string{"agent", "static"}, remaining)
+
+	for _, action := range client.Actions() {
+		if action.GetSubresource() == "eviction" {
+			eviction := action.(k8stesting.CreateAction).GetObject().(*policyv1.Eviction)
+			assert.Equal(t, ptr.To[int64](30), eviction.DeleteOptions.GracePeriodSeconds)
+		}
+	}
+
+	first := <-events
+	assert.Equal(t, types.CreateAPIEvent, first.Name)
+	assert.Equal(t, "nodeDrain", first.ResourceType)
+	var (
+		waitedForBudget bool
+		last            types.APIEvent
+	)
+	for len(events) > 0 {
+		last = <-events
+		assert.Equal(t, types.ChangeAPIEvent, last.Name)
+		if last.Object.Object.(*NodeDrain).Message != "" {
+			waitedForBudget = true
+		}
+	}
+	assert.True(t, waitedForBudget)
+	assert.Equal(t, DrainStateDrained, last.Object.Object.(*NodeDrain).State)
+}
+
+func TestDrainBlocked(t *testing.T) {
+	bare := newPod("bare", func(pod *corev1.Pod) {
+		pod.OwnerReferences = nil
+	})
+	emptyDir := newPod("cache", func(pod *corev1.Pod) {
+		pod.Spec.Volumes = []corev1.Volume{{Name: "cache", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}}}
+	})
+
+	tests := []struct {
+		name    string
+		pod     *corev1.Pod
+		input   DrainInput
+		wantErr bool
+	}{
+		{name: "unmanaged pod", pod: bare, wantErr: true},
+		{name: "unmanaged pod with force", pod: bare, input: DrainInput{Force: true}},
+		{name: "emptyDir", pod: emptyDir, wantErr: true},
+		{name: "emptyDir with deleteEmptyDirData", pod: emptyDir, input: DrainInput{DeleteEmptyDirData: true}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := fake.NewClientset(newNode(), test.pod)
+			evictions(client, 0)
+			store := NewStore(context.Background(), &resourcetest.ClientGetter{K8s: client})
+
+			_, err := store.Drain(context.Background(), client, "node1", test.input)
+			if test.wantErr {
+				resourcetest.RequireAPIError(t, err, validation.InvalidState)
+				_, err := store.ByID(&types.APIRequest{}, nil, "node1")
+				resourcetest.RequireAPIError(t, err, validation.NotFound)
+				return
+			}
+			require.NoError(t, err)
+			waitForDrain(t, store, DrainStateDrained)
+		})
+	}
+}
+
+func TestDrainTimeout(t *testing.T) {
+	client := fake.NewClientset(newNode(), newPod("web", nil))
+	evictions(client, 1000)
+	store := NewStore(context.Background(), &resourcetest.ClientGetter{K8s: client})
+
+	_, err := store.Drain(context.Background(), client, "node1", DrainInput{Timeout: "200ms"})
+	require.NoError(t, err)
+
+	_, err = store.Drain(context.Background(), client, "node1", DrainInput{})
+	resourcetest.RequireAPIError(t, err, validation.Conflict)
+
+	drain := waitForDrain(t, store, DrainStateFailed)
+	assert.Contains(t, drain.Message, "evicting pod default/web: timed out")
+	assert.Equal(t, []string{"default/web"}, drain.Pending)
+
+	_, err = store.Drain(context.Background(), client, "node1", DrainInput{Timeout: "soon"})
+	resourcetest.RequireAPIError(t, err, validation.InvalidFormat)
+}
+
+func TestStoreAccess(t *testing.T) {
+	store := NewStore(context.Background(), &resourcetest.ClientGetter{})
+	for _, node := range []string{"node1", "node2"} {
+		store.drains[node] = &NodeDrain{ID: node, State: DrainStateDrained}
+	}
+
+	accessSet := &accesscontrol.AccessSet{}
+	accessSet.Add("get", k8sschema.GroupResource{Resource: "nodes"}, accesscontrol.Access{Namespace: accesscontrol.All, ResourceName: "node1"})
+	apiSchemas := types.EmptyAPISchemas()
+	accesscontrol.SetAccessSetAttribute(apiSchemas, accessSet)
+	apiOp := &types.APIRequest{Schemas: apiSchemas}
+
+	list, err := store.List(apiOp, nil)
+	require.NoError(t, err)
+	require.Len(t, list.Objects, 1)
+	assert.Equal(t, "node1", list.Objects[0].ID)
+
+	_, err = store.ByID(apiOp, nil, "node1")
+	assert.NoError(t, err)
+	_, err = store.ByID(apiOp, nil, "node2")
+	resourcetest.RequireAPIError(t, err, validation.NotFound)
+}
+
+func TestStorePodAccess(t *testing.T) {
+	store := NewStore(context.Background(), &resourcetest.ClientGetter{})
+	store.drains["node1"] = &NodeDrain{
+		ID:          "node1",
+		State:       DrainStateFailed,
+		Message:     "evicting pod secret/db: timed out",
+		Pending:     []string{"secret/db"},
+		Evicted:     []string{"default/web"},
+		messagePods: []string{"secret/db"},
+	}
+
+	accessSet := &accesscontrol.AccessSet{}
+	accessSet.Add("get", k8sschema.GroupResource{Resource: "nodes"}, accesscontrol.Access{Namespace: accesscontrol.All, ResourceName: accesscontrol.All})
+	accessSet.Add("list", k8sschema.GroupResource{Resource: "pods"}, accesscontrol.Access{Namespace: "default", ResourceName: accesscontrol.All})
+	apiSchemas := types.EmptyAPISchemas()
+	accesscontrol.SetAccessSetAttribute(apiSchemas, accessSet)
+
+	obj, err := store.ByID(&types.APIRequest{Schemas: apiSchemas}, nil, "node1")
+	require.NoError(t, err)
+	drain := obj.Object.(*NodeDrain)
+	assert.Empty(t, drain.Pending)
+	assert.Equal(t, []string{"default/web"}, drain.Evicted)
+	assert.Equal(t, "failed to evict some of the pods", drain.Message)
+
+	obj, err = store.ByID(&types.APIRequest{}, nil, "node1")
+	require.NoError(t, err)
+	assert.Equal(t, "evicting pod secret/db: timed out", obj.Object.(*NodeDrain).Message)
+}
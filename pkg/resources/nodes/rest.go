@@ -0,0 +1,43 @@
+package nodes
+
+const (
+	DrainStateDraining = "draining"
+	DrainStateDrained  = "drained"
+	DrainStateFailed   = "failed"
+)
+
+type DrainInput struct {
+	// DeleteEmptyDirData evicts the pods with emptyDir volumes, whose data is lost.
+	DeleteEmptyDirData bool `json:"deleteEmptyDirData,omitempty"`
+	// Force evicts the pods which aren't managed by a controller, and won't be recreated elsewhere.
+	Force bool `json:"force,omitempty"`
+	// GracePeriod is the termination grace period of the evicted pods in seconds, their own if unset or negative.
+	GracePeriod *int64 `json:"gracePeriod,omitempty"`
+	// Timeout is how long the pods can take to be evicted, as a duration such as 5m.
+	Timeout string `json:"timeout,omitempty"`
+}
+
+// NodeDrain is the status of the last drain of a node, whose name is its ID.
+type NodeDrain struct {
+	ID string `json:"id"`
+	// State is draining until every pod is evicted, then drained, or failed.
+	State   string `json:"state"`
+	Message string `json:"message,omitempty"`
+	// Pending lists the pods which are still being evicted, as namespace/name.
+	Pending []string `json:"pending,omitempty"`
+	// Evicted lists the pods which were evicted, as namespace/name.
+	Evicted    []string `json:"evicted,omitempty"`
+	StartedAt  string   `json:"startedAt"`
+	FinishedAt string   `json:"finishedAt,omitempty"`
+
+	// messagePods are the pods Message is about, as namespace/name
+	messagePods []string
+}
+
+func (n *NodeDrain) DeepCopy() *NodeDrain {
+	r := *n
+	r.Pending = append([]string(nil), n.Pending...)
+	r.Evicted = append([]string(nil), n.Evicted...)
+	r.messagePods = append([]string(nil), n.messagePods...)
+	return &r
+}
@@ -0,0 +1,71 @@
+// Package resourcetest holds the fixtures shared by the tests of the actions and links which call Kubernetes with the
+// client of the user.
+package resourcetest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/stores/proxy"
+	"github.com/rancher/wrangler/v3/pkg/schemas/validation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ClientGetter returns K8s as the client of every user. Its other methods panic.
+type ClientGetter struct {
+	proxy.ClientGetter
+	K8s kubernetes.Interface
+}
+
+func (c *ClientGetter) K8sInterface(*types.APIRequest) (kubernetes.Interface, error) {
+	return c.K8s, nil
+}
+
+// Response is what an action wrote.
+type Response struct {
+	Code   int
+	Object types.APIObject
+	Err    error
+}
+
+func (r *Response) Write(_ *types.APIRequest, code int, obj types.APIObject) {
+	r.Code, r.Object = code, obj
+}
+
+func (r *Response) WriteList(*types.APIRequest, int, types.APIObjectList) {}
+
+// RunAction posts body to the action handler of the object with the given namespace and name, and returns what the
+// handler wrote.
+func RunAction(handler http.Handler, apiSchema *types.APISchema, namespace, name, action, body string) *Response {
+	response := &Response{}
+	apiOp := types.StoreAPIContext(&types.APIRequest{
+		Request:        httptest.NewRequest(http.MethodPost, "/?action="+action, strings.NewReader(body)),
+		Method:         http.MethodPost,
+		Schema:         apiSchema,
+		Type:           apiSchema.ID,
+		Namespace:      namespace,
+		Name:           name,
+		Action:         action,
+		ResponseWriter: response,
+		Response:       httptest.NewRecorder(),
+		ErrorHandler: func(_ *types.APIRequest, err error) {
+			response.Err = err
+		},
+	})
+	handler.ServeHTTP(apiOp.Response, apiOp.Request)
+	return response
+}
+
+// RequireAPIError requires err to be an APIError with the given code.
+func RequireAPIError(t *testing.T, err error, code validation.ErrorCode) {
+	t.Helper()
+	var apiError *apierror.APIError
+	require.ErrorAs(t, err, &apiError)
+	assert.Equal(t, code, apiError.Code)
+}
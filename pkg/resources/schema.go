@@ -10,11 +10,18 @@ import (
 	"github.com/rancher/steve/pkg/client"
 	"github.com/rancher/steve/pkg/clustercache"
 	"github.com/rancher/steve/pkg/resources/apigroups"
+	"github.com/rancher/steve/pkg/resources/bulk"
 	"github.com/rancher/steve/pkg/resources/cluster"
 	"github.com/rancher/steve/pkg/resources/common"
 	"github.com/rancher/steve/pkg/resources/counts"
+	"github.com/rancher/steve/pkg/resources/diff"
+	"github.com/rancher/steve/pkg/resources/events"
+	"github.com/rancher/steve/pkg/resources/export"
 	"github.com/rancher/steve/pkg/resources/formatters"
+	"github.com/rancher/steve/pkg/resources/graph"
+	"github.com/rancher/steve/pkg/resources/nodes"
 	"github.com/rancher/steve/pkg/resources/userpreferences"
+	"github.com/rancher/steve/pkg/resources/workloads"
 	"github.com/rancher/steve/pkg/schema"
 	"github.com/rancher/steve/pkg/stores/proxy"
 	"github.com/rancher/steve/pkg/summarycache"
@@ -38,7 +45,12 @@ func DefaultSchemas(ctx context.Context, baseSchema *types.APISchemas, ccache cl
 	}, serverVersion)
 	apiroot.Register(baseSchema, []string{"v1"}, "proxy:/apis")
 	cluster.Register(ctx, baseSchema, cg, schemaFactory)
+	bulk.Register(baseSchema)
+	diff.Register(baseSchema)
+	graph.Register(baseSchema)
+	nodes.Register(ctx, baseSchema, cg)
 	userpreferences.Register(baseSchema)
+	workloads.Register(baseSchema)
 	return nil
 }
 
@@ -51,6 +63,12 @@ func DefaultSchemaTemplates(cf *client.Factory,
 	options common.TemplateOptions) []schema.Template {
 	return []schema.Template{
 		common.DefaultTemplate(cf, summaryCache, lookup, namespaceCache, options),
+		bulk.Template(options.RateLimiter),
+		export.Template(),
+		diff.Template(),
+		graph.Template(summaryCache, options.Policy),
+		events.Template(options.InSQLMode),
+		events.LinkTemplate(),
 		apigroups.Template(discovery),
 		{
 			ID:        "configmap",
@@ -67,7 +85,13 @@ func DefaultSchemaTemplates(cf *client.Factory,
 		{
 			ID: "management.cattle.io.cluster",
 			Customize: func(apiSchema *types.APISchema) {
-				cluster.AddApply(baseSchemas, apiSchema)
+				cluster.AddApply(baseSchemas, apiSchema, options.InSQLMode)
+			},
+		},
+		{
+			ID: "node",
+			Customize: func(apiSchema *types.APISchema) {
+				nodes.AddActions(baseSchemas, apiSchema)
 			},
 		},
 	}
@@ -83,6 +107,12 @@ func DefaultSchemaTemplatesForStore(store types.Store,
 
 	return []schema.Template{
 		common.DefaultTemplateForStore(store, summaryCache, lookup, options),
+		bulk.Template(options.RateLimiter),
+		export.Template(),
+		diff.Template(),
+		graph.Template(summaryCache, options.Policy),
+		events.Template(options.InSQLMode),
+		events.LinkTemplate(),
 		apigroups.Template(discovery),
 		{
 			ID:        "configmap",
@@ -99,7 +129,13 @@ func DefaultSchemaTemplatesForStore(store types.Store,
 		{
 			ID: "management.cattle.io.cluster",
 			Customize: func(apiSchema *types.APISchema) {
-				cluster.AddApply(baseSchemas, apiSchema)
+				cluster.AddApply(baseSchemas, apiSchema, options.InSQLMode)
+			},
+		},
+		{
+			ID: "node",
+			Customize: func(apiSchema *types.APISchema) {
+				nodes.AddActions(baseSchemas, apiSchema)
 			},
 		},
 	}
@@ -0,0 +1,412 @@
+// Package search adds the search type, which finds the objects of every type the user can list by name, label or a
+// few indexed fields with GET /v1/search?q=.
+package search
+
+import (
+	"cmp"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/store/empty"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/rancher/steve/pkg/attributes"
+	"github.com/rancher/steve/pkg/clustercache"
+	"github.com/rancher/steve/pkg/policy"
+	"github.com/rancher/wrangler/v3/pkg/data"
+	"github.com/rancher/wrangler/v3/pkg/schemas/validation"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	// DefaultLimit is the number of hits returned for each type, unless set with the limit query parameter
+	DefaultLimit = 5
+	// MaxLimit is the highest limit query parameter accepted
+	MaxLimit = 50
+
+	queryParam = "q"
+	limitParam = "limit"
+
+	// candidates is the number of matches fetched from the SQL cache for each type, which are then ranked
+	candidates = 100
+	// parallelism is the number of types searched at once
+	parallelism = 8
+)
+
+const (
+	scoreNameExact   = 100
+	scoreNamePrefix  = 80
+	scoreNameContain = 60
+	scoreFieldExact  = 50
+	scoreLabel       = 40
+	scoreFieldMatch  = 30
+)
+
+var (
+	// queryRegexp matches a name, or a label as key=value. Characters which would change the meaning of a filter are
+	// rejected.
+	queryRegexp = regexp.MustCompile(`^(?:([A-Za-z0-9._/-]+)=)?([A-Za-z0-9._-]+)$`)
+
+	// fields are the indexed fields searched besides the name, for each kind
+	fields = map[schema.GroupKind][][]string{
+		{Kind: "Namespace"}:             {{"spec", "displayName"}},
+		{Kind: "PersistentVolumeClaim"}: {{"spec", "volumeName"}},
+		{Kind: "Pod"}:                   {{"spec", "nodeName"}, {"status", "podIP"}},
+		{Kind: "Service"}:               {{"spec", "clusterIP"}},
+	}
+)
+
+// CachedTypes returns the types already held by the SQL cache, which can be searched without starting an informer.
+type CachedTypes interface {
+	CachedGVKs() []schema.GroupVersionKind
+}
+
+// SearchResult holds the best hits of a type, ranked by score.
+type SearchResult struct {
+	// ID is the type of the hits
+	ID string `json:"id"`
+	// Count is the number of objects of the type matching the query, which may be more than the hits
+	Count int   `json:"count"`
+	Hits  []Hit `json:"hits"`
+}
+
+// Hit is an object matching the query.
+type Hit struct {
+	Type  string `json:"type"`
+	ID    string `json:"id"`
+	Field string `json:"field"`
+	Value string `json:"value"`
+	Score int    `json:"score"`
+}
+
+// Register registers the search schema. Without SQL cache, sqlCache is nil and the names and labels of objects are
+// searched in the cluster cache, except for the types pol applies to.
+func Register(apiSchemas *types.APISchemas, ccache clustercache.ClusterCache, sqlCache CachedTypes, pol policy.Policy) {
+	apiSchemas.InternalSchemas.TypeName("search", SearchResult{})
+	apiSchemas.MustImportAndCustomize(SearchResult{}, func(apiSchema *types.APISchema) {
+		apiSchema.CollectionMethods = []string{http.MethodGet}
+		apiSchema.Store = &Store{
+			ccache:   ccache,
+			sqlCache: sqlCache,
+			policy:   pol,
+		}
+	})
+}
+
+// Store lists a SearchResult for each type with objects matching the query, the best first.
+type Store struct {
+	empty.Store
+	ccache   clustercache.ClusterCache
+	sqlCache CachedTypes
+	policy   policy.Policy
+}
+
+type query struct {
+	// label is set for key=value queries
+	label string
+	value string
+}
+
+func parseQuery(q string) (query, error) {
+	if q == "" {
+		return query{}, apierror.NewAPIError(validation.MissingRequired, "missing query parameter "+queryParam)
+	}
+	m := queryRegexp.FindStringSubmatch(q)
+	if m == nil {
+		return query{}, apierror.NewAPIError(validation.InvalidOption, "invalid query "+q+", must be a name or key=value")
+	}
+	return query{label: m[1], value: m[2]}, nil
+}
+
+func parseLimit(value string) (int, error) {
+	if value == "" {
+		return DefaultLimit, nil
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 1 || limit > MaxLimit {
+		return 0, apierror.NewAPIError(validation.InvalidOption, "invalid limit "+value+", must be between 1 and "+strconv.Itoa(MaxLimit))
+	}
+	return limit, nil
+}
+
+func (s *Store) List(apiOp *types.APIRequest, apiSchema *types.APISchema) (types.APIObjectList, error) {
+	q, err := parseQuery(apiOp.Query.Get(queryParam))
+	if err != nil {
+		return types.APIObjectList{}, err
+	}
+	limit, err := parseLimit(apiOp.Query.Get(limitParam))
+	if err != nil {
+		return types.APIObjectList{}, err
+	}
+
+	var (
+		lock    sync.Mutex
+		results []SearchResult
+		eg      errgroup.Group
+	)
+	eg.SetLimit(parallelism)
+	for _, typeSchema := range s.searchable(apiOp) {
+		eg.Go(func() error {
+			result, err := s.search(apiOp, typeSchema, q, limit)
+			if err != nil {
+				// a type failing shouldn't fail the search of the others
+				logrus.Debugf("search: failed to search %s: %v", typeSchema.ID, err)
+				return nil
+			}
+			if len(result.Hits) == 0 {
+				return nil
+			}
+			lock.Lock()
+			results = append(results, result)
+			lock.Unlock()
+			return nil
+		})
+	}
+	_ = eg.Wait()
+
+	slices.SortFunc(results, func(a, b SearchResult) int {
+		return cmp.Or(
+			cmp.Compare(b.Hits[0].Score, a.Hits[0].Score),
+			cmp.Compare(a.ID, b.ID),
+		)
+	})
+
+	var list types.APIObjectList
+	for _, result := range results {
+		list.Objects = append(list.Objects, types.APIObject{
+			Type:   "search",
+			ID:     result.ID,
+			Object: result,
+		})
+	}
+	list.Count = len(list.Objects)
+	return list, nil
+}
+
+// searchable returns the schemas of the Kubernetes types the user can list, sorted by ID. With SQL cache, only the
+// types already cached are returned.
+func (s *Store) searchable(apiOp *types.APIRequest) []*types.APISchema {
+	var cached map[schema.GroupVersionKind]bool
+	if s.sqlCache != nil {
+		cached = map[schema.GroupVersionKind]bool{}
+		for _, gvk := range s.sqlCache.CachedGVKs() {
+			cached[gvk] = true
+		}
+	}
+
+	var result []*types.APISchema
+	for _, apiSchema := range apiOp.Schemas.Schemas {
+		gvk := attributes.GVK(apiSchema)
+		if apiSchema.Store == nil || gvk.Kind == "" || !slices.Contains(attributes.Verbs(apiSchema), "list") {
+			continue
+		}
+		if cached != nil && !cached[gvk] {
+			continue
+		}
+		if apiOp.AccessControl.CanList(apiOp, apiSchema) != nil {
+			continue
+		}
+		result = append(result, apiSchema)
+	}
+	slices.SortFunc(result, func(a, b *types.APISchema) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+	return result
+}
+
+func (s *Store) search(apiOp *types.APIRequest, apiSchema *types.APISchema, q query, limit int) (SearchResult, error) {
+	gvk := attributes.GVK(apiSchema)
+	typeFields := fields[gvk.GroupKind()]
+	result := SearchResult{ID: apiSchema.ID}
+
+	if s.sqlCache != nil {
+		queries := []url.Values{sqlQuery(q, typeFields)}
+		if q.label == "" {
+			// the candidates come in the order of the store, so a short query matching many objects could leave out
+			// the objects named exactly like it, the best hits, which are listed first
+			queries = append([]url.Values{exactNameQuery(q)}, queries...)
+		}
+		seen := map[string]bool{}
+		for _, query := range queries {
+			list, err := apiSchema.Store.List(listRequest(apiOp, apiSchema, query), apiSchema)
+			if err != nil {
+				return result, err
+			}
+			// the last query matches all the objects the others do
+			result.Count = list.Count
+			for _, obj := range list.Objects {
+				if seen[obj.ID] {
+					continue
+				}
+				seen[obj.ID] = true
+				// the SQL cache filters on the same terms, this only ranks the objects
+				if hit, ok := q.match(obj.Data(), typeFields); ok {
+					hit.Type, hit.ID = apiSchema.ID, obj.ID
+					result.Hits = append(result.Hits, hit)
+				}
+			}
+		}
+	} else if policy.AppliesTo(s.policy, attributes.GVR(apiSchema), "list") {
+		// the policy may deny objects by their content, which the cluster cache doesn't hold, so they are listed
+		// through the store of the type, which applies it
+		var query url.Values
+		if q.label != "" {
+			query = url.Values{"labelSelector": {q.label + "=" + q.value}}
+		}
+		list, err := apiSchema.Store.List(listRequest(apiOp, apiSchema, query), apiSchema)
+		if err != nil {
+			return result, err
+		}
+		for _, obj := range list.Objects {
+			// only names and labels are searched, as with the cluster cache
+			if hit, ok := q.match(obj.Data(), nil); ok {
+				hit.Type, hit.ID = apiSchema.ID, obj.ID
+				result.Count++
+				result.Hits = append(result.Hits, hit)
+			}
+		}
+	} else {
+		access, _ := attributes.Access(apiSchema).(accesscontrol.AccessListByVerb)
+		all := access.Grants("list", "*", "*")
+		for _, item := range s.ccache.List(gvk) {
+			obj, err := meta.Accessor(item)
+			if err != nil {
+				continue
+			}
+			ns, name := obj.GetNamespace(), obj.GetName()
+			if !all && !access.Grants("list", ns, name) && !access.Grants("get", ns, name) {
+				continue
+			}
+			// the cluster cache only holds the metadata of objects, so only names and labels are searched
+			hit, ok := q.match(metadataObject(obj), nil)
+			if !ok {
+				continue
+			}
+			hit.Type, hit.ID = apiSchema.ID, name
+			if ns != "" {
+				hit.ID = ns + "/" + name
+			}
+			result.Count++
+			result.Hits = append(result.Hits, hit)
+		}
+	}
+
+	slices.SortFunc(result.Hits, func(a, b Hit) int {
+		return cmp.Or(
+			cmp.Compare(b.Score, a.Score),
+			cmp.Compare(a.ID, b.ID),
+		)
+	})
+	if len(result.Hits) > limit {
+		result.Hits = result.Hits[:limit]
+	}
+	return result, nil
+}
+
+// sqlQuery returns the query listing the first candidates matching q from the SQL cache.
+func sqlQuery(q query, typeFields [][]string) url.Values {
+	var filter string
+	if q.label != "" {
+		filter = "metadata.labels[" + q.label + "]=" + q.value
+	} else {
+		filters := []string{"metadata.name~" + q.value}
+		for _, field := range typeFields {
+			filters = append(filters, strings.Join(field, ".")+"~"+q.value)
+		}
+		filter = strings.Join(filters, ",")
+	}
+	return url.Values{
+		"filter":   {filter},
+		"pagesize": {strconv.Itoa(candidates)},
+	}
+}
+
+// exactNameQuery returns the query listing the objects named like q from the SQL cache.
+func exactNameQuery(q query) url.Values {
+	return url.Values{
+		"filter":   {"metadata.name=" + q.value},
+		"pagesize": {strconv.Itoa(candidates)},
+	}
+}
+
+// listRequest returns a copy of apiOp listing apiSchema with the given query across all namespaces, through the store
+// of the type which only lists the partitions the user can access.
+func listRequest(apiOp *types.APIRequest, apiSchema *types.APISchema, query url.Values) *types.APIRequest {
+	result := apiOp.Clone()
+	result.Request = apiOp.Request.Clone(apiOp.Context())
+	result.Request.URL.RawQuery = query.Encode()
+	result.Schema = apiSchema
+	result.Type = apiSchema.ID
+	result.Namespace = ""
+	result.Name = ""
+	result.Link = ""
+	result.Query = query
+	return result
+}
+
+func metadataObject(obj metav1.Object) data.Object {
+	labels := map[string]interface{}{}
+	for key, value := range obj.GetLabels() {
+		labels[key] = value
+	}
+	return data.Object{
+		"metadata": map[string]interface{}{
+			"name":   obj.GetName(),
+			"labels": labels,
+		},
+	}
+}
+
+// match returns the best hit of obj for the query, with the same case-insensitive matching as the SQL cache.
+func (q query) match(obj data.Object, typeFields [][]string) (Hit, bool) {
+	if q.label != "" {
+		value, ok := obj.Map("metadata", "labels")[q.label].(string)
+		if !ok || value != q.value {
+			return Hit{}, false
+		}
+		return Hit{Field: "metadata.labels[" + q.label + "]", Value: value, Score: scoreLabel}, true
+	}
+
+	term := strings.ToLower(q.value)
+	name := obj.String("metadata", "name")
+	lowerName := strings.ToLower(name)
+	switch {
+	case lowerName == term:
+		return Hit{Field: "metadata.name", Value: name, Score: scoreNameExact}, true
+	case strings.HasPrefix(lowerName, term):
+		return Hit{Field: "metadata.name", Value: name, Score: scoreNamePrefix}, true
+	case strings.Contains(lowerName, term):
+		return Hit{Field: "metadata.name", Value: name, Score: scoreNameContain}, true
+	}
+
+	var (
+		best  Hit
+		found bool
+	)
+	for _, field := range typeFields {
+		value := obj.String(field...)
+		lowerValue := strings.ToLower(value)
+		score := 0
+		switch {
+		case lowerValue == term:
+			score = scoreFieldExact
+		case strings.Contains(lowerValue, term):
+			score = scoreFieldMatch
+		}
+		if score > best.Score {
+			best = Hit{Field: strings.Join(field, "."), Value: value, Score: score}
+			found = true
+		}
+	}
+	return best, found
+}
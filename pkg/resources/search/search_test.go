@@ -0,0 +1,428 @@
+package search
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/rancher/apiserver/pkg/server"
+	"github.com/rancher/apiserver/pkg/store/empty"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/rancher/steve/pkg/attributes"
+	"github.com/rancher/steve/pkg/clustercache"
+	"github.com/rancher/steve/pkg/policy"
+	"github.com/rancher/wrangler/v3/pkg/data"
+	"github.com/rancher/wrangler/v3/pkg/schemas"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var (
+	podGVK       = schema.GroupVersionKind{Version: "v1", Kind: "Pod"}
+	configMapGVK = schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+	secretGVK    = schema.GroupVersionKind{Version: "v1", Kind: "Secret"}
+)
+
+type fakeStore struct {
+	empty.Store
+
+	objects []map[string]interface{}
+	queries []url.Values
+	// policy is applied to the objects listed, as by the proxy store
+	policy policy.Policy
+}
+
+// List only applies exact name filters, and the page size, as the SQL cache would.
+func (f *fakeStore) List(apiOp *types.APIRequest, apiSchema *types.APISchema) (types.APIObjectList, error) {
+	query := apiOp.Request.URL.Query()
+	f.queries = append(f.queries, query)
+	name, exact := strings.CutPrefix(query.Get("filter"), "metadata.name=")
+	var result types.APIObjectList
+	for _, obj := range f.objects {
+		u := &unstructured.Unstructured{Object: obj}
+		if !policy.Allowed(f.policy, policy.ObjectAttributes(apiOp, apiSchema, "list", u)) || (exact && u.GetName() != name) {
+			continue
+		}
+		result.Objects = append(result.Objects, types.APIObject{
+			Type:   apiSchema.ID,
+			ID:     u.GetNamespace() + "/" + u.GetName(),
+			Object: u,
+		})
+	}
+	result.Count = len(result.Objects)
+	if pageSize, _ := strconv.Atoi(query.Get("pagesize")); pageSize > 0 && len(result.Objects) > pageSize {
+		result.Objects = result.Objects[:pageSize]
+	}
+	return result, nil
+}
+
+type fakeClusterCache struct {
+	clustercache.ClusterCache
+
+	objects map[schema.GroupVersionKind][]interface{}
+}
+
+func (f *fakeClusterCache) List(gvk schema.GroupVersionKind) []interface{} {
+	return f.objects[gvk]
+}
+
+type fakeCachedTypes []schema.GroupVersionKind
+
+func (f fakeCachedTypes) CachedGVKs() []schema.GroupVersionKind {
+	return f
+}
+
+func object(namespace, name string, labels map[string]interface{}, spec map[string]interface{}) map[string]interface{} {
+	metadata := map[string]interface{}{
+		"name":      name,
+		"namespace": namespace,
+	}
+	if labels != nil {
+		metadata["labels"] = labels
+	}
+	obj := map[string]interface{}{"metadata": metadata}
+	if spec != nil {
+		obj["spec"] = spec
+	}
+	return obj
+}
+
+func addSchema(apiSchemas *types.APISchemas, id string, gvk schema.GroupVersionKind, access accesscontrol.AccessListByVerb, store types.Store) {
+	apiSchema := &types.APISchema{
+		Schema: &schemas.Schema{
+			ID:                id,
+			CollectionMethods: []string{http.MethodGet},
+			Attributes:        map[string]interface{}{},
+		},
+		Store: store,
+	}
+	attributes.SetGVK(apiSchema, gvk)
+	attributes.SetNamespaced(apiSchema, true)
+	attributes.SetVerbs(apiSchema, []string{"get", "list"})
+	attributes.SetAccess(apiSchema, access)
+	apiSchemas.AddSchema(*apiSchema)
+}
+
+func newRequest(apiSchemas *types.APISchemas, query url.Values) *types.APIRequest {
+	req := httptest.NewRequest(http.MethodGet, "/v1/search?"+query.Encode(), nil)
+	return &types.APIRequest{
+		Request:       req,
+		Method:        http.MethodGet,
+		Type:          "search",
+		Query:         query,
+		Schemas:       apiSchemas,
+		AccessControl: &server.SchemaBasedAccess{},
+	}
+}
+
+func results(t *testing.T, list types.APIObjectList) []SearchResult {
+	var result []SearchResult
+	for _, obj := range list.Objects {
+		searchResult, ok := obj.Object.(SearchResult)
+		require.True(t, ok)
+		result = append(result, searchResult)
+	}
+	return result
+}
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		q       string
+		want    query
+		wantErr bool
+	}{
+		{name: "name", q: "web", want: query{value: "web"}},
+		{name: "ip", q: "10.42.0.7", want: query{value: "10.42.0.7"}},
+		{name: "label", q: "app.kubernetes.io/name=web", want: query{label: "app.kubernetes.io/name", value: "web"}},
+		{name: "missing", q: "", wantErr: true},
+		{name: "filter operator", q: "web,metadata.namespace=kube-system", wantErr: true},
+		{name: "empty label value", q: "app=", wantErr: true},
+		{name: "quote", q: "'web'", wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := parseQuery(test.q)
+			if test.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestParseLimit(t *testing.T) {
+	limit, err := parseLimit("")
+	require.NoError(t, err)
+	assert.Equal(t, DefaultLimit, limit)
+
+	limit, err = parseLimit("20")
+	require.NoError(t, err)
+	assert.Equal(t, 20, limit)
+
+	for _, value := range []string{"0", "-1", "51", "all"} {
+		_, err := parseLimit(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	typeFields := fields[podGVK.GroupKind()]
+	tests := []struct {
+		name string
+		q    query
+		obj  map[string]interface{}
+		want Hit
+		ok   bool
+	}{
+		{
+			name: "exact name",
+			q:    query{value: "Web"},
+			obj:  object("team", "web", nil, nil),
+			want: Hit{Field: "metadata.name", Value: "web", Score: scoreNameExact},
+			ok:   true,
+		},
+		{
+			name: "name prefix",
+			q:    query{value: "web"},
+			obj:  object("team", "web-1", nil, nil),
+			want: Hit{Field: "metadata.name", Value: "web-1", Score: scoreNamePrefix},
+			ok:   true,
+		},
+		{
+			name: "name contains",
+			q:    query{value: "web"},
+			obj:  object("team", "api-web", nil, nil),
+			want: Hit{Field: "metadata.name", Value: "api-web", Score: scoreNameContain},
+			ok:   true,
+		},
+		{
+			name: "field",
+			q:    query{value: "node-1"},
+			obj:  object("team", "api", nil, map[string]interface{}{"nodeName": "node-1"}),
+			want: Hit{Field: "spec.nodeName", Value: "node-1", Score: scoreFieldExact},
+			ok:   true,
+		},
+		{
+			name: "label",
+			q:    query{label: "app", value: "web"},
+			obj:  object("team", "api", map[string]interface{}{"app": "web"}, nil),
+			want: Hit{Field: "metadata.labels[app]", Value: "web", Score: scoreLabel},
+			ok:   true,
+		},
+		{
+			name: "label value differs",
+			q:    query{label: "app", value: "web"},
+			obj:  object("team", "web", map[string]interface{}{"app": "api"}, nil),
+		},
+		{
+			name: "no match",
+			q:    query{value: "db"},
+			obj:  object("team", "web", nil, map[string]interface{}{"nodeName": "node-1"}),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, ok := test.q.match(data.Object(test.obj), typeFields)
+			assert.Equal(t, test.ok, ok)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestListClusterCache(t *testing.T) {
+	ccache := &fakeClusterCache{objects: map[schema.GroupVersionKind][]interface{}{
+		podGVK: {
+			&unstructured.Unstructured{Object: object("team", "api-web", nil, nil)},
+			&unstructured.Unstructured{Object: object("team", "web", nil, nil)},
+			&unstructured.Unstructured{Object: object("other", "web", nil, nil)},
+			&unstructured.Unstructured{Object: object("team", "db", nil, nil)},
+		},
+		configMapGVK: {
+			&unstructured.Unstructured{Object: object("team", "web-config", nil, nil)},
+		},
+	}}
+	teamOnly := accesscontrol.AccessListByVerb{
+		"list": accesscontrol.AccessList{{Namespace: "team", ResourceName: accesscontrol.All}},
+	}
+
+	apiSchemas := types.EmptyAPISchemas()
+	Register(apiSchemas, ccache, nil, nil)
+	addSchema(apiSchemas, "pod", podGVK, teamOnly, &empty.Store{})
+	addSchema(apiSchemas, "configmap", configMapGVK, teamOnly, &empty.Store{})
+
+	apiSchema := apiSchemas.LookupSchema("search")
+	require.NotNil(t, apiSchema)
+	list, err := apiSchema.Store.List(newRequest(apiSchemas, url.Values{"q": {"web"}}), apiSchema)
+	require.NoError(t, err)
+
+	assert.Equal(t, []SearchResult{
+		{
+			ID:    "pod",
+			Count: 2,
+			Hits: []Hit{
+				{Type: "pod", ID: "team/web", Field: "metadata.name", Value: "web", Score: scoreNameExact},
+				{Type: "pod", ID: "team/api-web", Field: "metadata.name", Value: "api-web", Score: scoreNameContain},
+			},
+		},
+		{
+			ID:    "configmap",
+			Count: 1,
+			Hits: []Hit{
+				{Type: "configmap", ID: "team/web-config", Field: "metadata.name", Value: "web-config", Score: scoreNamePrefix},
+			},
+		},
+	}, results(t, list))
+
+	list, err = apiSchema.Store.List(newRequest(apiSchemas, url.Values{"q": {"web"}, "limit": {"1"}}), apiSchema)
+	require.NoError(t, err)
+	searchResults := results(t, list)
+	require.Len(t, searchResults, 2)
+	assert.Equal(t, 2, searchResults[0].Count)
+	assert.Len(t, searchResults[0].Hits, 1)
+}
+
+func TestListSQLCache(t *testing.T) {
+	pods := &fakeStore{objects: []map[string]interface{}{
+		object("team", "api", nil, map[string]interface{}{"nodeName": "node-1"}),
+		object("team", "node-1-debug", nil, nil),
+	}}
+	configMaps := &fakeStore{objects: []map[string]interface{}{
+		object("team", "node-1", nil, nil),
+	}}
+
+	apiSchemas := types.EmptyAPISchemas()
+	Register(apiSchemas, nil, fakeCachedTypes{podGVK}, nil)
+	addSchema(apiSchemas, "pod", podGVK, nil, pods)
+	addSchema(apiSchemas, "configmap", configMapGVK, nil, configMaps)
+
+	apiSchema := apiSchemas.LookupSchema("search")
+	list, err := apiSchema.Store.List(newRequest(apiSchemas, url.Values{"q": {"node-1"}}), apiSchema)
+	require.NoError(t, err)
+
+	assert.Equal(t, []SearchResult{
+		{
+			ID:    "pod",
+			Count: 2,
+			Hits: []Hit{
+				{Type: "pod", ID: "team/node-1-debug", Field: "metadata.name", Value: "node-1-debug", Score: scoreNamePrefix},
+				{Type: "pod", ID: "team/api", Field: "spec.nodeName", Value: "node-1", Score: scoreFieldExact},
+			},
+		},
+	}, results(t, list))
+
+	// config maps aren't cached, so they aren't listed
+	assert.Empty(t, configMaps.queries)
+	require.Len(t, pods.queries, 2)
+	assert.Equal(t, url.Values{
+		"filter":   {"metadata.name=node-1"},
+		"pagesize": {"100"},
+	}, pods.queries[0])
+	assert.Equal(t, url.Values{
+		"filter":   {"metadata.name~node-1,spec.nodeName~node-1,status.podIP~node-1"},
+		"pagesize": {"100"},
+	}, pods.queries[1])
+
+	_, err = apiSchema.Store.List(newRequest(apiSchemas, url.Values{"q": {"app=web"}}), apiSchema)
+	require.NoError(t, err)
+	require.Len(t, pods.queries, 3)
+	assert.Equal(t, []string{"metadata.labels[app]=web"}, pods.queries[2]["filter"])
+}
+
+func TestListSQLCacheExactName(t *testing.T) {
+	pods := &fakeStore{}
+	for i := range candidates + 10 {
+		pods.objects = append(pods.objects, object("team", fmt.Sprintf("web-%03d", i), nil, nil))
+	}
+	// the exact match comes after the candidates of the substring query
+	pods.objects = append(pods.objects, object("team", "web", nil, nil))
+
+	apiSchemas := types.EmptyAPISchemas()
+	Register(apiSchemas, nil, fakeCachedTypes{podGVK}, nil)
+	addSchema(apiSchemas, "pod", podGVK, nil, pods)
+
+	apiSchema := apiSchemas.LookupSchema("search")
+	list, err := apiSchema.Store.List(newRequest(apiSchemas, url.Values{"q": {"web"}}), apiSchema)
+	require.NoError(t, err)
+
+	searchResults := results(t, list)
+	require.Len(t, searchResults, 1)
+	assert.Equal(t, candidates+11, searchResults[0].Count)
+	require.Len(t, searchResults[0].Hits, DefaultLimit)
+	assert.Equal(t, Hit{Type: "pod", ID: "team/web", Field: "metadata.name", Value: "web", Score: scoreNameExact}, searchResults[0].Hits[0])
+	assert.Equal(t, "team/web-000", searchResults[0].Hits[1].ID)
+}
+
+func TestListPolicy(t *testing.T) {
+	helmRelease := object("team", "web.v1", map[string]interface{}{"owner": "helm"}, nil)
+	helmRelease["type"] = "helm.sh/release.v1"
+	secrets := &fakeStore{
+		objects: []map[string]interface{}{
+			helmRelease,
+			object("team", "web-tls", map[string]interface{}{"owner": "helm"}, nil),
+		},
+		policy: policy.Func(func(attrs *policy.Attributes) policy.Decision {
+			if attrs.Object["type"] == "helm.sh/release.v1" {
+				return policy.Deny("helm releases are hidden")
+			}
+			return policy.Decision{}
+		}),
+	}
+	// the cluster cache only holds the metadata of the secrets, which the policy can't deny
+	ccache := &fakeClusterCache{objects: map[schema.GroupVersionKind][]interface{}{
+		secretGVK: {
+			&unstructured.Unstructured{Object: object("team", "web.v1", nil, nil)},
+			&unstructured.Unstructured{Object: object("team", "web-tls", nil, nil)},
+		},
+	}}
+	all := accesscontrol.AccessListByVerb{
+		"list": accesscontrol.AccessList{{Namespace: accesscontrol.All, ResourceName: accesscontrol.All}},
+	}
+
+	apiSchemas := types.EmptyAPISchemas()
+	Register(apiSchemas, ccache, nil, secrets.policy)
+	addSchema(apiSchemas, "secret", secretGVK, all, secrets)
+
+	apiSchema := apiSchemas.LookupSchema("search")
+	list, err := apiSchema.Store.List(newRequest(apiSchemas, url.Values{"q": {"web"}}), apiSchema)
+	require.NoError(t, err)
+	assert.Equal(t, []SearchResult{
+		{
+			ID:    "secret",
+			Count: 1,
+			Hits: []Hit{
+				{Type: "secret", ID: "team/web-tls", Field: "metadata.name", Value: "web-tls", Score: scoreNamePrefix},
+			},
+		},
+	}, results(t, list))
+	require.Len(t, secrets.queries, 1)
+	assert.Empty(t, secrets.queries[0])
+
+	list, err = apiSchema.Store.List(newRequest(apiSchemas, url.Values{"q": {"owner=helm"}}), apiSchema)
+	require.NoError(t, err)
+	searchResults := results(t, list)
+	require.Len(t, searchResults, 1)
+	assert.Equal(t, 1, searchResults[0].Count)
+	require.Len(t, secrets.queries, 2)
+	assert.Equal(t, url.Values{"labelSelector": {"owner=helm"}}, secrets.queries[1])
+}
+
+func TestListInvalidQuery(t *testing.T) {
+	apiSchemas := types.EmptyAPISchemas()
+	Register(apiSchemas, &fakeClusterCache{}, nil, nil)
+	apiSchema := apiSchemas.LookupSchema("search")
+
+	_, err := apiSchema.Store.List(newRequest(apiSchemas, url.Values{}), apiSchema)
+	assert.Error(t, err)
+	_, err = apiSchema.Store.List(newRequest(apiSchemas, url.Values{"q": {"web"}, "limit": {"100"}}), apiSchema)
+	assert.Error(t, err)
+}
@@ -2,15 +2,43 @@
 package events
 
 import (
+	"time"
+
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
 // TransformEventObject does special-case handling on event objects
-// 1. (only one so far): replaces the _type field with the contents of the field named "type", if it exists
+//  1. replaces the _type field with the contents of the field named "type", if it exists
+//  2. sets the _lastTimestamp field to LastTimestamp, in RFC 3339 form so that it sorts as a string
 func TransformEventObject(obj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
 	currentTypeValue, ok := obj.Object["type"]
 	if ok {
 		obj.Object["_type"] = currentTypeValue
 	}
+	if lastTimestamp := LastTimestamp(obj); !lastTimestamp.IsZero() {
+		obj.Object["_lastTimestamp"] = lastTimestamp.UTC().Format(time.RFC3339)
+	}
 	return obj, nil
 }
+
+// LastTimestamp returns when an event was last seen. Events recorded with the events.k8s.io API have no
+// lastTimestamp, but an eventTime and the last time of their series, if they were repeated.
+func LastTimestamp(obj *unstructured.Unstructured) time.Time {
+	for _, field := range [][]string{
+		{"lastTimestamp"},
+		{"series", "lastObservedTime"},
+		{"eventTime"},
+		{"firstTimestamp"},
+		{"metadata", "creationTimestamp"},
+	} {
+		value, _, _ := unstructured.NestedString(obj.Object, field...)
+		if value == "" {
+			continue
+		}
+		// eventTime and lastObservedTime are MicroTimes, which RFC3339Nano also parses
+		if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
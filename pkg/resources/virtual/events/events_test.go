@@ -43,6 +43,40 @@ func TestTransformEvents(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "set the last timestamp",
+			input: &unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"apiVersion": "/v1",
+					"kind":       "Event",
+					"metadata": map[string]interface{}{
+						"name":      "gregsFarm",
+						"namespace": "gregsNamespace",
+					},
+					"eventTime": "2025-01-10T22:52:16.123456Z",
+					"series": map[string]interface{}{
+						"count":            int64(3),
+						"lastObservedTime": "2025-01-10T23:00:00.000001Z",
+					},
+				},
+			},
+			wantOutput: &unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"apiVersion": "/v1",
+					"kind":       "Event",
+					"metadata": map[string]interface{}{
+						"name":      "gregsFarm",
+						"namespace": "gregsNamespace",
+					},
+					"eventTime": "2025-01-10T22:52:16.123456Z",
+					"series": map[string]interface{}{
+						"count":            int64(3),
+						"lastObservedTime": "2025-01-10T23:00:00.000001Z",
+					},
+					"_lastTimestamp": "2025-01-10T23:00:00Z",
+				},
+			},
+		},
 		{
 			name: "don't fix non-default-group event fields",
 			input: &unstructured.Unstructured{
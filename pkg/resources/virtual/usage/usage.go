@@ -0,0 +1,266 @@
+// Package usage caches the CPU and memory usage of pods and nodes reported by the metrics.k8s.io API, and adds it
+// to Pod and Node objects as the virtual status.usage field.
+package usage
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/rancher/steve/pkg/schema"
+	"github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	k8sschema "k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+)
+
+// DefaultInterval is how often the metrics are listed if no interval is given. The metrics server itself only
+// scrapes the kubelets every 15 seconds by default.
+const DefaultInterval = 30 * time.Second
+
+var (
+	PodGVK  = k8sschema.GroupVersionKind{Version: "v1", Kind: "Pod"}
+	NodeGVK = k8sschema.GroupVersionKind{Version: "v1", Kind: "Node"}
+
+	podMetricsGVR  = k8sschema.GroupVersionResource{Group: "metrics.k8s.io", Version: "v1beta1", Resource: "pods"}
+	nodeMetricsGVR = k8sschema.GroupVersionResource{Group: "metrics.k8s.io", Version: "v1beta1", Resource: "nodes"}
+
+	metricsGVRs = map[k8sschema.GroupVersionKind]k8sschema.GroupVersionResource{
+		PodGVK:  podMetricsGVR,
+		NodeGVK: nodeMetricsGVR,
+	}
+)
+
+// Usage is the CPU and memory used by a pod, summed over its containers, or by a node.
+type Usage struct {
+	CPU    resource.Quantity
+	Memory resource.Quantity
+}
+
+func (u Usage) equal(other Usage) bool {
+	return u.CPU.Cmp(other.CPU) == 0 && u.Memory.Cmp(other.Memory) == 0
+}
+
+// Handles returns whether objects of gvk get a usage.
+func Handles(gvk k8sschema.GroupVersionKind) bool {
+	return gvk == PodGVK || gvk == NodeGVK
+}
+
+// Cache periodically lists the PodMetrics and NodeMetrics of the cluster and keeps the latest usage of every pod
+// and node, keyed by namespace/name for pods and name for nodes.
+type Cache struct {
+	client   dynamic.Interface
+	interval time.Duration
+
+	lock  sync.RWMutex
+	usage map[k8sschema.GroupVersionKind]map[string]Usage
+	// generations is increased for a type each time the usage of any of its objects changes
+	generations map[k8sschema.GroupVersionKind]uint64
+	onChange    []func(gvk k8sschema.GroupVersionKind, keys []string)
+}
+
+// NewCache returns a Cache listing the metrics with client every interval, or every DefaultInterval if interval
+// isn't positive. Nothing is listed before Start is called.
+func NewCache(client dynamic.Interface, interval time.Duration) *Cache {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	return &Cache{
+		client:      client,
+		interval:    interval,
+		usage:       map[k8sschema.GroupVersionKind]map[string]Usage{},
+		generations: map[k8sschema.GroupVersionKind]uint64{},
+	}
+}
+
+// Start lists the metrics until ctx is done.
+func (c *Cache) Start(ctx context.Context) {
+	go wait.UntilWithContext(ctx, c.Refresh, c.interval)
+}
+
+// OnChange registers f to be called with the keys of the objects of gvk whose usage changed, appeared or
+// disappeared after each refresh.
+func (c *Cache) OnChange(f func(gvk k8sschema.GroupVersionKind, keys []string)) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.onChange = append(c.onChange, f)
+}
+
+// Get returns the usage of the object of gvk with the given key.
+func (c *Cache) Get(gvk k8sschema.GroupVersionKind, key string) (Usage, bool) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	u, ok := c.usage[gvk][key]
+	return u, ok
+}
+
+// Generation returns a number which changes whenever the usage of an object of gvk changes, as the resourceVersion
+// of the object doesn't.
+func (c *Cache) Generation(gvk k8sschema.GroupVersionKind) uint64 {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.generations[gvk]
+}
+
+// Refresh lists the metrics once. Types whose metrics can't be listed keep their previous usage, unless the metrics
+// API isn't available at all.
+func (c *Cache) Refresh(ctx context.Context) {
+	for gvk, gvr := range metricsGVRs {
+		list, err := c.client.Resource(gvr).List(ctx, metav1.ListOptions{})
+		if apierrors.IsNotFound(err) {
+			logrus.Debugf("metrics API unavailable, no usage for %s: %v", gvk.Kind, err)
+			list = &unstructured.UnstructuredList{}
+		} else if err != nil {
+			logrus.Errorf("failed to list %s metrics: %v", gvk.Kind, err)
+			continue
+		}
+
+		usage := make(map[string]Usage, len(list.Items))
+		for _, item := range list.Items {
+			u, err := usageOf(&item)
+			if err != nil {
+				logrus.Debugf("invalid metrics for %s %s/%s: %v", gvk.Kind, item.GetNamespace(), item.GetName(), err)
+				continue
+			}
+			key := item.GetName()
+			if item.GetNamespace() != "" {
+				key = item.GetNamespace() + "/" + key
+			}
+			usage[key] = u
+		}
+		c.set(gvk, usage)
+	}
+}
+
+// set replaces the usage of gvk and calls the OnChange funcs with the keys whose usage changed.
+func (c *Cache) set(gvk k8sschema.GroupVersionKind, usage map[string]Usage) {
+	c.lock.Lock()
+	old := c.usage[gvk]
+	c.usage[gvk] = usage
+	onChange := c.onChange
+	c.lock.Unlock()
+
+	var changed []string
+	for key, u := range usage {
+		if previous, ok := old[key]; !ok || !previous.equal(u) {
+			changed = append(changed, key)
+		}
+	}
+	for key := range old {
+		if _, ok := usage[key]; !ok {
+			changed = append(changed, key)
+		}
+	}
+	if len(changed) == 0 {
+		return
+	}
+	c.lock.Lock()
+	c.generations[gvk]++
+	c.lock.Unlock()
+	for _, f := range onChange {
+		f(gvk, changed)
+	}
+}
+
+// usageOf returns the usage of a NodeMetrics, or the usage of the containers of a PodMetrics added up.
+func usageOf(metrics *unstructured.Unstructured) (Usage, error) {
+	var result Usage
+	var usages []interface{}
+	if containers, ok := metrics.Object["containers"].([]interface{}); ok {
+		for _, container := range containers {
+			if m, ok := container.(map[string]interface{}); ok {
+				usages = append(usages, m["usage"])
+			}
+		}
+	} else {
+		usages = append(usages, metrics.Object["usage"])
+	}
+	for _, usage := range usages {
+		m, _ := usage.(map[string]interface{})
+		for name, total := range map[string]*resource.Quantity{"cpu": &result.CPU, "memory": &result.Memory} {
+			value, ok := m[name].(string)
+			if !ok {
+				continue
+			}
+			q, err := resource.ParseQuantity(value)
+			if err != nil {
+				return result, err
+			}
+			total.Add(q)
+		}
+	}
+	return result, nil
+}
+
+// Transform sets status.usage of obj, of type gvk, to its cached usage, or removes it if there's none. Like the
+// capacity of management clusters, the quantities are also added as numbers, in cores and bytes, so that they can
+// be sorted and filtered on: cpu, memory, cpuRaw and memoryRaw.
+func (c *Cache) Transform(gvk k8sschema.GroupVersionKind, obj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	key := obj.GetName()
+	if obj.GetNamespace() != "" {
+		key = obj.GetNamespace() + "/" + key
+	}
+	u, ok := c.Get(gvk, key)
+	if !ok {
+		unstructured.RemoveNestedField(obj.Object, "status", "usage")
+		return obj, nil
+	}
+	return obj, unstructured.SetNestedMap(obj.Object, map[string]interface{}{
+		"cpu":       u.CPU.String(),
+		"cpuRaw":    u.CPU.AsApproximateFloat64(),
+		"memory":    u.Memory.String(),
+		"memoryRaw": u.Memory.AsApproximateFloat64(),
+	}, "status", "usage")
+}
+
+// CanRead returns whether the user of apiOp may read the metrics of the object of gvk with the given namespace and
+// name, which its usage comes from. The metrics are listed with the admin client, so the usage must only be shown to
+// the users who could list the metrics themselves. An empty name checks the access to all the objects of the
+// namespace, and an empty namespace to all the namespaces.
+func CanRead(apiOp *types.APIRequest, gvk k8sschema.GroupVersionKind, namespace, name string) bool {
+	accessSet := accesscontrol.AccessSetFromAPIRequest(apiOp)
+	if accessSet == nil {
+		return false
+	}
+	if namespace == "" && gvk == PodGVK {
+		namespace = accesscontrol.All
+	}
+	if name == "" {
+		name = accesscontrol.All
+	}
+	gr := metricsGVRs[gvk].GroupResource()
+	return accessSet.Grants("list", gr, namespace, name) || (name != accesscontrol.All && accessSet.Grants("get", gr, namespace, name))
+}
+
+// Templates add the usage to pods and nodes in responses, including the usage refreshed since the object was
+// last cached, for the users who can read the metrics. Nothing is added without a cache.
+func Templates(c *Cache) []schema.Template {
+	if c == nil {
+		return nil
+	}
+	var result []schema.Template
+	for id, gvk := range map[string]k8sschema.GroupVersionKind{"pod": PodGVK, "node": NodeGVK} {
+		result = append(result, schema.Template{
+			ID: id,
+			Formatter: func(request *types.APIRequest, resource *types.RawResource) {
+				obj, ok := resource.APIObject.Object.(*unstructured.Unstructured)
+				if !ok {
+					return
+				}
+				if !CanRead(request, gvk, obj.GetNamespace(), obj.GetName()) {
+					// the usage is also saved in the SQL cache
+					unstructured.RemoveNestedField(obj.Object, "status", "usage")
+					return
+				}
+				c.Transform(gvk, obj)
+			},
+		})
+	}
+	return result
+}
@@ -0,0 +1,156 @@
+package usage
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sschema "k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/fake"
+)
+
+func podMetrics(name string, containers ...map[string]interface{}) *unstructured.Unstructured {
+	var c []interface{}
+	for _, usage := range containers {
+		c = append(c, map[string]interface{}{"name": "c", "usage": usage})
+	}
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "metrics.k8s.io/v1beta1",
+		"kind":       "PodMetrics",
+		"metadata":   map[string]interface{}{"name": name, "namespace": "default"},
+		"containers": c,
+	}}
+}
+
+func nodeMetrics(name string, usage map[string]interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "metrics.k8s.io/v1beta1",
+		"kind":       "NodeMetrics",
+		"metadata":   map[string]interface{}{"name": name},
+		"usage":      usage,
+	}}
+}
+
+func newClient(t *testing.T, objs ...*unstructured.Unstructured) *fake.FakeDynamicClient {
+	client := fake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[k8sschema.GroupVersionResource]string{
+		podMetricsGVR:  "PodMetricsList",
+		nodeMetricsGVR: "NodeMetricsList",
+	})
+	for _, obj := range objs {
+		gvr := podMetricsGVR
+		if obj.GetKind() == "NodeMetrics" {
+			gvr = nodeMetricsGVR
+		}
+		_, err := client.Resource(gvr).Namespace(obj.GetNamespace()).Create(context.Background(), obj, metav1.CreateOptions{})
+		require.NoError(t, err)
+	}
+	return client
+}
+
+func TestRefresh(t *testing.T) {
+	client := newClient(t,
+		podMetrics("web", map[string]interface{}{"cpu": "100m", "memory": "64Mi"}, map[string]interface{}{"cpu": "150m", "memory": "64Mi"}),
+		podMetrics("db", map[string]interface{}{"cpu": "1", "memory": "1Gi"}),
+		nodeMetrics("node1", map[string]interface{}{"cpu": "2500m", "memory": "4Gi"}),
+	)
+	c := NewCache(client, 0)
+	changes := map[k8sschema.GroupVersionKind][]string{}
+	c.OnChange(func(gvk k8sschema.GroupVersionKind, keys []string) {
+		slices.Sort(keys)
+		changes[gvk] = keys
+	})
+
+	c.Refresh(context.Background())
+
+	assert.Equal(t, map[k8sschema.GroupVersionKind][]string{
+		PodGVK:  {"default/db", "default/web"},
+		NodeGVK: {"node1"},
+	}, changes)
+	podGeneration, nodeGeneration := c.Generation(PodGVK), c.Generation(NodeGVK)
+	usage, ok := c.Get(PodGVK, "default/web")
+	require.True(t, ok)
+	assert.Equal(t, "250m", usage.CPU.String())
+	assert.Equal(t, "128Mi", usage.Memory.String())
+
+	// only the pods whose usage changed, and the ones gone, are reported
+	clear(changes)
+	require.NoError(t, client.Resource(podMetricsGVR).Namespace("default").Delete(context.Background(), "db", metav1.DeleteOptions{}))
+	_, err := client.Resource(podMetricsGVR).Namespace("default").Create(context.Background(),
+		podMetrics("cache", map[string]interface{}{"cpu": "10m", "memory": "1Mi"}), metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	c.Refresh(context.Background())
+
+	assert.Equal(t, map[k8sschema.GroupVersionKind][]string{PodGVK: {"default/cache", "default/db"}}, changes)
+	assert.NotEqual(t, podGeneration, c.Generation(PodGVK))
+	assert.Equal(t, nodeGeneration, c.Generation(NodeGVK), "unchanged usage should keep the generation")
+	_, ok = c.Get(PodGVK, "default/db")
+	assert.False(t, ok)
+}
+
+func TestTransform(t *testing.T) {
+	c := NewCache(newClient(t, nodeMetrics("node1", map[string]interface{}{"cpu": "2500m", "memory": "4Ki"})), 0)
+	c.Refresh(context.Background())
+
+	node := &unstructured.Unstructured{Object: map[string]interface{}{
+		"metadata": map[string]interface{}{"name": "node1"},
+		"status":   map[string]interface{}{"phase": "Running"},
+	}}
+	_, err := c.Transform(NodeGVK, node)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"phase": "Running",
+		"usage": map[string]interface{}{
+			"cpu":       "2500m",
+			"cpuRaw":    2.5,
+			"memory":    "4Ki",
+			"memoryRaw": 4096.0,
+		},
+	}, node.Object["status"])
+
+	// the usage is removed once there are no metrics for the object anymore
+	node.SetName("node2")
+	_, err = c.Transform(NodeGVK, node)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"phase": "Running"}, node.Object["status"])
+}
+
+func TestTemplatesRequireMetricsAccess(t *testing.T) {
+	c := NewCache(newClient(t, podMetrics("web", map[string]interface{}{"cpu": "1", "memory": "1Ki"})), 0)
+	c.Refresh(context.Background())
+	templates := Templates(c)
+	var formatter types.Formatter
+	for _, template := range templates {
+		if template.ID == "pod" {
+			formatter = template.Formatter
+		}
+	}
+	require.NotNil(t, formatter)
+
+	format := func(accessSet *accesscontrol.AccessSet) map[string]interface{} {
+		apiSchemas := types.EmptyAPISchemas()
+		accesscontrol.SetAccessSetAttribute(apiSchemas, accessSet)
+		pod := &unstructured.Unstructured{Object: map[string]interface{}{
+			"metadata": map[string]interface{}{"name": "web", "namespace": "default"},
+			// the usage saved in the SQL cache
+			"status": map[string]interface{}{"usage": map[string]interface{}{"cpu": "1"}},
+		}}
+		formatter(&types.APIRequest{Schemas: apiSchemas}, &types.RawResource{APIObject: types.APIObject{Object: pod}})
+		usage, _, _ := unstructured.NestedMap(pod.Object, "status", "usage")
+		return usage
+	}
+
+	assert.Nil(t, format(&accesscontrol.AccessSet{}))
+
+	accessSet := &accesscontrol.AccessSet{}
+	accessSet.Add("list", podMetricsGVR.GroupResource(), accesscontrol.Access{Namespace: "default", ResourceName: accesscontrol.All})
+	assert.Equal(t, "1", format(accessSet)["cpu"])
+	assert.Equal(t, 1024.0, format(accessSet)["memoryRaw"])
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/rancher/steve/pkg/resources/virtual/clusters"
 	"github.com/rancher/steve/pkg/resources/virtual/common"
 	"github.com/rancher/steve/pkg/resources/virtual/events"
+	"github.com/rancher/steve/pkg/resources/virtual/usage"
 
 	"github.com/sirupsen/logrus"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
@@ -23,14 +24,17 @@ var now = time.Now
 // TransformBuilder builds transform functions for specified GVKs through GetTransformFunc
 type TransformBuilder struct {
 	defaultFields *common.DefaultFields
+	usageCache    *usage.Cache
 }
 
-// NewTransformBuilder returns a TransformBuilder using the given summary cache
-func NewTransformBuilder(cache common.SummaryCache) *TransformBuilder {
+// NewTransformBuilder returns a TransformBuilder using the given summary cache. Pods and nodes get their resource
+// usage from usageCache, unless it is nil.
+func NewTransformBuilder(cache common.SummaryCache, usageCache *usage.Cache) *TransformBuilder {
 	return &TransformBuilder{
 		defaultFields: &common.DefaultFields{
 			Cache: cache,
 		},
+		usageCache: usageCache,
 	}
 }
 
@@ -41,6 +45,10 @@ func (t *TransformBuilder) GetTransformFunc(gvk schema.GroupVersionKind, columns
 		converters = append(converters, events.TransformEventObject)
 	} else if gvk.Kind == "Cluster" && gvk.Group == "management.cattle.io" && gvk.Version == "v3" {
 		converters = append(converters, clusters.TransformManagedCluster)
+	} else if t.usageCache != nil && usage.Handles(gvk) {
+		converters = append(converters, func(obj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+			return t.usageCache.Transform(gvk, obj)
+		})
 	}
 
 	// Detecting if we need to convert date fields
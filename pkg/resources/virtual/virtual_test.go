@@ -552,7 +552,7 @@ func TestTransformChain(t *testing.T) {
 				SummarizedObject: test.hasSummary,
 				Relationships:    test.hasRelationships,
 			}
-			tb := NewTransformBuilder(&fakeCache)
+			tb := NewTransformBuilder(&fakeCache, nil)
 			raw, isSignal, err := common.GetUnstructured(test.input)
 			require.False(t, isSignal)
 			require.Nil(t, err)
@@ -0,0 +1,11 @@
+package workloads
+
+type ScaleInput struct {
+	// Replicas is the number of replicas to scale to.
+	Replicas *int32 `json:"replicas"`
+}
+
+type RollbackInput struct {
+	// Revision is the revision to roll back to, the previous revision if unset.
+	Revision int64 `json:"revision,omitempty"`
+}
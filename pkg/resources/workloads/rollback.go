@@ -0,0 +1,136 @@
+package workloads
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/wrangler/v3/pkg/schemas/validation"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	apitypes "k8s.io/apimachinery/pkg/types"
+)
+
+// deploymentRevisionAnnotation is the revision of the template of a replicaset, set by the deployment controller.
+const deploymentRevisionAnnotation = "deployment.kubernetes.io/revision"
+
+// rollback sets the pod template of the workload back to the one of a previous revision, the previous one if
+// revision is 0. The revisions of deployments are their replicasets, those of statefulsets and daemonsets their
+// controllerrevisions.
+func (w *workload) rollback(ctx context.Context, revision int64) (runtime.Object, error) {
+	if w.kind == deploymentKind {
+		return w.rollbackDeployment(ctx, revision)
+	}
+	return w.rollbackControllerRevision(ctx, revision)
+}
+
+func (w *workload) rollbackDeployment(ctx context.Context, revision int64) (runtime.Object, error) {
+	deployment, err := w.apps.Deployments(w.namespace).Get(ctx, w.name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	// the deployment controller doesn't roll out paused deployments, which would leave the rollback half done
+	if deployment.Spec.Paused {
+		return nil, apierror.NewAPIError(validation.InvalidState, "can't roll back a paused deployment, resume it first")
+	}
+	selector, err := metav1.LabelSelectorAsSelector(deployment.Spec.Selector)
+	if err != nil {
+		return nil, err
+	}
+	replicaSets, err := w.apps.ReplicaSets(w.namespace).List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, err
+	}
+
+	templates := map[int64]*appsv1.ReplicaSet{}
+	for i := range replicaSets.Items {
+		rs := &replicaSets.Items[i]
+		if !metav1.IsControlledBy(rs, deployment) {
+			continue
+		}
+		if n, err := strconv.ParseInt(rs.Annotations[deploymentRevisionAnnotation], 10, 64); err == nil {
+			templates[n] = rs
+		}
+	}
+	rs, err := findRevision(templates, revision)
+	if err != nil {
+		return nil, err
+	}
+
+	template := rs.Spec.Template.DeepCopy()
+	delete(template.Labels, appsv1.DefaultDeploymentUniqueLabelKey)
+	patch, err := json.Marshal([]map[string]interface{}{
+		{"op": "replace", "path": "/spec/template", "value": template},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return w.patch(ctx, apitypes.JSONPatchType, patch)
+}
+
+func (w *workload) rollbackControllerRevision(ctx context.Context, revision int64) (runtime.Object, error) {
+	var (
+		owner    metav1.Object
+		selector *metav1.LabelSelector
+	)
+	switch w.kind {
+	case statefulSetKind:
+		statefulSet, err := w.apps.StatefulSets(w.namespace).Get(ctx, w.name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		owner, selector = statefulSet, statefulSet.Spec.Selector
+	case daemonSetKind:
+		daemonSet, err := w.apps.DaemonSets(w.namespace).Get(ctx, w.name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		owner, selector = daemonSet, daemonSet.Spec.Selector
+	default:
+		return nil, fmt.Errorf("unsupported workload kind %s", w.kind)
+	}
+	labelSelector, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	history, err := w.apps.ControllerRevisions(w.namespace).List(ctx, metav1.ListOptions{LabelSelector: labelSelector.String()})
+	if err != nil {
+		return nil, err
+	}
+
+	revisions := map[int64]*appsv1.ControllerRevision{}
+	for i := range history.Items {
+		if metav1.IsControlledBy(&history.Items[i], owner) {
+			revisions[history.Items[i].Revision] = &history.Items[i]
+		}
+	}
+	controllerRevision, err := findRevision(revisions, revision)
+	if err != nil {
+		return nil, err
+	}
+	// the data of a controllerrevision is the strategic merge patch restoring the template of its revision, as
+	// kubectl rollout undo applies it
+	return w.patch(ctx, apitypes.StrategicMergePatchType, controllerRevision.Data.Raw)
+}
+
+// findRevision returns the given revision, or the one before the latest if revision is 0.
+func findRevision[T any](revisions map[int64]T, revision int64) (T, error) {
+	var none T
+	if revision == 0 {
+		numbers := slices.Sorted(maps.Keys(revisions))
+		if len(numbers) < 2 {
+			return none, apierror.NewAPIError(validation.InvalidState, "no previous revision to roll back to")
+		}
+		revision = numbers[len(numbers)-2]
+	}
+	result, ok := revisions[revision]
+	if !ok {
+		return none, apierror.NewAPIError(validation.InvalidOption, fmt.Sprintf("revision %d not found", revision))
+	}
+	return result, nil
+}
@@ -0,0 +1,241 @@
+// Package workloads adds the scale, redeploy, pause, resume and rollback actions to deployments, statefulsets and
+// daemonsets. The actions run as the user of the request.
+package workloads
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/attributes"
+	"github.com/rancher/steve/pkg/resources/common"
+	"github.com/rancher/steve/pkg/schema"
+	"github.com/rancher/steve/pkg/stores/proxy"
+	"github.com/rancher/wrangler/v3/pkg/schemas"
+	"github.com/rancher/wrangler/v3/pkg/schemas/validation"
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	apitypes "k8s.io/apimachinery/pkg/types"
+	appsv1client "k8s.io/client-go/kubernetes/typed/apps/v1"
+)
+
+const (
+	// RestartedAtAnnotation is the pod template annotation set by redeploy, the same as kubectl rollout restart.
+	RestartedAtAnnotation = "kubectl.kubernetes.io/restartedAt"
+
+	deploymentKind  = "Deployment"
+	statefulSetKind = "StatefulSet"
+	daemonSetKind   = "DaemonSet"
+)
+
+// actionsByKind are the actions of each workload kind. Daemonsets can't be scaled and only deployments can be paused.
+var actionsByKind = map[string][]string{
+	deploymentKind:  {"scale", "redeploy", "pause", "resume", "rollback"},
+	statefulSetKind: {"scale", "redeploy", "rollback"},
+	daemonSetKind:   {"redeploy", "rollback"},
+}
+
+var actionInputs = map[string]string{
+	"scale":    "scaleInput",
+	"rollback": "rollbackInput",
+}
+
+func Register(apiSchemas *types.APISchemas) {
+	apiSchemas.MustImportAndCustomize(&ScaleInput{}, nil)
+	apiSchemas.MustImportAndCustomize(&RollbackInput{}, nil)
+}
+
+// Templates adds the workload actions to deployments, statefulsets and daemonsets.
+func Templates(cg proxy.ClientGetter) []schema.Template {
+	var result []schema.Template
+	for _, kind := range []string{deploymentKind, statefulSetKind, daemonSetKind} {
+		result = append(result, schema.Template{
+			Group: appsv1.GroupName,
+			Kind:  kind,
+			Customize: func(apiSchema *types.APISchema) {
+				AddActions(apiSchema, cg)
+			},
+		})
+	}
+	return result
+}
+
+func AddActions(apiSchema *types.APISchema, cg proxy.ClientGetter) {
+	gvk := attributes.GVK(apiSchema)
+	actions := actionsByKind[gvk.Kind]
+	if gvk.Group != appsv1.GroupName || len(actions) == 0 || !slices.Contains(attributes.Verbs(apiSchema), "patch") {
+		return
+	}
+	if apiSchema.ActionHandlers == nil {
+		apiSchema.ActionHandlers = map[string]http.Handler{}
+	}
+	if apiSchema.ResourceActions == nil {
+		apiSchema.ResourceActions = map[string]schemas.Action{}
+	}
+	for _, name := range actions {
+		apiSchema.ActionHandlers[name] = &Action{cg: cg, name: name}
+		apiSchema.ResourceActions[name] = schemas.Action{
+			Input:  actionInputs[name],
+			Output: apiSchema.ID,
+		}
+	}
+}
+
+// Action runs a workload action with the client of the user, and returns the updated workload.
+type Action struct {
+	cg   proxy.ClientGetter
+	name string
+}
+
+func (a *Action) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	apiOp := types.GetAPIContext(req.Context())
+
+	obj, err := a.Run(apiOp, req.Body)
+	if err != nil {
+		apiOp.WriteError(proxy.TranslateError(err))
+		return
+	}
+	apiOp.WriteResponse(http.StatusOK, obj)
+}
+
+// Run runs the action on the workload of apiOp, with the input read from body.
+func (a *Action) Run(apiOp *types.APIRequest, body io.Reader) (types.APIObject, error) {
+	client, err := a.cg.K8sInterface(apiOp)
+	if err != nil {
+		return types.APIObject{}, err
+	}
+	w := &workload{
+		apps:      client.AppsV1(),
+		kind:      attributes.Kind(apiOp.Schema),
+		namespace: apiOp.Namespace,
+		name:      apiOp.Name,
+	}
+
+	var obj runtime.Object
+	switch a.name {
+	case "scale":
+		var input ScaleInput
+		if err := decode(body, &input); err != nil {
+			return types.APIObject{}, err
+		}
+		if input.Replicas == nil {
+			return types.APIObject{}, apierror.NewAPIError(validation.MissingRequired, "replicas is required")
+		}
+		if *input.Replicas < 0 {
+			return types.APIObject{}, apierror.NewAPIError(validation.MinLimitExceeded, "replicas can't be negative")
+		}
+		obj, err = w.scale(apiOp.Context(), *input.Replicas)
+	case "redeploy":
+		obj, err = w.mergePatch(apiOp.Context(), map[string]interface{}{
+			"spec": map[string]interface{}{
+				"template": map[string]interface{}{
+					"metadata": map[string]interface{}{
+						"annotations": map[string]interface{}{
+							RestartedAtAnnotation: time.Now().Format(time.RFC3339),
+						},
+					},
+				},
+			},
+		})
+	case "pause", "resume":
+		obj, err = w.mergePatch(apiOp.Context(), map[string]interface{}{
+			"spec": map[string]interface{}{
+				"paused": a.name == "pause",
+			},
+		})
+	case "rollback":
+		var input RollbackInput
+		if err := decode(body, &input); err != nil {
+			return types.APIObject{}, err
+		}
+		obj, err = w.rollback(apiOp.Context(), input.Revision)
+	default:
+		return types.APIObject{}, apierror.NewAPIError(validation.InvalidAction, "invalid action "+a.name)
+	}
+	if err != nil {
+		return types.APIObject{}, err
+	}
+	return common.ToAPIObject(apiOp.Schema, obj)
+}
+
+// decode reads the input of an action. A missing body is an empty input.
+func decode(body io.Reader, input interface{}) error {
+	if body == nil {
+		return nil
+	}
+	if err := json.NewDecoder(io.LimitReader(body, 1<<20)).Decode(input); err != nil && !errors.Is(err, io.EOF) {
+		return apierror.NewAPIError(validation.InvalidBodyContent, err.Error())
+	}
+	return nil
+}
+
+// workload is a deployment, statefulset or daemonset, changed with the typed client of its kind.
+type workload struct {
+	apps      appsv1client.AppsV1Interface
+	kind      string
+	namespace string
+	name      string
+}
+
+func (w *workload) get(ctx context.Context) (runtime.Object, error) {
+	switch w.kind {
+	case deploymentKind:
+		return w.apps.Deployments(w.namespace).Get(ctx, w.name, metav1.GetOptions{})
+	case statefulSetKind:
+		return w.apps.StatefulSets(w.namespace).Get(ctx, w.name, metav1.GetOptions{})
+	case daemonSetKind:
+		return w.apps.DaemonSets(w.namespace).Get(ctx, w.name, metav1.GetOptions{})
+	}
+	return nil, fmt.Errorf("unsupported workload kind %s", w.kind)
+}
+
+func (w *workload) patch(ctx context.Context, patchType apitypes.PatchType, data []byte) (runtime.Object, error) {
+	switch w.kind {
+	case deploymentKind:
+		return w.apps.Deployments(w.namespace).Patch(ctx, w.name, patchType, data, metav1.PatchOptions{})
+	case statefulSetKind:
+		return w.apps.StatefulSets(w.namespace).Patch(ctx, w.name, patchType, data, metav1.PatchOptions{})
+	case daemonSetKind:
+		return w.apps.DaemonSets(w.namespace).Patch(ctx, w.name, patchType, data, metav1.PatchOptions{})
+	}
+	return nil, fmt.Errorf("unsupported workload kind %s", w.kind)
+}
+
+func (w *workload) mergePatch(ctx context.Context, patch map[string]interface{}) (runtime.Object, error) {
+	data, err := json.Marshal(patch)
+	if err != nil {
+		return nil, err
+	}
+	return w.patch(ctx, apitypes.MergePatchType, data)
+}
+
+// scale sets the replicas of the workload through its scale subresource, which can be granted separately from the
+// workload itself.
+func (w *workload) scale(ctx context.Context, replicas int32) (runtime.Object, error) {
+	scale := &autoscalingv1.Scale{
+		ObjectMeta: metav1.ObjectMeta{Name: w.name, Namespace: w.namespace},
+		Spec:       autoscalingv1.ScaleSpec{Replicas: replicas},
+	}
+	var err error
+	switch w.kind {
+	case deploymentKind:
+		_, err = w.apps.Deployments(w.namespace).UpdateScale(ctx, w.name, scale, metav1.UpdateOptions{})
+	case statefulSetKind:
+		_, err = w.apps.StatefulSets(w.namespace).UpdateScale(ctx, w.name, scale, metav1.UpdateOptions{})
+	default:
+		return nil, apierror.NewAPIError(validation.ActionNotAvailable, w.kind+" can't be scaled")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return w.get(ctx)
+}
@@ -0,0 +1,282 @@
+package workloads
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/attributes"
+	"github.com/rancher/steve/pkg/resources/resourcetest"
+	"github.com/rancher/wrangler/v3/pkg/schemas"
+	"github.com/rancher/wrangler/v3/pkg/schemas/validation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sschema "k8s.io/apimachinery/pkg/runtime/schema"
+	apitypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/utils/ptr"
+)
+
+func newSchema(kind string, verbs ...string) *types.APISchema {
+	apiSchema := &types.APISchema{Schema: &schemas.Schema{
+		ID:         "apps." + strings.ToLower(kind),
+		Attributes: map[string]interface{}{},
+	}}
+	attributes.SetGVK(apiSchema, k8sschema.GroupVersionKind{Group: "apps", Version: "v1", Kind: kind})
+	attributes.SetVerbs(apiSchema, verbs)
+	return apiSchema
+}
+
+func run(t *testing.T, client *fake.Clientset, kind, action, body string) (*unstructured.Unstructured, error) {
+	t.Helper()
+	a := &Action{cg: &resourcetest.ClientGetter{K8s: client}, name: action}
+	response := resourcetest.RunAction(a, newSchema(kind, "get", "patch"), "default", "web", action, body)
+	if response.Err != nil {
+		return nil, response.Err
+	}
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Equal(t, "default/web", response.Object.ID)
+	assert.Equal(t, "apps."+strings.ToLower(kind), response.Object.Type)
+	u := response.Object.Object.(*unstructured.Unstructured)
+	assert.Equal(t, "apps/v1", u.GetAPIVersion())
+	assert.Equal(t, kind, u.GetKind())
+	return u, nil
+}
+
+func podTemplate(image string, labels map[string]string) corev1.PodTemplateSpec {
+	return corev1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{Labels: labels},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "app", Image: image}}},
+	}
+}
+
+func newDeployment(paused bool) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", UID: "deployment-uid"},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: ptr.To[int32](1),
+			Paused:   paused,
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+			Template: podTemplate("app:3", map[string]string{"app": "web"}),
+		},
+	}
+}
+
+func ownedBy(owner metav1.Object, kind string) []metav1.OwnerReference {
+	return []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: kind, Name: owner.GetName(), UID: owner.GetUID(), Controller: ptr.To(true)}}
+}
+
+func TestAddActions(t *testing.T) {
+	tests := []struct {
+		name        string
+		schema      *types.APISchema
+		wantActions []string
+	}{
+		{name: "deployment", schema: newSchema("Deployment", "get", "patch"), wantActions: []string{"pause", "redeploy", "resume", "rollback", "scale"}},
+		{name: "statefulset", schema: newSchema("StatefulSet", "get", "patch"), wantActions: []string{"redeploy", "rollback", "scale"}},
+		{name: "daemonset", schema: newSchema("DaemonSet", "get", "patch"), wantActions: []string{"redeploy", "rollback"}},
+		{name: "without patch", schema: newSchema("Deployment", "get")},
+		{name: "other kind", schema: newSchema("ReplicaSet", "get", "patch")},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			AddActions(test.schema, &resourcetest.ClientGetter{})
+			var actions []string
+			for name := range test.schema.ResourceActions {
+				actions = append(actions, name)
+				assert.Contains(t, test.schema.ActionHandlers, name)
+			}
+			assert.ElementsMatch(t, test.wantActions, actions)
+		})
+	}
+
+	apiSchema := newSchema("Deployment", "patch")
+	AddActions(apiSchema, &resourcetest.ClientGetter{})
+	assert.Equal(t, schemas.Action{Input: "scaleInput", Output: "apps.deployment"}, apiSchema.ResourceActions["scale"])
+	assert.Equal(t, schemas.Action{Output: "apps.deployment"}, apiSchema.ResourceActions["redeploy"])
+}
+
+func TestTemplates(t *testing.T) {
+	var kinds []string
+	for _, template := range Templates(&resourcetest.ClientGetter{}) {
+		// templates are matched by group and kind, an ID would have to be the schema ID
+		assert.Equal(t, appsv1.GroupName, template.Group)
+		assert.Empty(t, template.ID)
+		kinds = append(kinds, template.Kind)
+	}
+	assert.Equal(t, []string{"Deployment", "StatefulSet", "DaemonSet"}, kinds)
+}
+
+func TestScale(t *testing.T) {
+	client := fake.NewClientset(newDeployment(false))
+	var scaled *autoscalingv1.Scale
+	// the fake client doesn't implement the scale subresource
+	client.PrependReactor("update", "deployments", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "scale" {
+			return false, nil, nil
+		}
+		scaled = action.(k8stesting.UpdateAction).GetObject().(*autoscalingv1.Scale)
+		deployment, err := client.Tracker().Get(appsv1.SchemeGroupVersion.WithResource("deployments"), "default", "web")
+		if err != nil {
+			return true, nil, err
+		}
+		deployment.(*appsv1.Deployment).Spec.Replicas = ptr.To(scaled.Spec.Replicas)
+		return true, scaled, client.Tracker().Update(appsv1.SchemeGroupVersion.WithResource("deployments"), deployment, "default")
+	})
+
+	u, err := run(t, client, "Deployment", "scale", `{"replicas": 3}`)
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), scaled.Spec.Replicas)
+	assert.Equal(t, "web", scaled.Name)
+	replicas, _, _ := unstructured.NestedInt64(u.Object, "spec", "replicas")
+	assert.Equal(t, int64(3), replicas)
+
+	_, err = run(t, client, "Deployment", "scale", `{}`)
+	resourcetest.RequireAPIError(t, err, validation.MissingRequired)
+	_, err = run(t, client, "Deployment", "scale", `{"replicas": -1}`)
+	resourcetest.RequireAPIError(t, err, validation.MinLimitExceeded)
+	_, err = run(t, client, "Deployment", "scale", `{"replicas": "3"}`)
+	resourcetest.RequireAPIError(t, err, validation.InvalidBodyContent)
+}
+
+func TestRedeploy(t *testing.T) {
+	client := fake.NewClientset(newDeployment(false))
+
+	u, err := run(t, client, "Deployment", "redeploy", "")
+	require.NoError(t, err)
+	restartedAt, _, _ := unstructured.NestedString(u.Object, "spec", "template", "metadata", "annotations", RestartedAtAnnotation)
+	_, err = time.Parse(time.RFC3339, restartedAt)
+	assert.NoError(t, err)
+}
+
+func TestPauseResume(t *testing.T) {
+	client := fake.NewClientset(newDeployment(false))
+
+	u, err := run(t, client, "Deployment", "pause", "")
+	require.NoError(t, err)
+	paused, _, _ := unstructured.NestedBool(u.Object, "spec", "paused")
+	assert.True(t, paused)
+
+	u, err = run(t, client, "Deployment", "resume", "")
+	require.NoError(t, err)
+	paused, _, _ = unstructured.NestedBool(u.Object, "spec", "paused")
+	assert.False(t, paused)
+}
+
+func TestRollbackDeployment(t *testing.T) {
+	deployment := newDeployment(false)
+	replicaSet := func(revision, image string, owner metav1.Object) *appsv1.ReplicaSet {
+		labels := map[string]string{"app": "web", appsv1.DefaultDeploymentUniqueLabelKey: "hash-" + revision}
+		return &appsv1.ReplicaSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            "web-" + revision,
+				Namespace:       "default",
+				Labels:          labels,
+				Annotations:     map[string]string{deploymentRevisionAnnotation: revision},
+				OwnerReferences: ownedBy(owner, "Deployment"),
+			},
+			Spec: appsv1.ReplicaSetSpec{Template: podTemplate(image, labels)},
+		}
+	}
+	other := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "other", UID: "other-uid"}}
+	objects := []runtime.Object{
+		deployment,
+		replicaSet("1", "app:1", deployment),
+		replicaSet("2", "app:2", deployment),
+		replicaSet("3", "app:3", deployment),
+		// not a revision of the deployment, even with matching labels
+		replicaSet("4", "other:4", other),
+	}
+
+	tests := []struct {
+		name      string
+		body      string
+		wantImage string
+		wantCode  validation.ErrorCode
+	}{
+		{name: "previous revision", wantImage: "app:2"},
+		{name: "given revision", body: `{"revision": 1}`, wantImage: "app:1"},
+		{name: "unknown revision", body: `{"revision": 4}`, wantCode: validation.InvalidOption},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := fake.NewClientset(objects...)
+			u, err := run(t, client, "Deployment", "rollback", test.body)
+			if test.wantCode.Code != "" {
+				resourcetest.RequireAPIError(t, err, test.wantCode)
+				return
+			}
+			require.NoError(t, err)
+
+			var result appsv1.Deployment
+			require.NoError(t, runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, &result))
+			assert.Equal(t, podTemplate(test.wantImage, map[string]string{"app": "web"}), result.Spec.Template)
+		})
+	}
+
+	t.Run("paused", func(t *testing.T) {
+		client := fake.NewClientset(newDeployment(true))
+		_, err := run(t, client, "Deployment", "rollback", "")
+		resourcetest.RequireAPIError(t, err, validation.InvalidState)
+	})
+
+	t.Run("no previous revision", func(t *testing.T) {
+		client := fake.NewClientset(deployment, replicaSet("1", "app:1", deployment))
+		_, err := run(t, client, "Deployment", "rollback", "")
+		resourcetest.RequireAPIError(t, err, validation.InvalidState)
+	})
+}
+
+func TestRollbackControllerRevision(t *testing.T) {
+	statefulSet := &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", UID: "statefulset-uid"},
+		Spec: appsv1.StatefulSetSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+			Template: podTemplate("app:2", map[string]string{"app": "web"}),
+		},
+	}
+	controllerRevision := func(revision int64, image string) *appsv1.ControllerRevision {
+		return &appsv1.ControllerRevision{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            "web-" + image,
+				Namespace:       "default",
+				Labels:          map[string]string{"app": "web"},
+				OwnerReferences: ownedBy(statefulSet, "StatefulSet"),
+			},
+			Revision: revision,
+			Data: runtime.RawExtension{
+				Raw: []byte(`{"spec":{"template":{"$patch":"replace","metadata":{"labels":{"app":"web"}},"spec":{"containers":[{"name":"app","image":"` + image + `"}]}}}}`),
+			},
+		}
+	}
+	client := fake.NewClientset(statefulSet, controllerRevision(1, "app:1"), controllerRevision(2, "app:2"))
+
+	u, err := run(t, client, "StatefulSet", "rollback", "")
+	require.NoError(t, err)
+	var result appsv1.StatefulSet
+	require.NoError(t, runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, &result))
+	assert.Equal(t, podTemplate("app:1", map[string]string{"app": "web"}), result.Spec.Template)
+
+	var patchType apitypes.PatchType
+	for _, action := range client.Actions() {
+		if patch, ok := action.(k8stesting.PatchAction); ok {
+			patchType = patch.GetPatchType()
+		}
+	}
+	assert.Equal(t, apitypes.StrategicMergePatchType, patchType)
+}
+
+func TestNotFound(t *testing.T) {
+	_, err := run(t, fake.NewClientset(), "Deployment", "pause", "")
+	// errors of the Kubernetes API keep their status
+	resourcetest.RequireAPIError(t, err, validation.NotFound)
+}
@@ -4,8 +4,11 @@ import (
 	"context"
 	"time"
 
+	"github.com/rancher/steve/pkg/audit"
 	steveauth "github.com/rancher/steve/pkg/auth"
 	authcli "github.com/rancher/steve/pkg/auth/cli"
+	"github.com/rancher/steve/pkg/client"
+	steveratelimit "github.com/rancher/steve/pkg/ratelimit"
 	"github.com/rancher/steve/pkg/server"
 	"github.com/rancher/steve/pkg/sqlcache/informer/factory"
 	"github.com/rancher/steve/pkg/ui"
@@ -22,6 +25,12 @@ type Config struct {
 	UIPath          string
 
 	WebhookConfig authcli.WebhookConfig
+	JWTConfig     authcli.JWTConfig
+	ClientCert    authcli.ClientCertConfig
+	TokenReview   authcli.TokenReviewConfig
+	AuthChain     authcli.ChainConfig
+	AuditConfig   audit.Config
+	RateLimit     steveratelimit.Config
 }
 
 func (c *Config) MustServer(ctx context.Context) *server.Server {
@@ -43,17 +52,55 @@ func (c *Config) ToServer(ctx context.Context, sqlCache bool) (*server.Server, e
 	}
 	restConfig.RateLimiter = ratelimit.None
 
-	if c.WebhookConfig.WebhookAuthentication {
-		auth, err = c.WebhookConfig.WebhookMiddleware()
+	enabled := map[string]steveauth.Authenticator{}
+	for name, newAuthenticator := range map[string]func() (steveauth.Authenticator, error){
+		authcli.ClientCertAuthenticator: c.ClientCert.ClientCertAuthenticator,
+		authcli.JWTAuthenticator:        c.JWTConfig.JWTAuthenticator,
+		authcli.WebhookAuthenticator:    c.WebhookConfig.WebhookAuthenticator,
+	} {
+		authenticator, err := newAuthenticator()
 		if err != nil {
 			return nil, err
 		}
+		if authenticator != nil {
+			enabled[name] = authenticator
+		}
+	}
+
+	var clientFactory *client.Factory
+	if c.TokenReview.TokenReviewAuthentication {
+		// the chain is not empty, so the factory impersonates like the default one would
+		clientFactory, err = client.NewFactory(restConfig, true)
+		if err != nil {
+			return nil, err
+		}
+		enabled[authcli.TokenReviewAuthenticator], err = c.TokenReview.TokenReviewAuthenticator(clientFactory)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	chain, err := c.AuthChain.Chain(enabled)
+	if err != nil {
+		return nil, err
+	}
+	if chain != nil {
+		auth = chain.Middleware()
+	}
+
+	auditLog, err := audit.New(c.AuditConfig)
+	if err != nil {
+		return nil, err
 	}
 
 	return server.New(ctx, restConfig, &server.Options{
-		AuthMiddleware: auth,
-		Next:           ui.New(c.UIPath),
-		SQLCache:       sqlCache,
+		AuthMiddleware:     auth,
+		ClientFactory:      clientFactory,
+		AuditLog:           auditLog,
+		RateLimiter:        steveratelimit.New(c.RateLimit),
+		RequestClientCerts: c.ClientCert.Enabled(),
+		Next:               ui.New(c.UIPath),
+		SQLCache:           sqlCache,
 		SQLCacheFactoryOptions: factory.CacheFactoryOptions{
 			GCInterval:  15 * time.Minute,
 			GCKeepCount: 1000,
@@ -89,5 +136,11 @@ func Flags(config *Config) []cli.Flag {
 		},
 	}
 
-	return append(flags, authcli.Flags(&config.WebhookConfig)...)
+	flags = append(flags, authcli.Flags(&config.WebhookConfig)...)
+	flags = append(flags, authcli.JWTFlags(&config.JWTConfig)...)
+	flags = append(flags, authcli.ClientCertFlags(&config.ClientCert)...)
+	flags = append(flags, authcli.TokenReviewFlags(&config.TokenReview)...)
+	flags = append(flags, authcli.ChainFlags(&config.AuthChain)...)
+	flags = append(flags, audit.Flags(&config.AuditConfig)...)
+	return append(flags, steveratelimit.Flags(&config.RateLimit)...)
 }
@@ -7,27 +7,35 @@ import (
 	"github.com/rancher/apiserver/pkg/types"
 	"github.com/rancher/apiserver/pkg/urlbuilder"
 	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/rancher/steve/pkg/audit"
 	"github.com/rancher/steve/pkg/auth"
+	"github.com/rancher/steve/pkg/etag"
 	k8sproxy "github.com/rancher/steve/pkg/proxy"
+	"github.com/rancher/steve/pkg/ratelimit"
 	"github.com/rancher/steve/pkg/schema"
 	"github.com/rancher/steve/pkg/server/router"
+	"github.com/rancher/steve/pkg/server/writer"
 	"github.com/sirupsen/logrus"
 	"k8s.io/apiserver/pkg/endpoints/request"
 	"k8s.io/client-go/rest"
 )
 
 func New(cfg *rest.Config, sf schema.Factory, authMiddleware auth.Middleware, next http.Handler,
-	routerFunc router.RouterFunc, extensionAPIServer http.Handler) (*apiserver.Server, http.Handler, error) {
+	routerFunc router.RouterFunc, extensionAPIServer http.Handler, auditLog *audit.Logger, rateLimiter *ratelimit.Limiter,
+	etagGenerations *etag.Generations) (*apiserver.Server, http.Handler, error) {
 	var (
 		proxy http.Handler
 		err   error
 	)
 
 	a := &apiServer{
-		sf:     sf,
-		server: apiserver.DefaultAPIServer(),
+		sf:              sf,
+		server:          apiserver.DefaultAPIServer(),
+		rateLimiter:     rateLimiter,
+		etagGenerations: etagGenerations,
 	}
 	a.server.AccessControl = accesscontrol.NewAccessControl()
+	writer.AddResponseWriters(a.server)
 
 	if authMiddleware == nil {
 		proxy, err = k8sproxy.Handler("/", cfg)
@@ -39,10 +47,17 @@ func New(cfg *rest.Config, sf schema.Factory, authMiddleware auth.Middleware, ne
 		proxy = k8sproxy.ImpersonatingHandler("/", cfg)
 	}
 
+	k8sResource := a.apiHandler(k8sAPI)
+	proxy = rateLimiter.WrapProxy(proxy)
+	if auditLog != nil {
+		k8sResource = auditLog.WrapV1(k8sResource)
+		proxy = auditLog.WrapProxy(proxy)
+	}
+
 	w := authMiddleware
 	handlers := router.Handlers{
 		Next:        next,
-		K8sResource: w(a.apiHandler(k8sAPI)),
+		K8sResource: w(k8sResource),
 		K8sProxy:    w(proxy),
 		APIRoot:     w(a.apiHandler(apiRoot)),
 	}
@@ -56,8 +71,12 @@ func New(cfg *rest.Config, sf schema.Factory, authMiddleware auth.Middleware, ne
 }
 
 type apiServer struct {
-	sf     schema.Factory
-	server *apiserver.Server
+	sf          schema.Factory
+	server      *apiserver.Server
+	rateLimiter *ratelimit.Limiter
+	// etagGenerations are the generations of the caches and policies of this server, which the tags of its responses
+	// depend on
+	etagGenerations *etag.Generations
 }
 
 func (a *apiServer) common(rw http.ResponseWriter, req *http.Request) (*types.APIRequest, bool) {
@@ -73,6 +92,10 @@ func (a *apiServer) common(rw http.ResponseWriter, req *http.Request) (*types.AP
 		rw.WriteHeader(http.StatusInternalServerError)
 	}
 
+	if a.etagGenerations != nil {
+		req = req.WithContext(etag.WithGenerations(req.Context(), a.etagGenerations))
+	}
+
 	urlBuilder, err := urlbuilder.NewPrefixed(req, schemas, "v1")
 	if err != nil {
 		rw.Write([]byte(err.Error()))
@@ -81,10 +104,11 @@ func (a *apiServer) common(rw http.ResponseWriter, req *http.Request) (*types.AP
 	}
 
 	return &types.APIRequest{
-		Schemas:    schemas,
-		Request:    req,
-		Response:   rw,
-		URLBuilder: urlBuilder,
+		Schemas:        schemas,
+		Request:        req,
+		Response:       rw,
+		URLBuilder:     urlBuilder,
+		ResponseFormat: writer.ResponseFormat(req),
 	}, true
 }
 
@@ -97,6 +121,15 @@ func (a *apiServer) apiHandler(apiFunc APIFunc) http.Handler {
 			if apiFunc != nil {
 				apiFunc(a.sf, apiOp)
 			}
+			audit.SetObjectRef(req.Context(), apiOp.Type, apiOp.Namespace, apiOp.Name)
+			// the name is only known once k8sAPI looked up whether the type is namespaced
+			if class, limited := ratelimit.V1Class(req, apiOp.Type, apiOp.Name); limited {
+				release, ok := a.rateLimiter.Admit(rw, req, class)
+				if !ok {
+					return
+				}
+				defer release()
+			}
 			a.server.Handle(apiOp)
 		}
 	})
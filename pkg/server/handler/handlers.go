@@ -9,7 +9,6 @@ import (
 
 func k8sAPI(sf schema.Factory, apiOp *types.APIRequest) {
 	vars := mux.Vars(apiOp.Request)
-	apiOp.Name = vars["name"]
 	apiOp.Type = vars["type"]
 
 	nOrN := vars["nameorns"]
@@ -21,6 +20,7 @@ func k8sAPI(sf schema.Factory, apiOp *types.APIRequest) {
 			vars["name"] = nOrN
 		}
 	}
+	apiOp.Name = vars["name"]
 
 	if namespace := vars["namespace"]; namespace != "" {
 		apiOp.Namespace = namespace
@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/attributes"
+	"github.com/rancher/steve/pkg/ratelimit"
+	"github.com/rancher/wrangler/v3/pkg/schemas"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestK8sAPIRateLimitClass(t *testing.T) {
+	apiSchemas := types.EmptyAPISchemas()
+	for id, namespaced := range map[string]bool{"pod": true, "node": false} {
+		apiSchema := types.APISchema{Schema: &schemas.Schema{ID: id, Attributes: map[string]interface{}{}}}
+		attributes.SetNamespaced(&apiSchema, namespaced)
+		apiSchemas.AddSchema(apiSchema)
+	}
+
+	tests := []struct {
+		name           string
+		target         string
+		vars           map[string]string
+		wantName       string
+		wantNamespace  string
+		want           ratelimit.Class
+		wantNotLimited bool
+	}{
+		{
+			name:     "cluster-scoped get",
+			target:   "/v1/nodes/node-1",
+			vars:     map[string]string{"type": "node", "nameorns": "node-1"},
+			wantName: "node-1",
+			// gets are served from the cache and aren't limited
+			wantNotLimited: true,
+		},
+		{
+			name:          "namespaced list",
+			target:        "/v1/pods/default",
+			vars:          map[string]string{"type": "pod", "nameorns": "default"},
+			wantNamespace: "default",
+			want:          ratelimit.ClassList,
+		},
+		{
+			name:           "namespaced get",
+			target:         "/v1/pods/default/web",
+			vars:           map[string]string{"type": "pod", "namespace": "default", "name": "web"},
+			wantName:       "web",
+			wantNamespace:  "default",
+			wantNotLimited: true,
+		},
+		{
+			name:   "cluster-scoped list",
+			target: "/v1/nodes",
+			vars:   map[string]string{"type": "node"},
+			want:   ratelimit.ClassList,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, test.target, nil), test.vars)
+			apiOp := &types.APIRequest{Request: req, Schemas: apiSchemas}
+			k8sAPI(nil, apiOp)
+			assert.Equal(t, test.wantName, apiOp.Name)
+			assert.Equal(t, test.wantNamespace, apiOp.Namespace)
+
+			class, limited := ratelimit.V1Class(req, apiOp.Type, apiOp.Name)
+			assert.Equal(t, !test.wantNotLimited, limited)
+			assert.Equal(t, test.want, class)
+		})
+	}
+}
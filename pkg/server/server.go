@@ -2,27 +2,39 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"net/http"
+	"time"
 
 	apiserver "github.com/rancher/apiserver/pkg/server"
 	"github.com/rancher/apiserver/pkg/types"
 	"github.com/rancher/dynamiclistener/server"
 	"github.com/rancher/steve/pkg/accesscontrol"
 	"github.com/rancher/steve/pkg/aggregation"
+	"github.com/rancher/steve/pkg/attributes"
+	"github.com/rancher/steve/pkg/audit"
 	"github.com/rancher/steve/pkg/auth"
 	"github.com/rancher/steve/pkg/client"
 	"github.com/rancher/steve/pkg/clustercache"
 	schemacontroller "github.com/rancher/steve/pkg/controllers/schema"
+	"github.com/rancher/steve/pkg/etag"
 	"github.com/rancher/steve/pkg/ext"
+	"github.com/rancher/steve/pkg/policy"
+	"github.com/rancher/steve/pkg/ratelimit"
 	"github.com/rancher/steve/pkg/resources"
 	"github.com/rancher/steve/pkg/resources/common"
+	"github.com/rancher/steve/pkg/resources/formatters"
 	"github.com/rancher/steve/pkg/resources/schemas"
+	"github.com/rancher/steve/pkg/resources/search"
+	"github.com/rancher/steve/pkg/resources/virtual/usage"
+	"github.com/rancher/steve/pkg/resources/workloads"
 	"github.com/rancher/steve/pkg/schema"
 	"github.com/rancher/steve/pkg/schema/definitions"
 	"github.com/rancher/steve/pkg/server/handler"
 	"github.com/rancher/steve/pkg/server/router"
+	"github.com/rancher/steve/pkg/server/writer"
 	"github.com/rancher/steve/pkg/sqlcache/informer/factory"
 	"github.com/rancher/steve/pkg/sqlcache/schematracker"
 	metricsStore "github.com/rancher/steve/pkg/stores/metrics"
@@ -76,6 +88,17 @@ type Server struct {
 	aggregationSecretNamespace string
 	aggregationSecretName      string
 	SQLCache                   bool
+
+	policy                   policy.Policy
+	policyConfigMapNamespace string
+	policyConfigMapName      string
+	redactions               []common.RedactionRule
+	auditLog                 *audit.Logger
+	requestClientCerts       bool
+	rateLimiter              *ratelimit.Limiter
+	resourceUsage            bool
+	resourceUsageInterval    time.Duration
+	etagGenerations          *etag.Generations
 }
 
 type Options struct {
@@ -105,6 +128,33 @@ type Options struct {
 
 	// SkipWaitForExtensionAPIServer allows serving requests despite the ExtensionAPIServer may not have been registered yet.
 	SkipWaitForExtensionAPIServer bool
+
+	// Policy is evaluated after RBAC and can deny access to individual objects or verbs in /v1 responses.
+	Policy policy.Policy
+	// PolicyConfigMapNamespace and PolicyConfigMapName enable the built-in CEL policy, whose rules are
+	// loaded from the "rules" entry of the ConfigMap. It is combined with Policy if both are set.
+	PolicyConfigMapNamespace string
+	PolicyConfigMapName      string
+
+	// Redactions hide fields from get, list and watch responses, e.g. the data of Secrets
+	// for users who cannot update them.
+	Redactions []common.RedactionRule
+
+	// AuditLog, if set, records /v1, /v1/subscribe and proxied Kubernetes requests.
+	AuditLog *audit.Logger
+
+	// RequestClientCerts makes the HTTPS listener ask clients for a TLS certificate, so that they can be
+	// authenticated with auth.NewClientCertAuthenticator. Certificates are verified by the authenticator,
+	// clients without one can still use other authentication methods.
+	RequestClientCerts bool
+
+	// RateLimiter, if set, limits the list, watch and mutating requests of each user.
+	RateLimiter *ratelimit.Limiter
+
+	// ResourceUsage adds the CPU and memory usage reported by the metrics.k8s.io API to pods and nodes, as
+	// status.usage. The metrics are listed every ResourceUsageInterval, 30 seconds by default.
+	ResourceUsage         bool
+	ResourceUsageInterval time.Duration
 }
 
 func New(ctx context.Context, restConfig *rest.Config, opts *Options) (*Server, error) {
@@ -138,6 +188,16 @@ func New(ctx context.Context, restConfig *rest.Config, opts *Options) (*Server,
 		cacheFactory:                  cacheFactory,
 		extensionAPIServer:            opts.ExtensionAPIServer,
 		SkipWaitForExtensionAPIServer: opts.SkipWaitForExtensionAPIServer,
+		policy:                        opts.Policy,
+		policyConfigMapNamespace:      opts.PolicyConfigMapNamespace,
+		policyConfigMapName:           opts.PolicyConfigMapName,
+		redactions:                    opts.Redactions,
+		auditLog:                      opts.AuditLog,
+		requestClientCerts:            opts.RequestClientCerts,
+		rateLimiter:                   opts.RateLimiter,
+		resourceUsage:                 opts.ResourceUsage,
+		resourceUsageInterval:         opts.ResourceUsageInterval,
+		etagGenerations:               &etag.Generations{},
 	}
 
 	if err := setup(ctx, server); err != nil {
@@ -202,16 +262,33 @@ func setup(ctx context.Context, server *Server) error {
 	definitions.Register(ctx, server.BaseSchemas, server.controllers.K8s.Discovery(),
 		server.controllers.CRD.CustomResourceDefinition(), server.controllers.API.APIService())
 
+	pol, err := setupPolicy(ctx, server)
+	if err != nil {
+		return err
+	}
+
 	summaryCache := summarycache.New(sf, ccache)
 	summaryCache.Start(ctx)
+	// the relationships and states of the summary cache change responses without changing the resourceVersion of the
+	// objects
+	server.etagGenerations.SetSchema(func(apiSchema *types.APISchema) uint64 {
+		return summaryCache.Generation(attributes.GVK(apiSchema).GroupKind())
+	})
+	var usageCache *usage.Cache
+	if server.resourceUsage {
+		usageCache = usage.NewCache(cf.AdminDynamicClient(), server.resourceUsageInterval)
+	}
 	cols, err := common.NewDynamicColumns(server.RESTConfig)
 	if err != nil {
 		return err
 	}
 
-	var onSchemasHandler schemacontroller.SchemasHandlerFunc
+	var (
+		onSchemasHandler schemacontroller.SchemasHandlerFunc
+		cachedTypes      search.CachedTypes
+	)
 	if server.SQLCache {
-		sqlStore, err := sqlproxy.NewProxyStore(ctx, cols, cf, summaryCache, summaryCache, server.cacheFactory, false)
+		sqlStore, err := sqlproxy.NewProxyStore(ctx, cols, cf, summaryCache, summaryCache, usageCache, server.cacheFactory, false)
 		if err != nil {
 			return err
 		}
@@ -222,6 +299,7 @@ func setup(ctx context.Context, server *Server) error {
 					sqlpartition.NewStore(
 						sqlStore,
 						asl,
+						pol,
 					),
 					asl,
 				),
@@ -230,11 +308,12 @@ func setup(ctx context.Context, server *Server) error {
 		store := metricsStore.NewMetricsStore(errStore)
 		// end store setup code
 
-		for _, template := range resources.DefaultSchemaTemplatesForStore(store, server.BaseSchemas, summaryCache, asl, server.controllers.K8s.Discovery(), common.TemplateOptions{InSQLMode: true}) {
+		for _, template := range resources.DefaultSchemaTemplatesForStore(store, server.BaseSchemas, summaryCache, asl, server.controllers.K8s.Discovery(), common.TemplateOptions{InSQLMode: true, Policy: pol, Redactions: server.redactions, RateLimiter: server.rateLimiter}) {
 			sf.AddTemplate(template)
 		}
 
 		sqlSchemaTracker := schematracker.NewSchemaTracker(sqlStore)
+		cachedTypes = sqlStore
 
 		onSchemasHandler = func(schemas *schema.Collection) error {
 			var retErr error
@@ -248,11 +327,27 @@ func setup(ctx context.Context, server *Server) error {
 			return retErr
 		}
 	} else {
-		for _, template := range resources.DefaultSchemaTemplates(cf, server.BaseSchemas, summaryCache, asl, server.controllers.K8s.Discovery(), server.controllers.Core.Namespace().Cache(), common.TemplateOptions{InSQLMode: false}) {
+		for _, template := range resources.DefaultSchemaTemplates(cf, server.BaseSchemas, summaryCache, asl, server.controllers.K8s.Discovery(), server.controllers.Core.Namespace().Cache(), common.TemplateOptions{InSQLMode: false, Policy: pol, Redactions: server.redactions, RateLimiter: server.rateLimiter}) {
 			sf.AddTemplate(template)
 		}
 		onSchemasHandler = ccache.OnSchemas
 	}
+	search.Register(server.BaseSchemas, ccache, cachedTypes, pol)
+
+	sf.AddTemplate(writer.Template())
+	sf.AddTemplate(formatters.PodLogTemplate(cf))
+	for _, template := range workloads.Templates(cf) {
+		sf.AddTemplate(template)
+	}
+	for _, template := range usage.Templates(usageCache) {
+		sf.AddTemplate(template)
+	}
+	if usageCache != nil {
+		// the usage changes responses without changing the resourceVersion of the objects
+		server.etagGenerations.SetType("pod", func() uint64 { return usageCache.Generation(usage.PodGVK) })
+		server.etagGenerations.SetType("node", func() uint64 { return usageCache.Generation(usage.NodeGVK) })
+		usageCache.Start(ctx)
+	}
 
 	schemas.SetupWatcher(ctx, server.BaseSchemas, asl, sf)
 
@@ -279,7 +374,8 @@ func setup(ctx context.Context, server *Server) error {
 		}
 	})
 
-	apiServer, handler, err := handler.New(server.RESTConfig, sf, server.authMiddleware, next, server.router, server.extensionAPIServer)
+	apiServer, handler, err := handler.New(server.RESTConfig, sf, server.authMiddleware, next, server.router, server.extensionAPIServer, server.auditLog, server.rateLimiter,
+		server.etagGenerations)
 	if err != nil {
 		return err
 	}
@@ -291,6 +387,23 @@ func setup(ctx context.Context, server *Server) error {
 	return nil
 }
 
+// setupPolicy combines the configured Policy with the ConfigMap backed CEL policy, if enabled.
+func setupPolicy(ctx context.Context, server *Server) (policy.Policy, error) {
+	if server.policyConfigMapNamespace == "" || server.policyConfigMapName == "" {
+		return server.policy, nil
+	}
+	celPolicy, err := policy.NewCELPolicy(nil, server.etagGenerations.Invalidate)
+	if err != nil {
+		return nil, fmt.Errorf("creating CEL policy: %w", err)
+	}
+	policy.WatchConfigMap(ctx, server.controllers.Core.ConfigMap(), server.policyConfigMapNamespace,
+		server.policyConfigMapName, celPolicy)
+	if server.policy == nil {
+		return celPolicy, nil
+	}
+	return policy.Union(server.policy, celPolicy), nil
+}
+
 func (c *Server) start(ctx context.Context) error {
 	if c.needControllerStart {
 		if err := c.controllers.Start(ctx); err != nil {
@@ -323,6 +436,14 @@ func (c *Server) ListenAndServe(ctx context.Context, httpsPort, httpPort int, op
 	if len(opts.TLSListenerConfig.SANs) == 0 {
 		opts.TLSListenerConfig.SANs = []string{"127.0.0.1"}
 	}
+	if c.requestClientCerts {
+		if opts.TLSListenerConfig.TLSConfig == nil {
+			opts.TLSListenerConfig.TLSConfig = &tls.Config{}
+		}
+		if opts.TLSListenerConfig.TLSConfig.ClientAuth == tls.NoClientCert {
+			opts.TLSListenerConfig.TLSConfig.ClientAuth = tls.RequestClientCert
+		}
+	}
 	if err := server.ListenAndServe(ctx, httpsPort, httpPort, c, opts); err != nil {
 		return err
 	}
@@ -0,0 +1,186 @@
+package writer
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/apiserver/pkg/writer"
+	"github.com/rancher/steve/pkg/attributes"
+	"github.com/rancher/steve/pkg/resources/common"
+	"github.com/rancher/steve/pkg/stores/queryhelper"
+)
+
+// idColumn selects the ID of the resource rather than a field of the object.
+const idColumn = "id"
+
+type column struct {
+	name string
+	path []string
+}
+
+// CSVResponseWriter writes a header row and a row per object with the values of the columns selected by the columns
+// query parameter, or the printer columns of the schema by default.
+type CSVResponseWriter struct{}
+
+func (c *CSVResponseWriter) Write(apiOp *types.APIRequest, code int, obj types.APIObject) {
+	if obj.Type == "error" {
+		jsonWriter.Write(apiOp, code, obj)
+		return
+	}
+	c.WriteList(apiOp, code, types.APIObjectList{Objects: []types.APIObject{obj}})
+}
+
+func (c *CSVResponseWriter) WriteList(apiOp *types.APIRequest, code int, list types.APIObjectList) {
+	start(apiOp, code, "text/csv; charset=utf-8", list)
+
+	columns := csvColumns(apiOp)
+	w := csv.NewWriter(apiOp.Response)
+	header := make([]string, 0, len(columns))
+	for _, col := range columns {
+		header = append(header, escapeFormula(col.name))
+	}
+	if err := w.Write(header); err != nil {
+		return
+	}
+
+	rows := &writer.EncodingResponseWriter{
+		Encoder: func(_ io.Writer, v interface{}) error {
+			resource, ok := v.(*types.RawResource)
+			if !ok || resource == nil {
+				return nil
+			}
+			return w.Write(row(resource, columns))
+		},
+	}
+	for _, obj := range list.Objects {
+		if err := rows.Body(apiOp, apiOp.Response, obj); err != nil {
+			return
+		}
+		w.Flush()
+		flush(apiOp.Response)
+	}
+	w.Flush()
+}
+
+// csvColumns returns the columns of the CSV response to apiOp. Each entry of the columns query parameter is either the
+// name of a printer column, matched case-insensitively, "id" or the dotted path of a field, such as
+// metadata.labels[app].
+func csvColumns(apiOp *types.APIRequest) []column {
+	definitions := common.GetColumnDefinitions(apiOp.Schema)
+
+	var names []string
+	for _, value := range apiOp.Request.URL.Query()["columns"] {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+
+	if len(names) == 0 {
+		return defaultColumns(apiOp.Schema, definitions)
+	}
+
+	columns := make([]column, 0, len(names))
+	for _, name := range names {
+		columns = append(columns, lookupColumn(name, definitions))
+	}
+	return columns
+}
+
+func defaultColumns(schema *types.APISchema, definitions []common.ColumnDefinition) []column {
+	var columns []column
+	if attributes.Namespaced(schema) {
+		columns = append(columns, column{name: "Namespace", path: []string{"metadata", "namespace"}})
+	}
+	if len(definitions) == 0 {
+		return append(columns,
+			column{name: "Name", path: []string{"metadata", "name"}},
+			column{name: "State", path: []string{"metadata", "state", "name"}})
+	}
+	for _, definition := range definitions {
+		columns = append(columns, column{name: definition.Name, path: fieldPath(definition.Field)})
+	}
+	return columns
+}
+
+func lookupColumn(name string, definitions []common.ColumnDefinition) column {
+	if strings.EqualFold(name, idColumn) {
+		return column{name: name}
+	}
+	for _, definition := range definitions {
+		if strings.EqualFold(definition.Name, name) {
+			return column{name: definition.Name, path: fieldPath(definition.Field)}
+		}
+	}
+	return column{name: name, path: fieldPath(name)}
+}
+
+func fieldPath(field string) []string {
+	field = strings.TrimPrefix(field, "$")
+	field = strings.TrimPrefix(field, ".")
+	return queryhelper.SafeSplit(field)
+}
+
+func row(resource *types.RawResource, columns []column) []string {
+	data := map[string]interface{}(resource.APIObject.Data())
+	result := make([]string, 0, len(columns))
+	for _, col := range columns {
+		if col.path == nil {
+			result = append(result, escapeFormula(resource.ID))
+			continue
+		}
+		result = append(result, cell(value(data, col.path)))
+	}
+	return result
+}
+
+// value returns the value at path of obj, indexing into slices for numeric path elements.
+func value(obj interface{}, path []string) interface{} {
+	for _, key := range path {
+		switch v := obj.(type) {
+		case map[string]interface{}:
+			obj = v[key]
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil
+			}
+			obj = v[i]
+		default:
+			return nil
+		}
+	}
+	return obj
+}
+
+func cell(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return escapeFormula(v)
+	case map[string]interface{}, []interface{}:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return ""
+		}
+		return string(data)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// escapeFormula prefixes values which spreadsheets would evaluate as a formula with a quote, so that a field set by
+// one user can't run a formula on the machine of another opening the export.
+func escapeFormula(v string) string {
+	if v != "" && strings.ContainsRune("=+-@\t\r", rune(v[0])) {
+		return "'" + v
+	}
+	return v
+}
@@ -0,0 +1,51 @@
+package writer
+
+import (
+	"net/http"
+
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/etag"
+	"k8s.io/apimachinery/pkg/api/meta"
+)
+
+// ConditionalWriter sets the ETag of successful GET responses, derived from the resourceVersion of objects and the
+// revision of lists, and answers matching If-None-Match requests with a 304 without writing the body.
+type ConditionalWriter struct {
+	types.ResponseWriter
+}
+
+func (c *ConditionalWriter) Write(apiOp *types.APIRequest, code int, obj types.APIObject) {
+	if code == http.StatusNotModified {
+		// the store already found the request to match
+		apiOp.Response.WriteHeader(code)
+		return
+	}
+	if code == http.StatusOK && obj.Type != "error" && c.notModified(apiOp, resourceVersion(obj)) {
+		return
+	}
+	c.ResponseWriter.Write(apiOp, code, obj)
+}
+
+func (c *ConditionalWriter) WriteList(apiOp *types.APIRequest, code int, list types.APIObjectList) {
+	// streamed lists have no revision until they have been written
+	if code == http.StatusOK && c.notModified(apiOp, list.Revision) {
+		return
+	}
+	c.ResponseWriter.WriteList(apiOp, code, list)
+}
+
+func (c *ConditionalWriter) notModified(apiOp *types.APIRequest, version string) bool {
+	if etag.Check(apiOp, etag.For(apiOp, version)) == nil {
+		return false
+	}
+	apiOp.Response.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+func resourceVersion(obj types.APIObject) string {
+	m, err := meta.Accessor(obj.Object)
+	if err != nil {
+		return ""
+	}
+	return m.GetResourceVersion()
+}
@@ -0,0 +1,118 @@
+package writer
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/apiserver/pkg/writer"
+)
+
+// JSONResponseWriter writes like the JSON writer of the apiserver, except that lists are encoded one object at a
+// time rather than converted as a whole first, and list streams are written as they are received.
+type JSONResponseWriter struct {
+	writer.EncodingResponseWriter
+}
+
+// NewJSONResponseWriter returns a JSONResponseWriter.
+func NewJSONResponseWriter() *JSONResponseWriter {
+	return &JSONResponseWriter{
+		EncodingResponseWriter: writer.EncodingResponseWriter{
+			ContentType: "application/json",
+			Encoder:     types.JSONEncoder,
+		},
+	}
+}
+
+func (j *JSONResponseWriter) streamsLists() {}
+
+// collectionTail holds the fields of a streamed collection which are only known once every object has been listed.
+type collectionTail struct {
+	Pagination *types.Pagination `json:"pagination,omitempty"`
+	Revision   string            `json:"revision,omitempty"`
+	Continue   string            `json:"continue,omitempty"`
+	Pages      int               `json:"pages,omitempty"`
+	Count      int               `json:"count,omitempty"`
+}
+
+func (j *JSONResponseWriter) WriteList(apiOp *types.APIRequest, code int, list types.APIObjectList) {
+	if apiOp.Schema.CollectionFormatter != nil {
+		// the formatter needs the whole collection
+		j.EncodingResponseWriter.WriteList(apiOp, code, list)
+		return
+	}
+
+	stream := streamFrom(apiOp.Context())
+	start(apiOp, code, j.ContentType, types.APIObjectList{})
+	w := apiOp.Response
+
+	head := newCollection(apiOp, list).Collection
+	if stream != nil {
+		head.Pagination = nil
+	}
+	data, err := json.Marshal(head)
+	if err != nil {
+		return
+	}
+	// leave the object open to add the data
+	_, _ = w.Write(data[:len(data)-1])
+	_, _ = io.WriteString(w, `,"data":[`)
+
+	first := true
+	write := func(obj types.APIObject) error {
+		if !first {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+		first = false
+		return j.Body(apiOp, w, obj)
+	}
+
+	if stream == nil {
+		for _, obj := range list.Objects {
+			if err := write(obj); err != nil {
+				return
+			}
+		}
+		_, _ = io.WriteString(w, "]}\n")
+		return
+	}
+
+	stream.each(func(obj types.APIObject) error {
+		err := write(obj)
+		flush(w)
+		return err
+	})
+	_, _ = io.WriteString(w, "]")
+
+	result := newCollection(apiOp, stream.result(apiOp)).Collection
+	if data, err = json.Marshal(collectionTail{
+		Pagination: result.Pagination,
+		Revision:   result.Revision,
+		Continue:   result.Continue,
+		Pages:      result.Pages,
+		Count:      result.Count,
+	}); err == nil && len(data) > len("{}") {
+		_, _ = io.WriteString(w, ",")
+		_, _ = w.Write(data[1 : len(data)-1])
+	}
+	_, _ = io.WriteString(w, "}\n")
+}
+
+// newCollection returns the collection of list without its data, built by the apiserver writer. The schema must not
+// have a collection formatter, which would be run on the empty collection.
+func newCollection(apiOp *types.APIRequest, list types.APIObjectList) *types.GenericCollection {
+	var result *types.GenericCollection
+	collector := writer.EncodingResponseWriter{
+		Encoder: func(_ io.Writer, v interface{}) error {
+			result, _ = v.(*types.GenericCollection)
+			return nil
+		},
+	}
+	// the objects are written separately, so only the collection fields are converted
+	list.Objects = nil
+	_ = collector.BodyList(apiOp, io.Discard, list)
+	result.Data = nil
+	return result
+}
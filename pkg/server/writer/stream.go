@@ -0,0 +1,114 @@
+package writer
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/rancher/apiserver/pkg/handlers"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/apiserver/pkg/writer"
+	"github.com/rancher/steve/pkg/schema"
+	"github.com/rancher/steve/pkg/stores/partition"
+	"github.com/sirupsen/logrus"
+)
+
+type streamKey struct{}
+
+// streamWriter is implemented by the response writers which write the list stream of the request, if there is one,
+// instead of the list they are given.
+type streamWriter interface {
+	streamsLists()
+}
+
+// listStream is a list stream whose first object has already been received.
+type listStream struct {
+	first types.APIObject
+	*partition.ListStream
+}
+
+// each calls f with every object of the stream. It drains the stream even if f fails, so that the store can finish.
+func (l *listStream) each(f func(types.APIObject) error) {
+	err := f(l.first)
+	for obj := range l.Objects {
+		if err == nil {
+			err = f(obj)
+		}
+	}
+}
+
+// result returns the list metadata of the stream. The response is aborted if listing failed midway, as the status
+// has already been written, so that clients see a broken response rather than a partial list.
+func (l *listStream) result(apiOp *types.APIRequest) types.APIObjectList {
+	list, err := l.Result()
+	if err != nil {
+		logrus.Errorf("failed to stream list of %s: %v", apiOp.Type, err)
+		panic(http.ErrAbortHandler)
+	}
+	return list
+}
+
+func streamFrom(ctx context.Context) *listStream {
+	stream, _ := ctx.Value(streamKey{}).(*listStream)
+	return stream
+}
+
+// Template sets ListHandler as the list handler of every schema which doesn't have one yet. Global templates are
+// applied last, so the list handlers set by the templates of a type are kept.
+func Template() schema.Template {
+	return schema.Template{
+		Customize: func(apiSchema *types.APISchema) {
+			if apiSchema.ListHandler == nil {
+				apiSchema.ListHandler = handlers.MetricsListHandler("200", ListHandler)
+			}
+		},
+	}
+}
+
+// ListHandler lists like the default list handler of the apiserver, but if both the store and the response writer
+// support it, the objects are written to the response as they are listed rather than collected in memory first.
+func ListHandler(apiOp *types.APIRequest) (types.APIObjectList, error) {
+	if !canStream(apiOp) {
+		return handlers.ListHandler(apiOp)
+	}
+	if err := apiOp.AccessControl.CanList(apiOp, apiOp.Schema); err != nil {
+		return types.APIObjectList{}, err
+	}
+
+	stream, err := partition.StreamList(apiOp.Schema.Store, apiOp, apiOp.Schema)
+	if err != nil {
+		return types.APIObjectList{}, err
+	}
+	if stream == nil {
+		return apiOp.Schema.Store.List(apiOp, apiOp.Schema)
+	}
+
+	// wait for the first object so that errors listing the first partition are still reported as errors
+	first, ok := <-stream.Objects
+	if !ok {
+		return stream.Result()
+	}
+	apiOp.Request = apiOp.Request.WithContext(context.WithValue(apiOp.Context(), streamKey{}, &listStream{
+		first:      first,
+		ListStream: stream,
+	}))
+	return types.APIObjectList{}, nil
+}
+
+func canStream(apiOp *types.APIRequest) bool {
+	if apiOp.Schema.Store == nil || apiOp.Schema.CollectionFormatter != nil {
+		return false
+	}
+	rw := apiOp.ResponseWriter
+	for {
+		switch w := rw.(type) {
+		case *ConditionalWriter:
+			rw = w.ResponseWriter
+		case *writer.GzipWriter:
+			rw = w.ResponseWriter
+		case streamWriter:
+			return true
+		default:
+			return false
+		}
+	}
+}
@@ -0,0 +1,164 @@
+// Package writer contains the response formats steve adds to the ones of the apiserver, for exporting lists to
+// spreadsheets and log pipelines, and for editing objects as Kubernetes YAML.
+package writer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	apiserver "github.com/rancher/apiserver/pkg/server"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/apiserver/pkg/writer"
+)
+
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+)
+
+var acceptFormats = map[string]string{
+	"text/csv":             FormatCSV,
+	"application/x-ndjson": FormatNDJSON,
+	"application/ndjson":   FormatNDJSON,
+	"application/yaml":     FormatYAML,
+}
+
+// AddResponseWriters registers the response writers of this package with server.
+func AddResponseWriters(server *apiserver.Server) {
+	server.ResponseWriters["json"] = &writer.GzipWriter{ResponseWriter: NewJSONResponseWriter()}
+	server.ResponseWriters[FormatCSV] = &writer.GzipWriter{ResponseWriter: &CSVResponseWriter{}}
+	server.ResponseWriters[FormatNDJSON] = &writer.GzipWriter{ResponseWriter: &NDJSONResponseWriter{}}
+
+	fallback := server.ResponseWriters[FormatYAML]
+	if gzip, ok := fallback.(*writer.GzipWriter); ok {
+		fallback = gzip.ResponseWriter
+	}
+	server.ResponseWriters[FormatYAML] = &writer.GzipWriter{ResponseWriter: &YAMLResponseWriter{Fallback: fallback}}
+
+	for format, rw := range server.ResponseWriters {
+		server.ResponseWriters[format] = &ConditionalWriter{ResponseWriter: rw}
+	}
+}
+
+// ResponseFormat returns the format requested with the format query parameter or the Accept header, if it is one of
+// the formats of this package. Otherwise it returns an empty string and the apiserver picks the format.
+func ResponseFormat(req *http.Request) string {
+	switch format := strings.ToLower(req.URL.Query().Get("format")); format {
+	case FormatCSV, FormatNDJSON, FormatYAML:
+		return format
+	}
+	for _, accept := range strings.Split(req.Header.Get("Accept"), ",") {
+		mediaType, _, _ := strings.Cut(accept, ";")
+		if format, ok := acceptFormats[strings.TrimSpace(mediaType)]; ok {
+			return format
+		}
+	}
+	return ""
+}
+
+// jsonWriter writes errors, which don't fit the row based formats.
+var jsonWriter = &writer.EncodingResponseWriter{
+	ContentType: "application/json",
+	Encoder:     types.JSONEncoder,
+}
+
+// start writes the status and headers of a list response. As the collection is not part of the response, the
+// pagination details are returned as headers.
+func start(apiOp *types.APIRequest, code int, contentType string, list types.APIObjectList) {
+	_ = writer.AddCommonResponseHeader(apiOp)
+	header := apiOp.Response.Header()
+	header.Set("Content-Type", contentType)
+	if list.Continue != "" {
+		header.Set("Link", fmt.Sprintf(`<%s>; rel="next"`, apiOp.URLBuilder.Marker(list.Continue)))
+	}
+	if list.Revision != "" {
+		header.Set("X-API-Revision", list.Revision)
+	}
+	if list.Pages > 0 {
+		header.Set("X-API-Pages", strconv.Itoa(list.Pages))
+	}
+	if list.Count > 0 {
+		header.Set("X-API-Count", strconv.Itoa(list.Count))
+	}
+	for _, warning := range list.Warnings {
+		header.Add("Warning", fmt.Sprintf("%d %s %s", warning.Code, warning.Agent, warning.Text))
+	}
+	apiOp.Response.WriteHeader(code)
+}
+
+// trailer sets the pagination details of a streamed list as trailers, as the headers have already been written.
+func trailer(apiOp *types.APIRequest, list types.APIObjectList) {
+	header := apiOp.Response.Header()
+	if list.Continue != "" {
+		header.Set(http.TrailerPrefix+"Link", fmt.Sprintf(`<%s>; rel="next"`, apiOp.URLBuilder.Marker(list.Continue)))
+	}
+	if list.Revision != "" {
+		header.Set(http.TrailerPrefix+"X-API-Revision", list.Revision)
+	}
+	if list.Count > 0 {
+		header.Set(http.TrailerPrefix+"X-API-Count", strconv.Itoa(list.Count))
+	}
+}
+
+func flush(rw http.ResponseWriter) {
+	if f, ok := rw.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// NDJSONResponseWriter writes one JSON object per line, without the collection wrapping a list.
+type NDJSONResponseWriter struct{}
+
+func (n *NDJSONResponseWriter) Write(apiOp *types.APIRequest, code int, obj types.APIObject) {
+	if obj.Type == "error" {
+		jsonWriter.Write(apiOp, code, obj)
+		return
+	}
+	start(apiOp, code, "application/x-ndjson", types.APIObjectList{})
+	_ = objectWriter(json.Marshal).Body(apiOp, apiOp.Response, obj)
+}
+
+func (n *NDJSONResponseWriter) streamsLists() {}
+
+func (n *NDJSONResponseWriter) WriteList(apiOp *types.APIRequest, code int, list types.APIObjectList) {
+	w := objectWriter(json.Marshal)
+	write := func(obj types.APIObject) error {
+		err := w.Body(apiOp, apiOp.Response, obj)
+		flush(apiOp.Response)
+		return err
+	}
+
+	stream := streamFrom(apiOp.Context())
+	if stream == nil {
+		start(apiOp, code, "application/x-ndjson", list)
+		for _, obj := range list.Objects {
+			if err := write(obj); err != nil {
+				return
+			}
+		}
+		return
+	}
+
+	start(apiOp, code, "application/x-ndjson", types.APIObjectList{})
+	stream.each(write)
+	// the pagination details are only known once every object has been listed
+	trailer(apiOp, stream.result(apiOp))
+}
+
+// objectWriter formats objects like the JSON writer and writes each of them on a line with marshal.
+func objectWriter(marshal func(interface{}) ([]byte, error)) *writer.EncodingResponseWriter {
+	return &writer.EncodingResponseWriter{
+		Encoder: func(w io.Writer, v interface{}) error {
+			data, err := marshal(v)
+			if err != nil {
+				return err
+			}
+			_, err = w.Write(append(data, '\n'))
+			return err
+		},
+	}
+}
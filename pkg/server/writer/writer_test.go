@@ -0,0 +1,610 @@
+package writer
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rancher/apiserver/pkg/server"
+	"github.com/rancher/apiserver/pkg/store/empty"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/apiserver/pkg/urlbuilder"
+	"github.com/rancher/apiserver/pkg/writer"
+	"github.com/rancher/steve/pkg/attributes"
+	"github.com/rancher/steve/pkg/etag"
+	"github.com/rancher/steve/pkg/resources/common"
+	"github.com/rancher/steve/pkg/stores/partition"
+	"github.com/rancher/wrangler/v3/pkg/schemas"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func newRequest(t *testing.T, target string, columns []common.ColumnDefinition) (*types.APIRequest, *httptest.ResponseRecorder) {
+	apiSchema := &types.APISchema{
+		Schema: &schemas.Schema{
+			ID:                "pod",
+			CollectionMethods: []string{http.MethodGet},
+			ResourceMethods:   []string{http.MethodGet},
+			Attributes:        map[string]interface{}{},
+		},
+	}
+	attributes.SetNamespaced(apiSchema, true)
+	attributes.SetGVK(apiSchema, schema.GroupVersionKind{Version: "v1", Kind: "Pod"})
+	if columns != nil {
+		attributes.SetColumns(apiSchema, columns)
+	}
+	apiSchemas := types.EmptyAPISchemas()
+	apiSchemas.AddSchema(*apiSchema)
+
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	urlBuilder, err := urlbuilder.NewPrefixed(req, apiSchemas, "v1")
+	require.NoError(t, err)
+
+	rw := httptest.NewRecorder()
+	return &types.APIRequest{
+		Request:        req,
+		Response:       rw,
+		Method:         http.MethodGet,
+		Type:           apiSchema.ID,
+		Schema:         apiSchema,
+		Schemas:        apiSchemas,
+		URLBuilder:     urlBuilder,
+		AccessControl:  &server.SchemaBasedAccess{},
+		ResponseFormat: ResponseFormat(req),
+	}, rw
+}
+
+func pod(namespace, name, status string) types.APIObject {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Pod",
+		"metadata": map[string]interface{}{
+			"name":      name,
+			"namespace": namespace,
+			"labels":    map[string]interface{}{"app": name},
+			"fields":    []interface{}{name, "1/1", status},
+		},
+	}}
+	return types.APIObject{Type: "pod", ID: namespace + "/" + name, Object: obj}
+}
+
+var podColumns = []common.ColumnDefinition{
+	{TableColumnDefinition: metav1.TableColumnDefinition{Name: "Name"}, Field: "$.metadata.fields[0]"},
+	{TableColumnDefinition: metav1.TableColumnDefinition{Name: "Ready"}, Field: "$.metadata.fields[1]"},
+	{TableColumnDefinition: metav1.TableColumnDefinition{Name: "Status"}, Field: "$.metadata.fields[2]"},
+}
+
+var pods = types.APIObjectList{
+	Objects: []types.APIObject{
+		pod("default", "a", "Running"),
+		pod("kube-system", "b,c", "Pending"),
+	},
+	Continue: "next-token",
+	Count:    5,
+}
+
+func TestResponseFormat(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		accept string
+		want   string
+	}{
+		{name: "csv query", target: "/v1/pods?format=csv", want: FormatCSV},
+		{name: "ndjson query", target: "/v1/pods?format=NDJSON", want: FormatNDJSON},
+		{name: "csv accept", target: "/v1/pods", accept: "text/csv; charset=utf-8", want: FormatCSV},
+		{name: "ndjson accept", target: "/v1/pods", accept: "application/json;q=0.5, application/x-ndjson", want: FormatNDJSON},
+		{name: "yaml query", target: "/v1/pods?format=yaml", want: FormatYAML},
+		{name: "yaml accept", target: "/v1/pods", accept: "application/yaml", want: FormatYAML},
+		{name: "other format", target: "/v1/pods?format=xml", accept: "application/json"},
+		{name: "none", target: "/v1/pods"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, test.target, nil)
+			req.Header.Set("Accept", test.accept)
+			assert.Equal(t, test.want, ResponseFormat(req))
+		})
+	}
+}
+
+func TestCSVWriteList(t *testing.T) {
+	tests := []struct {
+		name    string
+		target  string
+		columns []common.ColumnDefinition
+		want    string
+	}{
+		{
+			name:    "printer columns",
+			target:  "/v1/pods?format=csv",
+			columns: podColumns,
+			want:    "Namespace,Name,Ready,Status\ndefault,a,1/1,Running\nkube-system,\"b,c\",1/1,Pending\n",
+		},
+		{
+			name:   "no printer columns",
+			target: "/v1/pods?format=csv",
+			want:   "Namespace,Name,State\ndefault,a,\nkube-system,\"b,c\",\n",
+		},
+		{
+			name:    "requested columns",
+			target:  "/v1/pods?format=csv&columns=id,status,metadata.labels[app]&columns=metadata.labels",
+			columns: podColumns,
+			want: "id,Status,metadata.labels[app],metadata.labels\n" +
+				"default/a,Running,a,\"{\"\"app\"\":\"\"a\"\"}\"\n" +
+				"\"kube-system/b,c\",Pending,\"b,c\",\"{\"\"app\"\":\"\"b,c\"\"}\"\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			apiOp, rw := newRequest(t, test.target, test.columns)
+			(&CSVResponseWriter{}).WriteList(apiOp, http.StatusOK, pods)
+
+			assert.Equal(t, http.StatusOK, rw.Code)
+			assert.Equal(t, "text/csv; charset=utf-8", rw.Header().Get("Content-Type"))
+			assert.Equal(t, "5", rw.Header().Get("X-API-Count"))
+			assert.Contains(t, rw.Header().Get("Link"), "continue=next-token")
+			assert.Equal(t, test.want, rw.Body.String())
+		})
+	}
+}
+
+func TestCSVEscapeFormula(t *testing.T) {
+	list := types.APIObjectList{
+		Objects: []types.APIObject{
+			pod("default", "a", "=HYPERLINK(\"http://example.com\")"),
+			pod("default", "b", "+1"),
+			pod("default", "c", "-1"),
+			pod("default", "d", "@SUM(A1)"),
+			pod("default", "e", "\tx"),
+			pod("default", "f", "\rx"),
+			pod("default", "g", "a=b"),
+		},
+	}
+	apiOp, rw := newRequest(t, "/v1/pods?format=csv&columns=status,=cmd", podColumns)
+	(&CSVResponseWriter{}).WriteList(apiOp, http.StatusOK, list)
+
+	assert.Equal(t, "Status,'=cmd\n"+
+		"\"'=HYPERLINK(\"\"http://example.com\"\")\",\n"+
+		"'+1,\n"+
+		"'-1,\n"+
+		"'@SUM(A1),\n"+
+		"'\tx,\n"+
+		"\"'\rx\",\n"+
+		"a=b,\n", rw.Body.String())
+}
+
+func TestNDJSONWriteList(t *testing.T) {
+	apiOp, rw := newRequest(t, "/v1/pods?format=ndjson", podColumns)
+	(&NDJSONResponseWriter{}).WriteList(apiOp, http.StatusOK, pods)
+
+	assert.Equal(t, "application/x-ndjson", rw.Header().Get("Content-Type"))
+	lines := strings.Split(strings.TrimSuffix(rw.Body.String(), "\n"), "\n")
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[0], `"id":"default/a"`)
+	assert.Contains(t, lines[0], `"self":`)
+	assert.Contains(t, lines[1], `"id":"kube-system/b,c"`)
+}
+
+func TestWriteError(t *testing.T) {
+	apiOp, rw := newRequest(t, "/v1/pods?format=csv", nil)
+	(&CSVResponseWriter{}).Write(apiOp, http.StatusNotFound, types.APIObject{
+		Type:   "error",
+		Object: map[string]interface{}{"type": "error", "code": "NotFound"},
+	})
+
+	assert.Equal(t, http.StatusNotFound, rw.Code)
+	assert.Equal(t, "application/json", rw.Header().Get("Content-Type"))
+	assert.Contains(t, rw.Body.String(), `"code":"NotFound"`)
+}
+
+func steveFormattedPod() types.APIObject {
+	obj := pod("default", "a", "Running")
+	u := obj.Object.(*unstructured.Unstructured)
+	u.Object["id"] = "default/a"
+	u.Object["_type"] = "Opaque"
+	u.Object["resourcePermissions"] = map[string]interface{}{}
+	u.Object["metadata"].(map[string]interface{})["state"] = map[string]interface{}{"name": "running"}
+	u.Object["metadata"].(map[string]interface{})["relationships"] = []interface{}{}
+	u.Object["metadata"].(map[string]interface{})["managedFields"] = []interface{}{map[string]interface{}{"manager": "kubectl"}}
+	u.Object["status"] = map[string]interface{}{
+		"conditions": []interface{}{
+			map[string]interface{}{
+				"type":               "Ready",
+				"status":             "True",
+				"lastTransitionTime": "2024-01-01T00:00:00Z",
+				"lastUpdateTime":     "2024-01-01T00:00:00Z",
+				"error":              false,
+				"transitioning":      false,
+			},
+		},
+	}
+	return obj
+}
+
+func TestYAMLWrite(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		want   string
+	}{
+		{
+			name:   "steve fields removed",
+			target: "/v1/pods/default/a?format=yaml",
+			want: `apiVersion: v1
+kind: Pod
+metadata:
+  labels:
+    app: a
+  managedFields:
+  - manager: kubectl
+  name: a
+  namespace: default
+status:
+  conditions:
+  - lastTransitionTime: "2024-01-01T00:00:00Z"
+    status: "True"
+    type: Ready
+type: Opaque
+`,
+		},
+		{
+			name:   "managed fields and status removed",
+			target: "/v1/pods/default/a?format=yaml&removeManagedFields=true&removeStatus=true&download=true",
+			want: `apiVersion: v1
+kind: Pod
+metadata:
+  labels:
+    app: a
+  name: a
+  namespace: default
+type: Opaque
+`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			apiOp, rw := newRequest(t, test.target, nil)
+			(&YAMLResponseWriter{}).Write(apiOp, http.StatusOK, steveFormattedPod())
+
+			assert.Equal(t, "application/yaml", rw.Header().Get("Content-Type"))
+			assert.Equal(t, test.want, rw.Body.String())
+			if apiOp.Request.URL.Query().Has(DownloadQuery) {
+				assert.Equal(t, `attachment; filename="a.yaml"`, rw.Header().Get("Content-Disposition"))
+			} else {
+				assert.Empty(t, rw.Header().Get("Content-Disposition"))
+			}
+		})
+	}
+}
+
+func TestYAMLExport(t *testing.T) {
+	exported := func(name string) types.APIObject {
+		obj := steveFormattedPod()
+		u := obj.Object.(*unstructured.Unstructured)
+		u.SetName(name)
+		u.SetUID("0b5e1c1e")
+		u.SetResourceVersion("100")
+		u.SetGeneration(2)
+		u.SetCreationTimestamp(metav1.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+		u.SetAnnotations(map[string]string{lastAppliedAnnotation: "{}"})
+		return obj
+	}
+
+	apiOp, rw := newRequest(t, "/v1/pods/default/a?format=yaml&export=true", nil)
+	(&YAMLResponseWriter{}).Write(apiOp, http.StatusOK, exported("a"))
+	assert.Equal(t, `apiVersion: v1
+kind: Pod
+metadata:
+  labels:
+    app: a
+  name: a
+  namespace: default
+type: Opaque
+`, rw.Body.String())
+
+	apiOp, rw = newRequest(t, "/v1/pods/default?format=yaml&export=true&download=true", nil)
+	(&YAMLResponseWriter{}).WriteList(apiOp, http.StatusOK, types.APIObjectList{
+		Objects:  []types.APIObject{exported("a"), exported("b")},
+		Revision: "100",
+	})
+	assert.Equal(t, `apiVersion: v1
+kind: Pod
+metadata:
+  labels:
+    app: a
+  name: a
+  namespace: default
+type: Opaque
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  labels:
+    app: a
+  name: b
+  namespace: default
+type: Opaque
+`, rw.Body.String())
+	assert.Equal(t, `attachment; filename="pod.yaml"`, rw.Header().Get("Content-Disposition"))
+}
+
+func TestYAMLWriteList(t *testing.T) {
+	apiOp, rw := newRequest(t, "/v1/pods?format=yaml", nil)
+	list := types.APIObjectList{
+		Objects:  []types.APIObject{pod("default", "a", "Running")},
+		Revision: "100",
+	}
+	(&YAMLResponseWriter{}).WriteList(apiOp, http.StatusOK, list)
+
+	assert.Equal(t, `apiVersion: v1
+items:
+- apiVersion: v1
+  kind: Pod
+  metadata:
+    labels:
+      app: a
+    name: a
+    namespace: default
+kind: List
+metadata:
+  resourceVersion: "100"
+`, rw.Body.String())
+}
+
+type recordingWriter struct {
+	types.ResponseWriter
+	called bool
+}
+
+func (r *recordingWriter) Write(*types.APIRequest, int, types.APIObject) {
+	r.called = true
+}
+
+func TestYAMLWriteFallback(t *testing.T) {
+	apiOp, _ := newRequest(t, "/v1/pods?format=yaml", nil)
+	fallback := &recordingWriter{}
+	(&YAMLResponseWriter{Fallback: fallback}).Write(apiOp, http.StatusNotFound, types.APIObject{
+		Type:   "error",
+		Object: map[string]interface{}{"type": "error", "code": "NotFound"},
+	})
+	assert.True(t, fallback.called)
+}
+
+func TestJSONWriteList(t *testing.T) {
+	apiOp, rw := newRequest(t, "/v1/pods", podColumns)
+	(&writer.EncodingResponseWriter{ContentType: "application/json", Encoder: types.JSONEncoder}).WriteList(apiOp, http.StatusOK, pods)
+	want := rw.Body.String()
+
+	apiOp, rw = newRequest(t, "/v1/pods", podColumns)
+	NewJSONResponseWriter().WriteList(apiOp, http.StatusOK, pods)
+	assert.Equal(t, "application/json", rw.Header().Get("Content-Type"))
+	assert.JSONEq(t, want, rw.Body.String())
+
+	apiOp, rw = newRequest(t, "/v1/pods", podColumns)
+	NewJSONResponseWriter().WriteList(apiOp, http.StatusOK, types.APIObjectList{})
+	assert.Contains(t, rw.Body.String(), `"data":[]`)
+}
+
+type streamStore struct {
+	empty.Store
+	objects []types.APIObject
+	result  types.APIObjectList
+	err     error
+}
+
+func (s *streamStore) List(*types.APIRequest, *types.APISchema) (types.APIObjectList, error) {
+	return types.APIObjectList{Objects: s.objects}, nil
+}
+
+func (s *streamStore) ListStream(*types.APIRequest, *types.APISchema) (*partition.ListStream, error) {
+	objects := make(chan types.APIObject)
+	go func() {
+		defer close(objects)
+		for _, obj := range s.objects {
+			objects <- obj
+		}
+	}()
+	return &partition.ListStream{
+		Objects: objects,
+		Result: func() (types.APIObjectList, error) {
+			return s.result, s.err
+		},
+	}, nil
+}
+
+func TestListHandlerStream(t *testing.T) {
+	store := &streamStore{
+		objects: pods.Objects,
+		result:  types.APIObjectList{Revision: "10", Continue: "next-token", Count: 2},
+	}
+
+	tests := []struct {
+		name   string
+		target string
+		writer types.ResponseWriter
+		check  func(t *testing.T, rw *httptest.ResponseRecorder)
+	}{
+		{
+			name:   "json",
+			target: "/v1/pods",
+			writer: &ConditionalWriter{ResponseWriter: &writer.GzipWriter{ResponseWriter: NewJSONResponseWriter()}},
+			check: func(t *testing.T, rw *httptest.ResponseRecorder) {
+				assert.Empty(t, rw.Header().Get("ETag"))
+				var collection types.GenericCollection
+				require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &collection))
+				assert.Equal(t, "collection", collection.Type)
+				assert.Equal(t, "10", collection.Revision)
+				assert.Equal(t, "next-token", collection.Continue)
+				assert.Equal(t, 2, collection.Count)
+				require.NotNil(t, collection.Pagination)
+				assert.Contains(t, collection.Pagination.Next, "continue=next-token")
+				require.Len(t, collection.Data, 2)
+				assert.Equal(t, "default/a", collection.Data[0].ID)
+			},
+		},
+		{
+			name:   "ndjson",
+			target: "/v1/pods?format=ndjson",
+			writer: &NDJSONResponseWriter{},
+			check: func(t *testing.T, rw *httptest.ResponseRecorder) {
+				lines := strings.Split(strings.TrimSuffix(rw.Body.String(), "\n"), "\n")
+				assert.Len(t, lines, 2)
+				assert.Empty(t, rw.Header().Get("X-API-Revision"))
+				trailers := rw.Result().Trailer
+				assert.Equal(t, "10", trailers.Get("X-API-Revision"))
+				assert.Equal(t, "2", trailers.Get("X-API-Count"))
+				assert.Contains(t, trailers.Get("Link"), "continue=next-token")
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			apiOp, rw := newRequest(t, test.target, nil)
+			apiOp.Schema.Store = store
+			apiOp.ResponseWriter = test.writer
+
+			list, err := ListHandler(apiOp)
+			require.NoError(t, err)
+			assert.Empty(t, list.Objects)
+			require.NotNil(t, streamFrom(apiOp.Context()))
+
+			apiOp.WriteResponseList(http.StatusOK, list)
+			test.check(t, rw)
+		})
+	}
+}
+
+func TestListHandlerNoStream(t *testing.T) {
+	store := &streamStore{objects: pods.Objects}
+
+	// the CSV writer needs the whole list
+	apiOp, _ := newRequest(t, "/v1/pods?format=csv", nil)
+	apiOp.Schema.Store = store
+	apiOp.ResponseWriter = &CSVResponseWriter{}
+	list, err := ListHandler(apiOp)
+	require.NoError(t, err)
+	assert.Len(t, list.Objects, 2)
+	assert.Nil(t, streamFrom(apiOp.Context()))
+
+	// an empty stream is written as an empty list
+	apiOp, _ = newRequest(t, "/v1/pods", nil)
+	apiOp.Schema.Store = &streamStore{result: types.APIObjectList{Revision: "10"}}
+	apiOp.ResponseWriter = NewJSONResponseWriter()
+	list, err = ListHandler(apiOp)
+	require.NoError(t, err)
+	assert.Equal(t, "10", list.Revision)
+	assert.Nil(t, streamFrom(apiOp.Context()))
+
+	// errors listing the first objects are reported as errors
+	apiOp, _ = newRequest(t, "/v1/pods", nil)
+	apiOp.Schema.Store = &streamStore{err: errors.New("forbidden")}
+	apiOp.ResponseWriter = NewJSONResponseWriter()
+	_, err = ListHandler(apiOp)
+	assert.EqualError(t, err, "forbidden")
+}
+
+func TestTemplate(t *testing.T) {
+	apiSchema := &types.APISchema{Schema: &schemas.Schema{ID: "pod"}}
+	Template().Customize(apiSchema)
+	assert.NotNil(t, apiSchema.ListHandler)
+
+	// the list handlers set by the templates of a type are kept
+	apiSchema = &types.APISchema{Schema: &schemas.Schema{ID: "pod"}}
+	apiSchema.ListHandler = func(*types.APIRequest) (types.APIObjectList, error) {
+		return types.APIObjectList{Revision: "custom"}, nil
+	}
+	Template().Customize(apiSchema)
+	list, err := apiSchema.ListHandler(&types.APIRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, "custom", list.Revision)
+}
+
+func TestListStreamAbort(t *testing.T) {
+	apiOp, _ := newRequest(t, "/v1/pods", nil)
+	apiOp.Schema.Store = &streamStore{objects: pods.Objects, err: errors.New("partition failed")}
+	apiOp.ResponseWriter = NewJSONResponseWriter()
+
+	list, err := ListHandler(apiOp)
+	require.NoError(t, err)
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		apiOp.WriteResponseList(http.StatusOK, list)
+	})
+}
+
+func TestConditionalWriter(t *testing.T) {
+	versioned := pod("default", "a", "Running")
+	versioned.Object.(*unstructured.Unstructured).SetResourceVersion("7")
+	list := pods
+	list.Revision = "10"
+
+	tests := []struct {
+		name        string
+		ifNoneMatch func(apiOp *types.APIRequest) string
+		write       func(apiOp *types.APIRequest)
+		wantCode    int
+		wantETag    bool
+	}{
+		{
+			name:        "object matching",
+			ifNoneMatch: func(apiOp *types.APIRequest) string { return etag.For(apiOp, "7") },
+			write:       func(apiOp *types.APIRequest) { apiOp.WriteResponse(http.StatusOK, versioned) },
+			wantCode:    http.StatusNotModified,
+			wantETag:    true,
+		},
+		{
+			name:        "object changed",
+			ifNoneMatch: func(apiOp *types.APIRequest) string { return etag.For(apiOp, "6") },
+			write:       func(apiOp *types.APIRequest) { apiOp.WriteResponse(http.StatusOK, versioned) },
+			wantCode:    http.StatusOK,
+			wantETag:    true,
+		},
+		{
+			name:        "list matching",
+			ifNoneMatch: func(apiOp *types.APIRequest) string { return `"other", ` + etag.For(apiOp, "10") },
+			write:       func(apiOp *types.APIRequest) { apiOp.WriteResponseList(http.StatusOK, list) },
+			wantCode:    http.StatusNotModified,
+			wantETag:    true,
+		},
+		{
+			name:        "list without revision",
+			ifNoneMatch: func(*types.APIRequest) string { return "*" },
+			write:       func(apiOp *types.APIRequest) { apiOp.WriteResponseList(http.StatusOK, pods) },
+			wantCode:    http.StatusOK,
+		},
+		{
+			name:        "not modified by the store",
+			ifNoneMatch: func(*types.APIRequest) string { return "*" },
+			write: func(apiOp *types.APIRequest) {
+				apiOp.WriteResponse(http.StatusNotModified, types.APIObject{
+					Type:   "error",
+					Object: map[string]interface{}{"type": "error", "code": "NotModified"},
+				})
+			},
+			wantCode: http.StatusNotModified,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			apiOp, rw := newRequest(t, "/v1/pods", nil)
+			apiOp.ResponseWriter = &ConditionalWriter{ResponseWriter: NewJSONResponseWriter()}
+			apiOp.Request.Header.Set("If-None-Match", test.ifNoneMatch(apiOp))
+
+			test.write(apiOp)
+			assert.Equal(t, test.wantCode, rw.Code)
+			assert.Equal(t, test.wantETag, rw.Header().Get("ETag") != "")
+			if test.wantCode == http.StatusNotModified {
+				assert.Empty(t, rw.Body.String())
+			} else {
+				assert.NotEmpty(t, rw.Body.String())
+			}
+		})
+	}
+}
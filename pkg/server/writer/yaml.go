@@ -0,0 +1,215 @@
+package writer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/apiserver/pkg/writer"
+	"github.com/rancher/steve/pkg/attributes"
+	"github.com/rancher/wrangler/v3/pkg/data"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	FormatYAML = "yaml"
+
+	// DownloadQuery is the query parameter asking for the response to be saved as a file rather than displayed.
+	DownloadQuery = "download"
+	// ExportQuery is the query parameter asking for manifests which can be committed and applied to another cluster.
+	ExportQuery = "export"
+
+	lastAppliedAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+)
+
+// CleanOptions selects what Clean removes in addition to the fields added by steve.
+type CleanOptions struct {
+	ManagedFields bool
+	Status        bool
+	// Export also removes the fields set by the cluster the object lives in, such as its uid and resourceVersion.
+	Export bool
+}
+
+// CleanOptionsFromRequest returns the options set with the removeManagedFields, removeStatus and export query
+// parameters.
+func CleanOptionsFromRequest(apiOp *types.APIRequest) CleanOptions {
+	query := apiOp.Request.URL.Query()
+	managedFields, _ := strconv.ParseBool(query.Get("removeManagedFields"))
+	status, _ := strconv.ParseBool(query.Get("removeStatus"))
+	export, _ := strconv.ParseBool(query.Get(ExportQuery))
+	return CleanOptions{
+		ManagedFields: managedFields,
+		Status:        status,
+		Export:        export,
+	}
+}
+
+// Clean returns a copy of obj without the fields added by steve, as it would be returned by Kubernetes.
+func Clean(obj map[string]interface{}, opts CleanOptions) map[string]interface{} {
+	obj = deepCopy(obj)
+	for k := range types.ReservedFields {
+		delete(obj, k)
+		if v, ok := obj["_"+k]; ok {
+			delete(obj, "_"+k)
+			obj[k] = v
+		}
+	}
+	delete(obj, "resourcePermissions")
+	data.RemoveValue(obj, "metadata", "fields")
+	data.RemoveValue(obj, "metadata", "relationships")
+	data.RemoveValue(obj, "metadata", "state")
+
+	// undo summary.NormalizeConditions, which fills lastUpdateTime from lastTransitionTime
+	conditions, _ := data.GetValueN(obj, "status", "conditions").([]interface{})
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		delete(condition, "error")
+		delete(condition, "transitioning")
+		if condition["lastUpdateTime"] == condition["lastTransitionTime"] {
+			delete(condition, "lastUpdateTime")
+		}
+	}
+
+	if opts.Export {
+		for _, field := range []string{"resourceVersion", "uid", "creationTimestamp", "generation", "selfLink"} {
+			data.RemoveValue(obj, "metadata", field)
+		}
+		data.RemoveValue(obj, "metadata", "annotations", lastAppliedAnnotation)
+		if annotations, ok := data.GetValueN(obj, "metadata", "annotations").(map[string]interface{}); ok && len(annotations) == 0 {
+			data.RemoveValue(obj, "metadata", "annotations")
+		}
+	}
+	if opts.ManagedFields || opts.Export {
+		data.RemoveValue(obj, "metadata", "managedFields")
+	}
+	if opts.Status || opts.Export {
+		delete(obj, "status")
+	}
+	return obj
+}
+
+// deepCopy copies obj through JSON, as the fields added by steve are not limited to JSON types.
+func deepCopy(obj map[string]interface{}) map[string]interface{} {
+	var result map[string]interface{}
+	bytes, err := json.Marshal(obj)
+	if err != nil || json.Unmarshal(bytes, &result) != nil {
+		return map[string]interface{}{}
+	}
+	return result
+}
+
+// YAMLResponseWriter writes Kubernetes objects and lists as Kubernetes would, without the fields added by steve.
+// Anything else, like errors and action outputs, is written by Fallback.
+type YAMLResponseWriter struct {
+	Fallback types.ResponseWriter
+}
+
+func (y *YAMLResponseWriter) Write(apiOp *types.APIRequest, code int, obj types.APIObject) {
+	if obj.Type == "error" || !isKubernetes(apiOp.Schemas.LookupSchema(obj.Type)) {
+		y.Fallback.Write(apiOp, code, obj)
+		return
+	}
+
+	result := Manifests(apiOp, CleanOptionsFromRequest(apiOp), []types.APIObject{obj})
+	if len(result) == 0 {
+		y.Fallback.Write(apiOp, code, obj)
+		return
+	}
+
+	if apiOp.Request.URL.Query().Get(DownloadQuery) != "" {
+		apiOp.Response.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.yaml"`, obj.Name()))
+	}
+	start(apiOp, code, "application/yaml", types.APIObjectList{})
+	_ = encodeYAML(apiOp.Response, result[0])
+}
+
+func (y *YAMLResponseWriter) WriteList(apiOp *types.APIRequest, code int, list types.APIObjectList) {
+	if !isKubernetes(apiOp.Schema) {
+		y.Fallback.WriteList(apiOp, code, list)
+		return
+	}
+
+	opts := CleanOptionsFromRequest(apiOp)
+	manifests := Manifests(apiOp, opts, list.Objects)
+	if opts.Export {
+		// the list is not a resource of the cluster, so export its objects on their own
+		if apiOp.Request.URL.Query().Get(DownloadQuery) != "" {
+			apiOp.Response.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.yaml"`, apiOp.Type))
+		}
+		start(apiOp, code, "application/yaml", list)
+		_ = WriteManifests(apiOp.Response, manifests)
+		return
+	}
+
+	items := make([]interface{}, 0, len(manifests))
+	for _, manifest := range manifests {
+		items = append(items, manifest)
+	}
+
+	metadata := map[string]interface{}{}
+	if list.Revision != "" {
+		metadata["resourceVersion"] = list.Revision
+	}
+	if list.Continue != "" {
+		metadata["continue"] = list.Continue
+	}
+
+	start(apiOp, code, "application/yaml", list)
+	_ = encodeYAML(apiOp.Response, map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "List",
+		"metadata":   metadata,
+		"items":      items,
+	})
+}
+
+// Manifests formats objs like the JSON writer, so that include, exclude and redactions apply, and returns them
+// cleaned with opts. Objects without a schema are skipped.
+func Manifests(apiOp *types.APIRequest, opts CleanOptions, objs []types.APIObject) []map[string]interface{} {
+	result := make([]map[string]interface{}, 0, len(objs))
+	w := &writer.EncodingResponseWriter{
+		Encoder: func(_ io.Writer, v interface{}) error {
+			if resource, ok := v.(*types.RawResource); ok && resource != nil {
+				result = append(result, Clean(resource.APIObject.Data(), opts))
+			}
+			return nil
+		},
+	}
+	for _, obj := range objs {
+		_ = w.Body(apiOp, io.Discard, obj)
+	}
+	return result
+}
+
+// WriteManifests writes manifests as a multi-document YAML stream, as accepted by kubectl apply.
+func WriteManifests(w io.Writer, manifests []map[string]interface{}) error {
+	for i, manifest := range manifests {
+		if i > 0 {
+			if _, err := io.WriteString(w, "---\n"); err != nil {
+				return err
+			}
+		}
+		if err := encodeYAML(w, manifest); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func encodeYAML(w io.Writer, v interface{}) error {
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+func isKubernetes(schema *types.APISchema) bool {
+	return schema != nil && attributes.GVK(schema).Kind != ""
+}
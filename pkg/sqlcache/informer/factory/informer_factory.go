@@ -217,6 +217,25 @@ func (f *CacheFactory) cacheForLocked(ctx context.Context, gi *guardedInformer,
 	return &Cache{ByOptionsLister: gi.informer, gvk: gvk}, nil
 }
 
+// CachedGVKs returns the GVKs whose informer is running and synced, which can be listed without starting a new one.
+func (f *CacheFactory) CachedGVKs() []schema.GroupVersionKind {
+	f.informersMutex.Lock()
+	defer f.informersMutex.Unlock()
+
+	var result []schema.GroupVersionKind
+	for gvk, gi := range f.informers {
+		// informers being created hold the lock until they're started, and aren't synced yet anyway
+		if !gi.informerMutex.TryLock() {
+			continue
+		}
+		if gi.informer != nil && gi.informer.HasSynced() {
+			result = append(result, gvk)
+		}
+		gi.informerMutex.Unlock()
+	}
+	return result
+}
+
 // DoneWithCache must be called for every successful CacheFor call. The Cache should
 // no longer be used after DoneWithCache is called.
 //
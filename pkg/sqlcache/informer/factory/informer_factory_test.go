@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"sync"
 	"testing"
 	"time"
 
@@ -554,3 +555,31 @@ func TestCacheFor(t *testing.T) {
 		t.Run(test.description, func(t *testing.T) { test.test(t) })
 	}
 }
+
+func TestCachedGVKs(t *testing.T) {
+	newGuardedInformer := func(synced bool) *guardedInformer {
+		sii := NewMockSharedIndexInformer(gomock.NewController(t))
+		sii.EXPECT().HasSynced().Return(synced).AnyTimes()
+		return &guardedInformer{
+			informer:      &informer.Informer{SharedIndexInformer: sii},
+			informerMutex: &sync.Mutex{},
+		}
+	}
+	synced := schema.GroupVersionKind{Version: "v1", Kind: "Pod"}
+	notSynced := schema.GroupVersionKind{Version: "v1", Kind: "Secret"}
+	failed := schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+	creating := schema.GroupVersionKind{Version: "v1", Kind: "Service"}
+
+	f := &CacheFactory{
+		informers: map[schema.GroupVersionKind]*guardedInformer{
+			synced:    newGuardedInformer(true),
+			notSynced: newGuardedInformer(false),
+			failed:    {informerMutex: &sync.Mutex{}},
+			creating:  newGuardedInformer(true),
+		},
+	}
+	f.informers[creating].informerMutex.Lock()
+	defer f.informers[creating].informerMutex.Unlock()
+
+	assert.Equal(t, []schema.GroupVersionKind{synced}, f.CachedGVKs())
+}
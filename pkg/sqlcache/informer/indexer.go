@@ -66,6 +66,7 @@ type Store interface {
 	cache.Store
 
 	GetByKey(key string) (item any, exists bool, err error)
+	UpdateByKeys(keys []string, update func(obj any) (any, bool)) error
 	GetName() string
 	RegisterAfterAdd(f func(key string, obj any, tx db.TxClient) error)
 	RegisterAfterUpdate(f func(key string, obj any, tx db.TxClient) error)
@@ -50,6 +50,8 @@ type ByOptionsLister interface {
 	ListByOptions(ctx context.Context, lo *sqltypes.ListOptions, partitions []partition.Partition, namespace string) (*unstructured.UnstructuredList, int, string, error)
 	Watch(ctx context.Context, options WatchOptions, eventsCh chan<- watch.Event) error
 	GetLatestResourceVersion() []string
+	// UpdateByKeys replaces the objects saved under keys with the ones update returns, if it returns true
+	UpdateByKeys(keys []string, update func(obj any) (any, bool)) error
 	RunGC(context.Context)
 	DropAll(context.Context) error
 }
@@ -101,6 +101,20 @@ func (mr *MockByOptionsListerMockRecorder) RunGC(arg0 any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RunGC", reflect.TypeOf((*MockByOptionsLister)(nil).RunGC), arg0)
 }
 
+// UpdateByKeys mocks base method.
+func (m *MockByOptionsLister) UpdateByKeys(keys []string, update func(any) (any, bool)) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateByKeys", keys, update)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateByKeys indicates an expected call of UpdateByKeys.
+func (mr *MockByOptionsListerMockRecorder) UpdateByKeys(keys, update any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateByKeys", reflect.TypeOf((*MockByOptionsLister)(nil).UpdateByKeys), keys, update)
+}
+
 // Watch mocks base method.
 func (m *MockByOptionsLister) Watch(ctx context.Context, options WatchOptions, eventsCh chan<- watch.Event) error {
 	m.ctrl.T.Helper()
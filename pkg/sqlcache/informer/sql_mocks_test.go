@@ -425,6 +425,20 @@ func (mr *MockStoreMockRecorder) Update(obj any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockStore)(nil).Update), obj)
 }
 
+// UpdateByKeys mocks base method.
+func (m *MockStore) UpdateByKeys(keys []string, update func(any) (any, bool)) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateByKeys", keys, update)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateByKeys indicates an expected call of UpdateByKeys.
+func (mr *MockStoreMockRecorder) UpdateByKeys(keys, update any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateByKeys", reflect.TypeOf((*MockStore)(nil).UpdateByKeys), keys, update)
+}
+
 // Upsert mocks base method.
 func (m *MockStore) Upsert(tx db.TxClient, stmt db.Stmt, key string, obj db.SerializedObject) error {
 	m.ctrl.T.Helper()
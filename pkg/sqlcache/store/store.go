@@ -364,6 +364,53 @@ func (s *Store) Update(obj any) error {
 	return nil
 }
 
+// UpdateByKeys calls update with the object saved under each of keys and saves the object it returns. All of them
+// happen in one transaction, so that a concurrent Update of an object is never overwritten with an older version of
+// it. Nothing is saved for keys with no object, or for which update returns false.
+func (s *Store) UpdateByKeys(keys []string, update func(obj any) (any, bool)) error {
+	var updated []string
+	err := s.WithTransaction(s.ctx, true, func(tx db.TxClient) error {
+		updated = nil
+		for _, key := range keys {
+			rows, err := tx.Stmt(s.getStmt).QueryContext(s.ctx, key)
+			if err != nil {
+				return err
+			}
+			result, err := s.ReadObjects(rows, s.typ)
+			if err != nil {
+				return err
+			}
+			if len(result) == 0 {
+				continue
+			}
+			obj, ok := update(result[0])
+			if !ok {
+				continue
+			}
+			serialized, err := s.Serialize(obj, s.shouldEncrypt)
+			if err != nil {
+				return err
+			}
+			if err := s.Upsert(tx, s.upsertStmt, key, serialized); err != nil {
+				return err
+			}
+			if err := s.runAfterUpdate(key, obj, tx); err != nil {
+				return err
+			}
+			updated = append(updated, key)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Errorf("Error in Store.UpdateByKeys for type %v: %v", s.name, err)
+		return err
+	}
+	for _, key := range updated {
+		s.checkUpdateExternalInfo(key)
+	}
+	return nil
+}
+
 // Delete deletes the given object, if it exists in this Store
 func (s *Store) Delete(obj any) error {
 	key, err := s.keyFunc(obj)
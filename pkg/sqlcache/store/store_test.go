@@ -301,6 +301,87 @@ func TestUpdate(t *testing.T) {
 	}
 }
 
+func TestUpdateByKeys(t *testing.T) {
+	testObject := testStoreObject{Id: "something", Val: "a"}
+	updatedObject := testStoreObject{Id: "something", Val: "b"}
+	serialized := db.SerializedObject{Bytes: []byte("testobject")}
+
+	setup := func(t *testing.T, stored []any) (*MockClient, *MockTxClient, *Store) {
+		c, txC := SetupMockDB(t)
+		store := SetupStore(t, c, false)
+		r := &sql.Rows{}
+		txC.EXPECT().Stmt(store.getStmt).Return(store.getStmt)
+		store.getStmt.(*MockStmt).EXPECT().QueryContext(context.Background(), "something").Return(r, nil)
+		c.EXPECT().ReadObjects(r, reflect.TypeOf(testObject)).Return(stored, nil)
+		c.EXPECT().WithTransaction(gomock.Any(), true, gomock.Any()).Return(nil).Do(
+			func(ctx context.Context, shouldEncrypt bool, f db.WithTransactionFunction) {
+				if err := f(txC); err != nil {
+					t.Fail()
+				}
+			})
+		return c, txC, store
+	}
+
+	t.Run("update saves the returned object", func(t *testing.T) {
+		c, txC, store := setup(t, []any{testObject})
+		c.EXPECT().Serialize(updatedObject, false).Return(serialized, nil)
+		c.EXPECT().Upsert(txC, store.upsertStmt, "something", serialized).Return(nil)
+		var afterUpdate []any
+		store.afterUpdate = append(store.afterUpdate, func(key string, obj any, txC db.TxClient) error {
+			afterUpdate = append(afterUpdate, obj)
+			return nil
+		})
+
+		err := store.UpdateByKeys([]string{"something"}, func(obj any) (any, bool) {
+			assert.Equal(t, testObject, obj)
+			return updatedObject, true
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, []any{updatedObject}, afterUpdate)
+	})
+
+	t.Run("update returning false saves nothing", func(t *testing.T) {
+		_, _, store := setup(t, []any{testObject})
+		err := store.UpdateByKeys([]string{"something"}, func(obj any) (any, bool) {
+			return obj, false
+		})
+		assert.Nil(t, err)
+	})
+
+	t.Run("missing object isn't updated", func(t *testing.T) {
+		_, _, store := setup(t, []any{})
+		err := store.UpdateByKeys([]string{"something"}, func(obj any) (any, bool) {
+			t.Fail()
+			return obj, true
+		})
+		assert.Nil(t, err)
+	})
+
+	t.Run("keys are updated in one transaction", func(t *testing.T) {
+		c, txC := SetupMockDB(t)
+		store := SetupStore(t, c, false)
+		c.EXPECT().WithTransaction(gomock.Any(), true, gomock.Any()).Return(nil).Times(1).Do(
+			func(ctx context.Context, shouldEncrypt bool, f db.WithTransactionFunction) {
+				if err := f(txC); err != nil {
+					t.Fail()
+				}
+			})
+		for _, key := range []string{"something", "other"} {
+			r := &sql.Rows{}
+			txC.EXPECT().Stmt(store.getStmt).Return(store.getStmt)
+			store.getStmt.(*MockStmt).EXPECT().QueryContext(context.Background(), key).Return(r, nil)
+			c.EXPECT().ReadObjects(r, reflect.TypeOf(testObject)).Return([]any{testObject}, nil)
+			c.EXPECT().Upsert(txC, store.upsertStmt, key, serialized).Return(nil)
+		}
+		c.EXPECT().Serialize(updatedObject, false).Return(serialized, nil).Times(2)
+
+		err := store.UpdateByKeys([]string{"something", "other"}, func(obj any) (any, bool) {
+			return updatedObject, true
+		})
+		assert.Nil(t, err)
+	})
+}
+
 // Delete deletes the given object from the accumulator associated with the given object's key
 func TestDelete(t *testing.T) {
 	type testCase struct {
@@ -5,6 +5,7 @@ import (
 
 	"github.com/rancher/apiserver/pkg/types"
 	"github.com/rancher/steve/pkg/metrics"
+	"github.com/rancher/steve/pkg/stores/partition"
 )
 
 type Store struct {
@@ -33,6 +34,15 @@ func (s *Store) List(apiOp *types.APIRequest, schema *types.APISchema) (types.AP
 	return apiObjectList, err
 }
 
+// ListStream records the time taken to start streaming, as the objects are listed while the response is written.
+func (s *Store) ListStream(apiOp *types.APIRequest, schema *types.APISchema) (*partition.ListStream, error) {
+	m := metrics.MetricLogger{Resource: apiOp.Schema.ID, Method: apiOp.Method}
+	storeStart := time.Now()
+	stream, err := partition.StreamList(s.Store, apiOp, schema)
+	m.RecordProxyStoreResponseTime(err, float64(time.Since(storeStart).Milliseconds()))
+	return stream, err
+}
+
 func (s *Store) Create(apiOp *types.APIRequest, schema *types.APISchema, data types.APIObject) (types.APIObject, error) {
 	m := metrics.MetricLogger{Resource: apiOp.Schema.ID, Method: apiOp.Method}
 	storeStart := time.Now()
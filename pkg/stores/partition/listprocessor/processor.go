@@ -282,6 +282,11 @@ func FilterList(list <-chan []unstructured.Unstructured, filters []OrFilter) []u
 	return result
 }
 
+// Matches returns whether a single object matches all of the filters, for filtering objects one at a time.
+func Matches(obj map[string]interface{}, filters []OrFilter) bool {
+	return len(filters) == 0 || matchesAll(obj, filters)
+}
+
 func matchesOne(obj map[string]interface{}, filter Filter) bool {
 	var objValue interface{}
 	var ok bool
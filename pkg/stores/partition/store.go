@@ -166,13 +166,7 @@ func (s *Store) List(apiOp *types.APIRequest, schema *types.APISchema) (types.AP
 		return result, err
 	}
 
-	lister := ParallelPartitionLister{
-		Lister: func(ctx context.Context, partition Partition, cont string, revision string, limit int) (*unstructured.UnstructuredList, []types.Warning, error) {
-			return s.listPartition(ctx, apiOp, schema, partition, cont, revision, limit)
-		},
-		Concurrency: 3,
-		Partitions:  partitions,
-	}
+	lister := s.newLister(apiOp, schema, partitions)
 
 	opts := listprocessor.ParseQuery(apiOp)
 
@@ -15,6 +15,7 @@ import (
 	"github.com/rancher/wrangler/v3/pkg/generic"
 	"github.com/rancher/wrangler/v3/pkg/schemas"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
@@ -2147,6 +2148,62 @@ func TestListByRevision(t *testing.T) {
 	assert.Equal(t, wantVersion, got.Revision)
 }
 
+func TestListStream(t *testing.T) {
+	schema := &types.APISchema{Schema: &schemas.Schema{ID: "apple"}}
+	store := NewStore(mockPartitioner{
+		stores: map[string]UnstructuredStore{
+			"pink": &mockStore{
+				contents: &unstructured.UnstructuredList{
+					Object: map[string]interface{}{
+						"metadata": map[string]interface{}{
+							"resourceVersion": "5",
+						},
+					},
+					Items: []unstructured.Unstructured{
+						newApple("fuji").Unstructured,
+						newApple("granny-smith").Unstructured,
+					},
+				},
+			},
+			"green": &mockStore{
+				contents: &unstructured.UnstructuredList{
+					Items: []unstructured.Unstructured{
+						newApple("honeycrisp").Unstructured,
+						newApple("bramley").Unstructured,
+					},
+				},
+			},
+		},
+		partitions: map[string][]Partition{
+			"user1": {
+				mockPartition{name: "pink"},
+				mockPartition{name: "green"},
+			},
+		},
+	}, &mockAccessSetLookup{}, mockNamespaceCache{})
+
+	stream, err := store.ListStream(newRequest("filter=data.color=pink", "user1"), schema)
+	require.NoError(t, err)
+	require.NotNil(t, stream)
+	var got []types.APIObject
+	for obj := range stream.Objects {
+		got = append(got, obj)
+	}
+	assert.Equal(t, []types.APIObject{
+		newApple("fuji").toObj(),
+		newApple("honeycrisp").toObj(),
+	}, got)
+	list, err := stream.Result()
+	require.NoError(t, err)
+	assert.Equal(t, types.APIObjectList{Revision: "5", Count: 2}, list)
+
+	for _, query := range []string{"sort=metadata.name", "pagesize=1"} {
+		stream, err = store.ListStream(newRequest(query, "user1"), schema)
+		assert.NoError(t, err)
+		assert.Nil(t, stream, "%s can't be streamed", query)
+	}
+}
+
 type mockPartitioner struct {
 	stores     map[string]UnstructuredStore
 	partitions map[string][]Partition
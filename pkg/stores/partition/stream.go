@@ -0,0 +1,102 @@
+package partition
+
+import (
+	"context"
+
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/stores/partition/listprocessor"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// ListStreamer is implemented by stores which can return the objects of a list as they are listed, rather than
+// collecting the whole list in memory first.
+type ListStreamer interface {
+	// ListStream returns nil if the list can't be streamed, and List must be used instead.
+	ListStream(apiOp *types.APIRequest, schema *types.APISchema) (*ListStream, error)
+}
+
+// ListStream is a list whose objects are sent on Objects. Objects must be drained by the caller.
+type ListStream struct {
+	Objects <-chan types.APIObject
+	// Result returns the list without its objects, with the revision, continue token and count, and the error which
+	// ended the listing early, if any. It must only be called once Objects is closed.
+	Result func() (types.APIObjectList, error)
+}
+
+// StreamList returns the list stream of store if it implements ListStreamer, and nil otherwise.
+func StreamList(store types.Store, apiOp *types.APIRequest, schema *types.APISchema) (*ListStream, error) {
+	if streamer, ok := store.(ListStreamer); ok {
+		return streamer.ListStream(apiOp, schema)
+	}
+	return nil, nil
+}
+
+// ListStream returns the objects across all applicable partitions as they are received from Kubernetes, filtered
+// like List. Sorted and paginated lists, which need every object first, and cached lists can't be streamed.
+func (s *Store) ListStream(apiOp *types.APIRequest, schema *types.APISchema) (*ListStream, error) {
+	opts := listprocessor.ParseQuery(apiOp)
+	if s.listCache != nil || len(opts.Sort.Fields) > 0 || opts.Pagination.PageSize() > 0 {
+		return nil, nil
+	}
+
+	partitions, err := s.Partitioner.All(apiOp, schema, "list", "")
+	if err != nil {
+		return nil, err
+	}
+
+	lister := s.newLister(apiOp, schema, partitions)
+	ctx := apiOp.Context()
+	batches, err := lister.List(ctx, opts.ChunkSize, opts.Resume, opts.Revision)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		objects = make(chan types.APIObject)
+		count   int
+	)
+	go func() {
+		defer close(objects)
+		// the lister blocks until each batch is received, so batches are drained even if nobody is listening anymore
+		for items := range batches {
+			for i := range items {
+				if !s.matches(&items[i], opts) || ctx.Err() != nil {
+					continue
+				}
+				select {
+				case objects <- ToAPI(schema, &items[i], nil, types.ReservedFields):
+					count++
+				case <-ctx.Done():
+				}
+			}
+		}
+	}()
+
+	return &ListStream{
+		Objects: objects,
+		Result: func() (types.APIObjectList, error) {
+			return types.APIObjectList{
+				Revision: lister.Revision(),
+				Continue: lister.Continue(),
+				Count:    count,
+			}, lister.Err()
+		},
+	}, nil
+}
+
+func (s *Store) matches(item *unstructured.Unstructured, opts *listprocessor.ListOptions) bool {
+	if !listprocessor.Matches(item.Object, opts.Filters) {
+		return false
+	}
+	return len(listprocessor.FilterByProjectsAndNamespaces([]unstructured.Unstructured{*item}, opts.ProjectsOrNamespaces, s.namespaceCache)) > 0
+}
+
+func (s *Store) newLister(apiOp *types.APIRequest, schema *types.APISchema, partitions []Partition) *ParallelPartitionLister {
+	return &ParallelPartitionLister{
+		Lister: func(ctx context.Context, partition Partition, cont string, revision string, limit int) (*unstructured.UnstructuredList, []types.Warning, error) {
+			return s.listPartition(ctx, apiOp, schema, partition, cont, revision, limit)
+		},
+		Concurrency: 3,
+		Partitions:  partitions,
+	}
+}
@@ -1,10 +1,18 @@
 package proxy
 
 import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
 	"github.com/rancher/apiserver/pkg/apierror"
 	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/stores/partition"
 	"github.com/rancher/wrangler/v3/pkg/schemas/validation"
-	"k8s.io/apimachinery/pkg/api/errors"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 // ErrorStore implements types.store with errors translated into APIErrors
@@ -20,47 +28,88 @@ func NewErrorStore(s types.Store) *ErrorStore {
 // ByID looks up a single object by its ID.
 func (e *ErrorStore) ByID(apiOp *types.APIRequest, schema *types.APISchema, id string) (types.APIObject, error) {
 	data, err := e.Store.ByID(apiOp, schema, id)
-	return data, translateError(err)
+	return data, TranslateError(err)
 }
 
 // List returns a list of resources.
 func (e *ErrorStore) List(apiOp *types.APIRequest, schema *types.APISchema) (types.APIObjectList, error) {
 	data, err := e.Store.List(apiOp, schema)
-	return data, translateError(err)
+	return data, TranslateError(err)
+}
+
+// ListStream returns a list of resources as they are listed, if the underlying store supports it.
+func (e *ErrorStore) ListStream(apiOp *types.APIRequest, schema *types.APISchema) (*partition.ListStream, error) {
+	stream, err := partition.StreamList(e.Store, apiOp, schema)
+	if err != nil || stream == nil {
+		return nil, TranslateError(err)
+	}
+	result := stream.Result
+	stream.Result = func() (types.APIObjectList, error) {
+		list, err := result()
+		return list, TranslateError(err)
+	}
+	return stream, nil
 }
 
 // Create creates a single object in the store.
 func (e *ErrorStore) Create(apiOp *types.APIRequest, schema *types.APISchema, data types.APIObject) (types.APIObject, error) {
 	data, err := e.Store.Create(apiOp, schema, data)
-	return data, translateError(err)
+	return data, TranslateError(err)
 }
 
 // Update updates a single object in the store.
 func (e *ErrorStore) Update(apiOp *types.APIRequest, schema *types.APISchema, data types.APIObject, id string) (types.APIObject, error) {
 	data, err := e.Store.Update(apiOp, schema, data, id)
-	return data, translateError(err)
+	return data, TranslateError(err)
 }
 
 // Delete deletes an object from a store.
 func (e *ErrorStore) Delete(apiOp *types.APIRequest, schema *types.APISchema, id string) (types.APIObject, error) {
 	data, err := e.Store.Delete(apiOp, schema, id)
-	return data, translateError(err)
+	return data, TranslateError(err)
 
 }
 
 // Watch returns a channel of events for a list or resource.
 func (e *ErrorStore) Watch(apiOp *types.APIRequest, schema *types.APISchema, wr types.WatchRequest) (chan types.APIEvent, error) {
 	data, err := e.Store.Watch(apiOp, schema, wr)
-	return data, translateError(err)
+	return data, TranslateError(err)
 }
 
-func translateError(err error) error {
-	if apiError, ok := err.(errors.APIStatus); ok {
+// TranslateError returns the errors of the Kubernetes API as APIErrors with their status, instead of as server
+// errors.
+func TranslateError(err error) error {
+	var apiError k8serrors.APIStatus
+	if errors.As(err, &apiError) {
 		status := apiError.Status()
+		message := status.Message
+		if managers := conflictingManagers(status); len(managers) > 0 {
+			message = fmt.Sprintf("%s (conflicting field managers: %s, use force=true to take ownership of the fields)",
+				message, strings.Join(managers, ", "))
+		}
 		return apierror.NewAPIError(validation.ErrorCode{
 			Status: int(status.Code),
 			Code:   string(status.Reason),
-		}, status.Message)
+		}, message)
 	}
 	return err
 }
+
+var conflictManagerRegexp = regexp.MustCompile(`conflict with "([^"]*)"`)
+
+// conflictingManagers returns the field managers a server-side apply conflicted with.
+func conflictingManagers(status metav1.Status) []string {
+	if status.Reason != metav1.StatusReasonConflict || status.Details == nil {
+		return nil
+	}
+	var managers []string
+	for _, cause := range status.Details.Causes {
+		if cause.Type != metav1.CauseTypeFieldManagerConflict {
+			continue
+		}
+		if match := conflictManagerRegexp.FindStringSubmatch(cause.Message); match != nil && !slices.Contains(managers, match[1]) {
+			managers = append(managers, match[1])
+		}
+	}
+	return managers
+}
@@ -0,0 +1,31 @@
+package proxy
+
+import (
+	"mime"
+	"net/http"
+
+	apitypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+)
+
+// DryRunWarning is returned as a Warning header when a request was only validated.
+const DryRunWarning = "dry run: the request was validated but nothing was persisted"
+
+// PatchType returns the patch type of the request's content type. Strategic merge patch is the default, as it
+// was the only type supported for a long time.
+func PatchType(req *http.Request) apitypes.PatchType {
+	mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	switch pType := apitypes.PatchType(mediaType); pType {
+	case apitypes.JSONPatchType, apitypes.MergePatchType, apitypes.ApplyYAMLPatchType:
+		return pType
+	default:
+		return apitypes.StrategicMergePatchType
+	}
+}
+
+// AddDryRunWarning adds a warning to warnings that nothing was persisted if the request was a dry run.
+func AddDryRunWarning(warnings rest.WarningHandler, dryRun []string) {
+	if len(dryRun) > 0 {
+		warnings.HandleWarningHeader(299, "-", DryRunWarning)
+	}
+}
@@ -0,0 +1,92 @@
+package proxy
+
+import (
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/policy"
+	"github.com/rancher/steve/pkg/stores/partition"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// policyStore is a partition.UnstructuredStore which applies a policy.Policy on top of the RBAC based partitioning.
+// Objects denied by the policy are dropped from lists and watches, and single object requests fail with a
+// permission denied error.
+type policyStore struct {
+	partition.UnstructuredStore
+	policy policy.Policy
+}
+
+// ByID looks up a single object and checks that the policy allows reading it.
+func (p *policyStore) ByID(apiOp *types.APIRequest, schema *types.APISchema, id string) (*unstructured.Unstructured, []types.Warning, error) {
+	obj, warnings, err := p.UnstructuredStore.ByID(apiOp, schema, id)
+	if err != nil || obj == nil {
+		return obj, warnings, err
+	}
+	if err := policy.Check(p.policy, policy.ObjectAttributes(apiOp, schema, "get", obj)); err != nil {
+		return nil, nil, err
+	}
+	return obj, warnings, nil
+}
+
+// List returns the objects of the partition that the policy allows listing.
+func (p *policyStore) List(apiOp *types.APIRequest, schema *types.APISchema) (*unstructured.UnstructuredList, []types.Warning, error) {
+	list, warnings, err := p.UnstructuredStore.List(apiOp, schema)
+	if err != nil || list == nil {
+		return list, warnings, err
+	}
+	list.Items = policy.FilterList(apiOp, schema, p.policy, "list", list.Items)
+	return list, warnings, nil
+}
+
+// Create checks the policy against the submitted object before creating it.
+func (p *policyStore) Create(apiOp *types.APIRequest, schema *types.APISchema, data types.APIObject) (*unstructured.Unstructured, []types.Warning, error) {
+	input := data.Data()
+	attrs := policy.AttributesFor(apiOp, schema, "create", types.Namespace(input), types.Name(input), input)
+	if attrs.Namespace == "" {
+		attrs.Namespace = apiOp.Namespace
+	}
+	if err := policy.Check(p.policy, attrs); err != nil {
+		return nil, nil, err
+	}
+	return p.UnstructuredStore.Create(apiOp, schema, data)
+}
+
+// Update checks the policy against both the live object and the submitted one before updating.
+func (p *policyStore) Update(apiOp *types.APIRequest, schema *types.APISchema, data types.APIObject, id string) (*unstructured.Unstructured, []types.Warning, error) {
+	if err := p.checkLive(apiOp, schema, "update", id); err != nil {
+		return nil, nil, err
+	}
+	if input := data.Data(); len(input) > 0 {
+		attrs := policy.AttributesFor(apiOp, schema, "update", types.Namespace(input), types.Name(input), input)
+		if err := policy.Check(p.policy, attrs); err != nil {
+			return nil, nil, err
+		}
+	}
+	return p.UnstructuredStore.Update(apiOp, schema, data, id)
+}
+
+// Delete checks the policy against the live object before deleting it.
+func (p *policyStore) Delete(apiOp *types.APIRequest, schema *types.APISchema, id string) (*unstructured.Unstructured, []types.Warning, error) {
+	if err := p.checkLive(apiOp, schema, "delete", id); err != nil {
+		return nil, nil, err
+	}
+	return p.UnstructuredStore.Delete(apiOp, schema, id)
+}
+
+// Watch drops events for objects that the policy does not allow watching.
+func (p *policyStore) Watch(apiOp *types.APIRequest, schema *types.APISchema, w types.WatchRequest) (chan watch.Event, error) {
+	c, err := p.UnstructuredStore.Watch(apiOp, schema, w)
+	if err != nil {
+		return nil, err
+	}
+	return policy.FilterWatch(apiOp, schema, p.policy, c), nil
+}
+
+func (p *policyStore) checkLive(apiOp *types.APIRequest, schema *types.APISchema, verb, id string) error {
+	obj, _, err := p.UnstructuredStore.ByID(apiOp, schema, id)
+	if err != nil || obj == nil {
+		// let the actual request report the error
+		return nil
+	}
+	return policy.Check(p.policy, policy.ObjectAttributes(apiOp, schema, verb, obj))
+}
@@ -0,0 +1,146 @@
+package proxy
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/policy"
+	"github.com/rancher/steve/pkg/stores/partition"
+	"github.com/rancher/wrangler/v3/pkg/schemas"
+	"github.com/rancher/wrangler/v3/pkg/schemas/validation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+type fakeUnstructuredStore struct {
+	partition.UnstructuredStore
+
+	objects []*unstructured.Unstructured
+	calls   []string
+}
+
+func (f *fakeUnstructuredStore) ByID(_ *types.APIRequest, _ *types.APISchema, id string) (*unstructured.Unstructured, []types.Warning, error) {
+	for _, obj := range f.objects {
+		if obj.GetNamespace()+"/"+obj.GetName() == id {
+			return obj.DeepCopy(), nil, nil
+		}
+	}
+	return nil, nil, apierror.NewAPIError(validation.NotFound, "not found")
+}
+
+func (f *fakeUnstructuredStore) List(_ *types.APIRequest, _ *types.APISchema) (*unstructured.UnstructuredList, []types.Warning, error) {
+	list := &unstructured.UnstructuredList{}
+	for _, obj := range f.objects {
+		list.Items = append(list.Items, *obj.DeepCopy())
+	}
+	return list, nil, nil
+}
+
+func (f *fakeUnstructuredStore) Create(_ *types.APIRequest, _ *types.APISchema, data types.APIObject) (*unstructured.Unstructured, []types.Warning, error) {
+	f.calls = append(f.calls, "create")
+	return &unstructured.Unstructured{Object: data.Data()}, nil, nil
+}
+
+func (f *fakeUnstructuredStore) Update(_ *types.APIRequest, _ *types.APISchema, data types.APIObject, _ string) (*unstructured.Unstructured, []types.Warning, error) {
+	f.calls = append(f.calls, "update")
+	return &unstructured.Unstructured{Object: data.Data()}, nil, nil
+}
+
+func (f *fakeUnstructuredStore) Delete(_ *types.APIRequest, _ *types.APISchema, id string) (*unstructured.Unstructured, []types.Warning, error) {
+	f.calls = append(f.calls, "delete")
+	return f.ByID(nil, nil, id)
+}
+
+func (f *fakeUnstructuredStore) Watch(_ *types.APIRequest, _ *types.APISchema, _ types.WatchRequest) (chan watch.Event, error) {
+	c := make(chan watch.Event, len(f.objects))
+	for _, obj := range f.objects {
+		c <- watch.Event{Type: watch.Added, Object: obj.DeepCopy()}
+	}
+	close(c)
+	return c, nil
+}
+
+func newPolicyStore() (*policyStore, *fakeUnstructuredStore) {
+	store := &fakeUnstructuredStore{objects: []*unstructured.Unstructured{
+		{Object: map[string]interface{}{"metadata": map[string]interface{}{"name": "public", "namespace": "ns"}}},
+		{Object: map[string]interface{}{"metadata": map[string]interface{}{"name": "secret", "namespace": "ns"}}},
+	}}
+	return &policyStore{
+		UnstructuredStore: store,
+		policy: policy.Func(func(attrs *policy.Attributes) policy.Decision {
+			if attrs.Name == "secret" {
+				return policy.Deny("hidden")
+			}
+			return policy.Allow
+		}),
+	}, store
+}
+
+func requirePermissionDenied(t *testing.T, err error) {
+	t.Helper()
+	var apiErr *apierror.APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusForbidden, apiErr.Code.Status)
+}
+
+func TestPolicyStoreRead(t *testing.T) {
+	p, _ := newPolicyStore()
+	apiOp := &types.APIRequest{}
+	schema := &types.APISchema{Schema: &schemas.Schema{ID: "configmap"}}
+
+	obj, _, err := p.ByID(apiOp, schema, "ns/public")
+	require.NoError(t, err)
+	assert.Equal(t, "public", obj.GetName())
+
+	_, _, err = p.ByID(apiOp, schema, "ns/secret")
+	requirePermissionDenied(t, err)
+
+	list, _, err := p.List(apiOp, schema)
+	require.NoError(t, err)
+	require.Len(t, list.Items, 1)
+	assert.Equal(t, "public", list.Items[0].GetName())
+
+	c, err := p.Watch(apiOp, schema, types.WatchRequest{})
+	require.NoError(t, err)
+	var names []string
+	for event := range c {
+		names = append(names, event.Object.(*unstructured.Unstructured).GetName())
+	}
+	assert.Equal(t, []string{"public"}, names)
+}
+
+func TestPolicyStoreWrite(t *testing.T) {
+	apiOp := &types.APIRequest{Namespace: "ns"}
+	schema := &types.APISchema{Schema: &schemas.Schema{ID: "configmap"}}
+	input := func(name string) types.APIObject {
+		return types.APIObject{Object: map[string]interface{}{"metadata": map[string]interface{}{"name": name}}}
+	}
+
+	p, store := newPolicyStore()
+	_, _, err := p.Create(apiOp, schema, input("secret"))
+	requirePermissionDenied(t, err)
+	_, _, err = p.Update(apiOp, schema, input("public"), "ns/secret")
+	requirePermissionDenied(t, err)
+	_, _, err = p.Update(apiOp, schema, input("secret"), "ns/public")
+	requirePermissionDenied(t, err)
+	_, _, err = p.Delete(apiOp, schema, "ns/secret")
+	requirePermissionDenied(t, err)
+	assert.Empty(t, store.calls)
+
+	_, _, err = p.Create(apiOp, schema, input("public"))
+	require.NoError(t, err)
+	_, _, err = p.Update(apiOp, schema, input("public"), "ns/public")
+	require.NoError(t, err)
+	_, _, err = p.Delete(apiOp, schema, "ns/public")
+	require.NoError(t, err)
+	// a missing object is reported by the store rather than the policy
+	_, _, err = p.Delete(apiOp, schema, "ns/missing")
+	var apiErr *apierror.APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusNotFound, apiErr.Code.Status)
+	assert.Equal(t, []string{"create", "update", "delete", "delete"}, store.calls)
+}
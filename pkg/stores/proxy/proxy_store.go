@@ -34,6 +34,7 @@ import (
 
 	"github.com/rancher/steve/pkg/accesscontrol"
 	"github.com/rancher/steve/pkg/attributes"
+	"github.com/rancher/steve/pkg/policy"
 	metricsStore "github.com/rancher/steve/pkg/stores/metrics"
 	"github.com/rancher/steve/pkg/stores/partition"
 )
@@ -90,8 +91,8 @@ type Store struct {
 	notifier     RelationshipNotifier
 }
 
-// NewProxyStore returns a wrapped types.Store.
-func NewProxyStore(clientGetter ClientGetter, notifier RelationshipNotifier, lookup accesscontrol.AccessSetLookup, namespaceCache corecontrollers.NamespaceCache) types.Store {
+// NewProxyStore returns a wrapped types.Store. pol may be nil if no policy should be applied on top of RBAC.
+func NewProxyStore(clientGetter ClientGetter, notifier RelationshipNotifier, lookup accesscontrol.AccessSetLookup, namespaceCache corecontrollers.NamespaceCache, pol policy.Policy) types.Store {
 	return &ErrorStore{
 		Store: &unformatterStore{
 			Store: &WatchRefresh{
@@ -101,6 +102,7 @@ func NewProxyStore(clientGetter ClientGetter, notifier RelationshipNotifier, loo
 							clientGetter: clientGetter,
 							notifier:     notifier,
 						},
+						policy: pol,
 					},
 					lookup,
 					namespaceCache,
@@ -459,6 +461,9 @@ func (s *Store) Create(apiOp *types.APIRequest, schema *types.APISchema, params
 
 	resp, err = k8sClient.Create(apiOp, &unstructured.Unstructured{Object: input}, opts)
 	rowToObject(resp)
+	if err == nil {
+		AddDryRunWarning(&buffer, opts.DryRun)
+	}
 	return resp, buffer, err
 }
 
@@ -482,17 +487,15 @@ func (s *Store) Update(apiOp *types.APIRequest, schema *types.APISchema, params
 			return nil, nil, err
 		}
 
-		pType := apitypes.StrategicMergePatchType
-		if apiOp.Request.Header.Get("content-type") == string(apitypes.JSONPatchType) {
-			pType = apitypes.JSONPatchType
-		}
+		pType := PatchType(apiOp.Request)
 
+		// fieldManager and force are passed through for server-side apply
 		opts := metav1.PatchOptions{}
 		if err := decodeParams(apiOp, &opts); err != nil {
 			return nil, nil, err
 		}
 
-		if pType == apitypes.StrategicMergePatchType {
+		if pType == apitypes.StrategicMergePatchType || pType == apitypes.MergePatchType {
 			data := map[string]interface{}{}
 			if err := json.Unmarshal(bytes, &data); err != nil {
 				return nil, nil, err
@@ -509,6 +512,7 @@ func (s *Store) Update(apiOp *types.APIRequest, schema *types.APISchema, params
 			return nil, nil, err
 		}
 
+		AddDryRunWarning(&buffer, opts.DryRun)
 		return resp, buffer, nil
 	}
 
@@ -525,20 +529,22 @@ func (s *Store) Update(apiOp *types.APIRequest, schema *types.APISchema, params
 		return nil, nil, err
 	}
 
-	resp, err := k8sClient.Update(apiOp, &unstructured.Unstructured{Object: moveFromUnderscore(input)}, metav1.UpdateOptions{})
+	resp, err := k8sClient.Update(apiOp, &unstructured.Unstructured{Object: moveFromUnderscore(input)}, opts)
 	if err != nil {
 		return nil, nil, err
 	}
 
 	rowToObject(resp)
+	AddDryRunWarning(&buffer, opts.DryRun)
 	return resp, buffer, nil
 }
 
 // Delete deletes an object from a store.
 func (s *Store) Delete(apiOp *types.APIRequest, schema *types.APISchema, id string) (*unstructured.Unstructured, []types.Warning, error) {
+	// dryRun, propagationPolicy and gracePeriodSeconds are passed through
 	opts := metav1.DeleteOptions{}
 	if err := decodeParams(apiOp, &opts); err != nil {
-		return nil, nil, nil
+		return nil, nil, err
 	}
 
 	buffer := WarningBuffer{}
@@ -550,6 +556,7 @@ func (s *Store) Delete(apiOp *types.APIRequest, schema *types.APISchema, id stri
 	if err := k8sClient.Delete(apiOp, id, opts); err != nil {
 		return nil, nil, err
 	}
+	AddDryRunWarning(&buffer, opts.DryRun)
 
 	obj, _, err := s.byID(apiOp, schema, apiOp.Namespace, id)
 	if err != nil {
@@ -2,6 +2,7 @@ package proxy
 
 import (
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"strings"
 	"testing"
@@ -20,11 +21,13 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	apitypes "k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/dynamic/fake"
 	"k8s.io/client-go/rest"
 	clientgotesting "k8s.io/client-go/testing"
+	"k8s.io/utils/ptr"
 
 	"github.com/rancher/apiserver/pkg/apierror"
 	"github.com/rancher/apiserver/pkg/types"
@@ -842,3 +845,157 @@ func TestUpdate(t *testing.T) {
 		})
 	}
 }
+
+func TestUpdatePatch(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantType    apitypes.PatchType
+		wantPatch   string
+	}{
+		{
+			name:      "strategic merge patch by default",
+			body:      `{"metadata":{"labels":{"a":"b"}},"_type":"Opaque"}`,
+			wantType:  apitypes.StrategicMergePatchType,
+			wantPatch: `{"metadata":{"labels":{"a":"b"}},"type":"Opaque"}`,
+		},
+		{
+			name:        "json patch",
+			contentType: "application/json-patch+json",
+			body:        `[{"op":"remove","path":"/metadata/labels/a"}]`,
+			wantType:    apitypes.JSONPatchType,
+			wantPatch:   `[{"op":"remove","path":"/metadata/labels/a"}]`,
+		},
+		{
+			name:        "merge patch",
+			contentType: "application/merge-patch+json; charset=utf-8",
+			body:        `{"spec":{"list":["a"]},"_type":"Opaque"}`,
+			wantType:    apitypes.MergePatchType,
+			wantPatch:   `{"spec":{"list":["a"]},"type":"Opaque"}`,
+		},
+		{
+			name:        "server-side apply",
+			contentType: "application/apply-patch+yaml",
+			body:        "apiVersion: v1\nkind: Secret\nmetadata:\n  name: testing-secret\n",
+			wantType:    apitypes.ApplyPatchType,
+			wantPatch:   "apiVersion: v1\nkind: Secret\nmetadata:\n  name: testing-secret\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testClientFactory, err := client.NewFactory(&rest.Config{}, false)
+			assert.NoError(t, err)
+
+			var action clientgotesting.PatchAction
+			fakeClient := fake.NewSimpleDynamicClient(runtime.NewScheme())
+			fakeClient.PrependReactor("patch", "*", func(a clientgotesting.Action) (bool, runtime.Object, error) {
+				action = a.(clientgotesting.PatchAction)
+				return true, &unstructured.Unstructured{Object: map[string]interface{}{"kind": "Secret"}}, nil
+			})
+			testStore := Store{
+				clientGetter: &testFactory{Factory: testClientFactory, fakeClient: fakeClient},
+			}
+
+			req := httptest.NewRequest(http.MethodPatch, "/v1/secrets/testing-ns/testing-secret?fieldManager=steve&force=true", strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			schema := &types.APISchema{Schema: &schemas.Schema{ID: "secret", Attributes: map[string]interface{}{"kind": "Secret", "version": "v1", "namespaced": true}}}
+			apiOp := &types.APIRequest{Request: req, Method: http.MethodPatch, Namespace: "testing-ns", Schema: schema}
+
+			_, _, err = testStore.Update(apiOp, schema, types.APIObject{}, "testing-secret")
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantType, action.GetPatchType())
+			assert.Equal(t, tt.wantPatch, string(action.GetPatch()))
+
+			opts := metav1.PatchOptions{}
+			assert.NoError(t, decodeParams(apiOp, &opts))
+			assert.Equal(t, "steve", opts.FieldManager)
+			assert.Equal(t, ptr.To(true), opts.Force)
+		})
+	}
+}
+
+func TestTranslateErrorApplyConflict(t *testing.T) {
+	err := apierrors.NewApplyConflict([]metav1.StatusCause{
+		{Type: metav1.CauseTypeFieldManagerConflict, Message: `conflict with "kubectl" using apps/v1`, Field: ".spec.replicas"},
+		{Type: metav1.CauseTypeFieldManagerConflict, Message: `conflict with "helm" using apps/v1`, Field: ".metadata.labels.app"},
+		{Type: metav1.CauseTypeFieldManagerConflict, Message: `conflict with "kubectl" using apps/v1`, Field: ".spec.paused"},
+	}, "Apply failed with 3 conflicts")
+
+	apiError, ok := TranslateError(err).(*apierror.APIError)
+	assert.True(t, ok)
+	assert.Equal(t, validation.ErrorCode{Code: "Conflict", Status: http.StatusConflict}, apiError.Code)
+	assert.Equal(t, "Apply failed with 3 conflicts (conflicting field managers: kubectl, helm, use force=true to take ownership of the fields)", apiError.Message)
+
+	apiError, ok = TranslateError(apierrors.NewConflict(schema2.GroupResource{Resource: "secrets"}, "a", errors.New("the object has been modified"))).(*apierror.APIError)
+	assert.True(t, ok)
+	assert.Equal(t, http.StatusConflict, apiError.Code.Status)
+	assert.NotContains(t, apiError.Message, "field managers")
+}
+
+func TestDryRun(t *testing.T) {
+	testClientFactory, err := client.NewFactory(&rest.Config{}, false)
+	assert.NoError(t, err)
+
+	secret := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata": map[string]interface{}{
+			"name":      "testing-secret",
+			"namespace": "testing-ns",
+		},
+	}}
+	fakeClient := fake.NewSimpleDynamicClient(runtime.NewScheme())
+	fakeClient.PrependReactor("get", "*", func(a clientgotesting.Action) (bool, runtime.Object, error) {
+		return true, secret.DeepCopy(), nil
+	})
+	var deleteOptions metav1.DeleteOptions
+	fakeClient.PrependReactor("delete", "*", func(a clientgotesting.Action) (bool, runtime.Object, error) {
+		deleteOptions = a.(clientgotesting.DeleteActionImpl).DeleteOptions
+		return true, nil, nil
+	})
+	testStore := Store{
+		clientGetter: &testFactory{Factory: testClientFactory, fakeClient: fakeClient},
+	}
+	schema := &types.APISchema{Schema: &schemas.Schema{ID: "secret", Attributes: map[string]interface{}{"kind": "Secret", "version": "v1", "namespaced": true}}}
+	wantWarnings := []types.Warning{{Code: 299, Agent: "-", Text: DryRunWarning}}
+
+	req := httptest.NewRequest(http.MethodDelete, "/v1/secrets/testing-ns/testing-secret?dryRun=All&propagationPolicy=Foreground&gracePeriodSeconds=5", nil)
+	apiOp := &types.APIRequest{Request: req, Method: http.MethodDelete, Namespace: "testing-ns", Schema: schema}
+	obj, warnings, err := testStore.Delete(apiOp, schema, "testing-secret")
+	assert.NoError(t, err)
+	assert.Equal(t, "testing-secret", obj.GetName())
+	assert.Equal(t, wantWarnings, warnings)
+	assert.Equal(t, metav1.DeleteOptions{
+		DryRun:             []string{metav1.DryRunAll},
+		PropagationPolicy:  ptr.To(metav1.DeletePropagationForeground),
+		GracePeriodSeconds: ptr.To[int64](5),
+	}, deleteOptions)
+
+	req = httptest.NewRequest(http.MethodDelete, "/v1/secrets/testing-ns/testing-secret?gracePeriodSeconds=soon", nil)
+	apiOp = &types.APIRequest{Request: req, Method: http.MethodDelete, Namespace: "testing-ns", Schema: schema}
+	_, _, err = testStore.Delete(apiOp, schema, "testing-secret")
+	assert.Error(t, err)
+
+	req = httptest.NewRequest(http.MethodPost, "/v1/secrets?dryRun=All", nil)
+	apiOp = &types.APIRequest{Request: req, Method: http.MethodPost, Namespace: "testing-ns", Schema: schema}
+	_, warnings, err = testStore.Create(apiOp, schema, types.APIObject{Object: map[string]interface{}{
+		"metadata": map[string]interface{}{"name": "other-secret"},
+	}})
+	assert.NoError(t, err)
+	assert.Equal(t, wantWarnings, warnings)
+
+	opts := metav1.CreateOptions{}
+	assert.NoError(t, decodeParams(apiOp, &opts))
+	assert.Equal(t, []string{metav1.DryRunAll}, opts.DryRun)
+
+	req = httptest.NewRequest(http.MethodPost, "/v1/secrets", nil)
+	apiOp = &types.APIRequest{Request: req, Method: http.MethodPost, Namespace: "testing-ns", Schema: schema}
+	_, warnings, err = testStore.Create(apiOp, schema, types.APIObject{Object: map[string]interface{}{
+		"metadata": map[string]interface{}{"name": "third-secret"},
+	}})
+	assert.NoError(t, err)
+	assert.Empty(t, warnings)
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/rancher/apiserver/pkg/types"
 	"github.com/rancher/steve/pkg/accesscontrol"
 	"github.com/rancher/steve/pkg/attributes"
+	"github.com/rancher/steve/pkg/policy"
 	"github.com/rancher/steve/pkg/stores/partition"
 	"github.com/rancher/wrangler/v3/pkg/kv"
 	"github.com/sirupsen/logrus"
@@ -37,6 +38,7 @@ func (p Partition) Name() string {
 // rbacPartitioner is an implementation of the partition.Partioner interface.
 type rbacPartitioner struct {
 	proxyStore *Store
+	policy     policy.Policy
 }
 
 // Lookup returns the default passthrough partition which is used only for retrieving single resources.
@@ -82,10 +84,18 @@ func (p *rbacPartitioner) All(apiOp *types.APIRequest, schema *types.APISchema,
 }
 
 // Store returns an UnstructuredStore suited to listing and watching resources by partition.
+// If a policy is configured, the store additionally drops or refuses objects denied by it.
 func (p *rbacPartitioner) Store(apiOp *types.APIRequest, partition partition.Partition) (partition.UnstructuredStore, error) {
-	return &byNameOrNamespaceStore{
+	store := &byNameOrNamespaceStore{
 		Store:     p.proxyStore,
 		partition: partition.(Partition),
+	}
+	if p.policy == nil {
+		return store, nil
+	}
+	return &policyStore{
+		UnstructuredStore: store,
+		policy:            p.policy,
 	}, nil
 }
 
@@ -2,6 +2,7 @@ package proxy
 
 import (
 	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/stores/partition"
 	"github.com/rancher/wrangler/v3/pkg/data"
 	"github.com/rancher/wrangler/v3/pkg/data/convert"
 )
@@ -26,6 +27,11 @@ func (u *unformatterStore) List(apiOp *types.APIRequest, schema *types.APISchema
 	return u.Store.List(apiOp, schema)
 }
 
+// ListStream returns a list of resources as they are listed, if the underlying store supports it.
+func (u *unformatterStore) ListStream(apiOp *types.APIRequest, schema *types.APISchema) (*partition.ListStream, error) {
+	return partition.StreamList(u.Store, apiOp, schema)
+}
+
 // Create creates a single object in the store.
 func (u *unformatterStore) Create(apiOp *types.APIRequest, schema *types.APISchema, data types.APIObject) (types.APIObject, error) {
 	return u.Store.Create(apiOp, schema, data)
@@ -6,6 +6,7 @@ import (
 
 	"github.com/rancher/apiserver/pkg/types"
 	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/rancher/steve/pkg/stores/partition"
 	"k8s.io/apiserver/pkg/endpoints/request"
 )
 
@@ -23,6 +24,11 @@ func NewWatchRefresh(s types.Store, asl accesscontrol.AccessSetLookup) *WatchRef
 	}
 }
 
+// ListStream returns a list of resources as they are listed, if the underlying store supports it.
+func (w *WatchRefresh) ListStream(apiOp *types.APIRequest, schema *types.APISchema) (*partition.ListStream, error) {
+	return partition.StreamList(w.Store, apiOp, schema)
+}
+
 // Watch performs a watch request which halts if the user's access level changes.
 func (w *WatchRefresh) Watch(apiOp *types.APIRequest, schema *types.APISchema, wr types.WatchRequest) (chan types.APIEvent, error) {
 	user, ok := request.UserFrom(apiOp.Context())
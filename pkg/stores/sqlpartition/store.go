@@ -5,11 +5,21 @@ package sqlpartition
 
 import (
 	"context"
+	"strconv"
 
 	"github.com/rancher/apiserver/pkg/types"
 	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/rancher/steve/pkg/attributes"
+	"github.com/rancher/steve/pkg/etag"
+	"github.com/rancher/steve/pkg/policy"
 	cachepartition "github.com/rancher/steve/pkg/sqlcache/partition"
 	"github.com/rancher/steve/pkg/stores/partition"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	pageSizeParam = "pagesize"
+	pageParam     = "page"
 )
 
 // Partitioner is an interface for interacting with partitions.
@@ -27,15 +37,19 @@ type Store struct {
 	Partitioner       Partitioner
 	asl               accesscontrol.AccessSetLookup
 	sqlReservedFields map[string]bool
+	// policy is evaluated after the AccessSet based partitioning, it may be nil
+	policy policy.Policy
 }
 
-// NewStore creates a types.proxyStore implementation with a partitioner
-func NewStore(store UnstructuredStore, asl accesscontrol.AccessSetLookup) *Store {
+// NewStore creates a types.proxyStore implementation with a partitioner. pol may be nil if no policy
+// should be applied on top of RBAC.
+func NewStore(store UnstructuredStore, asl accesscontrol.AccessSetLookup, pol policy.Policy) *Store {
 	s := &Store{
 		Partitioner: &rbacPartitioner{
 			proxyStore: store,
 		},
-		asl: asl,
+		asl:    asl,
+		policy: pol,
 	}
 	sqlReservedFields := map[string]bool{}
 	for key, value := range types.ReservedFields {
@@ -53,6 +67,10 @@ func NewStore(store UnstructuredStore, asl accesscontrol.AccessSetLookup) *Store
 func (s *Store) Delete(apiOp *types.APIRequest, schema *types.APISchema, id string) (types.APIObject, error) {
 	target := s.Partitioner.Store()
 
+	if err := s.checkLive(apiOp, schema, target, "delete", id); err != nil {
+		return types.APIObject{}, err
+	}
+
 	obj, warnings, err := target.Delete(apiOp, schema, id)
 	if err != nil {
 		return types.APIObject{}, err
@@ -68,28 +86,78 @@ func (s *Store) ByID(apiOp *types.APIRequest, schema *types.APISchema, id string
 	if err != nil {
 		return types.APIObject{}, err
 	}
+	if obj != nil {
+		if err := policy.Check(s.policy, policy.ObjectAttributes(apiOp, schema, "get", obj)); err != nil {
+			return types.APIObject{}, err
+		}
+	}
 	return partition.ToAPI(schema, obj, warnings, types.ReservedFields), nil
 }
 
 // List returns a list of objects across all applicable partitions.
 // If pagination parameters are used, it returns a segment of the list.
 func (s *Store) List(apiOp *types.APIRequest, schema *types.APISchema) (types.APIObjectList, error) {
-	var (
-		result types.APIObjectList
-	)
-
 	partitions, err := s.Partitioner.All(apiOp, schema, "list", "")
 	if err != nil {
-		return result, err
+		return types.APIObjectList{}, err
 	}
 
 	store := s.Partitioner.Store()
 
+	if policy.AppliesTo(s.policy, attributes.GVR(schema), "list") {
+		return s.listVisible(apiOp, schema, store, partitions)
+	}
+
 	list, total, continueToken, err := store.ListByPartitions(apiOp, schema, partitions)
 	if err != nil {
-		return result, err
+		return types.APIObjectList{}, err
+	}
+	return s.toAPIList(schema, list, total, continueToken), nil
+}
+
+// listVisible lists the objects of the partitions which the policy allows. It is only used for the types the policy
+// applies to, as policies can't be evaluated by the SQL cache: every object matching the query is fetched and the page is cut once the denied ones are dropped, for the
+// count and continue token to only account for the objects the user can see.
+func (s *Store) listVisible(apiOp *types.APIRequest, schema *types.APISchema, store UnstructuredStore, partitions []cachepartition.Partition) (types.APIObjectList, error) {
+	pageSize, page := 0, 1
+	unpaged := apiOp
+	if apiOp.Request != nil {
+		query := apiOp.Request.URL.Query()
+		pageSize, _ = strconv.Atoi(query.Get(pageSizeParam))
+		if n, err := strconv.Atoi(query.Get(pageParam)); err == nil && n > 1 {
+			page = n
+		}
+		query.Del(pageSizeParam)
+		query.Del(pageParam)
+		unpaged = apiOp.Clone()
+		// conditional requests are answered for the page the user asked for
+		unpaged.Request = apiOp.Request.Clone(etag.WithTaggedRequest(apiOp.Context(), apiOp.Request))
+		unpaged.Request.URL.RawQuery = query.Encode()
+		unpaged.Query = query
 	}
 
+	list, _, _, err := store.ListByPartitions(unpaged, schema, partitions)
+	if err != nil {
+		return types.APIObjectList{}, err
+	}
+	list.Items = policy.FilterList(apiOp, schema, s.policy, "list", list.Items)
+
+	total := len(list.Items)
+	continueToken := ""
+	if pageSize > 0 {
+		start := min(pageSize*(page-1), total)
+		end := min(start+pageSize, total)
+		list.Items = list.Items[start:end]
+		if end < total {
+			// same token as the SQL cache, the offset of the next page
+			continueToken = strconv.Itoa(end)
+		}
+	}
+	return s.toAPIList(schema, list, total, continueToken), nil
+}
+
+func (s *Store) toAPIList(schema *types.APISchema, list *unstructured.UnstructuredList, total int, continueToken string) types.APIObjectList {
+	var result types.APIObjectList
 	result.Count = total
 
 	for _, item := range list.Items {
@@ -98,16 +166,24 @@ func (s *Store) List(apiOp *types.APIRequest, schema *types.APISchema) (types.AP
 		result.Objects = append(result.Objects, partition.ToAPI(schema, item, nil, s.sqlReservedFields))
 	}
 
-	result.Revision = ""
 	result.Continue = continueToken
 	result.Revision = list.GetResourceVersion()
-	return result, nil
+	return result
 }
 
 // Create creates a single object in the store.
 func (s *Store) Create(apiOp *types.APIRequest, schema *types.APISchema, data types.APIObject) (types.APIObject, error) {
 	target := s.Partitioner.Store()
 
+	input := data.Data()
+	attrs := policy.AttributesFor(apiOp, schema, "create", types.Namespace(input), types.Name(input), input)
+	if attrs.Namespace == "" {
+		attrs.Namespace = apiOp.Namespace
+	}
+	if err := policy.Check(s.policy, attrs); err != nil {
+		return types.APIObject{}, err
+	}
+
 	obj, warnings, err := target.Create(apiOp, schema, data)
 	if err != nil {
 		return types.APIObject{}, err
@@ -119,6 +195,16 @@ func (s *Store) Create(apiOp *types.APIRequest, schema *types.APISchema, data ty
 func (s *Store) Update(apiOp *types.APIRequest, schema *types.APISchema, data types.APIObject, id string) (types.APIObject, error) {
 	target := s.Partitioner.Store()
 
+	if err := s.checkLive(apiOp, schema, target, "update", id); err != nil {
+		return types.APIObject{}, err
+	}
+	if input := data.Data(); len(input) > 0 {
+		attrs := policy.AttributesFor(apiOp, schema, "update", types.Namespace(input), types.Name(input), input)
+		if err := policy.Check(s.policy, attrs); err != nil {
+			return types.APIObject{}, err
+		}
+	}
+
 	obj, warnings, err := target.Update(apiOp, schema, data, id)
 	if err != nil {
 		return types.APIObject{}, err
@@ -140,6 +226,7 @@ func (s *Store) Watch(apiOp *types.APIRequest, schema *types.APISchema, wr types
 	if err != nil {
 		return nil, err
 	}
+	c = policy.FilterWatch(apiOp, schema, s.policy, c)
 
 	go func() {
 		defer close(response)
@@ -151,3 +238,16 @@ func (s *Store) Watch(apiOp *types.APIRequest, schema *types.APISchema, wr types
 
 	return response, nil
 }
+
+// checkLive evaluates the policy against the current state of the object before it is changed.
+// Lookup errors are ignored so that the actual request reports them.
+func (s *Store) checkLive(apiOp *types.APIRequest, schema *types.APISchema, target UnstructuredStore, verb, id string) error {
+	if s.policy == nil {
+		return nil
+	}
+	obj, _, err := target.ByID(apiOp, schema, id)
+	if err != nil || obj == nil {
+		return nil
+	}
+	return policy.Check(s.policy, policy.ObjectAttributes(apiOp, schema, verb, obj))
+}
@@ -12,10 +12,14 @@ import (
 
 	"github.com/rancher/wrangler/v3/pkg/schemas"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/mock/gomock"
 
 	"github.com/rancher/apiserver/pkg/types"
 	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/rancher/steve/pkg/attributes"
+	"github.com/rancher/steve/pkg/etag"
+	"github.com/rancher/steve/pkg/policy"
 	"github.com/rancher/steve/pkg/sqlcache/partition"
 	"github.com/rancher/steve/pkg/stores/sqlproxy"
 	"github.com/rancher/wrangler/v3/pkg/generic"
@@ -23,6 +27,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/labels"
+	k8sschema "k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/apiserver/pkg/authentication/user"
 	"k8s.io/apiserver/pkg/endpoints/request"
@@ -96,6 +101,146 @@ func TestList(t *testing.T) {
 			assert.Equal(t, expectedAPIObjList, l)
 		},
 	})
+	tests = append(tests, testCase{
+		description: "List() with a policy should drop denied objects and adjust the count.",
+		test: func(t *testing.T) {
+			p := NewMockPartitioner(gomock.NewController(t))
+			us := NewMockUnstructuredStore(gomock.NewController(t))
+			s := Store{
+				Partitioner: p,
+				policy: policy.Func(func(attrs *policy.Attributes) policy.Decision {
+					if attrs.Name == "fuji" {
+						return policy.Deny("no apples")
+					}
+					return policy.Allow
+				}),
+			}
+			req := &types.APIRequest{}
+			schema := &types.APISchema{
+				Schema: &schemas.Schema{},
+			}
+			partitions := make([]partition.Partition, 0)
+			uListToReturn := &unstructured.UnstructuredList{
+				Items: []unstructured.Unstructured{
+					{
+						Object: map[string]interface{}{
+							"metadata": map[string]interface{}{
+								"name":      "fuji",
+								"namespace": "fruitsnamespace",
+							},
+						},
+					},
+					{
+						Object: map[string]interface{}{
+							"metadata": map[string]interface{}{
+								"name":      "bosc",
+								"namespace": "fruitsnamespace",
+							},
+						},
+					},
+				},
+			}
+			p.EXPECT().All(req, schema, "list", "").Return(partitions, nil)
+			p.EXPECT().Store().Return(us)
+			us.EXPECT().ListByPartitions(req, schema, partitions).Return(uListToReturn, 2, "", nil)
+			l, err := s.List(req, schema)
+			assert.Nil(t, err)
+			assert.Equal(t, 1, l.Count)
+			assert.Len(t, l.Objects, 1)
+			assert.Equal(t, "fruitsnamespace/bosc", l.Objects[0].ID)
+		},
+	})
+	tests = append(tests, testCase{
+		description: "List() with a policy should paginate and count the allowed objects only.",
+		test: func(t *testing.T) {
+			p := NewMockPartitioner(gomock.NewController(t))
+			us := NewMockUnstructuredStore(gomock.NewController(t))
+			s := Store{
+				Partitioner: p,
+				policy: policy.Func(func(attrs *policy.Attributes) policy.Decision {
+					if attrs.Name == "fuji" {
+						return policy.Deny("no apples")
+					}
+					return policy.Allow
+				}),
+			}
+			schema := &types.APISchema{
+				Schema: &schemas.Schema{},
+			}
+			partitions := make([]partition.Partition, 0)
+			var items []unstructured.Unstructured
+			for _, name := range []string{"bosc", "fuji", "anjou", "comice"} {
+				items = append(items, unstructured.Unstructured{
+					Object: map[string]interface{}{
+						"metadata": map[string]interface{}{
+							"name":      name,
+							"namespace": "fruitsnamespace",
+						},
+					},
+				})
+			}
+			newReq := func(query string) *types.APIRequest {
+				return &types.APIRequest{Request: &http.Request{URL: &url.URL{RawQuery: query}}}
+			}
+			var current *types.APIRequest
+			p.EXPECT().All(gomock.Any(), schema, "list", "").Return(partitions, nil).Times(2)
+			p.EXPECT().Store().Return(us).Times(2)
+			us.EXPECT().ListByPartitions(gomock.Any(), schema, partitions).DoAndReturn(
+				func(apiOp *types.APIRequest, _ *types.APISchema, _ []partition.Partition) (*unstructured.UnstructuredList, int, string, error) {
+					// the whole list is fetched before the page is cut, but tagged for the page
+					assert.Equal(t, url.Values{"filter": {"metadata.namespace=fruitsnamespace"}}, apiOp.Request.URL.Query())
+					assert.Equal(t, etag.For(current, "1"), etag.For(apiOp, "1"))
+					list := &unstructured.UnstructuredList{}
+					for _, item := range items {
+						list.Items = append(list.Items, *item.DeepCopy())
+					}
+					return list, len(list.Items), "", nil
+				}).Times(2)
+
+			current = newReq("filter=metadata.namespace%3Dfruitsnamespace&pagesize=2")
+			l, err := s.List(current, schema)
+			assert.Nil(t, err)
+			assert.Equal(t, 3, l.Count)
+			assert.Equal(t, "2", l.Continue)
+			assert.Len(t, l.Objects, 2)
+			assert.Equal(t, "fruitsnamespace/bosc", l.Objects[0].ID)
+			assert.Equal(t, "fruitsnamespace/anjou", l.Objects[1].ID)
+
+			current = newReq("filter=metadata.namespace%3Dfruitsnamespace&pagesize=2&page=2")
+			l, err = s.List(current, schema)
+			assert.Nil(t, err)
+			assert.Equal(t, 3, l.Count)
+			assert.Equal(t, "", l.Continue)
+			assert.Len(t, l.Objects, 1)
+			assert.Equal(t, "fruitsnamespace/comice", l.Objects[0].ID)
+		},
+	})
+	tests = append(tests, testCase{
+		description: "List() with a policy which doesn't apply to the type should leave the pagination to the SQL cache.",
+		test: func(t *testing.T) {
+			p := NewMockPartitioner(gomock.NewController(t))
+			us := NewMockUnstructuredStore(gomock.NewController(t))
+			celPolicy, err := policy.NewCELPolicy([]policy.Rule{{Name: "secrets", Resources: []string{"secrets"}, Expression: "true"}}, nil)
+			require.NoError(t, err)
+			s := Store{
+				Partitioner: p,
+				policy:      celPolicy,
+			}
+			req := &types.APIRequest{Request: &http.Request{URL: &url.URL{RawQuery: "pagesize=1"}}}
+			schema := &types.APISchema{
+				Schema: &schemas.Schema{},
+			}
+			attributes.SetGVR(schema, k8sschema.GroupVersionResource{Version: "v1", Resource: "configmaps"})
+			partitions := make([]partition.Partition, 0)
+			p.EXPECT().All(req, schema, "list", "").Return(partitions, nil)
+			p.EXPECT().Store().Return(us)
+			us.EXPECT().ListByPartitions(req, schema, partitions).Return(&unstructured.UnstructuredList{}, 5, "1", nil)
+			l, err := s.List(req, schema)
+			assert.Nil(t, err)
+			assert.Equal(t, 5, l.Count)
+			assert.Equal(t, "1", l.Continue)
+		},
+	})
 	tests = append(tests, testCase{
 		description: "List() with partitioner All() error returned should returned an error.",
 		test: func(t *testing.T) {
@@ -281,6 +281,20 @@ func (mr *MockCacheFactoryMockRecorder) CacheFor(ctx, fields, externalUpdateInfo
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CacheFor", reflect.TypeOf((*MockCacheFactory)(nil).CacheFor), ctx, fields, externalUpdateInfo, selfUpdateInfo, transform, client, gvk, typeGuidance, namespaced, watchable)
 }
 
+// CachedGVKs mocks base method.
+func (m *MockCacheFactory) CachedGVKs() []schema.GroupVersionKind {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CachedGVKs")
+	ret0, _ := ret[0].([]schema.GroupVersionKind)
+	return ret0
+}
+
+// CachedGVKs indicates an expected call of CachedGVKs.
+func (mr *MockCacheFactoryMockRecorder) CachedGVKs() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CachedGVKs", reflect.TypeOf((*MockCacheFactory)(nil).CachedGVKs))
+}
+
 // DoneWithCache mocks base method.
 func (m *MockCacheFactory) DoneWithCache(arg0 *factory.Cache) {
 	m.ctrl.T.Helper()
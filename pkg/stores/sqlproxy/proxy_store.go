@@ -10,6 +10,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"regexp"
+	"slices"
 	"strings"
 	"sync"
 
@@ -17,11 +18,13 @@ import (
 	"github.com/rancher/apiserver/pkg/apierror"
 	"github.com/rancher/apiserver/pkg/types"
 	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/rancher/steve/pkg/etag"
 	"github.com/rancher/steve/pkg/schema/table"
 	"github.com/rancher/steve/pkg/sqlcache/informer"
 	"github.com/rancher/steve/pkg/sqlcache/informer/factory"
 	"github.com/rancher/steve/pkg/sqlcache/partition"
 	"github.com/rancher/steve/pkg/sqlcache/sqltypes"
+	"github.com/rancher/steve/pkg/stores/proxy"
 	"github.com/rancher/steve/pkg/stores/queryhelper"
 	"github.com/rancher/wrangler/v3/pkg/data"
 	"github.com/rancher/wrangler/v3/pkg/kv"
@@ -36,6 +39,7 @@ import (
 	"github.com/rancher/steve/pkg/resources/common"
 	"github.com/rancher/steve/pkg/resources/virtual"
 	virtualCommon "github.com/rancher/steve/pkg/resources/virtual/common"
+	"github.com/rancher/steve/pkg/resources/virtual/usage"
 	metricsStore "github.com/rancher/steve/pkg/stores/metrics"
 	"github.com/rancher/steve/pkg/stores/sqlpartition/listprocessor"
 	"github.com/rancher/steve/pkg/stores/sqlproxy/tablelistconvert"
@@ -60,6 +64,8 @@ const (
 	watchTimeoutEnv            = "CATTLE_WATCH_TIMEOUT_SECONDS"
 	errNamespaceRequired       = "metadata.namespace or apiOp.namespace are required"
 	errResourceVersionRequired = "metadata.resourceVersion is required for update"
+	// usageBatchSize is the number of objects whose usage is refreshed in one transaction
+	usageBatchSize = 500
 )
 
 var (
@@ -68,8 +74,11 @@ var (
 	// Please keep the gvkKey entries in alphabetical order, on a field-by-field basis
 	typeSpecificIndexedFields = map[string][][]string{
 		gvkKey("", "v1", "Event"): {
+			{"_lastTimestamp"},
 			{"_type"},
 			{"involvedObject", "kind"},
+			{"involvedObject", "name"},
+			{"involvedObject", "namespace"},
 			{"involvedObject", "uid"},
 			{"message"},
 			{"reason"},
@@ -79,7 +88,9 @@ var (
 		},
 		gvkKey("", "v1", "Node"): {
 			{"status", "nodeInfo", "kubeletVersion"},
-			{"status", "nodeInfo", "operatingSystem"}},
+			{"status", "nodeInfo", "operatingSystem"},
+			{"status", "usage", "cpuRaw"},
+			{"status", "usage", "memoryRaw"}},
 		gvkKey("", "v1", "PersistentVolume"): {
 			{"status", "reason"},
 			{"spec", "persistentVolumeReclaimPolicy"},
@@ -90,6 +101,8 @@ var (
 			{"spec", "containers", "image"},
 			{"spec", "nodeName"},
 			{"status", "podIP"},
+			{"status", "usage", "cpuRaw"},
+			{"status", "usage", "memoryRaw"},
 		},
 		gvkKey("", "v1", "ReplicationController"): {
 			{"spec", "template", "spec", "containers", "image"}},
@@ -354,6 +367,11 @@ type Store struct {
 	columnSetter     SchemaColumnSetter
 	transformBuilder TransformBuilder
 
+	usageCache *usage.Cache
+	// usageLock protects usageInformers, the latest caches of the types which have a usage
+	usageLock      sync.Mutex
+	usageInformers map[schema.GroupVersionKind]*factory.Cache
+
 	watchers *Watchers
 }
 
@@ -363,18 +381,26 @@ type CacheFactory interface {
 	CacheFor(ctx context.Context, fields [][]string, externalUpdateInfo *sqltypes.ExternalGVKUpdates, selfUpdateInfo *sqltypes.ExternalGVKUpdates, transform cache.TransformFunc, client dynamic.ResourceInterface, gvk schema.GroupVersionKind, typeGuidance map[string]string, namespaced bool, watchable bool) (*factory.Cache, error)
 	DoneWithCache(*factory.Cache)
 	Stop(gvk schema.GroupVersionKind) error
+	CachedGVKs() []schema.GroupVersionKind
 }
 
 // NewProxyStore returns a Store implemented directly on top of kubernetes.
-func NewProxyStore(ctx context.Context, c SchemaColumnSetter, clientGetter ClientGetter, notifier RelationshipNotifier, scache virtualCommon.SummaryCache, factory CacheFactory, needToInitNamespaceCache bool) (*Store, error) {
+//
+// If usageCache isn't nil, pods and nodes get their resource usage from it, and their indexed usage fields are kept
+// up to date as it changes.
+func NewProxyStore(ctx context.Context, c SchemaColumnSetter, clientGetter ClientGetter, notifier RelationshipNotifier, scache virtualCommon.SummaryCache, usageCache *usage.Cache, factory CacheFactory, needToInitNamespaceCache bool) (*Store, error) {
 	store := &Store{
 		ctx:              ctx,
 		clientGetter:     clientGetter,
 		notifier:         notifier,
 		columnSetter:     c,
-		transformBuilder: virtual.NewTransformBuilder(scache),
+		transformBuilder: virtual.NewTransformBuilder(scache, usageCache),
+		usageCache:       usageCache,
 		watchers:         newWatchers(),
 	}
+	if usageCache != nil {
+		usageCache.OnChange(store.refreshUsage)
+	}
 
 	if factory == nil {
 		var err error
@@ -393,6 +419,11 @@ func NewProxyStore(ctx context.Context, c SchemaColumnSetter, clientGetter Clien
 	return store, nil
 }
 
+// CachedGVKs returns the GVKs which are already cached, and can be listed without waiting for a new informer.
+func (s *Store) CachedGVKs() []schema.GroupVersionKind {
+	return s.cacheFactory.CachedGVKs()
+}
+
 // Reset locks the store, resets the underlying cache factory, and warm the namespace cache.
 func (s *Store) Reset(gvk schema.GroupVersionKind) error {
 	s.lock.Lock()
@@ -403,6 +434,9 @@ func (s *Store) Reset(gvk schema.GroupVersionKind) error {
 	if err := s.cacheFactory.Stop(gvk); err != nil {
 		return fmt.Errorf("reset: %w", err)
 	}
+	s.usageLock.Lock()
+	delete(s.usageInformers, gvk)
+	s.usageLock.Unlock()
 
 	if gvk == namespaceGVK {
 		if err := s.initializeNamespaceCache(); err != nil {
@@ -767,6 +801,9 @@ func (s *Store) Create(apiOp *types.APIRequest, schema *types.APISchema, params
 
 	resp, err = k8sClient.Create(apiOp, &unstructured.Unstructured{Object: input}, opts)
 	rowToObject(resp)
+	if err == nil {
+		proxy.AddDryRunWarning(&buffer, opts.DryRun)
+	}
 	return resp, buffer, err
 }
 
@@ -790,17 +827,15 @@ func (s *Store) Update(apiOp *types.APIRequest, schema *types.APISchema, params
 			return nil, nil, err
 		}
 
-		pType := apitypes.StrategicMergePatchType
-		if apiOp.Request.Header.Get("content-type") == string(apitypes.JSONPatchType) {
-			pType = apitypes.JSONPatchType
-		}
+		pType := proxy.PatchType(apiOp.Request)
 
+		// fieldManager and force are passed through for server-side apply
 		opts := metav1.PatchOptions{}
 		if err := decodeParams(apiOp, &opts); err != nil {
 			return nil, nil, err
 		}
 
-		if pType == apitypes.StrategicMergePatchType {
+		if pType == apitypes.StrategicMergePatchType || pType == apitypes.MergePatchType {
 			data := map[string]interface{}{}
 			if err := json.Unmarshal(bytes, &data); err != nil {
 				return nil, nil, err
@@ -817,6 +852,7 @@ func (s *Store) Update(apiOp *types.APIRequest, schema *types.APISchema, params
 			return nil, nil, err
 		}
 
+		proxy.AddDryRunWarning(&buffer, opts.DryRun)
 		return resp, buffer, nil
 	}
 
@@ -833,20 +869,22 @@ func (s *Store) Update(apiOp *types.APIRequest, schema *types.APISchema, params
 		return nil, nil, err
 	}
 
-	resp, err := k8sClient.Update(apiOp, &unstructured.Unstructured{Object: moveFromUnderscore(input)}, metav1.UpdateOptions{})
+	resp, err := k8sClient.Update(apiOp, &unstructured.Unstructured{Object: moveFromUnderscore(input)}, opts)
 	if err != nil {
 		return nil, nil, err
 	}
 
 	rowToObject(resp)
+	proxy.AddDryRunWarning(&buffer, opts.DryRun)
 	return resp, buffer, nil
 }
 
 // Delete deletes an object from a store.
 func (s *Store) Delete(apiOp *types.APIRequest, schema *types.APISchema, id string) (*unstructured.Unstructured, []types.Warning, error) {
+	// dryRun, propagationPolicy and gracePeriodSeconds are passed through
 	opts := metav1.DeleteOptions{}
 	if err := decodeParams(apiOp, &opts); err != nil {
-		return nil, nil, nil
+		return nil, nil, err
 	}
 
 	buffer := WarningBuffer{}
@@ -858,6 +896,7 @@ func (s *Store) Delete(apiOp *types.APIRequest, schema *types.APISchema, id stri
 	if err := k8sClient.Delete(apiOp, id, opts); err != nil {
 		return nil, nil, err
 	}
+	proxy.AddDryRunWarning(&buffer, opts.DryRun)
 
 	obj, _, err := s.byID(apiOp, schema, apiOp.Namespace, id)
 	if err != nil {
@@ -878,6 +917,14 @@ var typeGuidanceTable = map[schema.GroupVersionKind]map[string]string{
 		"status.requested.memoryRaw":   "REAL",
 		"status.requested.pods":        "INT",
 	},
+	schema.GroupVersionKind{Group: "", Version: "v1", Kind: "Node"}: {
+		"status.usage.cpuRaw":    "REAL", // set by the resource usage transform
+		"status.usage.memoryRaw": "REAL",
+	},
+	schema.GroupVersionKind{Group: "", Version: "v1", Kind: "Pod"}: {
+		"status.usage.cpuRaw":    "REAL",
+		"status.usage.memoryRaw": "REAL",
+	},
 	schema.GroupVersionKind{Group: "", Version: "v1", Kind: "Secret"}: {
 		"metadata.fields[2]": "INT", // name: Data
 	},
@@ -950,6 +997,20 @@ func (s *Store) ListByPartitions(apiOp *types.APIRequest, apiSchema *types.APISc
 		}
 		return nil, 0, "", err
 	}
+	if s.usageCache != nil && usage.Handles(gvk) && usesUsage(opts) && !usage.CanRead(apiOp, gvk, apiOp.Namespace, "") {
+		// the order and count of the objects would reveal the usage the formatter hides
+		return nil, 0, "", apierror.NewAPIError(validation.PermissionDenied, "sorting and filtering on status.usage requires access to list the "+gvk.Kind+" metrics")
+	}
+
+	// the revision of the list is the latest one of the cache, so conditional requests can be answered without
+	// running the query
+	if apiOp.Request != nil && apiOp.Request.Header.Get("If-None-Match") != "" {
+		if resourceVersion := inf.ByOptionsLister.GetLatestResourceVersion(); len(resourceVersion) > 0 {
+			if err := etag.Check(apiOp, etag.For(apiOp, resourceVersion[0])); err != nil {
+				return nil, 0, "", err
+			}
+		}
+	}
 
 	if gvk.Group == "ext.cattle.io" && (gvk.Kind == "Token" || gvk.Kind == "Kubeconfig") {
 		accessSet := accesscontrol.AccessSetFromAPIRequest(apiOp)
@@ -1092,5 +1153,58 @@ func (s *Store) cacheFor(ctx context.Context, apiOp *types.APIRequest, apiSchema
 	if err != nil {
 		return nil, fmt.Errorf("cachefor %v: %w", gvk, err)
 	}
+	if s.usageCache != nil && usage.Handles(gvk) {
+		s.usageLock.Lock()
+		if s.usageInformers == nil {
+			s.usageInformers = map[schema.GroupVersionKind]*factory.Cache{}
+		}
+		s.usageInformers[gvk] = inf
+		s.usageLock.Unlock()
+	}
 	return inf, nil
 }
+
+// usesUsage returns whether opts sort or filter on the usage of objects.
+func usesUsage(opts sqltypes.ListOptions) bool {
+	isUsage := func(fields []string) bool {
+		return len(fields) > 1 && fields[0] == "status" && fields[1] == "usage"
+	}
+	for _, orFilter := range opts.Filters {
+		for _, filter := range orFilter.Filters {
+			if isUsage(filter.Field) {
+				return true
+			}
+		}
+	}
+	for _, sort := range opts.SortList.SortDirectives {
+		if isUsage(sort.Fields) {
+			return true
+		}
+	}
+	return false
+}
+
+// refreshUsage updates the usage of the cached objects of gvk with the given keys. The transform only runs when
+// objects are added or updated by the informer, which a change of usage doesn't cause.
+func (s *Store) refreshUsage(gvk schema.GroupVersionKind, keys []string) {
+	s.usageLock.Lock()
+	inf := s.usageInformers[gvk]
+	s.usageLock.Unlock()
+	if inf == nil {
+		return
+	}
+	// the keys are written in batches, so that a refresh of a large cluster doesn't hold the database for long
+	for batch := range slices.Chunk(keys, usageBatchSize) {
+		err := inf.UpdateByKeys(batch, func(obj any) (any, bool) {
+			u, ok := obj.(*unstructured.Unstructured)
+			if !ok {
+				return obj, false
+			}
+			u, err := s.usageCache.Transform(gvk, u)
+			return u, err == nil
+		})
+		if err != nil {
+			logrus.Debugf("failed to refresh the usage of %d %v: %v", len(batch), gvk, err)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"strings"
 	"testing"
@@ -15,7 +16,9 @@ import (
 	"github.com/rancher/steve/pkg/accesscontrol"
 	"github.com/rancher/steve/pkg/attributes"
 	"github.com/rancher/steve/pkg/client"
+	"github.com/rancher/steve/pkg/etag"
 	"github.com/rancher/steve/pkg/resources/common"
+	"github.com/rancher/steve/pkg/resources/virtual/usage"
 	"github.com/rancher/steve/pkg/schema/table"
 	"github.com/rancher/steve/pkg/sqlcache/informer"
 	"github.com/rancher/steve/pkg/sqlcache/informer/factory"
@@ -98,7 +101,7 @@ func TestNewProxyStore(t *testing.T) {
 				noTypeGuidance,
 				false,
 				true).Return(c, nil)
-			s, err := NewProxyStore(context.Background(), scc, cg, rn, nil, cf, true)
+			s, err := NewProxyStore(context.Background(), scc, cg, rn, nil, nil, cf, true)
 			assert.Nil(t, err)
 			assert.Equal(t, scc, s.columnSetter)
 			assert.Equal(t, cg, s.clientGetter)
@@ -115,7 +118,7 @@ func TestNewProxyStore(t *testing.T) {
 			cg := NewMockClientGetter(gomock.NewController(t))
 			rn := NewMockRelationshipNotifier(gomock.NewController(t))
 			cf := NewMockCacheFactory(gomock.NewController(t))
-			s, err := NewProxyStore(context.Background(), scc, cg, rn, nil, cf, false)
+			s, err := NewProxyStore(context.Background(), scc, cg, rn, nil, nil, cf, false)
 			assert.Nil(t, err)
 			assert.Equal(t, scc, s.columnSetter)
 			assert.Equal(t, cg, s.clientGetter)
@@ -136,7 +139,7 @@ func TestNewProxyStore(t *testing.T) {
 			nsSchema := baseNSSchema
 			scc.EXPECT().SetColumns(context.Background(), &nsSchema).Return(fmt.Errorf("error"))
 
-			s, err := NewProxyStore(context.Background(), scc, cg, rn, nil, cf, true)
+			s, err := NewProxyStore(context.Background(), scc, cg, rn, nil, nil, cf, true)
 			assert.Nil(t, err)
 			assert.Equal(t, scc, s.columnSetter)
 			assert.Equal(t, cg, s.clientGetter)
@@ -158,7 +161,7 @@ func TestNewProxyStore(t *testing.T) {
 			scc.EXPECT().SetColumns(context.Background(), &nsSchema).Return(nil)
 			cg.EXPECT().TableAdminClient(nil, &nsSchema, "", &WarningBuffer{}).Return(nil, fmt.Errorf("error"))
 
-			s, err := NewProxyStore(context.Background(), scc, cg, rn, nil, cf, true)
+			s, err := NewProxyStore(context.Background(), scc, cg, rn, nil, nil, cf, true)
 			assert.Nil(t, err)
 			assert.Equal(t, scc, s.columnSetter)
 			assert.Equal(t, cg, s.clientGetter)
@@ -191,7 +194,7 @@ func TestNewProxyStore(t *testing.T) {
 				false,
 				true).Return(nil, fmt.Errorf("error"))
 
-			s, err := NewProxyStore(context.Background(), scc, cg, rn, nil, cf, true)
+			s, err := NewProxyStore(context.Background(), scc, cg, rn, nil, nil, cf, true)
 			assert.Nil(t, err)
 			assert.Equal(t, scc, s.columnSetter)
 			assert.Equal(t, cg, s.clientGetter)
@@ -312,6 +315,80 @@ func TestListByPartitions(t *testing.T) {
 			assert.Equal(t, "", contToken)
 		},
 	})
+	tests = append(tests, testCase{
+		description: "client ListByPartitions() with an If-None-Match header matching the latest revision should return" +
+			" NotModified without listing.",
+		test: func(t *testing.T) {
+			cg := NewMockClientGetter(gomock.NewController(t))
+			cf := NewMockCacheFactory(gomock.NewController(t))
+			ri := NewMockResourceInterface(gomock.NewController(t))
+			bloi := NewMockByOptionsLister(gomock.NewController(t))
+			tb := NewMockTransformBuilder(gomock.NewController(t))
+			inf := &informer.Informer{
+				ByOptionsLister: bloi,
+			}
+			c := &factory.Cache{
+				ByOptionsLister: inf,
+			}
+			s := &Store{
+				ctx:              context.Background(),
+				namespaceCache:   &factory.Cache{ByOptionsLister: bloi},
+				clientGetter:     cg,
+				cacheFactory:     cf,
+				transformBuilder: tb,
+			}
+			var partitions []partition.Partition
+			req := &types.APIRequest{
+				Method: http.MethodGet,
+				Request: &http.Request{
+					URL:    &url.URL{Path: "/v1/some.gvks"},
+					Header: http.Header{},
+				},
+				Response: httptest.NewRecorder(),
+			}
+			schema := &types.APISchema{
+				Schema: &schemas.Schema{Attributes: map[string]interface{}{
+					"columns": []common.ColumnDefinition{
+						{
+							Field: "some.field",
+						},
+					},
+					"verbs": []string{"list", "watch"},
+				}},
+			}
+			gvk := schema2.GroupVersionKind{
+				Group:   "some",
+				Version: "test",
+				Kind:    "gvk",
+			}
+			typeSpecificIndexedFields["some_test_gvk"] = [][]string{{"gvk", "specific", "fields"}}
+
+			setupContext(req)
+			attributes.SetGVK(schema, gvk)
+			tag := etag.For(req, "42")
+			req.Request.Header.Set("If-None-Match", tag)
+			cg.EXPECT().TableAdminClient(req, schema, "", &WarningBuffer{}).Return(ri, nil)
+			// This tests that fields are being extracted from schema columns and the type specific fields map
+			cf.EXPECT().CacheFor(gomock.Cond(isDerivedContext),
+				[][]string{{"some", "field"}, {`id`}, {`metadata`, `state`, `name`}, {"gvk", "specific", "fields"}},
+				gomock.Any(),
+				gomock.Any(),
+				gomock.Any(),
+				&tablelistconvert.Client{ResourceInterface: ri},
+				attributes.GVK(schema),
+				gomock.Any(),
+				attributes.Namespaced(schema),
+				true).Return(c, nil)
+			cf.EXPECT().DoneWithCache(c)
+			tb.EXPECT().GetTransformFunc(attributes.GVK(schema), []common.ColumnDefinition{{Field: "some.field"}}, false).Return(func(obj interface{}) (interface{}, error) { return obj, nil })
+			bloi.EXPECT().GetLatestResourceVersion().Return([]string{"42"})
+			_, _, _, err := s.ListByPartitions(req, schema, partitions)
+			var apiError *apierror.APIError
+			assert.True(t, errors.As(err, &apiError))
+			assert.Equal(t, etag.NotModified, apiError.Code)
+			assert.Equal(t, tag, req.Response.Header().Get("ETag"))
+		},
+	})
 	tests = append(tests, testCase{
 		description: "client ListByPartitions() with no errors returned should return no errors. Should pass fields" +
 			" from schema.",
@@ -1750,3 +1827,57 @@ func TestUpdate(t *testing.T) {
 		})
 	}
 }
+
+func TestRefreshUsage(t *testing.T) {
+	podMetricsGVR := schema.GroupVersionResource{Group: "metrics.k8s.io", Version: "v1beta1", Resource: "pods"}
+	client := fake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		podMetricsGVR: "PodMetricsList",
+		{Group: "metrics.k8s.io", Version: "v1beta1", Resource: "nodes"}: "NodeMetricsList",
+	})
+	_, err := client.Resource(podMetricsGVR).Namespace("default").Create(context.Background(), &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "metrics.k8s.io/v1beta1",
+		"kind":       "PodMetrics",
+		"metadata":   map[string]interface{}{"name": "web", "namespace": "default"},
+		"containers": []interface{}{map[string]interface{}{"name": "app", "usage": map[string]interface{}{"cpu": "1", "memory": "1Ki"}}},
+	}}, metav1.CreateOptions{})
+	assert.NoError(t, err)
+
+	usageCache := usage.NewCache(client, 0)
+	bloi := NewMockByOptionsLister(gomock.NewController(t))
+	s := &Store{
+		usageCache:     usageCache,
+		usageInformers: map[schema.GroupVersionKind]*factory.Cache{usage.PodGVK: {ByOptionsLister: bloi}},
+	}
+	usageCache.OnChange(s.refreshUsage)
+
+	pod := &unstructured.Unstructured{Object: map[string]interface{}{
+		"metadata": map[string]interface{}{"name": "web", "namespace": "default"},
+	}}
+	var updated any
+	bloi.EXPECT().UpdateByKeys([]string{"default/web"}, gomock.Any()).DoAndReturn(func(keys []string, update func(obj any) (any, bool)) error {
+		var ok bool
+		updated, ok = update(pod)
+		assert.True(t, ok)
+		return nil
+	})
+
+	usageCache.Refresh(context.Background())
+
+	cpu, _, _ := unstructured.NestedFloat64(updated.(*unstructured.Unstructured).Object, "status", "usage", "cpuRaw")
+	memory, _, _ := unstructured.NestedFloat64(updated.(*unstructured.Unstructured).Object, "status", "usage", "memoryRaw")
+	assert.Equal(t, 1.0, cpu)
+	assert.Equal(t, 1024.0, memory)
+}
+
+func TestUsesUsage(t *testing.T) {
+	assert.False(t, usesUsage(sqltypes.ListOptions{
+		Filters:  []sqltypes.OrFilter{{Filters: []sqltypes.Filter{{Field: []string{"status", "phase"}}}}},
+		SortList: sqltypes.SortList{SortDirectives: []sqltypes.Sort{{Fields: []string{"metadata", "name"}}}},
+	}))
+	assert.True(t, usesUsage(sqltypes.ListOptions{
+		Filters: []sqltypes.OrFilter{{Filters: []sqltypes.Filter{{Field: []string{"status", "usage", "memoryRaw"}}}}},
+	}))
+	assert.True(t, usesUsage(sqltypes.ListOptions{
+		SortList: sqltypes.SortList{SortDirectives: []sqltypes.Sort{{Fields: []string{"status", "usage", "cpuRaw"}}}},
+	}))
+}
@@ -2,8 +2,10 @@ package summarycache
 
 import (
 	"context"
+	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 
 	"github.com/rancher/apiserver/pkg/types"
 	"github.com/rancher/steve/pkg/attributes"
@@ -15,6 +17,7 @@ import (
 	"github.com/rancher/wrangler/v3/pkg/summary"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	runtimeschema "k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/tools/cache"
@@ -49,6 +52,8 @@ type SummaryCache struct {
 	schemas      *schema.Collection
 	clusterCache clustercache.ClusterCache
 	cbs          map[int]chan *summary.Relationship
+	// generations holds an *atomic.Uint64 by runtimeschema.GroupKind, see Generation
+	generations sync.Map
 }
 
 func New(schemas *schema.Collection, clusterCache clustercache.ClusterCache) *SummaryCache {
@@ -63,6 +68,32 @@ func New(schemas *schema.Collection, clusterCache clustercache.ClusterCache) *Su
 	return s
 }
 
+// Generation returns a number which changes whenever the relationships of the objects of gk, or the states of the
+// objects they are related to, may have changed. Neither changes the resourceVersion of the objects of gk.
+func (s *SummaryCache) Generation(gk runtimeschema.GroupKind) uint64 {
+	if generation, ok := s.generations.Load(gk); ok {
+		return generation.(*atomic.Uint64).Load()
+	}
+	return 0
+}
+
+// changed increases the generation of the kinds related to summarized, whose relationships show it.
+func (s *SummaryCache) changed(summarized *summary.SummarizedObject, rels []*summary.Relationship) {
+	bump := func(apiVersion, kind string) {
+		gk := runtimeschema.FromAPIVersionAndKind(apiVersion, kind).GroupKind()
+		generation, _ := s.generations.LoadOrStore(gk, &atomic.Uint64{})
+		generation.(*atomic.Uint64).Add(1)
+	}
+	for _, rel := range rels {
+		bump(rel.APIVersion, rel.Kind)
+	}
+	relObjs, _ := s.cache.ByIndex(relationshipIndex, toKey(summarized))
+	for _, relObj := range relObjs {
+		related := relObj.(*summary.SummarizedObject)
+		bump(related.APIVersion, related.Kind)
+	}
+}
+
 func (s *SummaryCache) Start(ctx context.Context) {
 	s.clusterCache.OnAdd(ctx, s.OnAdd)
 	s.clusterCache.OnRemove(ctx, s.OnRemove)
@@ -143,6 +174,34 @@ func (s *SummaryCache) SummaryAndRelationship(obj runtime.Object) (*summary.Summ
 	return summarized, rels
 }
 
+// Get returns the object of gvk with the given namespace and name from the cluster cache.
+func (s *SummaryCache) Get(gvk runtimeschema.GroupVersionKind, namespace, name string) (runtime.Object, bool) {
+	obj, ok, err := s.clusterCache.Get(gvk, namespace, name)
+	if err != nil || !ok {
+		return nil, false
+	}
+	ro, ok := obj.(runtime.Object)
+	return ro, ok
+}
+
+// Select returns the objects of gvk in namespace, or in every namespace if namespace is empty, whose labels match
+// selector.
+func (s *SummaryCache) Select(gvk runtimeschema.GroupVersionKind, namespace string, selector labels.Selector) []runtime.Object {
+	var result []runtime.Object
+	for _, obj := range s.clusterCache.List(gvk) {
+		ro, ok := obj.(runtime.Object)
+		if !ok {
+			continue
+		}
+		m, err := meta.Accessor(ro)
+		if err != nil || (namespace != "" && m.GetNamespace() != namespace) || !selector.Matches(labels.Set(m.GetLabels())) {
+			continue
+		}
+		result = append(result, ro)
+	}
+	return result
+}
+
 func (s *SummaryCache) reverseRel(summarized *summary.SummarizedObject, rel summary.Relationship) Relationship {
 	return s.toRel(summarized.Namespace, &summary.Relationship{
 		Name:       summarized.Name,
@@ -226,6 +285,7 @@ func (s *SummaryCache) Add(obj runtime.Object) {
 	key := toKey(summary)
 
 	s.cache.Add(key, summary)
+	s.changed(summary, rels)
 	for _, rel := range rels {
 		s.notify(rel)
 	}
@@ -246,16 +306,21 @@ func (s *SummaryCache) Remove(obj runtime.Object) {
 	key := toKey(summary)
 
 	s.cache.Delete(key)
+	s.changed(summary, rels)
 	for _, rel := range rels {
 		s.notify(rel)
 	}
 }
 
 func (s *SummaryCache) Change(newObj, oldObj runtime.Object) {
-	_, oldRels := s.process(oldObj)
+	oldSummary, oldRels := s.process(oldObj)
 	summary, rels := s.process(newObj)
 	key := toKey(summary)
 
+	if !stateEquals(oldSummary, summary) || !relsEqual(oldRels, rels) {
+		s.changed(summary, slices.Concat(oldRels, rels))
+	}
+
 	if len(rels) == len(oldRels) {
 		for i, rel := range rels {
 			if !relEquals(oldRels[i], rel) {
@@ -381,6 +446,17 @@ func toKey(obj runtime.Object) string {
 	return toKeyFrom(namespace, name, gvk)
 }
 
+func stateEquals(left, right *summary.SummarizedObject) bool {
+	return left.State == right.State &&
+		left.Error == right.Error &&
+		left.Transitioning == right.Transitioning &&
+		slices.Equal(left.Message, right.Message)
+}
+
+func relsEqual(left, right []*summary.Relationship) bool {
+	return slices.EqualFunc(left, right, relEquals)
+}
+
 func relEquals(left, right *summary.Relationship) bool {
 	if left == nil && right == nil {
 		return true